@@ -0,0 +1,53 @@
+package gocache
+
+import "fmt"
+
+// Append atomically appends data to the string or byte slice stored under
+// key and returns the length of the result. A string stays a string and a
+// byte slice stays a byte slice; any other value makes Append return
+// ErrWrongType. A missing or expired key is set to a copy of data, as a
+// byte slice, with the default expiration. An existing key keeps its
+// expiration and priority. The stored value is replaced rather than
+// modified, so values returned by earlier Gets do not change.
+func (c *Cache) Append(key string, data []byte) (int, error) {
+	return c.appendValue(key, data, func() interface{} { return append([]byte(nil), data...) })
+}
+
+// AppendString is Append for string data, which sets a missing or expired
+// key to data as a string.
+func (c *Cache) AppendString(key string, data string) (int, error) {
+	return c.appendValue(key, []byte(data), func() interface{} { return data })
+}
+
+// appendValue implements Append, setting a missing key to initial().
+func (c *Cache) appendValue(key string, data []byte, initial func() interface{}) (int, error) {
+	if err := c.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	var n int
+	_, err := c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		if !found {
+			n = len(data)
+			return initial(), true, nil
+		}
+
+		switch v := value.(type) {
+		case string:
+			s := v + string(data)
+			n = len(s)
+			return s, true, nil
+		case []byte:
+			b := make([]byte, len(v)+len(data))
+			copy(b, v)
+			copy(b[len(v):], data)
+			n = len(b)
+			return b, true, nil
+		}
+		return nil, false, fmt.Errorf("%w: %q holds %T, not a string or []byte", ErrWrongType, key, value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
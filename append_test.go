@@ -0,0 +1,72 @@
+package gocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheAppend(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if n, err := cache.Append("bytes", []byte("ab")); err != nil || n != 2 {
+		t.Errorf("Expected a missing key to be created, got %d (%v)", n, err)
+	}
+	before, _ := cache.Get("bytes")
+	if n, err := cache.Append("bytes", []byte("cd")); err != nil || n != 4 {
+		t.Errorf("Expected length 4, got %d (%v)", n, err)
+	}
+	if value, _ := cache.Get("bytes"); string(value.([]byte)) != "abcd" {
+		t.Errorf("Expected abcd, got %q", value)
+	}
+	if string(before.([]byte)) != "ab" {
+		t.Errorf("Expected an earlier Get to be unchanged, got %q", before)
+	}
+
+	cache.SetWithExpiration("log", "x", time.Minute)
+	if n, err := cache.AppendString("log", "yz"); err != nil || n != 3 {
+		t.Errorf("Expected length 3, got %d (%v)", n, err)
+	}
+	if value, _ := cache.Get("log"); value != "xyz" {
+		t.Errorf("Expected a string to stay a string, got %#v", value)
+	}
+	if n, err := cache.AppendString("new", "s"); err != nil || n != 1 {
+		t.Errorf("Expected a missing key to be created, got %d (%v)", n, err)
+	}
+	if value, _ := cache.Get("new"); value != "s" {
+		t.Errorf("Expected AppendString to create a string, got %#v", value)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("log"); err != ErrKeyExpired {
+		t.Errorf("Expected Append to keep the expiration, got %v", err)
+	}
+
+	cache.Set("int", 1)
+	if _, err := cache.Append("int", []byte("x")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType for an int, got %v", err)
+	}
+}
+
+func TestCacheAppendConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				cache.AppendString("log", ".")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("log"); len(value.(string)) != 800 {
+		t.Errorf("Expected 800 appends, got %d", len(value.(string)))
+	}
+}
@@ -0,0 +1,135 @@
+package gocache
+
+// arenaGenerationSize is the number of entries allocated at once by
+// arenaStore.
+const arenaGenerationSize = 4096
+
+// arenaStore allocates items in large slabs, one per generation, instead of
+// keeping them in the map buckets. The map only holds a small location per
+// key, and a slab is released as a whole once none of its entries are live,
+// or when the cache is flushed. Overwrites reuse their slot, but slots of
+// deleted entries are not reused; once more than half of all slots are
+// dead, the live entries are moved into fresh generations so old slabs can
+// be released.
+type arenaStore struct {
+	generations []*arenaGeneration // nil once released
+	index       map[string]uint64  // key to generation<<32 | slot
+	count       int
+	dead        int // slots of deleted entries in slabs not yet released
+}
+
+// arenaGeneration is a slab of entries allocated together.
+type arenaGeneration struct {
+	entries []Entry
+	live    int
+}
+
+func newArenaStore() *arenaStore {
+	return &arenaStore{index: make(map[string]uint64)}
+}
+
+// entry returns the slab entry at loc.
+func (s *arenaStore) entry(loc uint64) *Entry {
+	return &s.generations[loc>>32].entries[uint32(loc)]
+}
+
+// alloc stores an entry in the current generation, starting a new one when
+// it is full, and returns its location.
+func (s *arenaStore) alloc(key string, item Item) uint64 {
+	last := len(s.generations) - 1
+	if last < 0 || s.generations[last] == nil || len(s.generations[last].entries) == arenaGenerationSize {
+		s.generations = append(s.generations, &arenaGeneration{
+			entries: make([]Entry, 0, arenaGenerationSize),
+		})
+		last++
+	}
+
+	gen := s.generations[last]
+	gen.entries = append(gen.entries, Entry{Key: key, Item: item})
+	gen.live++
+	return uint64(last)<<32 | uint64(len(gen.entries)-1)
+}
+
+// release marks the entry at loc as dead and frees its generation once it
+// holds no live entries.
+func (s *arenaStore) release(loc uint64) {
+	g := loc >> 32
+	gen := s.generations[g]
+	gen.entries[uint32(loc)] = Entry{} // drop references to key and value
+	gen.live--
+	s.dead++
+
+	full := len(gen.entries) == arenaGenerationSize
+	if gen.live == 0 && full {
+		s.generations[g] = nil
+		s.dead -= len(gen.entries)
+	}
+}
+
+// compact moves the live entries into fresh generations.
+func (s *arenaStore) compact() {
+	old := s.generations
+	s.generations = nil
+	s.dead = 0
+	for key, loc := range s.index {
+		s.index[key] = s.alloc(key, old[loc>>32].entries[uint32(loc)].Item)
+	}
+}
+
+func (s *arenaStore) get(key string) (Item, bool) {
+	loc, found := s.index[key]
+	if !found {
+		return Item{}, false
+	}
+	return s.entry(loc).Item, true
+}
+
+func (s *arenaStore) lookup(key string) (Item, bool) {
+	return s.get(key)
+}
+
+func (s *arenaStore) set(key string, item Item) {
+	if loc, found := s.index[key]; found {
+		// Overwrite in place; the slot stays live.
+		s.entry(loc).Item = item
+		return
+	}
+
+	if s.dead > arenaGenerationSize && s.dead > s.count {
+		s.compact()
+	}
+	s.index[key] = s.alloc(key, item)
+	s.count++
+}
+
+func (s *arenaStore) delete(key string) {
+	loc, found := s.index[key]
+	if !found {
+		return
+	}
+	delete(s.index, key)
+	s.release(loc)
+	s.count--
+}
+
+func (s *arenaStore) len() int {
+	return s.count
+}
+
+func (s *arenaStore) rangeItems(fn func(key string, item Item) bool) {
+	for key, loc := range s.index {
+		if !fn(key, s.entry(loc).Item) {
+			return
+		}
+	}
+}
+
+func (s *arenaStore) clear() {
+	*s = *newArenaStore()
+}
+
+func (s *arenaStore) commit() {}
+
+func (s *arenaStore) lockFree() bool {
+	return false
+}
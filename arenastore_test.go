@@ -0,0 +1,68 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestArenaStorageGenerations(t *testing.T) {
+	cache := New(Options{Storage: ArenaStorage})
+	defer cache.Stop()
+
+	for i := 0; i < 3*arenaGenerationSize; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	s := cache.items.(*arenaStore)
+	if len(s.generations) != 3 {
+		t.Fatalf("Expected 3 generations, got %d", len(s.generations))
+	}
+
+	// Deleting every entry of the first generation releases its slab.
+	for i := 0; i < arenaGenerationSize; i++ {
+		cache.Delete(fmt.Sprintf("key%d", i))
+	}
+	if s.generations[0] != nil {
+		t.Error("Expected first generation to be released")
+	}
+
+	// Overwrites reuse their slot.
+	cache.Set("key5000", "updated")
+	if value, _ := cache.Get("key5000"); value != "updated" {
+		t.Errorf("Expected updated value, got %v", value)
+	}
+	if s.generations[2].live != arenaGenerationSize {
+		t.Errorf("Expected overwrite in place, got %d live entries", s.generations[2].live)
+	}
+
+	cache.Flush()
+	if len(s.generations) != 0 || cache.ItemCount() != 0 {
+		t.Error("Expected Flush to release all generations")
+	}
+}
+
+func TestArenaStorageCompaction(t *testing.T) {
+	cache := New(Options{Storage: ArenaStorage})
+	defer cache.Stop()
+
+	// Keep every fourth entry alive so no generation empties on its own.
+	n := 4 * arenaGenerationSize
+	for i := 0; i < n; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	for i := 0; i < n; i++ {
+		if i%4 != 0 {
+			cache.Delete(fmt.Sprintf("key%d", i))
+		}
+	}
+	cache.Set("trigger", true)
+
+	s := cache.items.(*arenaStore)
+	if len(s.generations) != 2 || s.dead != 0 {
+		t.Errorf("Expected live entries compacted into 2 generations, got %d (%d dead)", len(s.generations), s.dead)
+	}
+	for i := 0; i < n; i += 4 {
+		if value, err := cache.Get(fmt.Sprintf("key%d", i)); err != nil || value != i {
+			t.Fatalf("Expected key%d to survive compaction, got %v (%v)", i, value, err)
+		}
+	}
+}
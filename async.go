@@ -0,0 +1,463 @@
+package gocache
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAsyncBufferSize is the number of keys with writes queued by
+// SetAsync when AsyncOptions.BufferSize is 0.
+const DefaultAsyncBufferSize = 10000
+
+// asyncBatchSize is the largest number of queued writes applied at once.
+const asyncBatchSize = 256
+
+// AsyncBackpressure is what SetAsync does when its buffer is full.
+type AsyncBackpressure int
+
+const (
+	// AsyncBlock waits until the background goroutine makes room.
+	AsyncBlock AsyncBackpressure = iota
+	// AsyncDrop drops the write and returns ErrAsyncBufferFull.
+	AsyncDrop
+	// AsyncSetSync applies the write synchronously, like
+	// SetWithExpiration.
+	AsyncSetSync
+)
+
+// AsyncOptions configures SetAsync. Zero values select the defaults.
+type AsyncOptions struct {
+	// BufferSize bounds the number of keys with queued writes. Several
+	// writes of a key are coalesced into the latest.
+	BufferSize int
+
+	// Backpressure is what SetAsync does when the buffer is full. The
+	// default is AsyncBlock.
+	Backpressure AsyncBackpressure
+
+	// OnError is called with the key and error of a queued write that
+	// could not be applied, such as one exceeding Options.MaxValueSize,
+	// or that the backing store refused.
+	OnError func(key string, err error)
+}
+
+// asyncWrite is a write queued by SetAsync.
+type asyncWrite struct {
+	value      interface{}
+	expiration int64 // Unix timestamp in nanoseconds, or 0
+	seq        uint64
+}
+
+// asyncWrites queues the writes of SetAsync and applies them in the
+// background. A write leaves the queue when it is applied, or when a later
+// write or deletion of its key made another way supersedes it.
+type asyncWrites struct {
+	options AsyncOptions
+	queued  int64 // len(pending) + len(inflight), read without mu by Get
+
+	mu       sync.Mutex
+	room     *sync.Cond // signaled when the queue shrinks or stops
+	pending  map[string]asyncWrite
+	order    []string              // keys of pending, oldest first; may hold superseded keys
+	inflight map[string]asyncWrite // the batch being applied
+	seq      uint64
+	idle     chan struct{} // closed when the queue is empty, if waited for
+	started  bool          // whether the goroutine applying the writes runs
+	stopped  bool
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+func newAsyncWrites(options AsyncOptions) *asyncWrites {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultAsyncBufferSize
+	}
+	a := &asyncWrites{
+		options:  options,
+		pending:  make(map[string]asyncWrite),
+		inflight: make(map[string]asyncWrite),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	a.room = sync.NewCond(&a.mu)
+	return a
+}
+
+// SetAsync queues value to be stored under key for the default expiration
+// and returns without waiting for the cache lock, for hot paths where even
+// that shows up in latency. A background goroutine, started by the first
+// call, applies the queued writes in order.
+//
+// Get and its variants, such as GetContext, GetBytes and GetVersioned, see
+// a queued write at once, so a goroutine reads its own writes. Operations
+// that read and change the item, such as Increment, CompareAndSwap, Update
+// or ExpireAt, apply the queued write of their key first, so they build on
+// it too. Set and its variants, Delete, DeleteWhere and the Flush of the
+// cache or of a namespace supersede the writes of the keys they touch that
+// are still queued.
+//
+// Errors that can only be detected when the write is applied, such as
+// ErrValueTooLarge, are reported to AsyncOptions.OnError; the key and value
+// are validated up front. When the buffer is full, SetAsync applies
+// Options.Async.Backpressure. Use FlushAsync to wait until the queued
+// writes are applied. After Stop or Close, writes are applied
+// synchronously.
+func (c *Cache) SetAsync(key string, value interface{}) error {
+	return c.SetAsyncWithExpiration(key, value, c.defaultExpiration())
+}
+
+// SetAsyncWithExpiration is like SetAsync, but stores the item for
+// duration instead of the default expiration. If duration is 0, the item
+// never expires. The item expires duration after the call, not after the
+// write is applied.
+func (c *Cache) SetAsyncWithExpiration(key string, value interface{}, duration time.Duration) error {
+	if value == nil && !c.allowNil {
+		return ErrNilValue
+	}
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	var expiration int64
+	if duration > 0 {
+		expiration = c.clock.Now().Add(duration).UnixNano()
+	}
+
+	queued, err := c.async.enqueue(key, value, expiration, c.applyAsync)
+	if err != nil || queued {
+		return err
+	}
+	if _, err := c.setIf(key, value, expiration, 0, 0, nil); err != nil {
+		return err
+	}
+	return c.storeThrough(context.Background(), key, value, 0)
+}
+
+// enqueue queues a write, applying the backpressure policy while the queue
+// is full, and starts apply in a goroutine the first time. It reports false
+// if the caller must apply the write itself.
+func (a *asyncWrites) enqueue(key string, value interface{}, expiration int64, apply func()) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for {
+		if a.stopped {
+			return false, nil
+		}
+		if _, queued := a.pending[key]; queued || len(a.pending) < a.options.BufferSize {
+			break
+		}
+		switch a.options.Backpressure {
+		case AsyncDrop:
+			return false, ErrAsyncBufferFull
+		case AsyncSetSync:
+			return false, nil
+		}
+		a.room.Wait()
+	}
+	if !a.started {
+		a.started = true
+		go apply()
+	}
+	a.seq++
+	if _, queued := a.pending[key]; !queued {
+		a.order = append(a.order, key)
+		atomic.AddInt64(&a.queued, 1)
+	}
+	a.pending[key] = asyncWrite{value: value, expiration: expiration, seq: a.seq}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// expiredAt reports whether the write's item has expired at now, a Unix
+// timestamp in nanoseconds.
+func (w asyncWrite) expiredAt(now int64) bool {
+	return w.expiration > 0 && now > w.expiration
+}
+
+// lookup returns the latest queued write of key.
+func (a *asyncWrites) lookup(key string) (asyncWrite, bool) {
+	if atomic.LoadInt64(&a.queued) == 0 {
+		return asyncWrite{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if w, ok := a.pending[key]; ok {
+		return w, true
+	}
+	w, ok := a.inflight[key]
+	return w, ok
+}
+
+// supersede drops the queued writes of key with a sequence number up to
+// seq, and reports whether there were any. It is called with c.mu held by
+// the writes that take precedence over them, and to drop a write that
+// failed.
+func (a *asyncWrites) supersede(key string, seq uint64) bool {
+	if atomic.LoadInt64(&a.queued) == 0 {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var dropped bool
+	if w, ok := a.pending[key]; ok && w.seq <= seq {
+		delete(a.pending, key)
+		dropped = true
+	}
+	if w, ok := a.inflight[key]; ok && w.seq <= seq {
+		delete(a.inflight, key)
+		dropped = true
+	}
+	if dropped {
+		a.changed()
+	}
+	return dropped
+}
+
+// supersedeAll drops all queued writes. It is called with c.mu held.
+func (a *asyncWrites) supersedeAll() {
+	if atomic.LoadInt64(&a.queued) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending = make(map[string]asyncWrite)
+	a.inflight = make(map[string]asyncWrite)
+	a.order = nil
+	a.changed()
+}
+
+// supersedePrefix drops the queued writes of the keys starting with
+// prefix. It is called with c.mu held.
+func (a *asyncWrites) supersedePrefix(prefix string) {
+	if atomic.LoadInt64(&a.queued) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key := range a.pending {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.pending, key)
+		}
+	}
+	for key := range a.inflight {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.inflight, key)
+		}
+	}
+	a.changed()
+}
+
+// current reports whether w is still the write of key being applied. It
+// is called with c.mu held.
+func (a *asyncWrites) current(key string, w asyncWrite) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	inflight, ok := a.inflight[key]
+	return ok && inflight.seq == w.seq
+}
+
+// latest reports whether w is the latest queued write of key. It is called
+// with c.mu held.
+func (a *asyncWrites) latest(key string, w asyncWrite) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if pending, ok := a.pending[key]; ok {
+		return pending.seq == w.seq
+	}
+	inflight, ok := a.inflight[key]
+	return ok && inflight.seq == w.seq
+}
+
+// applied removes w from the writes being applied.
+func (a *asyncWrites) applied(key string, w asyncWrite) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if inflight, ok := a.inflight[key]; ok && inflight.seq == w.seq {
+		delete(a.inflight, key)
+		a.changed()
+	}
+}
+
+// changed updates the count of queued writes after a removal, wakes the
+// writers waiting for room, and the waiters of FlushAsync if the queue is
+// empty. It must be called with a.mu held.
+func (a *asyncWrites) changed() {
+	n := len(a.pending) + len(a.inflight)
+	atomic.StoreInt64(&a.queued, int64(n))
+	a.room.Broadcast()
+	if n == 0 && a.idle != nil {
+		close(a.idle)
+		a.idle = nil
+	}
+}
+
+// take moves the oldest queued writes to inflight and returns their keys.
+func (a *asyncWrites) take() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var keys []string
+	for len(a.order) > 0 && len(keys) < asyncBatchSize {
+		key := a.order[0]
+		a.order = a.order[1:]
+		if w, ok := a.pending[key]; ok {
+			delete(a.pending, key)
+			a.inflight[key] = w
+			keys = append(keys, key)
+		}
+	}
+	a.room.Broadcast()
+	return keys
+}
+
+// applyAsync applies queued writes until the queue is stopped and empty.
+func (c *Cache) applyAsync() {
+	a := c.async
+	defer close(a.done)
+
+	for range a.wake {
+		for {
+			keys := a.take()
+			if len(keys) == 0 {
+				break
+			}
+			for _, key := range keys {
+				c.applyAsyncWrite(key)
+			}
+		}
+		a.mu.Lock()
+		stopped := a.stopped && len(a.order) == 0
+		a.mu.Unlock()
+		if stopped {
+			return
+		}
+	}
+}
+
+// applyAsyncWrite applies the write of key being applied, unless it has
+// been superseded.
+func (c *Cache) applyAsyncWrite(key string) {
+	a := c.async
+	a.mu.Lock()
+	w, ok := a.inflight[key]
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	c.applyWrite(key, w, func(Item, bool) bool { return a.current(key, w) })
+	a.applied(key, w)
+}
+
+// applyQueued applies the write of key queued by SetAsync, if any, so that
+// an operation reading and changing the item sees it, as Get does. A write
+// that fails is reported and dropped.
+func (c *Cache) applyQueued(key string) {
+	a := c.async
+	for {
+		w, ok := a.lookup(key)
+		if !ok {
+			return
+		}
+		stored, err := c.applyWrite(key, w, func(Item, bool) bool { return a.latest(key, w) })
+		if err != nil {
+			a.supersede(key, w.seq)
+		}
+		if stored || err != nil {
+			return
+		}
+		// A later write of key was queued in the meantime; apply that.
+	}
+}
+
+// applyWrite stores the queued write w of key if cond holds, writes it
+// through to the backing store, and reports errors to
+// AsyncOptions.OnError. It reports whether w was stored.
+func (c *Cache) applyWrite(key string, w asyncWrite, cond func(Item, bool) bool) (bool, error) {
+	stored, err := c.setItem(key, w.value, w.expiration, 0, 0, cond, w.seq)
+	if stored {
+		err = c.storeThrough(context.Background(), key, w.value, 0)
+	}
+	if err != nil {
+		c.log(slog.LevelWarn, "async write failed", "key", key, "error", err)
+		if c.async.options.OnError != nil {
+			c.async.options.OnError(key, err)
+		}
+	}
+	return stored, err
+}
+
+// queuedItem returns the item of a queued write for a Get of key, which is
+// counted as a hit.
+func (c *Cache) queuedItem(key string, w asyncWrite) (Item, error) {
+	atomic.AddUint64(&c.counters.hits, 1)
+	value, err := c.copyValue(w.value)
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: value, Expiration: w.expiration}, nil
+}
+
+// FlushAsync waits until the writes queued by SetAsync are applied or
+// superseded, or until ctx is done, in which case it returns ctx.Err().
+func (c *Cache) FlushAsync(ctx context.Context) error {
+	a := c.async
+	a.mu.Lock()
+	if len(a.pending)+len(a.inflight) == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.idle == nil {
+		a.idle = make(chan struct{})
+	}
+	idle := a.idle
+	a.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopAsync applies the queued writes and stops the background goroutine,
+// waiting until it is done or ctx is. Later writes of SetAsync are applied
+// synchronously.
+func (c *Cache) stopAsync(ctx context.Context) error {
+	a := c.async
+	a.mu.Lock()
+	a.stopped = true
+	a.room.Broadcast()
+	started := a.started
+	a.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,335 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetAsyncReadsOwnWrites(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	// Hold the lock so that the write cannot be applied yet.
+	c.mu.Lock()
+	if err := c.SetAsync("key", "value"); err != nil {
+		c.mu.Unlock()
+		t.Fatal(err)
+	}
+	value, err := c.Get("key")
+	c.mu.Unlock()
+	if err != nil || value != "value" {
+		t.Fatalf("Expected the queued value, got %v, %v", value, err)
+	}
+
+	if err := c.FlushAsync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.ItemCount(); n != 1 {
+		t.Fatalf("Expected 1 item after FlushAsync, got %d", n)
+	}
+}
+
+func TestSetAsyncSuperseded(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	c.mu.Lock()
+	c.SetAsync("set", 1)
+	c.SetAsync("deleted", 1)
+	c.SetAsync("flushed", 1)
+	c.mu.Unlock()
+
+	c.Set("set", 2)
+	if !c.Delete("deleted") {
+		t.Error("Expected Delete of a queued write to find the key")
+	}
+	if err := c.FlushAsync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := c.Get("set"); value != 2 {
+		t.Errorf("Expected 2 after a later Set, got %v", value)
+	}
+	if _, err := c.Get("deleted"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound after a later Delete, got %v", err)
+	}
+
+	c.mu.Lock()
+	c.SetAsync("flushed", 1)
+	c.mu.Unlock()
+	c.Flush()
+	c.FlushAsync(context.Background())
+	if n := c.ItemCount(); n != 0 {
+		t.Errorf("Expected 0 items after Flush, got %d", n)
+	}
+}
+
+func TestSetAsyncDropWhenFull(t *testing.T) {
+	c := New(Options{Async: AsyncOptions{BufferSize: 2, Backpressure: AsyncDrop}})
+	defer c.Stop()
+
+	c.mu.Lock()
+	var accepted int
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		if err = c.SetAsync(fmt.Sprint("key", i), i); err == nil {
+			accepted++
+		}
+	}
+	// Rewriting a queued key needs no room.
+	rewriteErr := c.SetAsync("key0", 0)
+	c.mu.Unlock()
+
+	if !errors.Is(err, ErrAsyncBufferFull) {
+		t.Fatalf("Expected ErrAsyncBufferFull on a full buffer, got %v", err)
+	}
+	if rewriteErr != nil {
+		t.Fatalf("Expected a queued key to be rewritten on a full buffer, got %v", rewriteErr)
+	}
+	c.FlushAsync(context.Background())
+	if n := c.ItemCount(); n != accepted {
+		t.Fatalf("Expected %d items, got %d", accepted, n)
+	}
+}
+
+func TestSetAsyncSyncWhenFull(t *testing.T) {
+	c := New(Options{Async: AsyncOptions{BufferSize: 1, Backpressure: AsyncSetSync}})
+	defer c.Stop()
+
+	c.mu.Lock()
+	c.SetAsync("a", 1)
+	// Wait for "a" to be taken for applying, then fill the buffer.
+	waitUntil(t, func() bool {
+		c.async.mu.Lock()
+		defer c.async.mu.Unlock()
+		return len(c.async.inflight) == 1
+	})
+	c.SetAsync("b", 1)
+	done := make(chan error)
+	go func() { done <- c.SetAsync("c", 1) }()
+	select {
+	case err := <-done:
+		c.mu.Unlock()
+		t.Fatalf("Expected SetAsync on a full buffer to wait for the lock, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	c.mu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("c"); err != nil {
+		t.Fatalf("Expected the synchronous write to be stored, got %v", err)
+	}
+}
+
+func TestSetAsyncReportsErrors(t *testing.T) {
+	var mu sync.Mutex
+	var failed []string
+	c := New(Options{
+		MaxValueSize: 3,
+		Cost:         func(_ string, v interface{}) int64 { return int64(len(v.(string))) },
+		Async: AsyncOptions{OnError: func(key string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, ErrValueTooLarge) {
+				failed = append(failed, key)
+			}
+		}},
+	})
+	defer c.Stop()
+
+	if err := c.SetAsync("key", nil); err != ErrNilValue {
+		t.Fatalf("Expected ErrNilValue, got %v", err)
+	}
+	c.SetAsync("small", "abc")
+	c.SetAsync("large", "abcd")
+	c.FlushAsync(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0] != "large" {
+		t.Fatalf("Expected OnError to be called for [large], got %v", failed)
+	}
+}
+
+func TestStopAppliesAsyncWrites(t *testing.T) {
+	c := New(Options{})
+
+	for i := 0; i < 1000; i++ {
+		c.SetAsync(fmt.Sprint("key", i), i)
+	}
+	c.Stop()
+	if n := c.ItemCount(); n != 1000 {
+		t.Fatalf("Expected 1000 items after Stop, got %d", n)
+	}
+
+	c.SetAsync("late", 1)
+	if _, err := c.Get("late"); err != nil {
+		t.Fatalf("Expected SetAsync after Stop to be applied, got %v", err)
+	}
+}
+
+// newStalledAsyncCache returns a cache whose background goroutine is stuck
+// applying a queued write of the key "stall", so that later writes of
+// SetAsync stay queued until release is called.
+func newStalledAsyncCache(t *testing.T) (c *Cache, release func()) {
+	t.Helper()
+	stall := make(chan struct{})
+	c = New(Options{Cost: func(key string, _ interface{}) int64 {
+		if key == "stall" {
+			<-stall
+		}
+		return 1
+	}})
+	c.SetAsync("stall", 1)
+	waitUntil(t, func() bool {
+		c.async.mu.Lock()
+		defer c.async.mu.Unlock()
+		_, inflight := c.async.inflight["stall"]
+		return inflight
+	})
+	var once sync.Once
+	release = func() { once.Do(func() { close(stall) }) }
+	t.Cleanup(func() {
+		release()
+		c.Stop()
+	})
+	return c, release
+}
+
+func TestSetAsyncReadModifyWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued interface{}
+		change func(c *Cache) error
+		want   interface{}
+	}{
+		{"Increment", int64(5), func(c *Cache) error {
+			_, err := c.Increment("key", 1)
+			return err
+		}, int64(6)},
+		{"Append", []byte("ab"), func(c *Cache) error {
+			_, err := c.Append("key", []byte("c"))
+			return err
+		}, []byte("abc")},
+		{"CompareAndSwap", "old", func(c *Cache) error {
+			if swapped, err := c.CompareAndSwap("key", "old", "new"); err != nil || !swapped {
+				return fmt.Errorf("expected a swap, got %v, %v", swapped, err)
+			}
+			return nil
+		}, "new"},
+		{"Add", "old", func(c *Cache) error {
+			if err := c.Add("key", "new", 0); err != ErrKeyExists {
+				return fmt.Errorf("expected ErrKeyExists, got %v", err)
+			}
+			return nil
+		}, "old"},
+		{"RPush", []interface{}{"a"}, func(c *Cache) error {
+			_, err := c.RPush("key", "b")
+			return err
+		}, []interface{}{"a", "b"}},
+		{"SAdd", map[string]bool{"a": true}, func(c *Cache) error {
+			_, err := c.SAdd("key", "b")
+			return err
+		}, map[string]bool{"a": true, "b": true}},
+		{"HSet", map[string]interface{}{"a": 1}, func(c *Cache) error {
+			_, err := c.HSet("key", "b", 2)
+			return err
+		}, map[string]interface{}{"a": 1, "b": 2}},
+		{"ZAdd", SortedSet{{Member: "a", Score: 1}}, func(c *Cache) error {
+			_, err := c.ZAdd("key", ScoredMember{Member: "b", Score: 2})
+			return err
+		}, SortedSet{{Member: "a", Score: 1}, {Member: "b", Score: 2}}},
+		{"SetBit", []byte{0x01}, func(c *Cache) error {
+			_, err := c.SetBit("key", 0, true)
+			return err
+		}, []byte{0x81}},
+		{"UpdateInPlace", 5, func(c *Cache) error {
+			return c.UpdateInPlace("key", func(old interface{}, exists bool) (interface{}, time.Duration, error) {
+				if !exists {
+					return nil, 0, errors.New("queued write not seen")
+				}
+				return old.(int) + 1, 0, nil
+			})
+		}, 6},
+		{"Update", 5, func(c *Cache) error {
+			return c.Update(func(tx *Txn) error {
+				old, err := tx.Get("key")
+				if err != nil {
+					return err
+				}
+				return tx.Set("key", old.(int)+1)
+			})
+		}, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, release := newStalledAsyncCache(t)
+			if err := c.SetAsync("key", tt.queued); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.change(c); err != nil {
+				t.Fatalf("Expected the change to succeed, got %v", err)
+			}
+			if value, err := c.Get("key"); err != nil || !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("Expected %v after the change, got %v, %v", tt.want, value, err)
+			}
+
+			release()
+			if err := c.FlushAsync(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := c.Get("key"); err != nil || !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("Expected %v once the queue is applied, got %v, %v", tt.want, value, err)
+			}
+		})
+	}
+}
+
+func TestSetAsyncReadPaths(t *testing.T) {
+	c, _ := newStalledAsyncCache(t)
+	c.Set("key", "old")
+	if err := c.SetAsync("key", "new"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetAsync("added", "new")
+
+	reads := map[string]func(key string) (interface{}, error){
+		"Get": c.Get,
+		"GetContext": func(key string) (interface{}, error) {
+			return c.GetContext(context.Background(), key)
+		},
+		"GetWithExpiration": func(key string) (interface{}, error) {
+			value, _, err := c.GetWithExpiration(key)
+			return value, err
+		},
+		"GetVersioned": func(key string) (interface{}, error) {
+			value, _, err := c.GetVersioned(key)
+			return value, err
+		},
+		"GetBytes": func(key string) (interface{}, error) {
+			return c.GetBytes([]byte(key))
+		},
+		"GetOrSet": func(key string) (interface{}, error) {
+			return c.GetOrSet(key, func() (interface{}, error) {
+				return nil, errors.New("queued write not seen")
+			})
+		},
+		"Keyed.Get": NewKeyed(c, func(dst []byte, key string) []byte {
+			return append(dst, key...)
+		}).Get,
+	}
+	for name, read := range reads {
+		for _, key := range []string{"key", "added"} {
+			if value, err := read(key); err != nil || value != "new" {
+				t.Errorf("Expected %s of %s to return the queued write, got %v, %v", name, key, value, err)
+			}
+		}
+	}
+}
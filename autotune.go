@@ -0,0 +1,257 @@
+package gocache
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Default auto-tuning settings.
+const (
+	DefaultAutoTuneInterval = time.Minute
+	DefaultAutoTuneStep     = 0.1
+	DefaultAutoTuneMinGain  = 0.01
+)
+
+// autoTuneMinLookups is the number of lookups an interval needs for its
+// hit rates to be worth acting on, and autoTuneMaxBackoff the most
+// intervals the tuner holds off shrinking.
+const (
+	autoTuneMinLookups = 100
+	autoTuneMaxBackoff = 64
+)
+
+// AutoTuneOptions configures automatic sizing of the cache. The tuned limit
+// is MaxEntries if it is set, and MaxCost otherwise; the other one is left
+// alone. Zero values select the defaults.
+//
+// The tuner remembers the keys of the latest evictions, as many as a Step
+// of the cache holds. A miss on one of these ghost keys would have been a
+// hit in a cache one Step larger, so the share of lookups that are ghost
+// hits measures what growing by a Step would gain. Every Interval, the
+// limit grows by a Step if the gain is at least MinGain, and shrinks by a
+// Step if it is below half of it: after shrinking, the ghosts of the items
+// evicted measure what they were worth, and the limit grows back if it was
+// too much. Each time that happens, the tuner waits twice as many
+// intervals before shrinking again.
+type AutoTuneOptions struct {
+	// Enabled turns auto-tuning on.
+	Enabled bool
+
+	// Min and Max bound the tuned limit. If 0, they are a quarter and four
+	// times the initial limit.
+	Min int64
+	Max int64
+
+	// Interval is the time between adjustments. If 0, one minute is used.
+	Interval time.Duration
+
+	// Step is the fraction of the limit added or removed at a time. If 0,
+	// 0.1 is used.
+	Step float64
+
+	// MinGain is the hit rate, as a fraction of lookups, that a Step of
+	// capacity must add to be kept. If 0, 0.01 is used.
+	MinGain float64
+
+	// OnTune, if set, is called with the new limit after each change.
+	OnTune func(limit int64)
+}
+
+// autoTuner adjusts the limit of a cache by hill-climbing on ghost hits.
+type autoTuner struct {
+	options AutoTuneOptions
+	cost    bool // tunes MaxCost rather than MaxEntries
+	stop    chan struct{}
+	once    sync.Once
+
+	mu        sync.Mutex
+	ghosts    map[string]struct{}
+	order     []string // ghost keys, oldest first
+	ghostHits uint64
+	last      Stats // counters at the last adjustment, zero at first
+
+	// Shrinking a cache that is just big enough for its working set is
+	// reversed at the next adjustment. To keep from oscillating, the tuner
+	// holds off shrinking for backoff intervals after such a reversal,
+	// doubling backoff each time.
+	shrunk  bool // the last adjustment shrank the limit
+	hold    int  // intervals to wait before shrinking
+	backoff int
+}
+
+func newAutoTuner(options AutoTuneOptions, maxEntries int, maxCost int64) *autoTuner {
+	if !options.Enabled || (maxEntries <= 0 && maxCost <= 0) {
+		return nil
+	}
+	limit := int64(maxEntries)
+	if limit <= 0 {
+		limit = maxCost
+	}
+	if options.Min <= 0 {
+		options.Min = max(limit/4, 1)
+	}
+	if options.Max <= 0 {
+		options.Max = limit * 4
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultAutoTuneInterval
+	}
+	if options.Step <= 0 || options.Step >= 1 {
+		options.Step = DefaultAutoTuneStep
+	}
+	if options.MinGain <= 0 {
+		options.MinGain = DefaultAutoTuneMinGain
+	}
+	return &autoTuner{
+		options: options,
+		cost:    maxEntries <= 0,
+		stop:    make(chan struct{}),
+		ghosts:  make(map[string]struct{}),
+	}
+}
+
+// evicted remembers the key of an item evicted for lack of room, keeping
+// at most capacity ghosts.
+func (t *autoTuner) evicted(key string, capacity int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, found := t.ghosts[key]; found {
+		return
+	}
+	t.ghosts[key] = struct{}{}
+	t.order = append(t.order, key)
+	for len(t.order) > max(capacity, 1) {
+		delete(t.ghosts, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// missed counts a miss of key if it is a ghost.
+func (t *autoTuner) missed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, found := t.ghosts[key]; found {
+		t.ghostHits++
+	}
+}
+
+// ghostCapacity is the number of items in a Step of the cache. It must be
+// called with c.mu held.
+func (c *Cache) ghostCapacity() int {
+	return int(math.Ceil(float64(c.items.len()) * c.tuner.options.Step))
+}
+
+// Capacity returns the current MaxEntries and MaxCost, which differ from
+// the configured ones if Options.AutoTune has adjusted them.
+func (c *Cache) Capacity() (maxEntries int, maxCost int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxEntries, c.maxCost
+}
+
+// autoTune adjusts the limit every interval until the tuner stops.
+func (c *Cache) autoTune(ticker Ticker) {
+	defer ticker.Stop()
+
+	t := c.tuner
+	for {
+		select {
+		case <-ticker.C():
+			c.tune()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// tune makes one adjustment of the limit from the lookups since the last.
+func (c *Cache) tune() {
+	t := c.tuner
+	now := c.counters.load()
+	t.mu.Lock()
+	lookups := now.Hits + now.Misses - t.last.Hits - t.last.Misses
+	if lookups < autoTuneMinLookups {
+		t.mu.Unlock()
+		return
+	}
+	gain := float64(t.ghostHits) / float64(lookups)
+	t.last = now
+	t.ghostHits = 0
+	t.mu.Unlock()
+
+	var factor float64
+	switch {
+	case gain >= t.options.MinGain:
+		factor = 1 + t.options.Step
+		if t.shrunk {
+			// The last shrink was a mistake: wait longer before the next.
+			t.backoff = min(max(2*t.backoff, 1), autoTuneMaxBackoff)
+			t.hold = t.backoff
+		}
+		t.shrunk = false
+	case gain < t.options.MinGain/2:
+		if t.hold > 0 {
+			t.hold--
+			return
+		}
+		if t.shrunk {
+			t.backoff = 0
+		}
+		factor = 1 - t.options.Step
+		t.shrunk = true
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	limit := int64(c.maxEntries)
+	if t.cost {
+		limit = c.maxCost
+	}
+	next := int64(math.Round(float64(limit) * factor))
+	if next == limit {
+		// Move by at least one so that small limits can change too.
+		next += int64(math.Copysign(1, factor-1))
+	}
+	next = min(max(next, t.options.Min), t.options.Max)
+	if next == limit {
+		c.unlock()
+		return
+	}
+	if t.cost {
+		c.maxCost = next
+	} else {
+		c.maxEntries = int(next)
+	}
+	evicted := c.shrinkToLimits()
+	c.unlock()
+
+	c.notifyEvicted(evicted)
+	if t.options.OnTune != nil {
+		t.options.OnTune(next)
+	}
+}
+
+// shrinkToLimits evicts items until the cache is within MaxEntries and
+// MaxCost. It must be called with c.mu held.
+func (c *Cache) shrinkToLimits() []keyValue {
+	var evicted []keyValue
+	for (c.maxEntries > 0 && c.items.len() > c.maxEntries) || (c.maxCost > 0 && c.totalCost > c.maxCost) {
+		kv, ok := c.evictOne()
+		if !ok {
+			break
+		}
+		evicted = append(evicted, kv)
+	}
+	return evicted
+}
+
+// stopAutoTune stops the tuner, if any. It is safe to call more than once.
+func (c *Cache) stopAutoTune() {
+	if c.tuner != nil {
+		c.tuner.once.Do(func() { close(c.tuner.stop) })
+	}
+}
@@ -0,0 +1,84 @@
+package gocache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// autoTuneWorkload reads n keys chosen uniformly at random from keys,
+// setting those that miss.
+func autoTuneWorkload(c *Cache, r *rand.Rand, keys, n int) {
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(r.Intn(keys))
+		if _, err := c.Get(key); err != nil {
+			c.Set(key, key)
+		}
+	}
+}
+
+func TestAutoTuneGrowsForLargerWorkingSet(t *testing.T) {
+	c := New(Options{MaxEntries: 100, AutoTune: AutoTuneOptions{Enabled: true, Interval: time.Hour}})
+	defer c.Stop()
+	r := rand.New(rand.NewSource(1))
+
+	for round := 0; round < 20; round++ {
+		autoTuneWorkload(c, r, 150, 2000)
+		c.tune()
+	}
+	// The tuner probes a Step below the working set now and then.
+	if entries, _ := c.Capacity(); entries < 135 || entries > 400 {
+		t.Fatalf("MaxEntries = %d, want it grown to hold about the 150 keys", entries)
+	}
+	if ratio := c.Stats().HitRatio(); ratio < 0.5 {
+		t.Fatalf("hit ratio = %.2f", ratio)
+	}
+}
+
+func TestAutoTuneShrinksUnusedCapacity(t *testing.T) {
+	var tuned []int64
+	c := New(Options{MaxEntries: 100, AutoTune: AutoTuneOptions{
+		Enabled:  true,
+		Interval: time.Hour,
+		Min:      20,
+		OnTune:   func(limit int64) { tuned = append(tuned, limit) },
+	}})
+	defer c.Stop()
+	r := rand.New(rand.NewSource(1))
+
+	for round := 0; round < 30; round++ {
+		autoTuneWorkload(c, r, 10, 1000)
+		c.tune()
+	}
+	if entries, _ := c.Capacity(); entries != 20 {
+		t.Fatalf("MaxEntries = %d, want the minimum 20", entries)
+	}
+	if len(tuned) == 0 || tuned[len(tuned)-1] != 20 {
+		t.Fatalf("OnTune calls = %v", tuned)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get(fmt.Sprint(i)); err != nil {
+			t.Fatalf("working set key %d evicted", i)
+		}
+	}
+}
+
+func TestAutoTuneShrinkEvicts(t *testing.T) {
+	c := New(Options{MaxEntries: 100, AutoTune: AutoTuneOptions{Enabled: true, Interval: time.Hour}})
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	for i := 0; i < 200; i++ {
+		c.Get("0")
+	}
+	c.tune()
+	if entries, _ := c.Capacity(); entries != 90 {
+		t.Fatalf("MaxEntries = %d, want 90", entries)
+	}
+	if n := c.ItemCount(); n != 90 {
+		t.Fatalf("ItemCount = %d, want 90", n)
+	}
+}
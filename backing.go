@@ -0,0 +1,112 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// BackingStore is the system of record behind a read/write-through cache,
+// such as a database, set with Options.BackingStore.
+type BackingStore interface {
+	// Load returns the value of key, or an error matching ErrKeyNotFound
+	// if the store does not have it.
+	Load(ctx context.Context, key string) (interface{}, error)
+	// Store saves value under key.
+	Store(ctx context.Context, key string, value interface{}) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// backing connects a cache to its BackingStore.
+type backing struct {
+	store   BackingStore
+	onError func(key string, err error)
+	behind  *writeBehind // nil unless write-behind is enabled
+}
+
+// newBacking returns the backing of a cache, whose write-behind reports
+// errors with report.
+func newBacking(options Options, report func(key string, err error)) *backing {
+	if options.BackingStore == nil {
+		return nil
+	}
+	b := &backing{store: options.BackingStore, onError: options.OnBackingStoreError}
+	if options.WriteBehind.Enabled {
+		b.behind = newWriteBehind(b.store, options.WriteBehind, report)
+	}
+	return b
+}
+
+// storeThrough writes a value just set in the cache through to the backing
+// store, or queues it with write-behind. If writing fails, the key is
+// removed from the cache again, so that the cache does not serve a value
+// the store does not have, and the error is returned.
+func (c *Cache) storeThrough(ctx context.Context, key string, value interface{}, token LeaseToken) error {
+	if c.backing == nil {
+		return nil
+	}
+	if c.backing.behind != nil && c.backing.behind.enqueue(BackingWrite{Key: key, Value: value}) {
+		return nil
+	}
+	if err := c.backing.store.Store(ctx, key, value); err != nil {
+		c.delete(key, token)
+		return fmt.Errorf("backing store: %w", err)
+	}
+	return nil
+}
+
+// deleteThrough deletes key from the backing store, or queues its deletion
+// with write-behind, whether or not it was cached.
+func (c *Cache) deleteThrough(ctx context.Context, key string) error {
+	if c.backing == nil {
+		return nil
+	}
+	if c.backing.behind != nil && c.backing.behind.enqueue(BackingWrite{Key: key, Delete: true}) {
+		return nil
+	}
+	if err := c.backing.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("backing store: %w", err)
+	}
+	return nil
+}
+
+// drainWriteBehind writes the changes queued by write-behind, if enabled,
+// and stops it. Later changes are written synchronously.
+func (c *Cache) drainWriteBehind(ctx context.Context) error {
+	if c.backing == nil || c.backing.behind == nil {
+		return nil
+	}
+	return c.backing.behind.drain(ctx)
+}
+
+// reportBacking passes an error of the backing store that cannot be
+// returned to the caller to Options.OnBackingStoreError.
+func (c *Cache) reportBacking(key string, err error) {
+	if err == nil {
+		return
+	}
+	c.log(slog.LevelWarn, "backing store write failed", "key", key, "error", err)
+	if c.backing.onError != nil {
+		c.backing.onError(key, err)
+	}
+}
+
+// load returns the value of key from the backing store, or the latest
+// change of key queued by write-behind, which the store may not have yet.
+func (b *backing) load(ctx context.Context, key string) (interface{}, error) {
+	if b.behind != nil {
+		if change, queued := b.behind.lookup(key); queued {
+			if change.Delete {
+				return nil, ErrKeyNotFound
+			}
+			return change.Value, nil
+		}
+	}
+	value, err := b.store.Load(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		err = fmt.Errorf("backing store: %w", err)
+	}
+	return value, err
+}
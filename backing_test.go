@@ -0,0 +1,140 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory BackingStore counting its loads.
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+	loads  int32
+	fail   error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string]interface{})}
+}
+
+func (m *memoryStore) Load(ctx context.Context, key string) (interface{}, error) {
+	atomic.AddInt32(&m.loads, 1)
+	time.Sleep(10 * time.Millisecond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.values[key]; ok {
+		return v, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (m *memoryStore) Store(ctx context.Context, key string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	delete(m.values, key)
+	return nil
+}
+
+func (m *memoryStore) get(key string) interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key]
+}
+
+func TestBackingStoreWriteThrough(t *testing.T) {
+	store := newMemoryStore()
+	cache := New(Options{BackingStore: store})
+	defer cache.Stop()
+
+	cache.Set("a", "1")
+	cache.SetContext(context.Background(), "b", "2")
+	cache.Namespace("users", NamespaceOptions{}).Set("42", "alice")
+	for key, want := range map[string]interface{}{"a": "1", "b": "2", "users:42": "alice"} {
+		if got := store.get(key); got != want {
+			t.Errorf("Expected %q in the store under %s, got %v", want, key, got)
+		}
+	}
+
+	// Deletes reach the store even for keys that are not cached.
+	store.values["uncached"] = "x"
+	if cache.Delete("uncached") {
+		t.Error("Expected Delete to report that the key was not cached")
+	}
+	if store.get("uncached") != nil {
+		t.Error("Expected Delete to remove the key from the store")
+	}
+
+	// A failed write leaves neither the cache nor the store changed.
+	store.fail = errors.New("database down")
+	if err := cache.Set("a", "2"); !errors.Is(err, store.fail) {
+		t.Errorf("Expected the store's error, got %v", err)
+	}
+	if _, err := cache.getCachedItem("a"); err == nil {
+		t.Error("Expected a to be removed from the cache after a failed write")
+	}
+	if got := store.get("a"); got != "1" {
+		t.Errorf("Expected the store to keep 1, got %v", got)
+	}
+}
+
+func TestBackingStoreDeleteError(t *testing.T) {
+	store := newMemoryStore()
+	var reported error
+	cache := New(Options{BackingStore: store, OnBackingStoreError: func(key string, err error) { reported = err }})
+	defer cache.Stop()
+
+	store.fail = errors.New("database down")
+	cache.Delete("k")
+	if !errors.Is(reported, store.fail) {
+		t.Errorf("Expected the Delete error to be reported, got %v", reported)
+	}
+}
+
+func TestBackingStoreReadThrough(t *testing.T) {
+	store := newMemoryStore()
+	store.values["user:1"] = "alice"
+	cache := New(Options{BackingStore: store, DefaultExpiration: time.Minute})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := cache.Get("user:1"); err != nil || value != "alice" {
+				t.Errorf("Expected alice, got %v (%v)", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&store.loads); n != 1 {
+		t.Errorf("Expected 1 load, got %d", n)
+	}
+	if _, expires, err := cache.GetWithExpiration("user:1"); err != nil || expires.IsZero() {
+		t.Errorf("Expected the loaded value to be cached with the default expiration, got %v (%v)", expires, err)
+	}
+	if n := atomic.LoadInt32(&store.loads); n != 1 {
+		t.Errorf("Expected the cached value to be used, got %d loads", n)
+	}
+
+	if _, err := cache.Get("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Command bench runs the standard gocache workloads against a set of cache
+// configurations and reports the results as a table or as JSON, so that
+// performance comparisons can be reproduced on any machine:
+//
+//	go run ./bench -format json > results.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"gocache"
+)
+
+// keySpace is the number of distinct keys the workloads touch.
+const keySpace = 10000
+
+// configuration is a named set of cache options under comparison.
+type configuration struct {
+	Name    string
+	Options gocache.Options
+}
+
+var configurations = []configuration{
+	{"map", gocache.Options{}},
+	{"read-optimized", gocache.Options{ReadOptimized: true}},
+	{"copy-on-write", gocache.Options{CopyOnWrite: true}},
+	{"arena", gocache.Options{Storage: gocache.ArenaStorage}},
+	{"bytes/gob", gocache.Options{Storage: gocache.BytesStorage, Codec: gocache.GobCodec}},
+	{"bytes/json", gocache.Options{Storage: gocache.BytesStorage, Codec: gocache.JSONCodec}},
+	{"bytes/msgpack", gocache.Options{Storage: gocache.BytesStorage, Codec: gocache.MsgpackCodec}},
+	{"lru/bounded", gocache.Options{MaxEntries: keySpace / 2}},
+}
+
+// workload is a mix of reads and writes over a key distribution.
+type workload struct {
+	Name      string
+	ReadRatio float64 // fraction of operations that are Get
+	Zipf      bool    // skewed key popularity instead of uniform
+}
+
+var workloads = []workload{
+	{"read-mostly/uniform", 0.95, false},
+	{"read-mostly/zipf", 0.95, true},
+	{"mixed/zipf", 0.5, true},
+	{"write-heavy/uniform", 0.1, false},
+}
+
+// Result is one measured configuration and workload pair.
+type Result struct {
+	Configuration string  `json:"configuration"`
+	Workload      string  `json:"workload"`
+	Iterations    int     `json:"iterations"`
+	NsPerOp       float64 `json:"ns_per_op"`
+	OpsPerSec     float64 `json:"ops_per_sec"`
+	BytesPerOp    int64   `json:"bytes_per_op"`
+	AllocsPerOp   int64   `json:"allocs_per_op"`
+}
+
+// Report describes the machine and the results of a run.
+type Report struct {
+	Time       time.Time `json:"time"`
+	GoVersion  string    `json:"go_version"`
+	GOOS       string    `json:"goos"`
+	GOARCH     string    `json:"goarch"`
+	CPUs       int       `json:"cpus"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Results    []Result  `json:"results"`
+}
+
+func main() {
+	format := flag.String("format", "text", "output format: text or json")
+	configFilter := flag.String("config", "", "only run configurations matching this regexp")
+	workloadFilter := flag.String("workload", "", "only run workloads matching this regexp")
+	flag.Parse()
+
+	configRe, err := regexp.Compile(*configFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	workloadRe, err := regexp.Compile(*workloadFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report := Report{
+		Time:       time.Now().UTC(),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		CPUs:       runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+	for _, c := range configurations {
+		if !configRe.MatchString(c.Name) {
+			continue
+		}
+		for _, w := range workloads {
+			if !workloadRe.MatchString(w.Name) {
+				continue
+			}
+			report.Results = append(report.Results, run(c, w))
+		}
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	case "text":
+		printTable(report)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+}
+
+// run benchmarks workload w against a cache configured with c.
+func run(c configuration, w workload) Result {
+	keys := make([]string, keySpace)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+
+	r := testing.Benchmark(func(b *testing.B) {
+		cache := gocache.New(c.Options)
+		defer cache.Stop()
+		for _, key := range keys {
+			cache.Set(key, key)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			rnd := rand.New(rand.NewSource(rand.Int63()))
+			next := func() int { return rnd.Intn(keySpace) }
+			if w.Zipf {
+				zipf := rand.NewZipf(rnd, 1.1, 1, keySpace-1)
+				next = func() int { return int(zipf.Uint64()) }
+			}
+
+			for pb.Next() {
+				key := keys[next()]
+				if rnd.Float64() < w.ReadRatio {
+					cache.Get(key)
+				} else {
+					cache.Set(key, key)
+				}
+			}
+		})
+	})
+
+	nsPerOp := float64(r.T.Nanoseconds()) / float64(r.N)
+	return Result{
+		Configuration: c.Name,
+		Workload:      w.Name,
+		Iterations:    r.N,
+		NsPerOp:       nsPerOp,
+		OpsPerSec:     1e9 / nsPerOp,
+		BytesPerOp:    r.AllocedBytesPerOp(),
+		AllocsPerOp:   r.AllocsPerOp(),
+	}
+}
+
+func printTable(report Report) {
+	fmt.Printf("%s %s/%s, %d CPUs\n\n", report.GoVersion, report.GOOS, report.GOARCH, report.CPUs)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "configuration\tworkload\tns/op\tops/s\tB/op\tallocs/op\t")
+	for _, r := range report.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.0f\t%d\t%d\t\n",
+			r.Configuration, r.Workload, r.NsPerOp, r.OpsPerSec, r.BytesPerOp, r.AllocsPerOp)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,111 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// maxBitOffset bounds the offsets of SetBit, so that a stray offset cannot
+// allocate a huge bitmap: 2^32 bits take 512 MB.
+const maxBitOffset = 1<<32 - 1
+
+// SetBit sets the bit at offset of the bitmap stored under key to value
+// and returns its previous value.
+//
+// Bitmaps are stored as []byte values, with bit 0 being the most
+// significant bit of the first byte, as in Redis, so a bitmap can also be
+// set with Set or extended with Append. SetBit grows the bitmap with zero
+// bytes as needed. A bitmap is created by the first SetBit to a missing or
+// expired key, with the default expiration, and keeps its expiration and
+// priority when changed, like a list. Every change replaces the stored
+// slice with a new one, so slices returned earlier do not change.
+// Operations on a key holding another type of value return ErrWrongType.
+func (c *Cache) SetBit(key string, offset int64, value bool) (bool, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return false, ErrBitOffset
+	}
+	if err := c.validateKey(key); err != nil {
+		return false, err
+	}
+
+	var old bool
+	_, err := c.modify(key, func(v interface{}, found bool) (interface{}, bool, error) {
+		var bitmap []byte
+		if found {
+			var ok bool
+			if bitmap, ok = v.([]byte); !ok {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a bitmap", ErrWrongType, key, v)
+			}
+		}
+		old = bitAt(bitmap, offset)
+		if found && old == value {
+			return nil, false, nil
+		}
+
+		next := make([]byte, max(len(bitmap), int(offset/8)+1))
+		copy(next, bitmap)
+		mask := byte(0x80) >> (offset % 8)
+		if value {
+			next[offset/8] |= mask
+		} else {
+			next[offset/8] &^= mask
+		}
+		return next, true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return old, nil
+}
+
+// GetBit returns the bit at offset of the bitmap stored under key. Bits
+// beyond the end of the bitmap, and of a missing bitmap, are 0.
+func (c *Cache) GetBit(key string, offset int64) (bool, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return false, ErrBitOffset
+	}
+	bitmap, err := c.getBitmap(key)
+	if err != nil {
+		return false, err
+	}
+	return bitAt(bitmap, offset), nil
+}
+
+// BitCount returns the number of bits set in the bitmap stored under key,
+// or 0 if it is missing.
+func (c *Cache) BitCount(key string) (int, error) {
+	bitmap, err := c.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, b := range bitmap {
+		n += bits.OnesCount8(b)
+	}
+	return n, nil
+}
+
+// getBitmap returns the bitmap stored under key, or nil if it is missing.
+func (c *Cache) getBitmap(key string) ([]byte, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bitmap, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds %T, not a bitmap", ErrWrongType, key, value)
+	}
+	return bitmap, nil
+}
+
+// bitAt returns the bit at offset of bitmap, or false beyond its end.
+func bitAt(bitmap []byte, offset int64) bool {
+	if offset/8 >= int64(len(bitmap)) {
+		return false
+	}
+	return bitmap[offset/8]&(byte(0x80)>>(offset%8)) != 0
+}
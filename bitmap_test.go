@@ -0,0 +1,82 @@
+package gocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheBitmap(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, DefaultExpiration: 24 * time.Hour})
+	defer cache.Stop()
+
+	for _, offset := range []int64{0, 7, 8, 100} {
+		if old, err := cache.SetBit("active", offset, true); err != nil || old {
+			t.Errorf("SetBit(%d): expected old bit 0, got %v (%v)", offset, old, err)
+		}
+	}
+	if old, _ := cache.SetBit("active", 7, true); !old {
+		t.Error("Expected the old bit 1")
+	}
+	if value, _ := cache.Get("active"); len(value.([]byte)) != 13 || value.([]byte)[0] != 0x81 || value.([]byte)[1] != 0x80 {
+		t.Errorf("Expected bits in Redis order in 13 bytes, got %x", value)
+	}
+	if n, err := cache.BitCount("active"); err != nil || n != 4 {
+		t.Errorf("Expected 4 bits set, got %d (%v)", n, err)
+	}
+
+	if bit, _ := cache.GetBit("active", 100); !bit {
+		t.Error("Expected bit 100 to be set")
+	}
+	if bit, _ := cache.GetBit("active", 101); bit {
+		t.Error("Expected bit 101 to be clear")
+	}
+	if bit, err := cache.GetBit("active", 1<<20); err != nil || bit {
+		t.Errorf("Expected a bit beyond the end to be clear, got %v (%v)", bit, err)
+	}
+	if old, _ := cache.SetBit("active", 0, false); !old {
+		t.Error("Expected clearing bit 0 to return 1")
+	}
+	if n, _ := cache.BitCount("active"); n != 3 {
+		t.Errorf("Expected 3 bits set, got %d", n)
+	}
+
+	if _, err := cache.SetBit("active", -1, true); err != ErrBitOffset {
+		t.Errorf("Expected ErrBitOffset, got %v", err)
+	}
+	if _, err := cache.GetBit("active", 1<<33); err != ErrBitOffset {
+		t.Errorf("Expected ErrBitOffset, got %v", err)
+	}
+	cache.Set("text", "x")
+	if _, err := cache.SetBit("text", 0, true); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	clock.Advance(25 * time.Hour)
+	if n, _ := cache.BitCount("active"); n != 0 {
+		t.Errorf("Expected the bitmap to expire, got %d bits", n)
+	}
+}
+
+func TestCacheBitmapConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				cache.SetBit("users", int64(i*50+n), true)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := cache.BitCount("users"); n != 400 {
+		t.Errorf("Expected 400 bits set, got %d", n)
+	}
+}
@@ -0,0 +1,146 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold blobs.
+	gob.Register(Blob{})
+}
+
+// BlobChunkSize is the size of the chunks SetReader stores a blob in.
+const BlobChunkSize = 64 << 10
+
+// Blob is the value of a key set with SetReader: a large byte string held
+// in chunks of BlobChunkSize, so that it never needs a single allocation
+// of its full size. A Blob is immutable, so it is never copied, even with
+// Options.CopyOnGet, and Get and Items return it at the cost of a pointer
+// copy. It is snapshotted with codecs that support
+// encoding.BinaryMarshaler, such as the default GobCodec, and is never
+// compressed.
+type Blob struct {
+	chunks [][]byte
+	size   int64
+}
+
+// Size returns the length of the blob in bytes.
+func (b Blob) Size() int64 {
+	return b.size
+}
+
+// Reader returns a reader of the content of the blob.
+func (b Blob) Reader() io.ReadCloser {
+	return &blobReader{chunks: b.chunks}
+}
+
+// MarshalBinary returns the content of the blob in a single slice.
+func (b Blob) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, b.size)
+	for _, chunk := range b.chunks {
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary sets the blob to a copy of data, in chunks.
+func (b *Blob) UnmarshalBinary(data []byte) error {
+	*b = Blob{size: int64(len(data))}
+	for len(data) > 0 {
+		n := min(len(data), BlobChunkSize)
+		b.chunks = append(b.chunks, append([]byte(nil), data[:n]...))
+		data = data[n:]
+	}
+	return nil
+}
+
+// blobReader reads the chunks of a blob.
+type blobReader struct {
+	chunks [][]byte
+	offset int // in chunks[0]
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && len(r.chunks) > 0 {
+		copied := copy(p[n:], r.chunks[0][r.offset:])
+		n += copied
+		r.offset += copied
+		if r.offset == len(r.chunks[0]) {
+			r.chunks, r.offset = r.chunks[1:], 0
+		}
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (r *blobReader) Close() error {
+	r.chunks = nil
+	return nil
+}
+
+// SetReader stores the content of r under key as a Blob, for duration, or
+// without expiration if duration is 0, like SetWithExpiration. It reads r
+// to the end in chunks of BlobChunkSize, so that multi-megabyte payloads
+// are never held in a single allocation. If reading r fails, nothing is
+// stored and the error is returned.
+//
+// Unless Options.Cost is set, the size of a blob checked against
+// Options.MaxValueSize is its length, so SetReader stops reading r as soon
+// as it exceeds the limit and returns ErrValueTooLarge.
+func (c *Cache) SetReader(key string, r io.Reader, duration time.Duration) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	limited := c.maxValueSize > 0 && c.cost == nil
+	if limited {
+		r = io.LimitReader(r, c.maxValueSize+1)
+	}
+
+	var blob Blob
+	for {
+		chunk := make([]byte, BlobChunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if n < BlobChunkSize/2 {
+				// Do not keep a mostly empty buffer for the last chunk.
+				chunk = append([]byte(nil), chunk[:n]...)
+			}
+			blob.chunks = append(blob.chunks, chunk[:n])
+			blob.size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if limited && blob.size > c.maxValueSize {
+		// blob holds only the first bytes of r, so its size is not known.
+		return fmt.Errorf("%w: %q is over %d", ErrValueTooLarge, key, c.maxValueSize)
+	}
+	return c.SetWithExpiration(key, blob, duration)
+}
+
+// GetReader returns a reader of the Blob stored under key, or of a []byte
+// value, which reads the value as it was when GetReader was called. It
+// returns the errors of Get, and ErrWrongType for a value of another type.
+func (c *Cache) GetReader(key string) (io.ReadCloser, error) {
+	value, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case Blob:
+		return v.Reader(), nil
+	case []byte:
+		return &blobReader{chunks: [][]byte{v}}, nil
+	}
+	return nil, fmt.Errorf("%w: %q holds %T, not a blob", ErrWrongType, key, value)
+}
@@ -0,0 +1,111 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestCacheSetReader(t *testing.T) {
+	cache := New(Options{CopyOnGet: true, MaxValueSize: 1 << 20})
+	defer cache.Stop()
+
+	data := make([]byte, 3*BlobChunkSize+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	if err := cache.SetReader("blob", iotest.HalfReader(bytes.NewReader(data)), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	value, _ := cache.Get("blob")
+	blob := value.(Blob)
+	if blob.Size() != int64(len(data)) || len(blob.chunks) != 4 {
+		t.Errorf("Expected %d bytes in 4 chunks, got %d in %d", len(data), blob.Size(), len(blob.chunks))
+	}
+	if again, _ := cache.Get("blob"); &again.(Blob).chunks[0][0] != &blob.chunks[0][0] {
+		t.Error("Expected a blob not to be copied with CopyOnGet")
+	}
+
+	r, err := cache.GetReader("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iotest.TestReader(r, data); err != nil {
+		t.Error(err)
+	}
+	r.Close()
+
+	if err := cache.SetReader("large", bytes.NewReader(make([]byte, 2<<20)), 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	// Reading stops at the limit, so an endless reader fails too.
+	endless := &countingReader{r: zeros{}}
+	if err := cache.SetReader("endless", endless, 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	if endless.n > 1<<20+1 {
+		t.Errorf("Expected reading to stop at the limit, read %d bytes", endless.n)
+	}
+	if err := cache.SetReader("limit", bytes.NewReader(make([]byte, 1<<20)), 0); err != nil {
+		t.Errorf("Expected a blob of the limit's size to be stored, got %v", err)
+	}
+	failed := errors.New("failed")
+	if err := cache.SetReader("broken", iotest.ErrReader(failed), 0); err != failed {
+		t.Errorf("Expected the error of the reader, got %v", err)
+	}
+	if _, err := cache.Get("broken"); err != ErrKeyNotFound {
+		t.Errorf("Expected nothing stored for a failed read, got %v", err)
+	}
+
+	cache.Set("bytes", []byte("plain"))
+	r, _ = cache.GetReader("bytes")
+	if content, _ := io.ReadAll(r); string(content) != "plain" {
+		t.Errorf("Expected plain, got %q", content)
+	}
+	cache.Set("int", 1)
+	if _, err := cache.GetReader("int"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.GetReader("missing"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+// zeros reads zero bytes forever.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestBlobSnapshot(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	data := bytes.Repeat([]byte("0123456789"), BlobChunkSize/5)
+	cache.SetReader("blob", bytes.NewReader(data), 0)
+	cache.SetReader("empty", bytes.NewReader(nil), 0)
+
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := restored.GetReader("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := io.ReadAll(r); !bytes.Equal(content, data) {
+		t.Errorf("Expected the blob to survive a snapshot, got %d bytes", len(content))
+	}
+	if value, err := restored.Get("empty"); err != nil || value.(Blob).Size() != 0 {
+		t.Errorf("Expected an empty blob, got %v (%v)", value, err)
+	}
+}
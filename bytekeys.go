@@ -0,0 +1,40 @@
+package gocache
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// GetBytes is like Get for a key held in a byte slice, such as a network
+// buffer. On a hit it looks the key up without converting it to a string,
+// so it does not allocate; key is not retained and may be reused once
+// GetBytes returns.
+func (c *Cache) GetBytes(key []byte) (interface{}, error) {
+	// The string shares key's memory. It is only used for lookups that do
+	// not keep it; paths that might keep the key (expiration, events) go
+	// through Get with a copy instead.
+	k := unsafe.String(unsafe.SliceData(key), len(key))
+	if atomic.LoadInt64(&c.async.queued) != 0 {
+		// A write queued by SetAsync may be newer than the stored item.
+		return c.Get(string(key))
+	}
+
+	item, found := c.lookupItem(k)
+	if found && !item.expiredAt(c.clock.Now().UnixNano()) {
+		atomic.AddUint64(&c.counters.hits, 1)
+		c.countKeyHit(k)
+		return c.expand(item.Value)
+	}
+	if !found {
+		atomic.AddUint64(&c.counters.misses, 1)
+		return nil, ErrKeyNotFound
+	}
+	return c.Get(string(key))
+}
+
+// SetBytes is like SetWithExpiration for a key held in a byte slice. The
+// key is copied, so the slice may be reused once SetBytes returns.
+func (c *Cache) SetBytes(key []byte, value interface{}, duration time.Duration) error {
+	return c.SetWithExpiration(string(key), value, duration)
+}
@@ -0,0 +1,50 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheBytesKeys(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	buf := []byte("key1")
+	if err := cache.SetBytes(buf, "value1", time.Minute); err != nil {
+		t.Fatalf("SetBytes failed: %v", err)
+	}
+	copy(buf, "XXXX") // reusing the buffer must not affect the stored key
+
+	if value, err := cache.Get("key1"); err != nil || value != "value1" {
+		t.Errorf("Expected value1, got %v (%v)", value, err)
+	}
+	if value, err := cache.GetBytes([]byte("key1")); err != nil || value != "value1" {
+		t.Errorf("Expected value1 from GetBytes, got %v (%v)", value, err)
+	}
+	if _, err := cache.GetBytes([]byte("missing")); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.GetBytes([]byte("key1")); err != ErrKeyExpired {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 2 || stats.Expirations != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheGetBytesAllocs(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("key", "value")
+
+	key := []byte("key")
+	allocs := testing.AllocsPerRun(100, func() {
+		cache.GetBytes(key)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected GetBytes hit not to allocate, got %v allocations", allocs)
+	}
+}
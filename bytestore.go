@@ -0,0 +1,266 @@
+package gocache
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+)
+
+// bytesSegmentSize is the size of the byte segments bytesStore allocates.
+// Entries larger than a segment get a segment of their own.
+const bytesSegmentSize = 1 << 20
+
+// bytesHeaderSize is the size of the fixed part of an encoded entry: key
+// length, value length, expiration, cost, priority, modification time and
+// version.
+const bytesHeaderSize = 4 + 4 + 8 + 8 + 8 + 8 + 8
+
+// bytesStore keeps items serialized in large byte segments, indexed by a
+// hash of their key. Its index maps hold no pointers, so the garbage
+// collector does not have to scan millions of keys and values. Values are
+// encoded with the cache's codec on Set and decoded on every read.
+//
+// Overwritten and deleted entries leave garbage in the segments; once more
+// than half of the used space is garbage, the live entries are copied into
+// fresh segments.
+type bytesStore struct {
+	codec    Codec
+	seed     maphash.Seed
+	segments [][]byte
+	index    map[uint64]uint64 // key hash to location
+	overflow map[string]uint64 // keys whose hash collides with an indexed key
+	count    int
+	used     int // bytes written to segments
+	garbage  int // bytes of entries that were overwritten or deleted
+}
+
+// encodedValue is a value already encoded by bytesStore.encode, passed
+// through Item.Value so that encoding errors surface before c.mu is taken.
+// Its first byte tells how the rest was encoded.
+type encodedValue []byte
+
+// Kinds of encodedValue.
+const (
+	bytesEncoded    byte = 0 // encoded with the codec
+	bytesCompressed byte = 1 // a compressedValue
+)
+
+func newBytesStore(codec Codec) *bytesStore {
+	return &bytesStore{
+		codec:    codec,
+		seed:     maphash.MakeSeed(),
+		index:    make(map[uint64]uint64),
+		overflow: make(map[string]uint64),
+	}
+}
+
+// encode serializes value with the store's codec. Compressed values are
+// already serialized and are kept as they are.
+func (s *bytesStore) encode(value interface{}) (encodedValue, error) {
+	if compressed, ok := value.(compressedValue); ok {
+		return append(encodedValue{bytesCompressed}, compressed...), nil
+	}
+	data, err := s.codec.Marshal(&value)
+	if err != nil {
+		return nil, err
+	}
+	return append(encodedValue{bytesEncoded}, data...), nil
+}
+
+// locate returns the location of key and whether it is in the overflow map.
+func (s *bytesStore) locate(key string) (loc uint64, overflow, found bool) {
+	if len(s.overflow) > 0 {
+		if loc, found := s.overflow[key]; found {
+			return loc, true, true
+		}
+	}
+	loc, found = s.index[maphash.String(s.seed, key)]
+	if found && s.key(loc) == key {
+		return loc, false, true
+	}
+	return 0, false, false
+}
+
+// entry returns the encoded entry at loc.
+func (s *bytesStore) entry(loc uint64) []byte {
+	seg, off := loc>>32, uint32(loc)
+	b := s.segments[seg][off:]
+	keyLen := binary.LittleEndian.Uint32(b)
+	valueLen := binary.LittleEndian.Uint32(b[4:])
+	return b[:bytesHeaderSize+int(keyLen)+int(valueLen)]
+}
+
+// parse splits the entry at loc into its key, its item without the value
+// and the encoded value.
+func (s *bytesStore) parse(loc uint64) (key string, item Item, value encodedValue) {
+	b := s.entry(loc)
+	keyLen := binary.LittleEndian.Uint32(b)
+	item = Item{
+		Expiration: int64(binary.LittleEndian.Uint64(b[8:])),
+		Cost:       int64(binary.LittleEndian.Uint64(b[16:])),
+		Priority:   int(int64(binary.LittleEndian.Uint64(b[24:]))),
+		Modified:   int64(binary.LittleEndian.Uint64(b[32:])),
+		Version:    binary.LittleEndian.Uint64(b[40:]),
+	}
+	key = string(b[bytesHeaderSize : bytesHeaderSize+keyLen])
+	return key, item, encodedValue(b[bytesHeaderSize+keyLen:])
+}
+
+// key returns the key of the entry at loc.
+func (s *bytesStore) key(loc uint64) string {
+	b := s.entry(loc)
+	keyLen := binary.LittleEndian.Uint32(b)
+	return string(b[bytesHeaderSize : bytesHeaderSize+keyLen])
+}
+
+// decode returns the key and item in the entry at loc.
+func (s *bytesStore) decode(loc uint64) (string, Item, bool) {
+	key, item, value := s.parse(loc)
+	if value[0] == bytesCompressed {
+		item.Value = compressedValue(append([]byte(nil), value[1:]...))
+		return key, item, true
+	}
+	if err := s.codec.Unmarshal(value[1:], &item.Value); err != nil {
+		return "", Item{}, false
+	}
+	return key, item, true
+}
+
+// append writes an entry to the last segment, starting a new one if it does
+// not fit, and returns its location.
+func (s *bytesStore) append(key string, item Item, value encodedValue) uint64 {
+	size := bytesHeaderSize + len(key) + len(value)
+	last := len(s.segments) - 1
+	if last < 0 || len(s.segments[last])+size > cap(s.segments[last]) {
+		capacity := bytesSegmentSize
+		if size > capacity {
+			capacity = size
+		}
+		s.segments = append(s.segments, make([]byte, 0, capacity))
+		last++
+	}
+
+	seg := s.segments[last]
+	loc := uint64(last)<<32 | uint64(len(seg))
+	seg = binary.LittleEndian.AppendUint32(seg, uint32(len(key)))
+	seg = binary.LittleEndian.AppendUint32(seg, uint32(len(value)))
+	seg = binary.LittleEndian.AppendUint64(seg, uint64(item.Expiration))
+	seg = binary.LittleEndian.AppendUint64(seg, uint64(item.Cost))
+	seg = binary.LittleEndian.AppendUint64(seg, uint64(int64(item.Priority)))
+	seg = binary.LittleEndian.AppendUint64(seg, uint64(item.Modified))
+	seg = binary.LittleEndian.AppendUint64(seg, item.Version)
+	seg = append(seg, key...)
+	seg = append(seg, value...)
+	s.segments[last] = seg
+	s.used += size
+	return loc
+}
+
+// put records loc as the location of key.
+func (s *bytesStore) put(key string, loc uint64) {
+	h := maphash.String(s.seed, key)
+	if existing, found := s.index[h]; found && s.key(existing) != key {
+		s.overflow[key] = loc
+		return
+	}
+	s.index[h] = loc
+}
+
+// compact copies the live entries into fresh segments.
+func (s *bytesStore) compact() {
+	fresh := &bytesStore{
+		codec:    s.codec,
+		seed:     s.seed,
+		index:    make(map[uint64]uint64, len(s.index)),
+		overflow: make(map[string]uint64, len(s.overflow)),
+		count:    s.count,
+	}
+	copyEntry := func(loc uint64) {
+		key, item, value := s.parse(loc)
+		fresh.put(key, fresh.append(key, item, value))
+	}
+	for _, loc := range s.index {
+		copyEntry(loc)
+	}
+	for _, loc := range s.overflow {
+		copyEntry(loc)
+	}
+	*s = *fresh
+}
+
+func (s *bytesStore) get(key string) (Item, bool) {
+	loc, _, found := s.locate(key)
+	if !found {
+		return Item{}, false
+	}
+	_, item, ok := s.decode(loc)
+	return item, ok
+}
+
+func (s *bytesStore) lookup(key string) (Item, bool) {
+	return s.get(key)
+}
+
+func (s *bytesStore) set(key string, item Item) {
+	value, ok := item.Value.(encodedValue)
+	if !ok {
+		// Items re-stored by the cache itself, such as by ExpireAt, were
+		// decoded from this store and encode again without error.
+		value, _ = s.encode(item.Value)
+	}
+
+	if s.used > bytesSegmentSize && s.garbage > s.used/2 {
+		s.compact()
+	}
+
+	s.delete(key)
+	s.put(key, s.append(key, item, value))
+	s.count++
+}
+
+func (s *bytesStore) delete(key string) {
+	loc, overflow, found := s.locate(key)
+	if !found {
+		return
+	}
+	if overflow {
+		delete(s.overflow, key)
+	} else {
+		delete(s.index, maphash.String(s.seed, key))
+	}
+	s.garbage += len(s.entry(loc))
+	s.count--
+}
+
+func (s *bytesStore) len() int {
+	return s.count
+}
+
+func (s *bytesStore) rangeItems(fn func(key string, item Item) bool) {
+	visit := func(loc uint64) bool {
+		key, item, ok := s.decode(loc)
+		if !ok {
+			return true
+		}
+		return fn(key, item)
+	}
+	for _, loc := range s.index {
+		if !visit(loc) {
+			return
+		}
+	}
+	for _, loc := range s.overflow {
+		if !visit(loc) {
+			return
+		}
+	}
+}
+
+func (s *bytesStore) clear() {
+	*s = *newBytesStore(s.codec)
+}
+
+func (s *bytesStore) commit() {}
+
+func (s *bytesStore) lockFree() bool {
+	return false
+}
@@ -0,0 +1,93 @@
+package gocache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytesStorageCompaction(t *testing.T) {
+	cache := New(Options{Storage: BytesStorage, Codec: MsgpackCodec})
+	defer cache.Stop()
+
+	value := strings.Repeat("x", 1000)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 1000; i++ {
+			if err := cache.Set(fmt.Sprintf("key%d", i), value+fmt.Sprint(round)); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+	}
+
+	s := cache.items.(*bytesStore)
+	if s.used > 4*bytesSegmentSize {
+		t.Errorf("Expected overwritten entries to be compacted, %d bytes used", s.used)
+	}
+	if count := cache.ItemCount(); count != 1000 {
+		t.Errorf("Expected 1000 items, got %d", count)
+	}
+	for i := 0; i < 1000; i++ {
+		got, err := cache.Get(fmt.Sprintf("key%d", i))
+		if err != nil || got != value+"4" {
+			t.Fatalf("Unexpected value for key%d: %v", i, err)
+		}
+	}
+}
+
+func TestBytesStorageHashCollision(t *testing.T) {
+	s := newBytesStore(MsgpackCodec)
+
+	// Make "a" occupy the index slot of "b", as if their hashes collided.
+	s.index[maphash.String(s.seed, "b")] = s.append("a", Item{}, mustEncode(t, s, "first"))
+	s.count++
+	s.set("b", Item{Value: mustEncode(t, s, "second"), Priority: 2})
+
+	if len(s.overflow) != 1 {
+		t.Fatalf("Expected colliding key in overflow, got %v", s.overflow)
+	}
+	if item, found := s.get("b"); !found || item.Value != "second" || item.Priority != 2 {
+		t.Errorf("Expected overflow key to be found, got %+v", item)
+	}
+	s.delete("b")
+	if _, found := s.get("b"); found || s.len() != 1 {
+		t.Error("Expected overflow key to be deleted")
+	}
+}
+
+func TestBytesStorageValues(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Storage: BytesStorage, Clock: clock})
+	defer cache.Stop()
+
+	if err := cache.Set("func", func() {}); err == nil {
+		t.Error("Expected error for a value the codec cannot encode")
+	}
+
+	data := []byte("abc")
+	cache.Set("bytes", data)
+	data[0] = 'z'
+	got, _ := cache.Get("bytes")
+	if string(got.([]byte)) != "abc" {
+		t.Errorf("Expected stored copy to be unaffected, got %s", got)
+	}
+
+	cache.ExpireAt("bytes", clock.Now().Add(time.Minute))
+	if got, err := cache.Get("bytes"); err != nil || string(got.([]byte)) != "abc" {
+		t.Errorf("Expected value to survive ExpireAt, got %v (%v)", got, err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("bytes"); err != ErrKeyExpired {
+		t.Errorf("Expected new expiration to apply, got %v", err)
+	}
+}
+
+func mustEncode(t *testing.T, s *bytesStore, value interface{}) encodedValue {
+	t.Helper()
+	encoded, err := s.encode(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
@@ -1,17 +1,106 @@
 package gocache
 
 import (
+	"context"
+	"log/slog"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Cache represents an in-memory cache with expiration.
 type Cache struct {
-	items             map[string]Item
-	mu                sync.RWMutex
-	defaultExpiration time.Duration
-	cleanupInterval   time.Duration
-	stopCleanup       chan bool
+	items            store
+	keys             []string       // keys of items, for picking by index
+	keyIndex         map[string]int // position of each key in keys, nil until needed
+	keyHits          []uint64       // hits of each key in keys, if trackKeyHits
+	trackKeyHits     bool
+	mu               sync.RWMutex
+	defaultTTL       atomic.Int64 // time.Duration, see defaultExpiration
+	contextTTL       bool
+	janitor          janitor
+	onEvicted        func(string, interface{})
+	onReplaced       func(string, interface{}, interface{})
+	onExpired        func(string, interface{})
+	expiredOverwrite ExpiredOverwritePolicy
+	maxEntries       int
+	maxCost          int64
+	totalCost        int64
+	maxValueSize     int64
+	keyRules         keyRules
+	cost             func(string, interface{}) int64
+	codec            Codec
+	clock            Clock
+	snapshotKeys     []EncryptionKey
+	snapshotFile     string
+
+	compression          Compressor
+	compressionThreshold int
+
+	copyOnGet bool
+	clone     func(interface{}) interface{}
+
+	policy     EvictionPolicy
+	policyMu   sync.Mutex  // serializes calls to policy from concurrent readers
+	priorities map[int]int // number of items stored at each priority
+
+	softLimit   float64
+	onSoftLimit func(QuotaWarning)
+	softCrossed map[QuotaResource]bool
+
+	leases   map[string]lease
+	leaseSeq uint64
+
+	negatives   map[string]int64 // expirations of keys cached as missing
+	negativeTTL time.Duration
+	missFilter  *missFilter
+
+	pinned    map[string]struct{} // keys exempt from eviction
+	protected map[string]struct{} // keys a committing transaction has stored
+	memory    *memoryWatcher
+	tuner     *autoTuner
+
+	hotKeys *hotKeys // nil unless Options.HotKeys is set
+
+	allowNil           bool
+	trackDistributions bool
+
+	logger   *slog.Logger
+	logLevel slog.Level
+
+	versionSeq uint64 // last Item.Version assigned
+
+	expMu          sync.Mutex
+	expSeq         uint64
+	pendingExpired map[uint64]keyValue // OnExpired callbacks not yet completed
+
+	counters  counters
+	history   *history
+	lifecycle lifecycle
+
+	nsMu       sync.RWMutex
+	namespaces map[string]*Namespace
+
+	shield *shield
+
+	subsMu        sync.RWMutex
+	subscribers   []chan Event
+	watchers      map[string][]chan Event
+	subsClosed    bool
+	subsDone      chan struct{} // closed with the subscribers, nil until Watch
+	droppedEvents uint64
+	events        eventLog
+
+	feeds       changeFeeds
+	backing     *backing
+	loader      Loader
+	loads       loadGroup
+	refresh     *refreshAhead
+	refreshJobs refreshJobs
+	tracer      atomic.Pointer[TraceRecorder]
+	async       *asyncWrites
+	keyLocks    keyLocks
 }
 
 // Options contains configuration options for creating a new cache.
@@ -20,41 +109,412 @@ type Options struct {
 	// If 0, items never expire by default.
 	DefaultExpiration time.Duration
 
+	// ContextDeadlineTTL makes SetContext expire items no later than the
+	// deadline of their context, so values computed for a request do not
+	// outlive it.
+	ContextDeadlineTTL bool
+
 	// CleanupInterval is the interval between automatic cleanup of expired items.
 	// If 0, expired items are not cleaned up automatically.
 	CleanupInterval time.Duration
+
+	// OnEvicted is called with the key and value of each item the cache
+	// removes on its own, such as when an item expires. It is not called for
+	// Delete or Flush. It runs after the cache lock has been released.
+	OnEvicted func(key string, value interface{})
+
+	// OnExpired is called with the key and value of each item that expires.
+	// It runs after the cache lock has been released. Expirations whose
+	// callback has not returned are saved by SaveSnapshot, and LoadSnapshot
+	// delivers them again along with the callbacks for items that expired
+	// while the cache was down, so every expiration is delivered at least
+	// once across restarts.
+	OnExpired func(key string, value interface{})
+
+	// OnReplaced is called when Set overwrites an existing item with a new
+	// value. It runs after the cache lock has been released.
+	OnReplaced func(key string, oldValue, newValue interface{})
+
+	// ExpiredOverwrite controls how Set treats an expired item that has not
+	// been cleaned up yet. The default is ExpiredOverwriteReplace.
+	ExpiredOverwrite ExpiredOverwritePolicy
+
+	// MaxEntries limits the number of items in the cache. When a Set of a new
+	// key would exceed it, items chosen by EvictionPolicy are evicted first.
+	// If 0, the number of items is unlimited.
+	MaxEntries int
+
+	// MaxCost limits the total cost of the items in the cache, as computed by
+	// Cost. When a Set would exceed it, items chosen by EvictionPolicy are
+	// evicted first. If 0, the total cost is unlimited.
+	MaxCost int64
+
+	// Cost returns the cost of storing value under key, for example its
+	// approximate size in bytes. If nil, every item costs 1.
+	Cost func(key string, value interface{}) int64
+
+	// MaxKeyLength rejects keys longer than this many bytes on Set with
+	// ErrInvalidKey. If 0, keys of any length are accepted.
+	MaxKeyLength int
+
+	// KeyCharset lists the characters keys may contain, such as
+	// "abcdefghijklmnopqrstuvwxyz0123456789-_". Set rejects other keys with
+	// ErrInvalidKey. If empty, any character is accepted.
+	KeyCharset string
+
+	// ValidateKey is called on Set after the other key checks. If it returns
+	// an error, Set fails with that error wrapped in ErrInvalidKey.
+	ValidateKey func(key string) error
+
+	// MaxValueSize rejects values larger than this with ErrValueTooLarge.
+	// The size of a value is its cost if Cost is set, and otherwise the
+	// length of its encoding with Codec, which costs an extra encoding per
+	// Set. If 0, values of any size are accepted.
+	MaxValueSize int64
+
+	// AllowNilValues lets Set store nil, for example to cache that a user
+	// has no avatar. Get then returns a nil value with a nil error for the
+	// key, and ErrKeyNotFound only for keys that are not cached. If false,
+	// Set rejects nil with ErrNilValue.
+	AllowNilValues bool
+
+	// EvictionPolicy chooses which items to evict when MaxEntries or MaxCost
+	// is reached, or under MemoryPressure. If nil, a least recently used
+	// policy is used.
+	EvictionPolicy EvictionPolicy
+
+	// SoftLimit is a fraction of MaxEntries and MaxCost, such as 0.8. When
+	// a Set takes the item count or total cost above that fraction of its
+	// limit, OnSoftLimit is called, giving operators time to react before
+	// eviction starts. If 0, no warnings are issued.
+	SoftLimit float64
+
+	// OnSoftLimit is called each time usage crosses SoftLimit from below. It
+	// runs after the cache lock has been released.
+	OnSoftLimit func(warning QuotaWarning)
+
+	// Codec converts values to bytes for persistence, networking and export.
+	// If nil, GobCodec is used.
+	Codec Codec
+
+	// Compression compresses large values on Set; Get decompresses them
+	// transparently. Compressed values are encoded with Codec, so Get
+	// returns a decoded copy of them. If nil, values are not compressed.
+	Compression Compressor
+
+	// CompressionThreshold is the encoded size in bytes from which values
+	// are compressed. If 0, DefaultCompressionThreshold is used.
+	CompressionThreshold int
+
+	// CopyOnGet makes every value that leaves the cache, through Get, Items,
+	// Range, notifications and callbacks, a copy of the cached value, so
+	// callers cannot mutate the shared value in place. Values are copied
+	// with Clone, or by a round trip through Codec if Clone is nil. The
+	// value passed to Set is stored as is. With BytesStorage, values are
+	// always decoded copies and this option has no effect.
+	CopyOnGet bool
+
+	// Clone returns a deep copy of value for CopyOnGet. Setting it implies
+	// CopyOnGet.
+	Clone func(value interface{}) interface{}
+
+	// TrackKeyHits counts the hits of each key for DumpMetadataCSV, at the
+	// cost of taking the read lock once more on every hit.
+	TrackKeyHits bool
+
+	// TrackDistributions keeps histograms of the sizes and TTLs of stored
+	// values in Stats.ValueSizes and Stats.TTLs, which the collector of the
+	// prometheus module exports. Unless Cost is set, sizes are measured by
+	// encoding values with Codec, which costs an extra encoding per Set.
+	TrackDistributions bool
+
+	// Logger receives the cache's log messages: janitor runs at
+	// slog.LevelDebug, evictions under memory pressure and snapshots saved
+	// and loaded at slog.LevelInfo, failures of Loader, BackingStore and
+	// background reloads at slog.LevelWarn, and failures to save or load
+	// a snapshot at slog.LevelError. If nil, nothing is logged.
+	Logger *slog.Logger
+
+	// LogLevel is the lowest level of the messages sent to Logger, on top
+	// of the filtering of its handler. The default, slog.LevelInfo, leaves
+	// out the janitor runs.
+	LogLevel slog.Level
+
+	// Async configures the buffer of SetAsync.
+	Async AsyncOptions
+
+	// HotKeys is the number of keys whose hits are counted for TopKeys.
+	// Set it to a few times the number of hot keys to report, such as 100.
+	// If 0, hot keys are not tracked.
+	HotKeys int
+
+	// SnapshotKeys enables encryption of snapshots with AES-GCM. Snapshots
+	// are encrypted with the first key, and can be loaded with any of them,
+	// so keys can be rotated by adding a new key in front. If empty,
+	// snapshots are written in plaintext.
+	SnapshotKeys []EncryptionKey
+
+	// SnapshotFile is the file Close writes a final snapshot to with
+	// SaveSnapshotFile, once queued writes have been applied, so that the
+	// contents can be restored with LoadSnapshotFile after a restart. If
+	// empty, no snapshot is written.
+	SnapshotFile string
+
+	// EventLogSize is the number of recent events whose keys are kept, so
+	// that a Notifications subscriber that detects a gap in Event.Seq can
+	// look up the namespaces it missed with MissedNamespaces. If 0,
+	// DefaultEventLogSize is used; if negative, no events are kept.
+	EventLogSize int
+
+	// Clock is the source of time for expiration and cleanup. If nil, the
+	// system clock is used.
+	Clock Clock
+
+	// ReadOptimized stores items in a sync.Map so that Get takes no lock
+	// unless a write is in progress. It suits workloads that overwhelmingly
+	// read a stable set of keys; writes become slower than with the default
+	// map.
+	ReadOptimized bool
+
+	// CopyOnWrite publishes the items map through an atomic pointer and
+	// copies it on every write, so Get never takes a lock or touches shared
+	// mutable state. Each write costs a copy of the whole map, so it only
+	// suits small, read-mostly caches. It takes precedence over
+	// ReadOptimized.
+	CopyOnWrite bool
+
+	// Shield staggers reloads through GetOrSet after bulk invalidations.
+	// Shielding is disabled unless Shield.MaxConcurrent is set.
+	Shield ShieldOptions
+
+	// Storage selects how items are held in memory. The default is
+	// MapStorage. Other storage modes take precedence over ReadOptimized and
+	// CopyOnWrite.
+	Storage Storage
+
+	// BackingStore makes the cache a read/write-through layer over a
+	// system of record such as a database. Set, SetWithExpiration,
+	// SetContext, SetWithLease and their namespace variants store the value
+	// in the backing store after caching it; Delete and DeleteWithLease
+	// delete the key from it whether or not it was cached; and Get loads
+	// missing keys from it, caching them with the default expiration.
+	// Other writes, such as Add, Increment, Flush and expiration, only
+	// affect the cache.
+	BackingStore BackingStore
+
+	// OnBackingStoreError is called with errors of the backing store that
+	// cannot be returned to the caller, such as those of Delete and of
+	// write-behind.
+	OnBackingStoreError func(key string, err error)
+
+	// Loader makes the cache read-through: Get, GetContext and
+	// GetWithExpiration call it for keys that are missing or expired, and
+	// cache what it returns. Concurrent misses of a key share one call.
+	// It takes precedence over BackingStore for loading.
+	Loader Loader
+
+	// NegativeTTL makes the cache remember for this long that a key does
+	// not exist when Loader or BackingStore return an error matching
+	// ErrKeyNotFound, or so does the function passed to GetOrSet. Get then
+	// returns ErrNotFoundCached for the key without loading it again. If
+	// 0, missing keys are not remembered; see also SetNegative.
+	NegativeTTL time.Duration
+
+	// AutoTune adjusts MaxEntries, or else MaxCost, at runtime to the size
+	// that pays off in hit rate.
+	AutoTune AutoTuneOptions
+
+	// MemoryPressure evicts items when the process approaches a memory
+	// threshold. It is disabled unless a threshold or a soft memory limit
+	// is set.
+	MemoryPressure MemoryPressureOptions
+
+	// MissFilter rejects keys recently found missing upstream with a bloom
+	// filter. It is disabled unless MissFilter.Capacity is set.
+	MissFilter MissFilterOptions
+
+	// RefreshAhead keeps hot keys from expiring: a Get of an item that
+	// expires within this window reloads it in the background with Loader
+	// or BackingStore, while the current value is still served. The
+	// reloaded value replaces the item unless it has been set or deleted
+	// in the meantime. If 0, items are only loaded once they are missing.
+	RefreshAhead time.Duration
+
+	// OnRefreshError is called when a background reload fails. The item
+	// then expires as usual unless a later Get reloads it in time.
+	OnRefreshError func(key string, err error)
+
+	// WriteBehind makes writes to BackingStore asynchronous and batched.
+	// Queued writes are drained by Stop, and by Close in PhaseDrain.
+	WriteBehind WriteBehindOptions
 }
 
+// Storage selects the in-memory representation of cache items.
+type Storage int
+
+const (
+	// MapStorage keeps items in a Go map, as live values.
+	MapStorage Storage = iota
+	// BytesStorage keeps items serialized with Codec in large pre-allocated
+	// byte segments indexed by key hash. The garbage collector then sees a
+	// few large allocations instead of millions of pointers, which keeps GC
+	// pauses short for very large caches. Every Get decodes its value, so
+	// callers receive a copy, and values must be supported by Codec (with
+	// GobCodec, register custom types with gob.Register). MsgpackCodec is
+	// the most compact choice. Sample, TrackKeyHits and eviction policies
+	// keep a string per key, which the garbage collector does scan.
+	BytesStorage
+	// ArenaStorage is experimental. It allocates items in large slabs of
+	// thousands of entries that are released in bulk once all of their
+	// entries are gone, cutting per-item allocation overhead for caches
+	// with tens of millions of small entries.
+	ArenaStorage
+)
+
+// ExpiredOverwritePolicy controls how Set treats an expired item that is
+// still present in the cache.
+type ExpiredOverwritePolicy int
+
+const (
+	// ExpiredOverwriteReplace treats the Set as a replacement of the expired
+	// item and calls OnReplaced.
+	ExpiredOverwriteReplace ExpiredOverwritePolicy = iota
+	// ExpiredOverwriteEvict treats the expired item as evicted before the Set,
+	// calling OnEvicted and emitting EventExpired, and then inserts the new
+	// item as if the key had been absent.
+	ExpiredOverwriteEvict
+)
+
 // New creates a new Cache with the specified default expiration and cleanup interval.
 // If cleanupInterval > 0, a background goroutine will be started to clean up expired
-// items at the specified interval.
+// items at the specified interval. New does not validate options; NewCache
+// does, and options can also be checked with Options.Validate.
 func New(options Options) *Cache {
 	c := &Cache{
-		items:             make(map[string]Item),
-		defaultExpiration: options.DefaultExpiration,
-		cleanupInterval:   options.CleanupInterval,
-		stopCleanup:       make(chan bool),
+		trackKeyHits:     options.TrackKeyHits,
+		hotKeys:          newHotKeys(options.HotKeys),
+		priorities:       make(map[int]int),
+		contextTTL:       options.ContextDeadlineTTL,
+		onEvicted:        options.OnEvicted,
+		onReplaced:       options.OnReplaced,
+		onExpired:        options.OnExpired,
+		pendingExpired:   make(map[uint64]keyValue),
+		expiredOverwrite: options.ExpiredOverwrite,
+		maxEntries:       options.MaxEntries,
+		maxCost:          options.MaxCost,
+		maxValueSize:     options.MaxValueSize,
+		allowNil:         options.AllowNilValues,
+		keyRules:         newKeyRules(options),
+		cost:             options.Cost,
+		softLimit:        options.SoftLimit,
+		onSoftLimit:      options.OnSoftLimit,
+		softCrossed:      make(map[QuotaResource]bool),
+		policy:           options.EvictionPolicy,
+		codec:            options.Codec,
+		snapshotKeys:     options.SnapshotKeys,
+		snapshotFile:     options.SnapshotFile,
+		compression:      options.Compression,
+		clock:            options.Clock,
+		leases:           make(map[string]lease),
+		shield:           newShield(options.Shield),
+		events:           newEventLog(options.EventLogSize),
+		loader:           options.Loader,
+		negativeTTL:      options.NegativeTTL,
+		refresh:          newRefreshAhead(options),
+		refreshJobs:      refreshJobs{onError: options.OnRefreshError},
+		async:            newAsyncWrites(options.Async),
+	}
+
+	if c.clock == nil {
+		c.clock = systemClock{}
+	}
+	c.defaultTTL.Store(int64(options.DefaultExpiration))
+	c.trackDistributions = options.TrackDistributions
+	c.logger, c.logLevel = options.Logger, options.LogLevel
+	c.backing = newBacking(options, c.reportBacking)
+	c.history = newHistory(c.clock.Now())
+	c.missFilter = newMissFilter(options.MissFilter, c.clock.Now())
+
+	if c.codec == nil {
+		c.codec = GobCodec
+	}
+	c.compressionThreshold = options.CompressionThreshold
+	if c.compressionThreshold == 0 {
+		c.compressionThreshold = DefaultCompressionThreshold
+	}
+	c.items = newStore(options, c.codec)
+	if c.trackKeyHits {
+		c.keyIndex = make(map[string]int)
+	}
+	if _, decoded := c.items.(*bytesStore); !decoded {
+		c.copyOnGet = options.CopyOnGet || options.Clone != nil
+		c.clone = options.Clone
+	}
+	c.memory = newMemoryWatcher(options.MemoryPressure)
+	c.tuner = newAutoTuner(options.AutoTune, c.maxEntries, c.maxCost)
+	if c.policy == nil && (c.maxEntries > 0 || c.maxCost > 0 || c.memory != nil) {
+		c.policy = NewLRUPolicy()
 	}
 
 	// Start cleanup routine if cleanup interval is specified
-	if options.CleanupInterval > 0 {
-		go c.startCleanupRoutine()
+	c.startJanitor(options.CleanupInterval)
+	if c.memory != nil {
+		go c.watchMemory(c.clock.NewTicker(c.memory.options.Interval))
+	}
+	if c.tuner != nil {
+		go c.autoTune(c.clock.NewTicker(c.tuner.options.Interval))
 	}
 
 	return c
 }
 
+// defaultExpiration returns the expiration of items stored without one,
+// which Configure may change at any time.
+func (c *Cache) defaultExpiration() time.Duration {
+	return time.Duration(c.defaultTTL.Load())
+}
+
+// janitor tracks the automatic cleanup goroutine.
+type janitor struct {
+	mu       sync.Mutex
+	interval time.Duration
+	stop     chan bool // nil if no cleanup goroutine runs
+	stopped  bool      // set by stopJanitor; the janitor is not restarted
+}
+
+// startJanitor stops the automatic cleanup goroutine, if any, and starts a
+// new one running every interval, unless interval is 0 or the cache has
+// been stopped.
+func (c *Cache) startJanitor(interval time.Duration) {
+	j := &c.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stop != nil {
+		close(j.stop)
+		j.stop = nil
+	}
+	j.interval = interval
+	if interval > 0 && !j.stopped {
+		j.stop = make(chan bool)
+		go c.startCleanupRoutine(c.clock.NewTicker(interval), j.stop)
+	}
+}
+
 // startCleanupRoutine starts a background goroutine that will periodically
-// delete expired items from the cache.
-func (c *Cache) startCleanupRoutine() {
-	ticker := time.NewTicker(c.cleanupInterval)
+// delete expired items from the cache on every tick of ticker, until stop
+// is closed.
+func (c *Cache) startCleanupRoutine(ticker Ticker, stop <-chan bool) {
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			c.DeleteExpired()
-		case <-c.stopCleanup:
+		case now := <-ticker.C():
+			start := c.clock.Now()
+			n := c.deleteExpired()
+			c.recordHistory(now)
+			c.log(slog.LevelDebug, "janitor run", "expired", n, "duration", c.clock.Now().Sub(start))
+		case <-stop:
 			return
 		}
 	}
@@ -63,107 +523,613 @@ func (c *Cache) startCleanupRoutine() {
 // Set adds an item to the cache with the specified key and value.
 // The item will expire after the DefaultExpiration time has passed.
 func (c *Cache) Set(key string, value interface{}) error {
-	return c.SetWithExpiration(key, value, c.defaultExpiration)
+	return c.SetWithExpiration(key, value, c.defaultExpiration())
 }
 
 // SetWithExpiration adds an item to the cache with the specified key, value, and expiration duration.
 // If duration is 0, the item never expires.
+// Returns ErrKeyLeased if another caller holds a lease on the key, or
+// ErrInvalidKey if the key violates the configured key constraints.
 func (c *Cache) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
-	if value == nil {
-		return ErrNilValue
+	return c.setThrough(context.Background(), key, value, duration, 0, 0)
+}
+
+// SetWithPriority is like SetWithExpiration, but stores the item with the
+// given eviction priority instead of 0. When the cache is full, items of
+// lower priority are always evicted before items of higher priority,
+// whatever the eviction policy, so that critical entries such as
+// authentication data survive capacity pressure. Within a priority, the
+// eviction policy chooses.
+func (c *Cache) SetWithPriority(key string, value interface{}, duration time.Duration, priority int) error {
+	return c.setThrough(context.Background(), key, value, duration, 0, priority)
+}
+
+// setThrough validates key, stores the value in the cache with the given
+// priority, and writes it through to the backing store, if any.
+func (c *Cache) setThrough(ctx context.Context, key string, value interface{}, duration time.Duration, token LeaseToken, priority int) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	if err := c.set(key, value, duration, token, priority); err != nil {
+		return err
+	}
+	return c.storeThrough(ctx, key, value, token)
+}
+
+// SetContext is like Set, but returns ctx.Err() without storing anything if
+// ctx is already done. With Options.ContextDeadlineTTL, the item expires at
+// the deadline of ctx if that comes before the default expiration.
+func (c *Cache) SetContext(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
+	duration := c.defaultExpiration()
+	if deadline, ok := ctx.Deadline(); ok && c.contextTTL {
+		remaining := deadline.Sub(c.clock.Now())
+		if remaining <= 0 {
+			return context.DeadlineExceeded
+		}
+		if duration <= 0 || remaining < duration {
+			duration = remaining
+		}
+	}
+	return c.setThrough(ctx, key, value, duration, 0, 0)
+}
+
+// set stores the value under key with the given eviction priority, checking
+// the given lease token against any active lease on the key.
+func (c *Cache) set(key string, value interface{}, duration time.Duration, token LeaseToken, priority int) error {
 	var expiration int64
 	if duration > 0 {
-		expiration = time.Now().Add(duration).UnixNano()
+		expiration = c.clock.Now().Add(duration).UnixNano()
+	}
+	_, err := c.setIf(key, value, expiration, token, priority, nil)
+	return err
+}
+
+// setIf is set with an absolute expiration, as a Unix timestamp in
+// nanoseconds or 0, for a value that is only stored if cond, unless nil,
+// returns true for the unexpired item currently stored under key, if any.
+// cond is called with c.mu held. It reports whether the value was stored.
+func (c *Cache) setIf(key string, value interface{}, expiration int64, token LeaseToken, priority int, cond func(old Item, found bool) bool) (bool, error) {
+	return c.setItem(key, value, expiration, token, priority, cond, math.MaxUint64)
+}
+
+// setItem is setIf superseding the writes of key queued by SetAsync up to
+// sequence number supersedes. Unless it applies a queued write itself, a
+// write with a condition first applies the queued write of key, which the
+// condition must see.
+func (c *Cache) setItem(key string, value interface{}, expiration int64, token LeaseToken, priority int, cond func(old Item, found bool) bool, supersedes uint64) (bool, error) {
+	p, err := c.prepareSet(key, value, expiration, priority)
+	if err != nil {
+		return false, err
 	}
+	now := c.clock.Now()
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
-	c.items[key] = Item{
-		Value:      value,
-		Expiration: expiration,
+	for cond != nil && supersedes == math.MaxUint64 {
+		if _, queued := c.async.lookup(key); !queued {
+			break
+		}
+		c.unlock()
+		c.applyQueued(key)
+		c.mu.Lock()
+	}
+
+	if err := c.checkLease(key, token, now.UnixNano()); err != nil {
+		c.unlock()
+		return false, err
+	}
+	if err := c.checkCost(p); err != nil {
+		c.unlock()
+		return false, err
+	}
+
+	old, found := c.items.get(key)
+	if cond != nil && !cond(old, found && !old.expiredAt(now.UnixNano())) {
+		c.unlock()
+		return false, nil
+	}
+
+	c.storeLocked(p, now, supersedes)
+	warnings := c.checkSoftLimits()
+	c.unlock()
+
+	c.finishSet(p, now)
+	for _, w := range warnings {
+		c.onSoftLimit(w)
 	}
+	return true, nil
+}
+
+// pendingSet is a value being stored: prepared by prepareSet, stored by
+// storeLocked, which records the effects that finishSet delivers once the
+// lock is released.
+type pendingSet struct {
+	key        string
+	value      interface{} // as given
+	stored     interface{} // compressed or encoded for the store
+	cost       int64
+	size       int64 // for Stats.ValueSizes, if tracked
+	expiration int64
+	priority   int
 
+	old      Item
+	replaced bool
+	evicted  []keyValue
+	expired  []expiredItem
+}
+
+// prepareSet checks value and converts it for the store, without the lock.
+func (c *Cache) prepareSet(key string, value interface{}, expiration int64, priority int) (*pendingSet, error) {
+	if value == nil && !c.allowNil {
+		return nil, ErrNilValue
+	}
+
+	p := &pendingSet{key: key, value: value, expiration: expiration, priority: priority}
+	p.cost = c.costOf(key, value)
+	if err := c.checkValueSize(key, value, p.cost); err != nil {
+		return nil, err
+	}
+	if c.trackDistributions {
+		var err error
+		if p.size, err = c.sizeOf(value, p.cost); err != nil {
+			return nil, err
+		}
+	}
+
+	stored, err := c.compress(value)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := c.items.(*bytesStore); ok {
+		if stored, err = s.encode(stored); err != nil {
+			return nil, err
+		}
+	}
+	p.stored = stored
+	return p, nil
+}
+
+// checkCost returns ErrCostTooLarge if a prepared value could never fit
+// in MaxCost. It must be called with c.mu held, as Configure and
+// Options.AutoTune change MaxCost.
+func (c *Cache) checkCost(p *pendingSet) error {
+	if c.maxCost > 0 && p.cost > c.maxCost {
+		return ErrCostTooLarge
+	}
 	return nil
 }
 
+// storeLocked stores a prepared value, evicting items if needed, and
+// supersedes the writes of its key queued by SetAsync up to sequence
+// number supersedes. It must be called with c.mu held, once the lease and
+// any condition have been checked.
+func (c *Cache) storeLocked(p *pendingSet, now time.Time, supersedes uint64) {
+	key := p.key
+	c.async.supersede(key, supersedes)
+
+	p.old, p.replaced = c.items.get(key)
+	if p.replaced && p.old.expiredAt(now.UnixNano()) {
+		atomic.AddUint64(&c.counters.expiredOverwrites, 1)
+		if c.expiredOverwrite == ExpiredOverwriteEvict {
+			p.expired = append(p.expired, c.expire(key, p.old.Value))
+			p.evicted = append(p.evicted, keyValue{key, p.old.Value})
+			p.replaced = false
+		}
+	}
+
+	if c.maxEntries > 0 || c.maxCost > 0 {
+		p.evicted = append(p.evicted, c.evict(key, p.cost)...)
+		if _, found := c.items.get(key); p.replaced && !found {
+			// The policy chose the item being replaced as a victim.
+			p.replaced = false
+		}
+	}
+
+	c.insert(key, Item{
+		Value:      p.stored,
+		Expiration: p.expiration,
+		Cost:       p.cost,
+		Priority:   p.priority,
+		Modified:   now.UnixNano(),
+		Version:    c.nextVersion(),
+	}, p.replaced)
+	c.emit(EventSet, key, p.value)
+}
+
+// finishSet delivers the effects of a stored value. It must be called
+// without c.mu held.
+func (c *Cache) finishSet(p *pendingSet, now time.Time) {
+	c.trace(TraceSet, p.key)
+	c.observeSet(p.key, p.size, p.expiration, now)
+	if p.replaced && c.onReplaced != nil {
+		c.onReplaced(p.key, c.valueOf(p.old.Value), p.value)
+	}
+	c.notifyEvicted(p.evicted)
+	c.notifyExpired(p.expired)
+}
+
+// nextVersion returns the version of a newly stored item. It must be called
+// with c.mu held.
+func (c *Cache) nextVersion() uint64 {
+	c.versionSeq++
+	return c.versionSeq
+}
+
+// insert stores item under key and informs the eviction policy. It must be
+// called with c.mu held.
+func (c *Cache) insert(key string, item Item, replaced bool) {
+	if replaced {
+		old, _ := c.items.get(key)
+		c.totalCost -= old.Cost
+		c.unindexPriority(old.Priority)
+	} else {
+		c.indexKey(key)
+	}
+	c.items.set(key, item)
+	delete(c.negatives, key)
+	if c.missFilter != nil {
+		c.missFilter.remove(key)
+	}
+	c.totalCost += item.Cost
+	c.priorities[item.Priority]++
+
+	if c.policy != nil {
+		c.policyMu.Lock()
+		if replaced {
+			c.policy.OnAccess(key)
+		} else {
+			c.policy.OnAdd(key)
+		}
+		c.policyMu.Unlock()
+	}
+}
+
+// remove deletes key and informs the eviction policy. It must be called with
+// c.mu held.
+func (c *Cache) remove(key string) {
+	old, found := c.items.get(key)
+	c.totalCost -= old.Cost
+	if found {
+		c.unindexPriority(old.Priority)
+	}
+	c.items.delete(key)
+	c.unindexKey(key)
+	delete(c.pinned, key)
+	c.rearmSoftLimits()
+
+	if c.policy != nil {
+		c.policyMu.Lock()
+		c.policy.OnRemove(key)
+		c.policyMu.Unlock()
+	}
+}
+
+// unlock commits pending changes to the store and releases the write lock.
+// It must be used instead of c.mu.Unlock wherever c.mu.Lock was taken.
+func (c *Cache) unlock() {
+	c.items.commit()
+	c.mu.Unlock()
+}
+
+// accessed informs the eviction policy that key was read.
+func (c *Cache) accessed(key string, found bool) {
+	if found && c.policy != nil {
+		c.policyMu.Lock()
+		c.policy.OnAccess(key)
+		c.policyMu.Unlock()
+	}
+}
+
+// notifyEvicted calls OnEvicted for each evicted item. It must be called
+// without c.mu held.
+func (c *Cache) notifyEvicted(evicted []keyValue) {
+	if c.onEvicted == nil {
+		return
+	}
+	for _, kv := range evicted {
+		c.onEvicted(kv.key, c.valueOf(kv.value))
+	}
+}
+
 // Get returns the value stored in the cache for the given key.
 // Returns ErrKeyNotFound if the key does not exist or ErrKeyExpired if the key has expired.
+// Returns ErrNotFoundCached if the key is recorded as missing upstream.
 func (c *Cache) Get(key string) (interface{}, error) {
+	item, err := c.getItem(key)
+	return item.Value, err
+}
+
+// GetWithExpiration is like Get but also returns the expiration time of the
+// item, or the zero time if it never expires.
+func (c *Cache) GetWithExpiration(key string) (interface{}, time.Time, error) {
+	item, err := c.getItem(key)
+	if err != nil || item.Expiration == 0 {
+		return item.Value, time.Time{}, err
+	}
+	return item.Value, time.Unix(0, item.Expiration), nil
+}
+
+// Peek returns the unexpired item stored under key, with its expiration and
+// priority. Unlike Get, it does not count a hit or miss, load missing keys
+// or make the item more recently used.
+func (c *Cache) Peek(key string) (Entry, bool) {
 	c.mu.RLock()
-	item, found := c.items[key]
+	item, found := c.items.get(key)
 	c.mu.RUnlock()
+	if !found || item.expiredAt(c.clock.Now().UnixNano()) {
+		return Entry{}, false
+	}
+	item.Value = c.valueOf(item.Value)
+	return Entry{Key: key, Item: item}, true
+}
 
+// getItem is Get returning the whole item, with its value expanded.
+func (c *Cache) getItem(key string) (Item, error) {
+	return c.getItemContext(context.Background(), key)
+}
+
+// getItemContext is getItem loading misses with Options.Loader or
+// Options.BackingStore, if set, within ctx.
+func (c *Cache) getItemContext(ctx context.Context, key string) (Item, error) {
+	if w, queued := c.async.lookup(key); queued && !w.expiredAt(c.clock.Now().UnixNano()) {
+		return c.queuedItem(key, w)
+	}
+	if c.missFilter != nil && c.missFilter.contains(key, c.clock.Now()) {
+		atomic.AddUint64(&c.counters.misses, 1)
+		return Item{}, ErrNotFoundCached
+	}
+	item, err := c.getCachedItem(key)
+	if err == ErrKeyNotFound || err == ErrKeyExpired {
+		if c.negativeCached(key) {
+			return Item{}, ErrNotFoundCached
+		}
+		if c.loader != nil || c.backing != nil {
+			return c.loadMissing(ctx, key)
+		}
+	}
+	if err == nil {
+		c.maybeRefresh(key, item)
+	}
+	return item, err
+}
+
+// getCachedItem is getItem without the backing store.
+func (c *Cache) getCachedItem(key string) (Item, error) {
+	c.trace(TraceGet, key)
+	item, found := c.lookupItem(key)
 	if !found {
-		return nil, ErrKeyNotFound
+		atomic.AddUint64(&c.counters.misses, 1)
+		if c.tuner != nil {
+			c.tuner.missed(key)
+		}
+		return Item{}, ErrKeyNotFound
 	}
 
-	if item.Expired() {
+	now := c.clock.Now().UnixNano()
+	if item.expiredAt(now) {
+		atomic.AddUint64(&c.counters.misses, 1)
 		// Delete the key if it's expired
 		c.mu.Lock()
 		// Check again after acquiring write lock to prevent race condition
-		if item, found := c.items[key]; found && item.Expired() {
-			delete(c.items, key)
+		item, found := c.items.get(key)
+		expired := found && item.expiredAt(now)
+		var exp expiredItem
+		if expired {
+			exp = c.expire(key, item.Value)
+		}
+		c.unlock()
+
+		if expired {
+			c.notifyEvicted([]keyValue{{key, item.Value}})
+			c.notifyExpired([]expiredItem{exp})
 		}
-		c.mu.Unlock()
-		return nil, ErrKeyExpired
+		return Item{}, ErrKeyExpired
 	}
 
-	return item.Value, nil
+	atomic.AddUint64(&c.counters.hits, 1)
+	c.countKeyHit(key)
+	value, err := c.expand(item.Value)
+	if err != nil {
+		return Item{}, err
+	}
+	item.Value = value
+	return item, nil
+}
+
+// lookupItem returns the item stored under key, expired or not, and
+// informs the eviction policy of the access.
+func (c *Cache) lookupItem(key string) (Item, bool) {
+	if c.items.lockFree() {
+		item, found := c.items.lookup(key)
+		c.accessed(key, found)
+		return item, found
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, found := c.items.get(key)
+	c.accessed(key, found)
+	return item, found
 }
 
 // GetOrSet gets the value from the cache if it exists and is not expired.
 // Otherwise, it sets the value using the provided function and returns it.
-func (c *Cache) GetOrSet(key string, fn func() (interface{}, error)) (interface{}, error) {
-	// Try to get the value from the cache first
-	value, err := c.Get(key)
-	if err == nil {
-		// Value found and not expired
-		return value, nil
-	}
+// Options such as WithBypassCache and WithNoStore change this per call.
+// Keys recorded as missing return ErrNotFoundCached without calling fn.
+func (c *Cache) GetOrSet(key string, fn func() (interface{}, error), opts ...CallOption) (interface{}, error) {
+	o := applyCallOptions(opts)
 
-	// Value not found or expired, compute it
-	value, err = fn()
-	if err != nil {
-		return nil, err
+	// Try to get the value from the cache first
+	if !o.bypassCache {
+		value, err := c.Get(key)
+		if err == nil || err == ErrNotFoundCached {
+			// Value found and not expired, or known to be missing
+			return value, err
+		}
 	}
 
-	// Store the computed value in the cache
-	err = c.Set(key, value)
-	if err != nil {
-		return nil, err
+	// Value not found or expired, compute and store it
+	value, err := c.load(key, fn, func(value interface{}) error {
+		return c.Set(key, value)
+	}, o)
+	if err != nil && !o.noStore {
+		c.cacheNotFound(key, err)
 	}
-
-	return value, nil
+	return value, err
 }
 
 // Delete removes the item with the given key from the cache.
-// It returns true if the key was found and deleted.
+// It returns true if the key was found and deleted. Keys leased by another
+// caller are not deleted; use DeleteWithLease instead.
 func (c *Cache) Delete(key string) bool {
+	deleted, err := c.delete(key, 0)
+	if err == nil && c.backing != nil {
+		c.reportBacking(key, c.deleteThrough(context.Background(), key))
+	}
+	return deleted
+}
+
+// delete removes the item with the given key, checking the given lease token
+// against any active lease on the key.
+func (c *Cache) delete(key string, token LeaseToken) (bool, error) {
+	c.trace(TraceDelete, key)
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
-	_, found := c.items[key]
+	if err := c.checkLease(key, token, c.clock.Now().UnixNano()); err != nil {
+		return false, err
+	}
+
+	queued := c.async.supersede(key, math.MaxUint64)
+	item, found := c.items.get(key)
 	if found {
-		delete(c.items, key)
+		c.remove(key)
+		c.emit(EventDeleted, key, item.Value)
+		return true, nil
+	}
+	return queued, nil
+}
+
+// DeleteWhere removes every unexpired item for which fn returns true, in a
+// single pass under the write lock, and returns the number of items
+// removed. It suits policy-driven purges, such as removing everything of a
+// tenant modified before some time. fn is called with the lock held, so it
+// must not use the cache and should return quickly. Keys leased by
+// Lease are left alone. Like Flush, DeleteWhere does not delete the keys
+// from Options.BackingStore.
+func (c *Cache) DeleteWhere(fn func(key string, item Item) bool) int {
+	var keys []string
+	now := c.clock.Now().UnixNano()
+
+	c.mu.Lock()
+	c.items.rangeItems(func(k string, v Item) bool {
+		if v.expiredAt(now) {
+			return true
+		}
+		v.Value = c.valueOf(v.Value)
+		if fn(k, v) && c.checkLease(k, 0, now) == nil {
+			keys = append(keys, k)
+		}
 		return true
+	})
+	for _, k := range keys {
+		c.async.supersede(k, math.MaxUint64)
+		item, _ := c.items.get(k)
+		c.remove(k)
+		c.emit(EventDeleted, k, item.Value)
 	}
-	return false
+	c.unlock()
+
+	for _, k := range keys {
+		c.trace(TraceDelete, k)
+	}
+	return len(keys)
+}
+
+// ExpireAt changes the expiration time of an existing item. A zero time
+// means the item never expires; a time in the past makes the item expire
+// immediately. Returns ErrKeyNotFound if the key does not exist or
+// ErrKeyLeased if another caller holds a lease on it.
+func (c *Cache) ExpireAt(key string, at time.Time) error {
+	var expiration int64
+	if !at.IsZero() {
+		expiration = at.UnixNano()
+	}
+
+	c.applyQueued(key)
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := c.checkLease(key, 0, c.clock.Now().UnixNano()); err != nil {
+		return err
+	}
+
+	item, found := c.items.get(key)
+	if !found {
+		return ErrKeyNotFound
+	}
+	item.Expiration = expiration
+	c.items.set(key, item)
+	c.changed(key, false)
+
+	return nil
 }
 
 // DeleteExpired removes all expired items from the cache.
 func (c *Cache) DeleteExpired() {
-	now := time.Now().UnixNano()
+	c.deleteExpired()
+}
+
+// deleteExpired is DeleteExpired returning the number of items removed.
+func (c *Cache) deleteExpired() int {
+	var n int
+	var evicted []keyValue
+	var expired []expiredItem
+
+	now := c.clock.Now().UnixNano()
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
-	for k, v := range c.items {
-		if v.Expiration > 0 && now > v.Expiration {
-			delete(c.items, k)
+	c.items.rangeItems(func(k string, v Item) bool {
+		if v.expiredAt(now) {
+			n++
+			exp := c.expire(k, v.Value)
+			if c.onExpired != nil {
+				expired = append(expired, exp)
+			}
+			if c.onEvicted != nil {
+				evicted = append(evicted, keyValue{k, v.Value})
+			}
+		}
+		return true
+	})
+
+	for k, l := range c.leases {
+		if now > l.expiration {
+			delete(c.leases, k)
+		}
+	}
+	for k, expiration := range c.negatives {
+		if now > expiration {
+			delete(c.negatives, k)
 		}
 	}
+
+	c.unlock()
+
+	c.notifyEvicted(evicted)
+	c.notifyExpired(expired)
+	return n
+}
+
+// keyValue is a key and value pair collected under the lock for callbacks
+// that run after it is released.
+type keyValue struct {
+	key   string
+	value interface{}
 }
 
 // Items returns a copy of all unexpired items in the cache.
@@ -171,35 +1137,174 @@ func (c *Cache) Items() map[string]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	items := make(map[string]interface{}, len(c.items))
-	now := time.Now().UnixNano()
+	items := make(map[string]interface{}, c.items.len())
+	now := c.clock.Now().UnixNano()
 
-	for k, v := range c.items {
-		if v.Expiration == 0 || now < v.Expiration {
-			items[k] = v.Value
+	c.items.rangeItems(func(k string, v Item) bool {
+		if !v.expiredAt(now) {
+			items[k] = c.valueOf(v.Value)
 		}
-	}
+		return true
+	})
 
 	return items
 }
 
+// Range calls fn for each unexpired entry in the cache, in no particular
+// order, until fn returns false. The read lock is held during the whole
+// iteration, so fn must not modify the cache and should return quickly;
+// Snapshot gives a view that can be iterated without holding it.
+func (c *Cache) Range(fn func(entry Entry) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now().UnixNano()
+	c.items.rangeItems(func(k string, v Item) bool {
+		if v.expiredAt(now) {
+			return true
+		}
+		v.Value = c.valueOf(v.Value)
+		return fn(Entry{Key: k, Item: v})
+	})
+}
+
+// RangeChunks calls fn with the unexpired entries of the cache in chunks of
+// up to size entries, until fn returns an error, which RangeChunks returns.
+// Unlike Range, no lock is held while fn runs, so fn may block, for example
+// on a slow network peer, without stalling writers. Only the keys are
+// copied up front; each chunk's values are read when the chunk is reached,
+// so the result is not a point-in-time view: keys deleted in the meantime
+// are skipped and keys added in the meantime are not visited. The slice
+// passed to fn is reused for the next chunk.
+func (c *Cache) RangeChunks(size int, fn func(entries []Entry) error) error {
+	return c.rangeChunks(size, func(entries []Entry) error {
+		now := c.clock.Now().UnixNano()
+		live := entries[:0]
+		for _, e := range entries {
+			if !e.expiredAt(now) {
+				e.Value = c.valueOf(e.Value)
+				live = append(live, e)
+			}
+		}
+		if len(live) == 0 {
+			return nil
+		}
+		return fn(live)
+	})
+}
+
+// rangeChunks is RangeChunks for all entries, expired or not, with their
+// values as stored.
+func (c *Cache) rangeChunks(size int, fn func(entries []Entry) error) error {
+	if size <= 0 {
+		size = 1
+	}
+
+	c.mu.RLock()
+	keys := make([]string, 0, c.items.len())
+	c.items.rangeItems(func(k string, _ Item) bool {
+		keys = append(keys, k)
+		return true
+	})
+	c.mu.RUnlock()
+
+	chunk := make([]Entry, 0, min(size, len(keys)))
+	for len(keys) > 0 {
+		n := min(size, len(keys))
+		chunk = chunk[:0]
+		c.mu.RLock()
+		for _, k := range keys[:n] {
+			if item, found := c.items.get(k); found {
+				chunk = append(chunk, Entry{Key: k, Item: item})
+			}
+		}
+		c.mu.RUnlock()
+		keys = keys[n:]
+
+		if len(chunk) == 0 {
+			continue
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ItemCount returns the number of items in the cache, including expired items.
 func (c *Cache) ItemCount() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return len(c.items)
+	return c.items.len()
 }
 
-// Flush removes all items from the cache.
+// Flush removes all items from the cache. Subscribers and watchers
+// receive an EventDeleted for each item removed.
 func (c *Cache) Flush() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items = make(map[string]Item)
+	defer c.unlock()
+
+	c.async.supersedeAll()
+	if c.policy != nil {
+		c.policyMu.Lock()
+		c.items.rangeItems(func(k string, _ Item) bool {
+			c.policy.OnRemove(k)
+			return true
+		})
+		c.policyMu.Unlock()
+	}
+	if c.notifying() {
+		c.items.rangeItems(func(k string, v Item) bool {
+			c.notify(EventDeleted, k, v.Value)
+			return true
+		})
+	}
+	c.items.clear()
+	c.totalCost = 0
+	c.priorities = make(map[int]int)
+	c.keys = nil
+	c.keyHits = nil
+	if c.hotKeys != nil {
+		c.hotKeys.reset()
+	}
+	c.rearmSoftLimits()
+	if c.keyIndex != nil {
+		c.keyIndex = make(map[string]int)
+	}
+	c.negatives = nil
+	c.pinned = nil
+	c.shield.invalidate("", c.clock.Now())
+	c.changed("", true)
 }
 
-// Stop stops the automatic cleanup goroutine.
+// Stop cancels the background reloads of RefreshAhead, applies the writes
+// queued by SetAsync, writes the changes queued by write-behind, stops the
+// automatic cleanup goroutine, the memory watcher, the auto-tuner and the
+// trace being recorded, and closes all notification channels returned by
+// Notifications. Use Close to also shut down subsystems registered with
+// OnShutdown and write Options.SnapshotFile. Stop is safe to call more
+// than once, concurrently, and before or after Close.
 func (c *Cache) Stop() {
-	if c.cleanupInterval > 0 {
-		c.stopCleanup <- true
+	c.stopRefresh(context.Background())
+	c.stopAsync(context.Background())
+	c.drainWriteBehind(context.Background())
+	c.stopJanitor()
+	c.stopMemoryWatcher()
+	c.stopAutoTune()
+	c.stopTrace()
+	c.closeSubscribers()
+}
+
+// stopJanitor stops the automatic cleanup goroutine for good. It is safe to
+// call more than once.
+func (c *Cache) stopJanitor() {
+	j := &c.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stop != nil {
+		close(j.stop)
+		j.stop = nil
 	}
+	j.stopped = true
 }
@@ -1,6 +1,11 @@
 package gocache
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -34,9 +39,11 @@ func TestCacheSetGet(t *testing.T) {
 }
 
 func TestCacheExpiration(t *testing.T) {
+	clock := newFakeClock()
 	cache := New(Options{
 		DefaultExpiration: 100 * time.Millisecond,
 		CleanupInterval:   0, // Disable automatic cleanup for this test
+		Clock:             clock,
 	})
 
 	// Set a key with a short expiration
@@ -55,7 +62,7 @@ func TestCacheExpiration(t *testing.T) {
 	}
 
 	// Wait for the short key to expire
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	// The short key should be expired now
 	_, err = cache.Get("short")
@@ -160,9 +167,11 @@ func TestCacheConcurrency(t *testing.T) {
 }
 
 func TestCacheDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
 	cache := New(Options{
 		DefaultExpiration: 100 * time.Millisecond,
 		CleanupInterval:   0, // Disable automatic cleanup for this test
+		Clock:             clock,
 	})
 
 	// Add items with expiration
@@ -171,7 +180,7 @@ func TestCacheDeleteExpired(t *testing.T) {
 	cache.SetWithExpiration("key3", "value3", 1*time.Hour)
 
 	// Wait for default expiration
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	// Verify count before deletion
 	count := cache.ItemCount()
@@ -249,6 +258,42 @@ func TestCacheNilValue(t *testing.T) {
 	}
 }
 
+func TestAllowNilValues(t *testing.T) {
+	for _, storage := range []Storage{MapStorage, BytesStorage, ArenaStorage} {
+		c := New(Options{AllowNilValues: true, Storage: storage})
+		defer c.Stop()
+
+		if err := c.Set("avatar", nil); err != nil {
+			t.Fatalf("storage %d: Set(nil) error = %v", storage, err)
+		}
+		if v, err := c.Get("avatar"); err != nil || v != nil {
+			t.Fatalf("storage %d: Get = %v, %v, want nil, nil", storage, v, err)
+		}
+		if _, err := c.Get("missing"); err != ErrKeyNotFound {
+			t.Fatalf("storage %d: Get(missing) error = %v, want ErrKeyNotFound", storage, err)
+		}
+	}
+}
+
+func TestAllowNilValuesSnapshot(t *testing.T) {
+	c := New(Options{AllowNilValues: true})
+	defer c.Stop()
+	c.Set("avatar", nil)
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(Options{AllowNilValues: true})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := restored.Get("avatar"); err != nil || v != nil {
+		t.Fatalf("Get = %v, %v, want nil, nil", v, err)
+	}
+}
+
 func BenchmarkCacheGet(b *testing.B) {
 	cache := New(Options{DefaultExpiration: time.Minute})
 	cache.Set("key", "value")
@@ -267,3 +312,303 @@ func BenchmarkCacheSet(b *testing.B) {
 		cache.Set("key", "value")
 	}
 }
+
+func TestCacheExpiredOverwrite(t *testing.T) {
+	var replaced, evicted []string
+	clock := newFakeClock()
+	options := Options{
+		Clock:             clock,
+		DefaultExpiration: 50 * time.Millisecond,
+		OnReplaced: func(key string, oldValue, newValue interface{}) {
+			replaced = append(replaced, key)
+		},
+		OnEvicted: func(key string, value interface{}) {
+			evicted = append(evicted, key)
+		},
+	}
+
+	cache := New(options)
+	cache.Set("key1", "old")
+	clock.Advance(100 * time.Millisecond)
+	cache.Set("key1", "new")
+
+	if len(replaced) != 1 || len(evicted) != 0 {
+		t.Errorf("Expected replacement only, got replaced=%v evicted=%v", replaced, evicted)
+	}
+	if stats := cache.Stats(); stats.ExpiredOverwrites != 1 {
+		t.Errorf("Expected 1 expired overwrite, got %d", stats.ExpiredOverwrites)
+	}
+
+	replaced, evicted = nil, nil
+	options.ExpiredOverwrite = ExpiredOverwriteEvict
+	cache = New(options)
+	cache.Set("key1", "old")
+	clock.Advance(100 * time.Millisecond)
+	cache.Set("key1", "new")
+
+	if len(replaced) != 0 || len(evicted) != 1 {
+		t.Errorf("Expected eviction only, got replaced=%v evicted=%v", replaced, evicted)
+	}
+	stats := cache.Stats()
+	if stats.ExpiredOverwrites != 1 || stats.Expirations != 1 {
+		t.Errorf("Expected 1 expired overwrite and 1 expiration, got %+v", stats)
+	}
+}
+
+func TestCacheGetOrSetCallOptions(t *testing.T) {
+	cache := New(Options{DefaultExpiration: time.Minute})
+	cache.Set("key", "cached")
+
+	computeCount := 0
+	getValue := func() (interface{}, error) {
+		computeCount++
+		return "fresh", nil
+	}
+
+	value, _ := cache.GetOrSet("key", getValue, WithNoStore())
+	if value != "cached" || computeCount != 0 {
+		t.Errorf("Expected cached value without compute, got '%v' (%d computes)", value, computeCount)
+	}
+
+	value, _ = cache.GetOrSet("key", getValue, WithBypassCache(), WithNoStore())
+	if value != "fresh" || computeCount != 1 {
+		t.Errorf("Expected fresh value, got '%v' (%d computes)", value, computeCount)
+	}
+	if value, _ := cache.Get("key"); value != "cached" {
+		t.Errorf("Expected WithNoStore to keep 'cached', got '%v'", value)
+	}
+
+	value, _ = cache.GetOrSet("key", getValue, WithBypassCache())
+	if value != "fresh" || computeCount != 2 {
+		t.Errorf("Expected fresh value, got '%v' (%d computes)", value, computeCount)
+	}
+	if value, _ := cache.Get("key"); value != "fresh" {
+		t.Errorf("Expected WithBypassCache to store 'fresh', got '%v'", value)
+	}
+}
+
+func TestCacheRange(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.SetWithExpiration("expired", "gone", time.Second)
+	clock.Advance(time.Minute)
+
+	seen := make(map[string]interface{})
+	cache.Range(func(e Entry) bool {
+		seen[e.Key] = e.Value
+		return true
+	})
+	if len(seen) != 2 || seen["key1"] != "value1" || seen["key2"] != "value2" {
+		t.Errorf("Expected the two live entries, got %v", seen)
+	}
+
+	calls := 0
+	cache.Range(func(Entry) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Expected Range to stop after fn returned false, got %d calls", calls)
+	}
+}
+
+func TestCacheRangeChunks(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	for i := 0; i < 25; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	cache.SetWithExpiration("expired", "gone", time.Second)
+	clock.Advance(time.Minute)
+
+	seen := make(map[string]interface{})
+	err := cache.RangeChunks(10, func(entries []Entry) error {
+		if len(entries) > 10 {
+			t.Errorf("Expected chunks of at most 10 entries, got %d", len(entries))
+		}
+		for _, e := range entries {
+			seen[e.Key] = e.Value
+			// No lock is held, so the cache may be modified from fn.
+			cache.Delete(e.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RangeChunks failed: %v", err)
+	}
+	if len(seen) != 25 || seen["key7"] != 7 {
+		t.Errorf("Expected the 25 live entries, got %v", seen)
+	}
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	stop := errors.New("stop")
+	calls := 0
+	err = cache.RangeChunks(1, func([]Entry) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected RangeChunks to stop with fn's error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCacheSetContext(t *testing.T) {
+	// Context deadlines are wall clock times, so start the fake clock now.
+	clock := &fakeClock{now: time.Now()}
+	cache := New(Options{Clock: clock, DefaultExpiration: time.Hour, ContextDeadlineTTL: true})
+	defer cache.Stop()
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Minute))
+	defer cancel()
+	if err := cache.SetContext(ctx, "request", "artifact"); err != nil {
+		t.Fatalf("SetContext failed: %v", err)
+	}
+	if err := cache.SetContext(context.Background(), "plain", "value"); err != nil {
+		t.Fatalf("SetContext failed: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("request"); err != ErrKeyExpired {
+		t.Errorf("Expected item to expire with the context deadline, got %v", err)
+	}
+	if _, err := cache.Get("plain"); err != nil {
+		t.Errorf("Expected item without deadline to use the default expiration, got %v", err)
+	}
+
+	cancel()
+	if err := cache.SetContext(ctx, "late", "value"); err != context.Canceled {
+		t.Errorf("Expected context error, got %v", err)
+	}
+
+	// Without the option, the deadline does not shorten the TTL.
+	clock = &fakeClock{now: time.Now()}
+	other := New(Options{Clock: clock})
+	defer other.Stop()
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(time.Minute))
+	defer cancel()
+	other.SetContext(ctx, "key", "value")
+	clock.Advance(2 * time.Minute)
+	if _, err := other.Get("key"); err != nil {
+		t.Errorf("Expected item not to inherit the deadline, got %v", err)
+	}
+}
+
+func TestCacheGetWithExpiration(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("forever", "value")
+	cache.SetWithExpiration("short", "value", time.Minute)
+
+	if _, expires, err := cache.GetWithExpiration("forever"); err != nil || !expires.IsZero() {
+		t.Errorf("Expected no expiration, got %v (%v)", expires, err)
+	}
+	if _, expires, err := cache.GetWithExpiration("short"); err != nil || !expires.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Expected expiration in a minute, got %v (%v)", expires, err)
+	}
+	if _, _, err := cache.GetWithExpiration("missing"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestCacheDeleteWhere(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+	events := cache.Notifications(10)
+
+	cache.Set("tenant:a:1", 1)
+	cache.Set("tenant:a:2", 2)
+	cutoff := clock.Now()
+	clock.Advance(time.Minute)
+	cache.Set("tenant:a:3", 3)
+	cache.Set("tenant:b:1", 4)
+	cache.Set("leased:tenant:a", 5)
+	if _, err := cache.Lease("leased:tenant:a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for len(events) > 0 {
+		<-events
+	}
+
+	n := cache.DeleteWhere(func(key string, item Item) bool {
+		return strings.HasPrefix(key, "tenant:a:") && !time.Unix(0, item.Modified).After(cutoff)
+	})
+	if n != 2 {
+		t.Errorf("Expected 2 items removed, got %d", n)
+	}
+	for _, key := range []string{"tenant:a:1", "tenant:a:2"} {
+		if _, err := cache.Get(key); err != ErrKeyNotFound {
+			t.Errorf("Expected %s to be removed, got %v", key, err)
+		}
+	}
+	if n := cache.ItemCount(); n != 3 {
+		t.Errorf("Expected 3 items left, got %d", n)
+	}
+	if n := len(events); n != 2 {
+		t.Errorf("Expected 2 delete events, got %d", n)
+	}
+
+	if n := cache.DeleteWhere(func(key string, _ Item) bool { return true }); n != 2 {
+		t.Errorf("Expected all but the leased item removed, got %d", n)
+	}
+	if _, err := cache.Get("leased:tenant:a"); err != nil {
+		t.Errorf("Expected the leased item to be kept, got %v", err)
+	}
+}
+
+func TestCacheDeleteWhereAsync(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	for i := 0; i < 5000; i++ {
+		cache.Set(fmt.Sprintf("k%d", i), i)
+	}
+	for i := 0; i < 5000; i++ {
+		cache.SetAsync(fmt.Sprintf("k%d", i), -i)
+	}
+	if n := cache.DeleteWhere(func(string, Item) bool { return true }); n != 5000 {
+		t.Errorf("Expected 5000 items removed, got %d", n)
+	}
+
+	if _, err := cache.Get("k4999"); err != ErrKeyNotFound {
+		t.Errorf("Expected the queued write to be superseded, got %v", err)
+	}
+	if err := cache.FlushAsync(context.Background()); err != nil {
+		t.Fatalf("FlushAsync failed: %v", err)
+	}
+	if n := cache.ItemCount(); n != 0 {
+		t.Errorf("Expected no items, got %d", n)
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.SetWithPriority("key", "value", time.Minute, 2)
+	entry, found := cache.Peek("key")
+	if !found || entry.Key != "key" || entry.Value != "value" || entry.Priority != 2 {
+		t.Errorf("Expected key with priority 2, got %+v (found %v)", entry, found)
+	}
+	if want := clock.Now().Add(time.Minute).UnixNano(); entry.Expiration != want {
+		t.Errorf("Expected expiration %d, got %d", want, entry.Expiration)
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Expected Peek not to count hits or misses, got %+v", stats)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, found := cache.Peek("key"); found {
+		t.Error("Expected Peek not to return an expired item")
+	}
+}
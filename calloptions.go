@@ -0,0 +1,37 @@
+package gocache
+
+// CallOption modifies the behavior of a single cache call, similar to
+// Cache-Control directives on an HTTP request.
+type CallOption func(*callOptions)
+
+// callOptions holds the per-call settings applied by CallOptions.
+type callOptions struct {
+	bypassCache bool
+	noStore     bool
+}
+
+// WithBypassCache skips reading the cache: the value is always computed
+// fresh and then stored, like Cache-Control: no-cache. Use it to honor
+// request-level refresh flags such as ?refresh=1.
+func WithBypassCache() CallOption {
+	return func(o *callOptions) {
+		o.bypassCache = true
+	}
+}
+
+// WithNoStore reads from the cache as usual, but a computed value is not
+// stored, like Cache-Control: no-store.
+func WithNoStore() CallOption {
+	return func(o *callOptions) {
+		o.noStore = true
+	}
+}
+
+// applyCallOptions returns the settings produced by opts.
+func applyCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,144 @@
+package gocache
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+)
+
+// Add stores value under key only if the key is missing or expired, and
+// returns ErrKeyExists otherwise.
+func (c *Cache) Add(key string, value interface{}, duration time.Duration) error {
+	return c.setWhen(key, value, duration, func(found bool) error {
+		if found {
+			return ErrKeyExists
+		}
+		return nil
+	})
+}
+
+// Replace stores value under key only if the key holds an unexpired item,
+// and returns ErrKeyNotFound otherwise.
+func (c *Cache) Replace(key string, value interface{}, duration time.Duration) error {
+	return c.setWhen(key, value, duration, func(found bool) error {
+		if !found {
+			return ErrKeyNotFound
+		}
+		return nil
+	})
+}
+
+// setWhen is SetWithExpiration for a value that is only stored if check
+// returns nil for whether key holds an unexpired item.
+func (c *Cache) setWhen(key string, value interface{}, duration time.Duration, check func(found bool) error) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	var expiration int64
+	if duration > 0 {
+		expiration = c.clock.Now().Add(duration).UnixNano()
+	}
+
+	var checkErr error
+	_, err := c.setIf(key, value, expiration, 0, 0, func(_ Item, found bool) bool {
+		checkErr = check(found)
+		return checkErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return checkErr
+}
+
+// CompareAndSwap stores new under key if the key holds an unexpired item
+// whose value equals old, keeping its expiration and priority, and reports
+// whether it did. Values are compared with ==, except that byte slices are
+// compared by content; other values that cannot be compared with ==, such
+// as maps, are never equal.
+func (c *Cache) CompareAndSwap(key string, old, new interface{}) (bool, error) {
+	return c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		if !found || !equalValues(value, old) {
+			return nil, false, nil
+		}
+		return new, true, nil
+	})
+}
+
+// modify atomically replaces the value of key with the result of fn, which
+// is called with the current value and whether the key holds an unexpired
+// item. If fn returns false, the item is left unchanged. A replaced item
+// keeps its expiration and priority; a new one gets the default
+// expiration. It reports whether a value was stored.
+//
+// fn runs without the lock held and is called again if another writer
+// changed the item in the meantime.
+func (c *Cache) modify(key string, fn func(value interface{}, found bool) (interface{}, bool, error)) (bool, error) {
+	return c.modifyExpiring(key, c.defaultExpiration(), fn)
+}
+
+// modifyExpiring is modify giving a new item the expiration duration
+// instead of the default expiration.
+func (c *Cache) modifyExpiring(key string, duration time.Duration, fn func(value interface{}, found bool) (interface{}, bool, error)) (bool, error) {
+	for {
+		c.applyQueued(key)
+		now := c.clock.Now().UnixNano()
+
+		c.mu.RLock()
+		old, found := c.items.get(key)
+		c.mu.RUnlock()
+		if found && old.expiredAt(now) {
+			found = false
+		}
+
+		var value interface{}
+		if found {
+			var err error
+			if value, err = c.expand(old.Value); err != nil {
+				return false, err
+			}
+		}
+		value, ok, err := fn(value, found)
+		if err != nil || !ok {
+			return false, err
+		}
+
+		expiration, priority := old.Expiration, old.Priority
+		if !found {
+			expiration, priority = 0, 0
+			if duration > 0 {
+				expiration = now + int64(duration)
+			}
+		}
+		// Store the value only if the item is still the one read above;
+		// otherwise another writer got in between and fn is retried.
+		stored, err := c.setIf(key, value, expiration, 0, priority, func(cur Item, curFound bool) bool {
+			if !found {
+				return !curFound
+			}
+			return curFound && cur.Version == old.Version && cur.Expiration == old.Expiration
+		})
+		if err != nil || stored {
+			return stored, err
+		}
+	}
+}
+
+// equalValues reports whether a and b are equal for CompareAndSwap.
+func equalValues(a, b interface{}) (equal bool) {
+	// Comparable types can still hold incomparable values in interface
+	// fields, which make == panic.
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+
+	if ab, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(ab, bb)
+	}
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return a == nil && b == nil
+	}
+	return a == b
+}
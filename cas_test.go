@@ -0,0 +1,96 @@
+package gocache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheAddReplace(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if err := cache.Replace("key", "value", 0); err != ErrKeyNotFound {
+		t.Errorf("Expected Replace of a missing key to fail with ErrKeyNotFound, got %v", err)
+	}
+	if err := cache.Add("key", "value", time.Minute); err != nil {
+		t.Errorf("Expected Add of a missing key to succeed, got %v", err)
+	}
+	if err := cache.Add("key", "other", 0); err != ErrKeyExists {
+		t.Errorf("Expected Add of an existing key to fail with ErrKeyExists, got %v", err)
+	}
+	if err := cache.Replace("key", "replaced", 0); err != nil {
+		t.Errorf("Expected Replace of an existing key to succeed, got %v", err)
+	}
+	if value, _ := cache.Get("key"); value != "replaced" {
+		t.Errorf("Expected replaced, got %v", value)
+	}
+
+	cache.SetWithExpiration("expired", "old", time.Second)
+	clock.Advance(time.Minute)
+	if err := cache.Add("expired", "new", 0); err != nil {
+		t.Errorf("Expected Add over an expired item to succeed, got %v", err)
+	}
+}
+
+func TestCacheCompareAndSwap(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if swapped, err := cache.CompareAndSwap("missing", "a", "b"); swapped || err != nil {
+		t.Errorf("Expected no swap for a missing key, got %v (%v)", swapped, err)
+	}
+
+	cache.SetWithExpiration("key", "a", time.Minute)
+	if swapped, _ := cache.CompareAndSwap("key", "x", "b"); swapped {
+		t.Error("Expected no swap for a different old value")
+	}
+	if swapped, _ := cache.CompareAndSwap("key", "a", "b"); !swapped {
+		t.Error("Expected a swap for the current old value")
+	}
+	if value, _ := cache.Get("key"); value != "b" {
+		t.Errorf("Expected b, got %v", value)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("key"); err != ErrKeyExpired {
+		t.Errorf("Expected CompareAndSwap to keep the expiration, got %v", err)
+	}
+
+	cache.Set("bytes", []byte("abc"))
+	if swapped, _ := cache.CompareAndSwap("bytes", []byte("abc"), []byte("def")); !swapped {
+		t.Error("Expected byte slices to be compared by content")
+	}
+	cache.Set("map", map[string]int{})
+	if swapped, _ := cache.CompareAndSwap("map", map[string]int{}, "x"); swapped {
+		t.Error("Expected maps never to compare equal")
+	}
+}
+
+func TestCacheCompareAndSwapConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("counter", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for {
+					value, _ := cache.Get("counter")
+					if swapped, _ := cache.CompareAndSwap("counter", value, value.(int)+1); swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("counter"); value != 1000 {
+		t.Errorf("Expected 1000, got %v", value)
+	}
+}
@@ -0,0 +1,98 @@
+package gocache
+
+import (
+	"sync"
+)
+
+// ChangeFeed collects the keys of a cache changed since they were last
+// taken, for mirroring the cache elsewhere, as the replication package
+// does. Keys changed several times in between are reported once, so a
+// slow consumer reads their current state with Peek and the feed never
+// grows beyond the number of keys in the cache.
+//
+// A change is a Set, Delete, expiration, eviction or change of expiration
+// of a key, or a Flush of the whole cache.
+type ChangeFeed struct {
+	cache *Cache
+
+	mu      sync.Mutex
+	keys    map[string]struct{}
+	flushed bool
+	ready   chan struct{} // has a value when there are changes to take
+}
+
+// changeFeeds are the feeds of a cache.
+type changeFeeds struct {
+	mu    sync.RWMutex
+	feeds map[*ChangeFeed]struct{}
+}
+
+// NewChangeFeed returns a feed of the changes made to the cache from now
+// on. It must be closed with Close when no longer needed.
+func (c *Cache) NewChangeFeed() *ChangeFeed {
+	f := &ChangeFeed{cache: c, keys: make(map[string]struct{}), ready: make(chan struct{}, 1)}
+	c.feeds.mu.Lock()
+	if c.feeds.feeds == nil {
+		c.feeds.feeds = make(map[*ChangeFeed]struct{})
+	}
+	c.feeds.feeds[f] = struct{}{}
+	c.feeds.mu.Unlock()
+	return f
+}
+
+// Ready returns a channel that receives a value when there are changes to
+// take.
+func (f *ChangeFeed) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Take returns and resets the keys changed since the last call. If the
+// cache was flushed in the meantime, flushed is set and keys holds only
+// the keys changed after the last flush.
+func (f *ChangeFeed) Take() (keys []string, flushed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key := range f.keys {
+		keys = append(keys, key)
+	}
+	flushed = f.flushed
+	f.keys = make(map[string]struct{})
+	f.flushed = false
+	return keys, flushed
+}
+
+// Close stops recording changes.
+func (f *ChangeFeed) Close() {
+	f.cache.feeds.mu.Lock()
+	delete(f.cache.feeds.feeds, f)
+	f.cache.feeds.mu.Unlock()
+}
+
+// mark records a change of key, or of all keys if flushed is set.
+func (f *ChangeFeed) mark(key string, flushed bool) {
+	f.mu.Lock()
+	if flushed {
+		f.flushed = true
+		f.keys = make(map[string]struct{})
+	} else {
+		f.keys[key] = struct{}{}
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// changed records a change of key, or of all keys if flushed is set, in
+// every feed.
+func (c *Cache) changed(key string, flushed bool) {
+	c.feeds.mu.RLock()
+	defer c.feeds.mu.RUnlock()
+
+	for f := range c.feeds.feeds {
+		f.mark(key, flushed)
+	}
+}
@@ -0,0 +1,45 @@
+package gocache
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestChangeFeed(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("before", 1)
+
+	feed := cache.NewChangeFeed()
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	cache.SetWithExpiration("b", 1, time.Minute)
+	cache.ExpireAt("b", time.Now().Add(time.Hour))
+	cache.Delete("before")
+
+	select {
+	case <-feed.Ready():
+	default:
+		t.Fatal("Expected the feed to be ready")
+	}
+	keys, flushed := feed.Take()
+	sort.Strings(keys)
+	if flushed || len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "before" {
+		t.Errorf("Expected a, b and before to be reported once, got %v (flushed %v)", keys, flushed)
+	}
+
+	cache.Set("c", 1)
+	cache.Flush()
+	cache.Set("d", 1)
+	keys, flushed = feed.Take()
+	if !flushed || len(keys) != 1 || keys[0] != "d" {
+		t.Errorf("Expected a flush followed by d, got %v (flushed %v)", keys, flushed)
+	}
+
+	feed.Close()
+	cache.Set("e", 1)
+	if keys, flushed := feed.Take(); len(keys) != 0 || flushed {
+		t.Errorf("Expected no changes after Close, got %v (flushed %v)", keys, flushed)
+	}
+}
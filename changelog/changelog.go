@@ -0,0 +1,235 @@
+// Package changelog records the changes made to a cache in a log, such as
+// a compacted Kafka topic, and rebuilds a cache by replaying that log. A
+// service that writes through a Log and replays the topic on startup keeps
+// its cache as a materialized view of the topic, in the manner of a Kafka
+// Streams state store.
+//
+// Each change is a Record keyed by the cache key. A Set produces the
+// encoded value; a Delete produces a tombstone, a record without a value,
+// so that log compaction eventually drops the key. The package does not
+// depend on a Kafka client: Producer and Consumer are small enough to be
+// implemented over any of them. With github.com/segmentio/kafka-go:
+//
+//	type producer struct{ w *kafka.Writer }
+//
+//	func (p producer) Produce(ctx context.Context, r changelog.Record) error {
+//		m := kafka.Message{Key: r.Key, Value: r.Value}
+//		for _, h := range r.Headers {
+//			m.Headers = append(m.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+//		}
+//		return p.w.WriteMessages(ctx, m)
+//	}
+//
+//	type consumer struct{ r *kafka.Reader } // reading the topic from offset 0
+//
+//	func (c consumer) Consume(ctx context.Context) (changelog.Record, error) {
+//		if lag, err := c.r.ReadLag(ctx); err != nil || lag == 0 {
+//			return changelog.Record{}, io.EOF // caught up (or failed)
+//		}
+//		m, err := c.r.ReadMessage(ctx)
+//		...convert m as above...
+//	}
+package changelog
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// ExpirationHeader is the record header carrying the expiration time of a
+// value, in Unix nanoseconds. Values that never expire have no such header.
+const ExpirationHeader = "gocache-expiration"
+
+// Header is a record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is an entry of the changelog. A Record with a nil Value is a
+// tombstone recording the deletion of Key.
+type Record struct {
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// Producer appends records to the log.
+type Producer interface {
+	// Produce appends r to the log, returning once it is stored. Records
+	// with the same key must be kept in the order they are produced, as
+	// Kafka does within a partition.
+	Produce(ctx context.Context, r Record) error
+}
+
+// Consumer reads the log in order.
+type Consumer interface {
+	// Consume returns the next record. It returns io.EOF when there are no
+	// more records to replay, for example once it has caught up with the
+	// end of the topic; a consumer that blocks for new records instead
+	// makes Replay follow the log until ctx is done.
+	Consume(ctx context.Context) (Record, error)
+}
+
+// DefaultProduceTimeout is the timeout used when Options.ProduceTimeout is
+// 0.
+const DefaultProduceTimeout = 5 * time.Second
+
+// Options configures a Log and Replay. Zero values select the defaults.
+type Options struct {
+	// Codec encodes values in records. It defaults to gocache.GobCodec, so
+	// custom types must be registered with gob.Register. A Log and the
+	// Replay of its records must use the same codec.
+	Codec gocache.Codec
+
+	// ProduceTimeout bounds each Produce call.
+	ProduceTimeout time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Codec == nil {
+		o.Codec = gocache.GobCodec
+	}
+	if o.ProduceTimeout <= 0 {
+		o.ProduceTimeout = DefaultProduceTimeout
+	}
+	return o
+}
+
+// stripes is the number of locks serializing writes to the same key.
+const stripes = 64
+
+// Log applies changes to a cache and records them with a Producer.
+type Log struct {
+	cache    *gocache.Cache
+	producer Producer
+	options  Options
+
+	locks [stripes]sync.Mutex
+}
+
+// New returns a Log recording the changes made through it to c with p.
+func New(c *gocache.Cache, p Producer, options Options) *Log {
+	return &Log{cache: c, producer: p, options: options.withDefaults()}
+}
+
+// Set stores value under key with the cache's default expiration and
+// records it.
+func (l *Log) Set(key string, value interface{}) error {
+	return l.set(key, value, func() error { return l.cache.Set(key, value) })
+}
+
+// SetWithExpiration stores value under key for duration and records it
+// with its expiration time.
+func (l *Log) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	return l.set(key, value, func() error { return l.cache.SetWithExpiration(key, value, duration) })
+}
+
+// set stores a value with store and produces a record of it. The key's
+// lock keeps concurrent writes to a key in the same order in the cache and
+// in the log.
+func (l *Log) set(key string, value interface{}, store func() error) error {
+	data, err := l.options.Codec.Marshal(&value)
+	if err != nil {
+		return err
+	}
+
+	mu := l.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := store(); err != nil {
+		return err
+	}
+	r := Record{Key: []byte(key), Value: data}
+	if _, expires, err := l.cache.GetWithExpiration(key); err == nil && !expires.IsZero() {
+		r.Headers = []Header{{Key: ExpirationHeader, Value: []byte(strconv.FormatInt(expires.UnixNano(), 10))}}
+	}
+	return l.produce(r)
+}
+
+// Delete removes key from the cache and records a tombstone for it. The
+// tombstone is produced even if the key was not cached.
+func (l *Log) Delete(key string) error {
+	mu := l.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	l.cache.Delete(key)
+	return l.produce(Record{Key: []byte(key)})
+}
+
+func (l *Log) produce(r Record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.options.ProduceTimeout)
+	defer cancel()
+	return l.producer.Produce(ctx, r)
+}
+
+func (l *Log) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l.locks[h.Sum32()%stripes]
+}
+
+// Replay applies the records read from consumer to c until the consumer
+// returns io.EOF, and returns the number of records applied. Values are
+// stored with the expiration recorded in their ExpirationHeader; values
+// that have expired since, and tombstones, delete their key. To rebuild a
+// cache, replay the log from its beginning into an empty cache.
+//
+// If the consumer or the cache fails, Replay stops and returns the error,
+// and the records applied so far stay in the cache.
+func Replay(ctx context.Context, c *gocache.Cache, consumer Consumer, options Options) (int, error) {
+	options = options.withDefaults()
+	n := 0
+	for {
+		r, err := consumer.Consume(ctx)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := apply(c, r, options.Codec); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// apply applies one record to c.
+func apply(c *gocache.Cache, r Record, codec gocache.Codec) error {
+	key := string(r.Key)
+	if r.Value == nil {
+		c.Delete(key)
+		return nil
+	}
+
+	var value interface{}
+	if err := codec.Unmarshal(r.Value, &value); err != nil {
+		return err
+	}
+	for _, h := range r.Headers {
+		if h.Key != ExpirationHeader {
+			continue
+		}
+		nanos, err := strconv.ParseInt(string(h.Value), 10, 64)
+		if err != nil {
+			return errors.New("changelog: invalid " + ExpirationHeader + " header")
+		}
+		ttl := time.Until(time.Unix(0, nanos))
+		if ttl <= 0 {
+			c.Delete(key)
+			return nil
+		}
+		return c.SetWithExpiration(key, value, ttl)
+	}
+	return c.SetWithExpiration(key, value, 0)
+}
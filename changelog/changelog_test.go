@@ -0,0 +1,120 @@
+package changelog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// topic is an in-memory log implementing Producer, with consumers reading
+// it from the beginning.
+type topic struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (t *topic) Produce(ctx context.Context, r Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, r)
+	return nil
+}
+
+// consumer reads a topic up to its end.
+type consumer struct {
+	t      *topic
+	offset int
+}
+
+func (c *consumer) Consume(ctx context.Context) (Record, error) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+	if c.offset == len(c.t.records) {
+		return Record{}, io.EOF
+	}
+	c.offset++
+	return c.t.records[c.offset-1], nil
+}
+
+func TestLogAndReplay(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	log := &topic{}
+	l := New(cache, log, Options{Codec: gocache.JSONCodec})
+
+	l.Set("a", "1")
+	l.Set("b", "2")
+	l.SetWithExpiration("c", "3", time.Hour)
+	l.Set("a", "updated")
+	l.Delete("b")
+
+	if n := len(log.records); n != 5 {
+		t.Fatalf("Expected 5 records, got %d", n)
+	}
+	if r := log.records[4]; string(r.Key) != "b" || r.Value != nil {
+		t.Errorf("Expected a tombstone for b, got %+v", r)
+	}
+	if r := log.records[2]; len(r.Headers) != 1 || r.Headers[0].Key != ExpirationHeader {
+		t.Errorf("Expected an expiration header for c, got %+v", r.Headers)
+	}
+
+	rebuilt := gocache.New(gocache.Options{})
+	defer rebuilt.Stop()
+	rebuilt.Set("b", "stale")
+	n, err := Replay(context.Background(), rebuilt, &consumer{t: log}, Options{Codec: gocache.JSONCodec})
+	if err != nil || n != 5 {
+		t.Fatalf("Expected 5 records replayed, got %d (%v)", n, err)
+	}
+	if value, err := rebuilt.Get("a"); err != nil || value != "updated" {
+		t.Errorf("Expected updated, got %v (%v)", value, err)
+	}
+	if _, err := rebuilt.Get("b"); err == nil {
+		t.Error("Expected b to be deleted by its tombstone")
+	}
+	_, expires, err := rebuilt.GetWithExpiration("c")
+	if err != nil || expires.IsZero() || time.Until(expires) > time.Hour {
+		t.Errorf("Expected c to expire within an hour, got %v (%v)", expires, err)
+	}
+	if _, expires, _ := rebuilt.GetWithExpiration("a"); !expires.IsZero() {
+		t.Errorf("Expected a to never expire, got %v", expires)
+	}
+}
+
+func TestReplayExpired(t *testing.T) {
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).UnixNano(), 10)
+	v := interface{}("new")
+	value, _ := gocache.GobCodec.Marshal(&v)
+	log := &topic{records: []Record{
+		{Key: []byte("k"), Value: value, Headers: []Header{{Key: ExpirationHeader, Value: []byte(past)}}},
+	}}
+
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	cache.Set("k", "old")
+	if _, err := Replay(context.Background(), cache, &consumer{t: log}, Options{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if _, err := cache.Get("k"); err == nil {
+		t.Error("Expected an expired record to delete its key")
+	}
+}
+
+type failingConsumer struct{}
+
+func (failingConsumer) Consume(ctx context.Context) (Record, error) {
+	return Record{}, errors.New("broker unavailable")
+}
+
+func TestReplayError(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	if _, err := Replay(context.Background(), cache, failingConsumer{}, Options{}); err == nil {
+		t.Error("Expected the consumer's error")
+	}
+}
@@ -0,0 +1,45 @@
+package gocache
+
+import (
+	"time"
+)
+
+// Clock provides the current time and tickers to the cache. It can be
+// replaced through Options.Clock so tests can control time instead of
+// sleeping while items expire.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on a channel at intervals, like time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Clock returns the clock of the cache: Options.Clock, or the system clock.
+// Servers built on the cache use it to convert between relative and
+// absolute expirations.
+func (c *Cache) Clock() Clock {
+	return c.clock
+}
+
+// systemClock is the default Clock, backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
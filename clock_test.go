@@ -0,0 +1,105 @@
+package gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTicker{c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing any tickers that come due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		for !t.stopped.Load() && !t.next.After(f.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped atomic.Bool // Stop may be called concurrently with Advance
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+func (t *fakeTicker) Stop()               { t.stopped.Store(true) }
+
+func TestCacheFakeClockCleanup(t *testing.T) {
+	clock := newFakeClock()
+	events := make(chan Event, 1)
+	cache := New(Options{
+		DefaultExpiration: time.Minute,
+		CleanupInterval:   time.Minute,
+		Clock:             clock,
+	})
+	defer cache.Stop()
+
+	cache.Set("key", "value")
+	notifications := cache.Notifications(1)
+	go func() {
+		for ev := range notifications {
+			if ev.Type == EventExpired {
+				events <- ev
+			}
+		}
+	}()
+
+	clock.Advance(2 * time.Minute)
+
+	select {
+	case ev := <-events:
+		if ev.Key != "key" || !ev.Time.Equal(clock.Now()) {
+			t.Errorf("Unexpected expiration event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected cleanup to run when the fake clock advanced")
+	}
+}
+
+// waitUntil polls cond until it holds or a second has passed.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
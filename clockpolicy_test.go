@@ -0,0 +1,74 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClockPolicy(t *testing.T) {
+	p := NewClockPolicy()
+	for _, key := range []string{"a", "b", "c"} {
+		p.OnAdd(key)
+	}
+	p.OnAccess("a")
+
+	if victim, ok := p.Victim(); !ok || victim != "b" {
+		t.Fatalf("Expected victim b, got %q, %v", victim, ok)
+	}
+	p.OnRemove("b")
+
+	// d reuses the slot of b behind the hand, so it gets a full turn.
+	p.OnAdd("d")
+	p.OnAccess("c")
+	if victim, _ := p.Victim(); victim != "a" {
+		t.Fatalf("Expected victim a, got %q", victim)
+	}
+	p.OnRemove("a")
+
+	p.OnAccess("c")
+	p.OnAccess("d")
+	if victim, _ := p.Victim(); victim != "d" {
+		t.Fatalf("Expected victim d when all were referenced, got %q", victim)
+	}
+
+	for _, key := range []string{"c", "d"} {
+		p.OnRemove(key)
+	}
+	if _, ok := p.Victim(); ok {
+		t.Fatal("Expected no victim from an empty policy")
+	}
+}
+
+func TestCacheClockChurn(t *testing.T) {
+	c := New(Options{MaxEntries: 3, EvictionPolicy: NewClockPolicy()})
+	defer c.Stop()
+
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprint("old", i), i)
+	}
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprint("new", i), i)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(fmt.Sprint("new", i)); err != nil {
+			t.Errorf("Expected new%d to replace an old key, got %v", i, err)
+		}
+	}
+}
+
+func TestCacheClockEviction(t *testing.T) {
+	c := New(Options{MaxEntries: 2, EvictionPolicy: NewClockPolicy()})
+	defer c.Stop()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.Get("key1")
+	c.Set("key3", "value3")
+
+	if _, err := c.Get("key2"); err != ErrKeyNotFound {
+		t.Fatalf("Expected key2 to be evicted, got %v", err)
+	}
+	if _, err := c.Get("key1"); err != nil {
+		t.Fatalf("Expected key1 to remain, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package gocache
+
+import (
+	"fmt"
+	"time"
+)
+
+// cloneChunkSize is the number of items Clone reads at a time.
+const cloneChunkSize = 1024
+
+// Clone returns a new cache created with options that holds the unexpired
+// items of c, with their expiration times and priorities, for use in tests,
+// canary comparisons or to fork a cache before mutating it. Stats, leases,
+// subscribers and pending OnExpired callbacks are not copied, and cloned
+// values count as set when they were cloned.
+//
+// The copy is shallow: both caches hold the same values, so mutating a value
+// in one is visible in the other. For a deep copy, set options.CopyOnGet or
+// options.Clone; the values are then copied into the new cache too.
+//
+// Items are read in chunks, as by RangeChunks, so a cache modified during
+// the call is not copied at a single point in time.
+func (c *Cache) Clone(options Options) (*Cache, error) {
+	clone := New(options)
+	err := c.RangeChunks(cloneChunkSize, func(entries []Entry) error {
+		now := c.clock.Now()
+		for _, e := range entries {
+			var duration time.Duration
+			if e.Expiration > 0 {
+				duration = time.Unix(0, e.Expiration).Sub(now)
+				if duration <= 0 {
+					continue
+				}
+			}
+			value, err := clone.copyValue(e.Value)
+			if err != nil {
+				return fmt.Errorf("cloning %q: %w", e.Key, err)
+			}
+			if err := clone.set(e.Key, value, duration, 0, e.Priority); err != nil {
+				return fmt.Errorf("cloning %q: %w", e.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		clone.Stop()
+		return nil, err
+	}
+	return clone, nil
+}
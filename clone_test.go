@@ -0,0 +1,57 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheClone(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("forever", []int{1})
+	cache.SetWithExpiration("soon", "value", time.Minute)
+	cache.SetWithExpiration("expired", "gone", time.Second)
+	clock.Advance(2 * time.Second)
+
+	clone, err := cache.Clone(Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	defer clone.Stop()
+
+	if n := clone.ItemCount(); n != 2 {
+		t.Errorf("Expected 2 cloned items, got %d", n)
+	}
+	clone.Delete("forever")
+	if _, err := cache.Get("forever"); err != nil {
+		t.Errorf("Expected the original to be independent of the clone, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := clone.Get("soon"); err != ErrKeyExpired {
+		t.Errorf("Expected the clone to keep expirations, got %v", err)
+	}
+}
+
+func TestCacheCloneDeep(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("list", []int{1, 2, 3})
+
+	shallow, _ := cache.Clone(Options{})
+	defer shallow.Stop()
+	deep, _ := cache.Clone(Options{CopyOnGet: true})
+	defer deep.Stop()
+
+	value, _ := cache.Get("list")
+	value.([]int)[0] = 100
+
+	if value, _ := shallow.Get("list"); value.([]int)[0] != 100 {
+		t.Errorf("Expected the shallow clone to share values, got %v", value)
+	}
+	if value, _ := deep.Get("list"); value.([]int)[0] != 1 {
+		t.Errorf("Expected the deep clone to hold copies, got %v", value)
+	}
+}
@@ -0,0 +1,119 @@
+// Package cluster spreads a key space over a fleet of gocache servers with
+// a consistent hash ring, so that adding or removing a server moves only a
+// small share of the keys.
+//
+//	client := cluster.NewClient(nil)
+//	client.AddNode("cache-1", cluster.NewHTTPNode("http://cache-1:8080", nil))
+//	client.AddNode("cache-2", cluster.NewHTTPNode("http://cache-2:8080", nil))
+//	err := client.Set(ctx, "user:42", user, time.Hour)
+//
+// Servers are reached through the Node interface; HTTPNode speaks the REST
+// API of httpserver. Instead of adding nodes by hand, a Gossip can discover
+// them and keep the client up to date as servers join and leave.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoNodes is returned when a client has no nodes to send a key to.
+var ErrNoNodes = errors.New("cluster: no nodes")
+
+// Node is a cache server of the fleet. Get returns an error matching
+// gocache.ErrKeyNotFound for missing and expired keys, and Delete reports
+// whether the key existed. A ttl of 0 means the server's default
+// expiration.
+type Node interface {
+	Get(ctx context.Context, key string) (interface{}, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+// Client sends each key to the node that owns it on a Ring. It is safe for
+// concurrent use.
+type Client struct {
+	ring *Ring
+
+	mu    sync.RWMutex
+	nodes map[string]Node
+}
+
+// NewClient returns a client without nodes that places them on ring. If
+// ring is nil, a ring with the default settings is used. Nodes already on
+// ring are ignored until they are added to the client.
+func NewClient(ring *Ring) *Client {
+	if ring == nil {
+		ring = NewRing(0, nil)
+	}
+	return &Client{ring: ring, nodes: make(map[string]Node)}
+}
+
+// Ring returns the client's ring.
+func (c *Client) Ring() *Ring {
+	return c.ring
+}
+
+// AddNode adds or replaces the node with the given name. Every client of
+// the fleet must use the same names for the same servers, so that they
+// agree on which keys each server owns.
+func (c *Client) AddNode(name string, node Node) {
+	c.mu.Lock()
+	c.nodes[name] = node
+	c.mu.Unlock()
+	c.ring.Add(name)
+}
+
+// RemoveNode removes the node with the given name. Its keys are sent to
+// the remaining nodes from then on; the values it held are not moved.
+func (c *Client) RemoveNode(name string) {
+	c.ring.Remove(name)
+	c.mu.Lock()
+	delete(c.nodes, name)
+	c.mu.Unlock()
+}
+
+// Node returns the name of the node that owns key and the node.
+func (c *Client) Node(key string) (string, Node, error) {
+	name, ok := c.ring.Get(key)
+	if !ok {
+		return "", nil, ErrNoNodes
+	}
+	c.mu.RLock()
+	node := c.nodes[name]
+	c.mu.RUnlock()
+	if node == nil {
+		return "", nil, fmt.Errorf("cluster: node %q is on the ring but not in the client", name)
+	}
+	return name, node, nil
+}
+
+// Get returns the value of key from the node that owns it.
+func (c *Client) Get(ctx context.Context, key string) (interface{}, error) {
+	_, node, err := c.Node(key)
+	if err != nil {
+		return nil, err
+	}
+	return node.Get(ctx, key)
+}
+
+// Set stores key on the node that owns it.
+func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	_, node, err := c.Node(key)
+	if err != nil {
+		return err
+	}
+	return node.Set(ctx, key, value, ttl)
+}
+
+// Delete removes key from the node that owns it.
+func (c *Client) Delete(ctx context.Context, key string) (bool, error) {
+	_, node, err := c.Node(key)
+	if err != nil {
+		return false, err
+	}
+	return node.Delete(ctx, key)
+}
@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gocache"
+	"gocache/httpserver"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(nil)
+	if err := client.Set(ctx, "a", 1, 0); !errors.Is(err, ErrNoNodes) {
+		t.Errorf("Expected ErrNoNodes, got %v", err)
+	}
+
+	caches := make(map[string]*gocache.Cache)
+	for _, name := range []string{"cache-1", "cache-2", "cache-3"} {
+		cache := gocache.New(gocache.Options{})
+		defer cache.Stop()
+		server := httptest.NewServer(httpserver.New(cache))
+		defer server.Close()
+		caches[name] = cache
+		client.AddNode(name, NewHTTPNode(server.URL, nil))
+	}
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		if err := client.Set(ctx, key, map[string]interface{}{"id": i}, time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	for name, cache := range caches {
+		if n := cache.ItemCount(); n == 0 || n == 30 {
+			t.Errorf("Expected %s to hold some of the keys, got %d", name, n)
+		}
+	}
+
+	owner, _, _ := client.Node("user:7")
+	if _, expires, err := caches[owner].GetWithExpiration("user:7"); err != nil || time.Until(expires) > time.Minute {
+		t.Errorf("Expected user:7 on its owner %s with the TTL, got %v (%v)", owner, expires, err)
+	}
+	value, err := client.Get(ctx, "user:7")
+	if err != nil || value.(map[string]interface{})["id"] != float64(7) {
+		t.Errorf("Expected user 7, got %v (%v)", value, err)
+	}
+
+	if deleted, err := client.Delete(ctx, "user:7"); !deleted || err != nil {
+		t.Errorf("Expected user:7 to be deleted, got %v (%v)", deleted, err)
+	}
+	if deleted, err := client.Delete(ctx, "user:7"); deleted || err != nil {
+		t.Errorf("Expected a second Delete to find nothing, got %v (%v)", deleted, err)
+	}
+	if _, err := client.Get(ctx, "user:7"); !errors.Is(err, gocache.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	client.RemoveNode(owner)
+	if name, _, _ := client.Node("user:7"); name == owner {
+		t.Errorf("Expected keys of a removed node to move, got %s", name)
+	}
+}
@@ -0,0 +1,427 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Default gossip settings.
+const (
+	DefaultGossipInterval = 200 * time.Millisecond
+	DefaultGossipFanout   = 3
+	DefaultDeadTimeout    = 5 * time.Second
+)
+
+// maxPacketSize bounds gossip messages, which are sent as single UDP
+// datagrams.
+const maxPacketSize = 64 * 1024
+
+// Member is a node discovered by gossip.
+type Member struct {
+	// Name identifies the node on the ring.
+	Name string
+	// Addr is the address of the node's cache server, for NewNode.
+	Addr string
+}
+
+// GossipOptions configures a Gossip.
+type GossipOptions struct {
+	// Name identifies this node, and must be unique in the fleet. It
+	// defaults to Addr.
+	Name string
+	// Addr is the address other nodes reach this node's cache server at,
+	// such as "http://10.0.0.5:8080".
+	Addr string
+
+	// BindAddr is the UDP address to gossip on, such as ":7946".
+	BindAddr string
+	// AdvertiseAddr is the UDP address other nodes gossip to this node
+	// at. It defaults to the address BindAddr is bound to, which only
+	// works if that is reachable, so set it when binding to all
+	// interfaces.
+	AdvertiseAddr string
+	// Seeds are the gossip addresses of nodes to join through. One
+	// running node is enough; the others are learned from it.
+	Seeds []string
+
+	// NewNode returns the Node reaching a member's cache server. It
+	// defaults to an HTTPNode for Member.Addr. It is also called for this
+	// node, which can return a Node wrapping its local cache.
+	NewNode func(m Member) Node
+
+	// Cache, if set, is the local cache that invalidations from other
+	// nodes are applied to.
+	Cache *gocache.Cache
+
+	// Interval is the time between gossip rounds, and Fanout the number
+	// of random members gossiped with each round.
+	Interval time.Duration
+	Fanout   int
+	// DeadTimeout is how long a member may go without a new heartbeat
+	// before it is considered dead and removed from the ring.
+	DeadTimeout time.Duration
+
+	// OnError, if set, is called with errors of the gossip protocol, such
+	// as malformed or undeliverable messages, for logging.
+	OnError func(err error)
+}
+
+// Gossip keeps the nodes of a Client in sync with the members of a fleet,
+// discovered with a gossip protocol over UDP and without a central
+// coordinator. Each node periodically exchanges its member list, with a
+// heartbeat counter per member, with a few random members; a member whose
+// heartbeat stops increasing for DeadTimeout is removed from the ring, and
+// a new node is added as soon as the gossip reaches this one.
+//
+//	g, err := cluster.NewGossip(client, cluster.GossipOptions{
+//		Name:          "cache-3",
+//		Addr:          "http://10.0.0.7:8080",
+//		BindAddr:      ":7946",
+//		AdvertiseAddr: "10.0.0.7:7946",
+//		Seeds:         []string{"10.0.0.5:7946"},
+//	})
+//
+// Gossip also broadcasts invalidations to every member, on a best-effort
+// basis: they are lost with the datagrams carrying them.
+type Gossip struct {
+	client  *Client
+	options GossipOptions
+	conn    *net.UDPConn
+	self    memberState
+
+	mu      sync.Mutex
+	members map[string]*memberInfo
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// memberState is a member as gossiped. States are ordered by Incarnation,
+// which changes when a node restarts, then Heartbeat.
+type memberState struct {
+	Name        string `json:"name"`
+	Addr        string `json:"addr"`
+	Gossip      string `json:"gossip"`
+	Incarnation int64  `json:"incarnation"`
+	Heartbeat   uint64 `json:"heartbeat"`
+	Left        bool   `json:"left,omitempty"`
+}
+
+func (s memberState) newer(than memberState) bool {
+	if s.Incarnation != than.Incarnation {
+		return s.Incarnation > than.Incarnation
+	}
+	return s.Heartbeat > than.Heartbeat
+}
+
+// memberInfo is what a node knows about another member.
+type memberInfo struct {
+	state   memberState
+	updated time.Time // when state last changed
+	alive   bool
+}
+
+// Kinds of gossip messages.
+const (
+	kindSync       = "sync" // a member list, answered with an ack
+	kindAck        = "ack"  // a member list
+	kindInvalidate = "invalidate"
+)
+
+type gossipMessage struct {
+	Kind    string        `json:"kind"`
+	Members []memberState `json:"members,omitempty"`
+	Keys    []string      `json:"keys,omitempty"`
+}
+
+// NewGossip starts gossiping on options.BindAddr, adds this node to client,
+// and joins the fleet through options.Seeds. It stops when Close or Leave
+// is called.
+func NewGossip(client *Client, options GossipOptions) (*Gossip, error) {
+	if options.Name == "" {
+		options.Name = options.Addr
+	}
+	if options.NewNode == nil {
+		options.NewNode = func(m Member) Node { return NewHTTPNode(m.Addr, nil) }
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultGossipInterval
+	}
+	if options.Fanout <= 0 {
+		options.Fanout = DefaultGossipFanout
+	}
+	if options.DeadTimeout <= 0 {
+		options.DeadTimeout = DefaultDeadTimeout
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", options.BindAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	if options.AdvertiseAddr == "" {
+		options.AdvertiseAddr = conn.LocalAddr().String()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Gossip{
+		client:  client,
+		options: options,
+		conn:    conn,
+		self: memberState{
+			Name:        options.Name,
+			Addr:        options.Addr,
+			Gossip:      options.AdvertiseAddr,
+			Incarnation: time.Now().UnixNano(),
+		},
+		members: make(map[string]*memberInfo),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	client.AddNode(g.self.Name, options.NewNode(Member{Name: g.self.Name, Addr: g.self.Addr}))
+
+	go g.receive()
+	go g.run(ctx)
+	return g, nil
+}
+
+// LocalAddr returns the UDP address the node gossips on.
+func (g *Gossip) LocalAddr() net.Addr {
+	return g.conn.LocalAddr()
+}
+
+// Members returns the live members, including this node, sorted by name.
+func (g *Gossip) Members() []Member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	members := []Member{{Name: g.self.Name, Addr: g.self.Addr}}
+	for _, m := range g.members {
+		if m.alive {
+			members = append(members, Member{Name: m.state.Name, Addr: m.state.Addr})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// Invalidate removes keys from the local cache and sends their
+// invalidation to every live member.
+func (g *Gossip) Invalidate(keys ...string) error {
+	if g.options.Cache != nil {
+		for _, key := range keys {
+			g.options.Cache.Delete(key)
+		}
+	}
+	var firstErr error
+	for _, addr := range g.peers(0) {
+		if err := g.send(addr, gossipMessage{Kind: kindInvalidate, Keys: keys}); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Leave tells the live members that this node is leaving, so that they
+// remove it from their rings without waiting for DeadTimeout, then stops
+// gossiping.
+func (g *Gossip) Leave() error {
+	g.mu.Lock()
+	g.self.Heartbeat++
+	g.self.Left = true
+	msg := gossipMessage{Kind: kindAck, Members: []memberState{g.self}}
+	g.mu.Unlock()
+
+	var firstErr error
+	for _, addr := range g.peers(0) {
+		if err := g.send(addr, msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := g.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Close stops gossiping. The other members remove this node once its
+// DeadTimeout passes.
+func (g *Gossip) Close() error {
+	g.cancel()
+	err := g.conn.Close()
+	<-g.done
+	return err
+}
+
+// run gossips every Interval until ctx is done.
+func (g *Gossip) run(ctx context.Context) {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.options.Interval)
+	defer ticker.Stop()
+	for {
+		g.round()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// round beats this node's heartbeat, expires silent members and sends the
+// member list to Fanout random members, or to the seeds while no member is
+// known.
+func (g *Gossip) round() {
+	g.mu.Lock()
+	g.self.Heartbeat++
+	now := time.Now()
+	for name, m := range g.members {
+		age := now.Sub(m.updated)
+		switch {
+		case m.alive && age > g.options.DeadTimeout:
+			m.alive = false
+			g.client.RemoveNode(name)
+		case !m.alive && age > 10*g.options.DeadTimeout:
+			// Forget the member once stale gossip about it is unlikely.
+			delete(g.members, name)
+		}
+	}
+	msg := g.syncMessage(kindSync)
+	g.mu.Unlock()
+
+	peers := g.peers(g.options.Fanout)
+	if len(peers) == 0 {
+		peers = g.options.Seeds
+	}
+	for _, addr := range peers {
+		g.report(g.send(addr, msg))
+	}
+}
+
+// syncMessage returns a message of kind with the member list. g.mu must be
+// held.
+func (g *Gossip) syncMessage(kind string) gossipMessage {
+	members := []memberState{g.self}
+	for _, m := range g.members {
+		members = append(members, m.state)
+	}
+	return gossipMessage{Kind: kind, Members: members}
+}
+
+// peers returns the gossip addresses of up to n random live members, or
+// of all of them if n is 0.
+func (g *Gossip) peers(n int) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var addrs []string
+	for _, m := range g.members {
+		if m.alive {
+			addrs = append(addrs, m.state.Gossip)
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if n > 0 && len(addrs) > n {
+		addrs = addrs[:n]
+	}
+	return addrs
+}
+
+// receive handles incoming messages until the connection is closed.
+func (g *Gossip) receive() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			g.report(err)
+			continue
+		}
+		var msg gossipMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			g.report(err)
+			continue
+		}
+
+		switch msg.Kind {
+		case kindSync, kindAck:
+			g.mu.Lock()
+			g.merge(msg.Members)
+			reply := g.syncMessage(kindAck)
+			g.mu.Unlock()
+			if msg.Kind == kindSync {
+				g.report(g.send(from.String(), reply))
+			}
+		case kindInvalidate:
+			if g.options.Cache != nil {
+				for _, key := range msg.Keys {
+					g.options.Cache.Delete(key)
+				}
+			}
+		}
+	}
+}
+
+// merge updates the member list with the newer states of states, adding
+// members that joined to the client and removing those that left. g.mu
+// must be held.
+func (g *Gossip) merge(states []memberState) {
+	now := time.Now()
+	for _, s := range states {
+		if s.Name == g.self.Name {
+			continue
+		}
+		m := g.members[s.Name]
+		if m == nil {
+			m = &memberInfo{}
+			g.members[s.Name] = m
+		} else if !s.newer(m.state) {
+			continue
+		}
+		m.state = s
+		m.updated = now
+
+		switch {
+		case s.Left && m.alive:
+			m.alive = false
+			g.client.RemoveNode(s.Name)
+		case !s.Left && !m.alive:
+			m.alive = true
+			g.client.AddNode(s.Name, g.options.NewNode(Member{Name: s.Name, Addr: s.Addr}))
+		}
+	}
+}
+
+func (g *Gossip) send(addr string, msg gossipMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	_, err = g.conn.WriteToUDP(data, raddr)
+	return err
+}
+
+// report passes an error to GossipOptions.OnError.
+func (g *Gossip) report(err error) {
+	if err != nil && g.options.OnError != nil {
+		g.options.OnError(err)
+	}
+}
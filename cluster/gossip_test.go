@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// nameNode is a Node that only records the member it was made for.
+type nameNode struct{ Member }
+
+func (nameNode) Get(ctx context.Context, key string) (interface{}, error) {
+	return nil, gocache.ErrKeyNotFound
+}
+
+func (nameNode) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return nil
+}
+
+func (nameNode) Delete(ctx context.Context, key string) (bool, error) {
+	return false, nil
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func startGossip(t *testing.T, name string, seeds ...string) (*Gossip, *Client, *gocache.Cache) {
+	cache := gocache.New(gocache.Options{})
+	t.Cleanup(cache.Stop)
+	client := NewClient(nil)
+	g, err := NewGossip(client, GossipOptions{
+		Name:        name,
+		Addr:        "http://" + name,
+		BindAddr:    "127.0.0.1:0",
+		Seeds:       seeds,
+		NewNode:     func(m Member) Node { return nameNode{m} },
+		Cache:       cache,
+		Interval:    10 * time.Millisecond,
+		DeadTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g, client, cache
+}
+
+func TestGossip(t *testing.T) {
+	g1, client1, cache1 := startGossip(t, "node-1")
+	seed := g1.LocalAddr().String()
+	g2, _, cache2 := startGossip(t, "node-2", seed)
+	g3, client3, _ := startGossip(t, "node-3", seed)
+
+	for _, client := range []*Client{client1, client3} {
+		client := client
+		waitFor(t, func() bool { return len(client.Ring().Nodes()) == 3 })
+	}
+	waitFor(t, func() bool { return len(g2.Members()) == 3 })
+	if members := g2.Members(); members[2] != (Member{Name: "node-3", Addr: "http://node-3"}) {
+		t.Errorf("Expected node-3 last, got %v", members)
+	}
+	if _, node, _ := client1.Node("some-key"); node.(nameNode).Addr == "" {
+		t.Errorf("Expected nodes made by NewNode, got %#v", node)
+	}
+
+	cache1.Set("k", "v")
+	cache2.Set("k", "v")
+	if err := g3.Invalidate("k"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	for _, cache := range []*gocache.Cache{cache1, cache2} {
+		cache := cache
+		waitFor(t, func() bool { _, err := cache.Get("k"); return err != nil })
+	}
+
+	// A member that leaves is removed at once, one that stops gossiping
+	// after DeadTimeout.
+	if err := g2.Leave(); err != nil {
+		t.Fatalf("Leave failed: %v", err)
+	}
+	waitFor(t, func() bool { return len(g1.Members()) == 2 })
+	g3.Close()
+	waitFor(t, func() bool { return len(client1.Ring().Nodes()) == 1 })
+	if members := g1.Members(); len(members) != 1 || members[0].Name != "node-1" {
+		t.Errorf("Expected node-1 alone, got %v", members)
+	}
+}
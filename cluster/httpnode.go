@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocache"
+	"gocache/httpserver"
+)
+
+// HTTPNode is a Node served by httpserver. Values travel as JSON, so values
+// read back are JSON types, as with httpserver itself.
+type HTTPNode struct {
+	base   string
+	client *http.Client
+}
+
+// NewHTTPNode returns a node for the httpserver API at baseURL, including
+// any path prefix it is served under. If client is nil,
+// http.DefaultClient is used.
+func NewHTTPNode(baseURL string, client *http.Client) *HTTPNode {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPNode{base: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// Get returns the value of key.
+func (n *HTTPNode) Get(ctx context.Context, key string) (interface{}, error) {
+	resp, err := n.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var value interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores value, encoded as JSON, under key.
+func (n *HTTPNode) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	resp, err := n.do(ctx, http.MethodPut, key, body, ttl)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Delete removes key.
+func (n *HTTPNode) Delete(ctx context.Context, key string) (bool, error) {
+	resp, err := n.do(ctx, http.MethodDelete, key, nil, 0)
+	if errors.Is(err, gocache.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, resp.Body.Close()
+}
+
+// do sends a request for key. Responses other than 2xx are returned as
+// errors, 404 as gocache.ErrKeyNotFound.
+func (n *HTTPNode) do(ctx context.Context, method, key string, body []byte, ttl time.Duration) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, n.base+"/keys/"+url.PathEscape(key), r)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		req.Header.Set(httpserver.TTLHeader, strconv.FormatFloat(ttl.Seconds(), 'f', -1, 64))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", gocache.ErrKeyNotFound, key)
+	}
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	return nil, fmt.Errorf("cluster: %s %s: %s %s", method, key, resp.Status, apiErr.Error)
+}
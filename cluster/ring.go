@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of virtual nodes per node when NewRing is
+// given 0.
+const DefaultReplicas = 160
+
+// HashFunc hashes keys and virtual node names onto the ring. It must give
+// the same results in every process that shares a ring.
+type HashFunc func(data []byte) uint64
+
+// Ring is a consistent hash ring. Each node is placed on the ring at
+// several points, its virtual nodes, and a key belongs to the node owning
+// the first point at or after the key's hash. Adding or removing a node
+// only moves the keys of the points it gains or loses, about 1/n of all
+// keys for n nodes. A Ring is safe for concurrent use.
+type Ring struct {
+	replicas int
+	hash     HashFunc
+
+	mu     sync.RWMutex
+	points []ringPoint // sorted by hash
+	nodes  map[string]bool
+}
+
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// NewRing returns an empty ring placing each node at replicas points,
+// hashed with hash. If replicas is 0, DefaultReplicas is used; if hash is
+// nil, a 64-bit FNV-1a hash with extra mixing is used.
+func NewRing(replicas int, hash HashFunc) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	if hash == nil {
+		hash = defaultHash
+	}
+	return &Ring{replicas: replicas, hash: hash, nodes: make(map[string]bool)}
+}
+
+// Add places nodes on the ring. Nodes already on it are ignored.
+func (r *Ring) Add(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, node := range nodes {
+		if r.nodes[node] {
+			continue
+		}
+		r.nodes[node] = true
+		for i := 0; i < r.replicas; i++ {
+			r.points = append(r.points, ringPoint{r.hash([]byte(node + "#" + strconv.Itoa(i))), node})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		// Break ties by name, so that every process orders them alike.
+		return r.points[i].node < r.points[j].node
+	})
+}
+
+// Remove takes nodes off the ring. Their keys move to the nodes that
+// follow their points.
+func (r *Ring) Remove(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := make(map[string]bool)
+	for _, node := range nodes {
+		if r.nodes[node] {
+			delete(r.nodes, node)
+			removed[node] = true
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	points := r.points[:0]
+	for _, p := range r.points {
+		if !removed[p.node] {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+}
+
+// Get returns the node that owns key, or false if the ring is empty.
+func (r *Ring) Get(key string) (string, bool) {
+	h := r.hash([]byte(key))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.points) == 0 {
+		return "", false
+	}
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node, true
+}
+
+// Nodes returns the nodes on the ring, sorted.
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// defaultHash is FNV-1a followed by a 64-bit finalizer, since FNV alone
+// spreads similar strings such as virtual node names poorly.
+func defaultHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
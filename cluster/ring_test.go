@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(0, nil)
+	if _, ok := r.Get("a"); ok {
+		t.Error("Expected an empty ring to have no owner")
+	}
+	r.Add("n1", "n2", "n3", "n1")
+	if nodes := r.Nodes(); len(nodes) != 3 {
+		t.Errorf("Expected 3 nodes, got %v", nodes)
+	}
+
+	const keys = 30000
+	owners := make(map[string]string, keys)
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		node, _ := r.Get(key)
+		owners[key] = node
+		counts[node]++
+	}
+	for node, n := range counts {
+		if n < keys/3*8/10 || n > keys/3*12/10 {
+			t.Errorf("Expected about a third of the keys on %s, got %d", node, n)
+		}
+	}
+
+	// A ring built in another order agrees on every key.
+	other := NewRing(0, nil)
+	other.Add("n3", "n1")
+	other.Add("n2")
+	for key, owner := range owners {
+		if node, _ := other.Get(key); node != owner {
+			t.Fatalf("Expected rings with the same nodes to agree on %s", key)
+		}
+	}
+
+	// Adding a node only moves keys to it.
+	r.Add("n4")
+	var moved int
+	for key, owner := range owners {
+		node, _ := r.Get(key)
+		if node != owner {
+			if node != "n4" {
+				t.Fatalf("Expected %s to move to n4 or stay on %s, got %s", key, owner, node)
+			}
+			moved++
+		}
+	}
+	if moved < keys/4*8/10 || moved > keys/4*12/10 {
+		t.Errorf("Expected about a quarter of the keys to move, got %d", moved)
+	}
+
+	// Removing it moves them back.
+	r.Remove("n4", "unknown")
+	for key, owner := range owners {
+		if node, _ := r.Get(key); node != owner {
+			t.Fatalf("Expected %s back on %s, got %s", key, owner, node)
+		}
+	}
+}
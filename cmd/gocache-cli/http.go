@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocache/httpserver"
+)
+
+// httpClient talks to the REST API of httpserver.
+type httpClient struct {
+	base   *url.URL
+	client *http.Client // for commands with a bounded response
+	stream *http.Client // for dump and restore, which may take long
+}
+
+func newHTTPClient(base *url.URL, timeout time.Duration) *httpClient {
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return &httpClient{
+		base:   base,
+		client: &http.Client{Timeout: timeout},
+		stream: &http.Client{},
+	}
+}
+
+func (c *httpClient) Get(key string) (string, error) {
+	resp, err := c.do(c.client, http.MethodGet, "/keys/"+url.PathEscape(key), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(body), "\n"), nil
+}
+
+func (c *httpClient) Set(key, value string, ttl time.Duration) error {
+	body := []byte(value)
+	if !json.Valid(body) {
+		body, _ = json.Marshal(value)
+	}
+	header := make(http.Header)
+	if ttl > 0 {
+		header.Set(httpserver.TTLHeader, strconv.FormatFloat(ttl.Seconds(), 'f', -1, 64))
+	}
+	resp, err := c.do(c.client, http.MethodPut, "/keys/"+url.PathEscape(key), header, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *httpClient) Del(keys []string) (int, error) {
+	var n int
+	for _, key := range keys {
+		resp, err := c.do(c.client, http.MethodDelete, "/keys/"+url.PathEscape(key), nil, nil)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		resp.Body.Close()
+		n++
+	}
+	return n, nil
+}
+
+func (c *httpClient) Keys(prefix string) ([]string, error) {
+	resp, err := c.do(c.client, http.MethodGet, "/keys?prefix="+url.QueryEscape(prefix), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var keys []string
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *httpClient) Stats(w io.Writer) error {
+	resp, err := c.do(c.client, http.MethodGet, "/stats", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return err
+	}
+	_, err = out.WriteTo(w)
+	return err
+}
+
+func (c *httpClient) Dump(w io.Writer) error {
+	resp, err := c.do(c.stream, http.MethodGet, "/dump", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// A dump that fails on the server ends with a truncated body, which
+	// Copy reports as an error.
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *httpClient) Restore(r io.Reader) error {
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err := c.do(c.stream, http.MethodPost, "/restore", header, r)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	c.stream.CloseIdleConnections()
+	return nil
+}
+
+// do sends a request to path under the base URL. Responses other than 2xx
+// are returned as errors, 404 as errNotFound.
+func (c *httpClient) do(client *http.Client, method, path string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/keys/") {
+		return nil, errNotFound
+	}
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+		return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+	}
+	return nil, errors.New(resp.Status)
+}
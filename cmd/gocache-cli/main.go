@@ -0,0 +1,217 @@
+// Command gocache-cli inspects and edits a cache served by one of the
+// gocache server packages, for operators debugging what is in the cache:
+//
+//	gocache-cli -server http://localhost:8080 keys user:
+//	gocache-cli -server redis://localhost:6379 get user:42
+//	gocache-cli dump > backup.snapshot
+//
+// The server is addressed by a URL. An http or https URL names the REST API
+// of httpserver, including any path prefix it is served under; a redis URL
+// names a respserver. The server can also be set with the GOCACHE_SERVER
+// environment variable.
+//
+// The commands are:
+//
+//	get <key>                  print the value of key
+//	set <key> <value> [<ttl>]  set key, with a TTL such as 30s or 1h
+//	del <key>...               delete keys and print how many existed
+//	keys [<prefix>]            print the unexpired keys, one per line
+//	stats                      print the cache's stats
+//	dump [<file>]              write a snapshot to file or stdout
+//	restore [<file>]           load a snapshot from file or stdin
+//
+// Over HTTP, values are JSON: set stores its argument as is if it is valid
+// JSON and as a JSON string otherwise, and get prints JSON. The Redis
+// protocol has no stats, dump or restore commands.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// client is a connection to a cache server.
+type client interface {
+	Get(key string) (string, error)
+	Set(key, value string, ttl time.Duration) error
+	Del(keys []string) (int, error)
+	Keys(prefix string) ([]string, error)
+	Stats(w io.Writer) error
+	Dump(w io.Writer) error
+	Restore(r io.Reader) error
+	Close() error
+}
+
+var (
+	// errNotFound is returned by Get for missing and expired keys.
+	errNotFound = errors.New("key not found")
+	// errUnsupported is returned for commands the server cannot run.
+	errUnsupported = errors.New("not supported by this server")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: gocache-cli [flags] <command> [args]
+
+commands:
+  get <key>
+  set <key> <value> [<ttl>]
+  del <key>...
+  keys [<prefix>]
+  stats
+  dump [<file>]
+  restore [<file>]
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gocache-cli: ")
+
+	server := os.Getenv("GOCACHE_SERVER")
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+	flag.StringVar(&server, "server", server, "URL of the server: http://host:port[/prefix] or redis://host:port")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout for connecting and for commands other than dump and restore")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := dial(server, *timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = run(c, flag.Arg(0), flag.Args()[1:])
+	c.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// dial connects to the server at the given URL.
+func dial(server string, timeout time.Duration) (client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return newHTTPClient(u, timeout), nil
+	case "redis":
+		return dialRESP(u.Host, timeout)
+	}
+	return nil, fmt.Errorf("unsupported server URL %q: the scheme must be http, https or redis", server)
+}
+
+// run executes a command and prints its result to stdout.
+func run(c client, command string, args []string) error {
+	switch command {
+	case "get":
+		if len(args) != 1 {
+			return errors.New("usage: get <key>")
+		}
+		value, err := c.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+
+	case "set":
+		if len(args) != 2 && len(args) != 3 {
+			return errors.New("usage: set <key> <value> [<ttl>]")
+		}
+		var ttl time.Duration
+		if len(args) == 3 {
+			var err error
+			if ttl, err = time.ParseDuration(args[2]); err != nil || ttl <= 0 {
+				return fmt.Errorf("invalid ttl %q", args[2])
+			}
+		}
+		return c.Set(args[0], args[1], ttl)
+
+	case "del":
+		if len(args) == 0 {
+			return errors.New("usage: del <key>...")
+		}
+		n, err := c.Del(args)
+		if err != nil {
+			return err
+		}
+		fmt.Println(n)
+
+	case "keys":
+		if len(args) > 1 {
+			return errors.New("usage: keys [<prefix>]")
+		}
+		keys, err := c.Keys(strings.Join(args, ""))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+
+	case "stats":
+		if len(args) != 0 {
+			return errors.New("usage: stats")
+		}
+		return c.Stats(os.Stdout)
+
+	case "dump":
+		if len(args) > 1 {
+			return errors.New("usage: dump [<file>]")
+		}
+		if len(args) == 0 {
+			return c.Dump(os.Stdout)
+		}
+		return dumpFile(c, args[0])
+
+	case "restore":
+		if len(args) > 1 {
+			return errors.New("usage: restore [<file>]")
+		}
+		if len(args) == 0 {
+			return c.Restore(os.Stdin)
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.Restore(f)
+
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return nil
+}
+
+// dumpFile writes a snapshot to the named file, removing the file if the
+// dump fails so that no truncated snapshot is left behind.
+func dumpFile(c client, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = c.Dump(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
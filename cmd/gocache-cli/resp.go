@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocache/internal/resp"
+)
+
+// respClient talks to a respserver, or any server speaking the Redis
+// protocol, over a single connection.
+type respClient struct {
+	conn    *resp.Conn
+	timeout time.Duration
+}
+
+func dialRESP(addr string, timeout time.Duration) (*respClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := resp.Dial(ctx, addr, resp.DialOptions{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	return &respClient{conn: conn, timeout: timeout}, nil
+}
+
+func (c *respClient) Get(key string) (string, error) {
+	reply, err := c.do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	if reply == nil {
+		return "", errNotFound
+	}
+	return reply.(string), nil
+}
+
+func (c *respClient) Set(key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+func (c *respClient) Del(keys []string) (int, error) {
+	reply, err := c.do(append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return int(n), nil
+}
+
+func (c *respClient) Keys(prefix string) ([]string, error) {
+	reply, err := c.do("KEYS", escapeGlob(prefix)+"*")
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	var keys []string
+	for _, key := range items {
+		s, _ := key.(string)
+		keys = append(keys, s)
+	}
+	return keys, nil
+}
+
+func (c *respClient) Stats(w io.Writer) error {
+	return fmt.Errorf("stats: %w", errUnsupported)
+}
+
+func (c *respClient) Dump(w io.Writer) error {
+	return fmt.Errorf("dump: %w", errUnsupported)
+}
+
+func (c *respClient) Restore(r io.Reader) error {
+	return fmt.Errorf("restore: %w", errUnsupported)
+}
+
+func (c *respClient) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and returns its reply: a string, an int64, a slice of
+// replies, or nil for a null reply. Error replies are returned as errors.
+func (c *respClient) do(args ...string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.conn.Do(ctx, args...)
+}
+
+// escapeGlob escapes the characters KEYS treats as glob syntax.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
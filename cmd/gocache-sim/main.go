@@ -0,0 +1,174 @@
+// Command gocache-sim replays an access trace recorded with Cache.Trace
+// against a set of eviction policies and cache sizes, and reports the hit
+// ratio of each, so that a policy and size can be chosen from real traffic
+// before changing a production cache:
+//
+//	gocache-sim -trace prod.trace -policies lru,tinylfu -sizes 1000,10000
+//
+// Every recorded Get is replayed as a Get followed, on a miss, by a Set of
+// the key, as an application filling the cache on demand would; recorded
+// Sets and Deletes are replayed as they are. The cache's clock follows the
+// times of the trace, so that -ttl expires items as they would have
+// expired.
+//
+// The policies are lru, tinylfu, clock, fifo and random.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gocache"
+)
+
+// policies are the eviction policies by name. Each returns a policy for a
+// cache of the given size.
+var policies = map[string]func(size int) gocache.EvictionPolicy{
+	"lru":     func(int) gocache.EvictionPolicy { return gocache.NewLRUPolicy() },
+	"tinylfu": gocache.NewTinyLFUPolicy,
+	"clock":   func(int) gocache.EvictionPolicy { return gocache.NewClockPolicy() },
+	"fifo":    func(int) gocache.EvictionPolicy { return gocache.NewFIFOPolicy() },
+	"random":  func(int) gocache.EvictionPolicy { return gocache.NewRandomPolicy() },
+}
+
+// result is the outcome of replaying the trace against one configuration.
+type result struct {
+	policy string
+	size   int
+	stats  gocache.Stats
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gocache-sim: ")
+
+	tracePath := flag.String("trace", "", "trace file recorded with Cache.Trace (required)")
+	policyList := flag.String("policies", "lru,tinylfu,clock,fifo,random", "comma-separated eviction policies to compare")
+	sizeList := flag.String("sizes", "1000,10000,100000", "comma-separated cache sizes, in entries")
+	ttl := flag.Duration("ttl", 0, "expiration of the items set during the replay; 0 for none")
+	flag.Parse()
+	if *tracePath == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names := strings.Split(*policyList, ",")
+	for _, name := range names {
+		if policies[name] == nil {
+			log.Fatalf("unknown policy %q", name)
+		}
+	}
+	var sizes []int
+	for _, s := range strings.Split(*sizeList, ",") {
+		size, err := strconv.Atoi(s)
+		if err != nil || size <= 0 {
+			log.Fatalf("invalid size %q", s)
+		}
+		sizes = append(sizes, size)
+	}
+
+	records, err := readTrace(*tracePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(records) == 0 {
+		log.Fatal("the trace is empty")
+	}
+
+	var results []result
+	for _, name := range names {
+		for _, size := range sizes {
+			results = append(results, result{name, size, replay(records, policies[name](size), size, *ttl)})
+		}
+	}
+
+	fmt.Printf("%d records from %s to %s\n\n", len(records),
+		records[0].Time.Format(time.RFC3339), records[len(records)-1].Time.Format(time.RFC3339))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "policy\tsize\thits\tmisses\thit ratio\tevictions\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f%%\t%d\t\n",
+			r.policy, r.size, r.stats.Hits, r.stats.Misses, 100*r.stats.HitRatio(), r.stats.Evictions)
+	}
+	tw.Flush()
+}
+
+// readTrace reads all records of the trace file at path.
+func readTrace(path string) ([]gocache.TraceRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr, err := gocache.ReadTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var records []gocache.TraceRecord
+	for {
+		rec, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// replay runs the trace against a cache of size entries evicting with
+// policy, and returns its stats.
+func replay(records []gocache.TraceRecord, policy gocache.EvictionPolicy, size int, ttl time.Duration) gocache.Stats {
+	clock := &traceClock{now: records[0].Time}
+	c := gocache.New(gocache.Options{
+		DefaultExpiration: ttl,
+		MaxEntries:        size,
+		EvictionPolicy:    policy,
+		Clock:             clock,
+	})
+	defer c.Stop()
+
+	for _, rec := range records {
+		clock.now = rec.Time
+		switch rec.Op {
+		case gocache.TraceGet:
+			if _, err := c.Get(rec.Key); err != nil {
+				c.Set(rec.Key, true)
+			}
+		case gocache.TraceSet:
+			c.Set(rec.Key, true)
+		case gocache.TraceDelete:
+			c.Delete(rec.Key)
+		}
+	}
+	return c.Stats()
+}
+
+// traceClock is a Clock set to the time of the record being replayed. The
+// replay does not use tickers, so its tickers never tick.
+type traceClock struct {
+	now time.Time
+}
+
+func (c *traceClock) Now() time.Time {
+	return c.now
+}
+
+func (c *traceClock) NewTicker(time.Duration) gocache.Ticker {
+	return idleTicker{}
+}
+
+type idleTicker struct{}
+
+func (idleTicker) C() <-chan time.Time { return nil }
+func (idleTicker) Stop()               {}
@@ -0,0 +1,77 @@
+package gocache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec converts values to and from bytes. It is used wherever the cache
+// needs to move values outside of process memory, such as persistence,
+// network servers and exports.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Built-in codecs.
+var (
+	// GobCodec encodes values with encoding/gob. It preserves concrete Go
+	// types, but custom types stored in interface values (such as cache
+	// values) must be registered with gob.Register. It is the default codec.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec encodes values with encoding/json. Values decoded into
+	// interface{} come back as JSON types (float64, map[string]interface{}
+	// and so on), so it is best suited to caches of JSON-friendly data or
+	// to exports meant for other tools.
+	JSONCodec Codec = jsonCodec{}
+
+	// MsgpackCodec encodes values in the MessagePack format. Like JSON it is
+	// schemaless, but more compact and it keeps integers, binary data and
+	// timestamps distinct. Struct fields may be renamed or skipped with a
+	// `msgpack:"name"` or `msgpack:"-"` tag.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MarshalValue encodes value, stored or to be stored under key, with the
+// codec of the key's namespace, or Options.Codec outside namespaces, as the
+// cache does in snapshots. Packages that move values to other processes
+// use it so that UnmarshalValue on a cache with the same codecs restores
+// them.
+func (c *Cache) MarshalValue(key string, value interface{}) ([]byte, error) {
+	return c.codecOf(key).Marshal(&value)
+}
+
+// UnmarshalValue decodes a value of key encoded with MarshalValue.
+func (c *Cache) UnmarshalValue(key string, data []byte) (interface{}, error) {
+	var value interface{}
+	if err := c.codecOf(key).Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
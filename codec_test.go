@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type codecTestValue struct {
+	Name    string
+	Count   int
+	Ratio   float64
+	Tags    []string
+	Data    []byte
+	Attrs   map[string]int
+	Created time.Time
+	Next    *codecTestValue
+	Skipped string `msgpack:"-" json:"-"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	original := codecTestValue{
+		Name:    "widget",
+		Count:   -300,
+		Ratio:   0.25,
+		Tags:    []string{"a", "b"},
+		Data:    []byte{0, 1, 2},
+		Attrs:   map[string]int{"x": 1, "y": 70000},
+		Created: time.Unix(1700000000, 123).UTC(),
+		Next:    &codecTestValue{Name: "child"},
+		Skipped: "ignored",
+	}
+
+	codecs := map[string]Codec{
+		"gob":     GobCodec,
+		"json":    JSONCodec,
+		"msgpack": MsgpackCodec,
+	}
+	for name, codec := range codecs {
+		data, err := codec.Marshal(original)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal: %v", name, err)
+		}
+
+		var decoded codecTestValue
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: failed to unmarshal: %v", name, err)
+		}
+
+		want := original
+		want.Skipped = ""
+		if name == "gob" {
+			want.Skipped = original.Skipped
+		}
+		if !reflect.DeepEqual(decoded, want) {
+			t.Errorf("%s: round trip mismatch:\n got %+v\nwant %+v", name, decoded, want)
+		}
+	}
+}
+
+func TestMsgpackInterfaceValues(t *testing.T) {
+	values := []interface{}{
+		nil, true, int64(-1), int64(-129), int64(1 << 40), uint64(1 << 63),
+		float64(1.5), "short", string(make([]byte, 300)), []byte("bin"),
+		[]interface{}{int64(1), "two"}, map[string]interface{}{"k": "v"},
+	}
+
+	for _, want := range values {
+		data, err := MsgpackCodec.Marshal(want)
+		if err != nil {
+			t.Fatalf("Failed to marshal %v: %v", want, err)
+		}
+		var got interface{}
+		if err := MsgpackCodec.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Failed to unmarshal %v: %v", want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %#v, got %#v", want, got)
+		}
+	}
+}
+
+func TestCacheMarshalValue(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Namespace("json", NamespaceOptions{Codec: JSONCodec})
+
+	for key, want := range map[string]interface{}{
+		"plain":                           3,
+		"json" + namespaceSeparator + "k": float64(3),
+	} {
+		data, err := cache.MarshalValue(key, 3)
+		if err != nil {
+			t.Fatalf("MarshalValue(%q): %v", key, err)
+		}
+		value, err := cache.UnmarshalValue(key, data)
+		if err != nil || value != want {
+			t.Errorf("Expected %q to round-trip to %#v, got %#v (%v)", key, want, value, err)
+		}
+	}
+}
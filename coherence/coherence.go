@@ -0,0 +1,279 @@
+// Package coherence keeps the in-process caches of a fleet of application
+// servers consistent. Each server changes its cache through a Coherence,
+// which applies the change locally and publishes an invalidation on a
+// message bus; the other servers drop the affected keys when they receive
+// it, so their next read loads the current value.
+//
+//	bus := coherence.NewRedisBus("redis:6379", "cache-invalidations", coherence.RedisOptions{})
+//	co := coherence.New(cache, bus, coherence.Options{})
+//	co.Set("user:42", user) // other servers drop their copy of user:42
+//
+// NewRedisBus and NewNATSBus provide buses over Redis pub/sub and NATS
+// subjects. Other brokers can be used by implementing Bus.
+//
+// Delivery is at most once: an invalidation published while a server is
+// disconnected from the bus, or dropped by the bus, is lost. To bound the
+// damage, a server flushes its cache whenever it reconnects after losing
+// its subscription, and whenever the sequence numbers of the messages of
+// another server show a gap; values should still carry TTLs.
+package coherence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gocache"
+)
+
+// Bus carries invalidation messages between servers.
+type Bus interface {
+	// Publish sends msg to every subscriber, including the sender's own
+	// subscription.
+	Publish(ctx context.Context, msg []byte) error
+
+	// Subscribe calls handle for every message published until ctx is
+	// done, in which case it returns ctx.Err(), or the subscription fails.
+	// Calls of handle do not overlap.
+	Subscribe(ctx context.Context, handle func(msg []byte)) error
+}
+
+// Default option values.
+const (
+	DefaultPublishTimeout = 5 * time.Second
+	DefaultRetryInterval  = time.Second
+)
+
+// Options configures a Coherence. Zero values select the defaults.
+type Options struct {
+	// PublishTimeout bounds each Publish call.
+	PublishTimeout time.Duration
+
+	// RetryInterval is the delay before subscribing again after the
+	// subscription failed.
+	RetryInterval time.Duration
+
+	// OnError, if set, is called with errors of the bus that are not
+	// returned to a caller, such as failed subscriptions and malformed
+	// messages, for logging.
+	OnError func(err error)
+}
+
+// Operations carried by messages.
+const (
+	opDelete     = "delete"
+	opFlush      = "flush"
+	opInvalidate = "invalidate_prefix"
+)
+
+// message is an invalidation on the bus.
+type message struct {
+	Origin string   `json:"origin"`
+	Seq    uint64   `json:"seq"` // numbers the messages of Origin from 1
+	Op     string   `json:"op"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+}
+
+// Coherence applies changes to a local cache and propagates invalidations
+// to the caches of other servers through a bus.
+type Coherence struct {
+	cache   *gocache.Cache
+	bus     Bus
+	options Options
+	id      string // identifies our own messages
+	seq     atomic.Uint64
+
+	// last is the sequence number of the last message received from each
+	// other server. It is only used by the subscription goroutine.
+	last map[string]uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a coherence layer for c on bus, and starts applying the
+// invalidations published by other servers. It stops when Close is called
+// or c is closed.
+func New(c *gocache.Cache, bus Bus, options Options) *Coherence {
+	if options.PublishTimeout <= 0 {
+		options.PublishTimeout = DefaultPublishTimeout
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = DefaultRetryInterval
+	}
+	var id [8]byte
+	rand.Read(id[:])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	co := &Coherence{
+		cache:   c,
+		bus:     bus,
+		options: options,
+		id:      hex.EncodeToString(id[:]),
+		last:    make(map[string]uint64),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	c.OnShutdown(gocache.PhaseStopIntake, "coherence", func(context.Context) error {
+		co.Close()
+		return nil
+	})
+	go co.subscribe(ctx)
+	return co
+}
+
+// Close stops applying invalidations from other servers and waits for the
+// subscription to end.
+func (co *Coherence) Close() {
+	co.cancel()
+	<-co.done
+}
+
+// Set stores value under key with the cache's default expiration and
+// invalidates the key on the other servers.
+func (co *Coherence) Set(key string, value interface{}) error {
+	if err := co.cache.Set(key, value); err != nil {
+		return err
+	}
+	return co.publish(message{Op: opDelete, Keys: []string{key}})
+}
+
+// SetWithExpiration stores value under key for duration and invalidates
+// the key on the other servers.
+func (co *Coherence) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	if err := co.cache.SetWithExpiration(key, value, duration); err != nil {
+		return err
+	}
+	return co.publish(message{Op: opDelete, Keys: []string{key}})
+}
+
+// Delete removes keys from the local cache and from the other servers.
+func (co *Coherence) Delete(keys ...string) error {
+	for _, key := range keys {
+		co.cache.Delete(key)
+	}
+	return co.publish(message{Op: opDelete, Keys: keys})
+}
+
+// Invalidate removes keys from the other servers only, for example after
+// the source of truth changed without this server caching the key.
+func (co *Coherence) Invalidate(keys ...string) error {
+	return co.publish(message{Op: opDelete, Keys: keys})
+}
+
+// InvalidatePrefix removes all keys starting with prefix, locally and on
+// the other servers. It is the way to drop a group of related keys that
+// share a prefix, such as every key of a tenant.
+func (co *Coherence) InvalidatePrefix(prefix string) error {
+	deletePrefix(co.cache, prefix)
+	return co.publish(message{Op: opInvalidate, Prefix: prefix})
+}
+
+// FlushNamespace flushes ns locally and removes its keys on the other
+// servers.
+func (co *Coherence) FlushNamespace(ns *gocache.Namespace) error {
+	ns.Flush()
+	return co.publish(message{Op: opInvalidate, Prefix: ns.Name() + ":"})
+}
+
+// Flush removes all items locally and on the other servers.
+func (co *Coherence) Flush() error {
+	co.cache.Flush()
+	return co.publish(message{Op: opFlush})
+}
+
+// publish sends an invalidation to the other servers.
+func (co *Coherence) publish(m message) error {
+	m.Origin = co.id
+	m.Seq = co.seq.Add(1)
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), co.options.PublishTimeout)
+	defer cancel()
+	return co.bus.Publish(ctx, data)
+}
+
+// subscribe applies invalidations until ctx is done, subscribing again
+// after failures.
+func (co *Coherence) subscribe(ctx context.Context) {
+	defer close(co.done)
+
+	for {
+		err := co.bus.Subscribe(ctx, co.apply)
+		if ctx.Err() != nil {
+			return
+		}
+		co.report(err)
+
+		select {
+		case <-time.After(co.options.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+		// Invalidations may have been missed while disconnected.
+		co.cache.Flush()
+		clear(co.last)
+	}
+}
+
+// apply applies an invalidation from another server.
+func (co *Coherence) apply(data []byte) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		co.report(err)
+		return
+	}
+	if m.Origin == co.id {
+		return
+	}
+	if last, ok := co.last[m.Origin]; ok && m.Seq > last+1 {
+		// Which keys the missed invalidations were for is unknown.
+		co.cache.Flush()
+		co.report(fmt.Errorf("coherence: missed %d invalidations from %s, flushed the cache", m.Seq-last-1, m.Origin))
+	}
+	if m.Seq > co.last[m.Origin] {
+		co.last[m.Origin] = m.Seq
+	}
+
+	switch m.Op {
+	case opDelete:
+		for _, key := range m.Keys {
+			co.cache.Delete(key)
+		}
+	case opInvalidate:
+		deletePrefix(co.cache, m.Prefix)
+	case opFlush:
+		co.cache.Flush()
+	}
+}
+
+// report passes an error to Options.OnError.
+func (co *Coherence) report(err error) {
+	if err != nil && co.options.OnError != nil {
+		co.options.OnError(err)
+	}
+}
+
+// deletePrefix removes the keys of c starting with prefix.
+func deletePrefix(c *gocache.Cache, prefix string) {
+	var keys []string
+	c.RangeChunks(1024, func(entries []gocache.Entry) error {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Key, prefix) {
+				keys = append(keys, e.Key)
+			}
+		}
+		return nil
+	})
+	for _, key := range keys {
+		c.Delete(key)
+	}
+}
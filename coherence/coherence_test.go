@@ -0,0 +1,190 @@
+package coherence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// memBus is an in-memory Bus shared by the servers of a test.
+type memBus struct {
+	mu   sync.Mutex
+	subs []chan []byte
+	fail chan struct{} // closing it fails the current subscriptions
+}
+
+func newMemBus() *memBus {
+	return &memBus{fail: make(chan struct{})}
+}
+
+func (b *memBus) Publish(ctx context.Context, msg []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		ch <- msg
+	}
+	return nil
+}
+
+func (b *memBus) Subscribe(ctx context.Context, handle func(msg []byte)) error {
+	ch := make(chan []byte, 100)
+	b.mu.Lock()
+	b.subs = append(b.subs, ch)
+	fail := b.fail
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		for i, sub := range b.subs {
+			if sub == ch {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-ch:
+			handle(msg)
+		case <-fail:
+			return errors.New("connection lost")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// subscribers waits until the bus has n subscribers.
+func (b *memBus) subscribers(t *testing.T, n int) {
+	t.Helper()
+	waitFor(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.subs) == n
+	})
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fleet starts n servers on bus.
+func fleet(t *testing.T, bus Bus, n int, options Options) ([]*gocache.Cache, []*Coherence) {
+	caches := make([]*gocache.Cache, n)
+	cos := make([]*Coherence, n)
+	for i := range caches {
+		caches[i] = gocache.New(gocache.Options{})
+		cos[i] = New(caches[i], bus, options)
+		cache := caches[i]
+		t.Cleanup(func() { cache.Close(context.Background()) })
+	}
+	return caches, cos
+}
+
+func TestCoherence(t *testing.T) {
+	bus := newMemBus()
+	caches, cos := fleet(t, bus, 2, Options{})
+	a, b := caches[0], caches[1]
+	bus.subscribers(t, 2)
+
+	b.Set("user:1", "old")
+	if err := cos[0].Set("user:1", "new"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { _, err := b.Get("user:1"); return err != nil })
+	if v, _ := a.Get("user:1"); v != "new" {
+		t.Errorf("Expected the publisher to keep its own value, got %v", v)
+	}
+
+	b.Set("user:2", "x")
+	b.Set("user:3", "x")
+	cos[0].Delete("user:2", "user:3")
+	waitFor(t, func() bool { return b.ItemCount() == 0 })
+
+	b.Set("tenant:1:a", "x")
+	b.Set("tenant:1:b", "x")
+	b.Set("tenant:2:a", "x")
+	cos[0].InvalidatePrefix("tenant:1:")
+	waitFor(t, func() bool { return b.ItemCount() == 1 })
+	if _, err := b.Get("tenant:2:a"); err != nil {
+		t.Error("Expected other prefixes to be kept")
+	}
+
+	b.Namespace("sessions", gocache.NamespaceOptions{}).Set("s1", "x")
+	cos[0].FlushNamespace(a.Namespace("sessions", gocache.NamespaceOptions{}))
+	waitFor(t, func() bool { return b.ItemCount() == 1 })
+
+	cos[1].Flush()
+	waitFor(t, func() bool { return b.ItemCount() == 0 })
+}
+
+func TestCoherenceReconnectFlushes(t *testing.T) {
+	bus := newMemBus()
+	var errs int
+	var mu sync.Mutex
+	caches, _ := fleet(t, bus, 1, Options{
+		RetryInterval: time.Millisecond,
+		OnError: func(error) {
+			mu.Lock()
+			errs++
+			mu.Unlock()
+		},
+	})
+	bus.subscribers(t, 1)
+	caches[0].Set("a", 1)
+
+	bus.mu.Lock()
+	close(bus.fail)
+	bus.fail = make(chan struct{})
+	bus.mu.Unlock()
+
+	waitFor(t, func() bool { return caches[0].ItemCount() == 0 })
+	bus.subscribers(t, 1)
+	mu.Lock()
+	defer mu.Unlock()
+	if errs != 1 {
+		t.Errorf("Expected the lost subscription to be reported once, got %d", errs)
+	}
+}
+
+func TestCoherenceGapFlushes(t *testing.T) {
+	bus := newMemBus()
+	var errs atomic.Int64
+	caches, _ := fleet(t, bus, 1, Options{OnError: func(error) { errs.Add(1) }})
+	bus.subscribers(t, 1)
+	c := caches[0]
+	c.Set("a", 1)
+	c.Set("b", 1)
+
+	publish := func(seq uint64, key string) {
+		data, _ := json.Marshal(message{Origin: "other", Seq: seq, Op: opDelete, Keys: []string{key}})
+		bus.Publish(context.Background(), data)
+	}
+	publish(1, "a")
+	waitFor(t, func() bool { _, err := c.Get("a"); return err != nil })
+	if _, err := c.Get("b"); err != nil {
+		t.Fatalf("Expected b to be kept without a gap, got %v", err)
+	}
+
+	// Message 2 was lost.
+	c.Set("a", 1)
+	publish(3, "a")
+	waitFor(t, func() bool { return c.ItemCount() == 0 })
+	if n := errs.Load(); n != 1 {
+		t.Errorf("Expected the gap to be reported once, got %d", n)
+	}
+}
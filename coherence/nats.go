@@ -0,0 +1,254 @@
+package coherence
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSOptions configures a NATSBus.
+type NATSOptions struct {
+	// Username and Password, or Token, authenticate the connection if set.
+	Username string
+	Password string
+	Token    string
+
+	// DialTimeout bounds connecting to the server.
+	DialTimeout time.Duration
+}
+
+// NATSBus is a Bus on a NATS subject. It speaks the NATS client protocol
+// directly, so it needs no client library.
+type NATSBus struct {
+	addr    string
+	subject string
+	options NATSOptions
+
+	mu  sync.Mutex // guards pub and serializes publishing
+	pub *natsConn
+}
+
+// NewNATSBus returns a bus on subject of the NATS server at addr, such as
+// "nats:4222". It connects lazily, on first use.
+func NewNATSBus(addr, subject string, options NATSOptions) *NATSBus {
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DefaultDialTimeout
+	}
+	return &NATSBus{addr: addr, subject: subject, options: options}
+}
+
+// Publish publishes msg on the subject and waits for the server to
+// acknowledge it, reconnecting once if the connection was lost.
+func (b *NATSBus) Publish(ctx context.Context, msg []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if b.pub == nil {
+			conn, err := b.dial(ctx)
+			if err != nil {
+				return err
+			}
+			b.pub = conn
+		}
+		err := b.pub.publish(ctx, b.subject, msg)
+		var natsErr natsError
+		if err == nil || errors.As(err, &natsErr) {
+			return err
+		}
+		// The connection is broken; retry once on a new one.
+		b.pub.Close()
+		b.pub = nil
+		if attempt == 1 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// Subscribe subscribes to the subject on a connection of its own and calls
+// handle for each message.
+func (b *NATSBus) Subscribe(ctx context.Context, handle func(msg []byte)) error {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Close the connection to interrupt the blocking read when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := io.WriteString(conn, "SUB "+b.subject+" 1\r\n"); err != nil {
+		return err
+	}
+	for {
+		payload, err := conn.next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if payload != nil {
+			handle(payload)
+		}
+	}
+}
+
+// Close closes the publishing connection. Subscriptions end with their
+// contexts.
+func (b *NATSBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pub == nil {
+		return nil
+	}
+	err := b.pub.Close()
+	b.pub = nil
+	return err
+}
+
+// dial connects and performs the handshake: the server's INFO, then our
+// CONNECT, confirmed by a PING round trip.
+func (b *NATSBus) dial(ctx context.Context) (*natsConn, error) {
+	d := net.Dialer{Timeout: b.options.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &natsConn{Conn: nc, r: bufio.NewReader(nc)}
+	if err := conn.handshake(ctx, b.options); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// natsError is an -ERR message from the server.
+type natsError string
+
+func (e natsError) Error() string {
+	return "coherence: nats: " + string(e)
+}
+
+// natsConn is a connection speaking the NATS protocol.
+type natsConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *natsConn) handshake(ctx context.Context, options NATSOptions) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(options.DialTimeout)
+	}
+	c.SetDeadline(deadline)
+	defer c.SetDeadline(time.Time{})
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("coherence: nats: expected INFO, got %q", line)
+	}
+
+	connect, err := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       "gocache-coherence",
+		"lang":       "go",
+		"protocol":   1,
+		"user":       options.Username,
+		"pass":       options.Password,
+		"auth_token": options.Token,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(c, "CONNECT "+string(connect)+"\r\nPING\r\n"); err != nil {
+		return err
+	}
+	return c.awaitPong()
+}
+
+// publish sends a message followed by a PING, and waits for the PONG that
+// confirms the server processed it.
+func (c *natsConn) publish(ctx context.Context, subject string, msg []byte) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	var b strings.Builder
+	b.WriteString("PUB " + subject + " " + strconv.Itoa(len(msg)) + "\r\n")
+	b.Write(msg)
+	b.WriteString("\r\nPING\r\n")
+	if _, err := io.WriteString(c, b.String()); err != nil {
+		return err
+	}
+	return c.awaitPong()
+}
+
+// awaitPong reads until a PONG, answering the server's PINGs.
+func (c *natsConn) awaitPong() error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(c, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(strings.Trim(strings.TrimSpace(line[4:]), "'"))
+		}
+	}
+}
+
+// next reads the next message of a subscription, returning its payload, or
+// nil for protocol messages that carry none.
+func (c *natsConn) next() ([]byte, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case line == "PING":
+		_, err := io.WriteString(c, "PONG\r\n")
+		return nil, err
+	case strings.HasPrefix(line, "-ERR"):
+		return nil, natsError(strings.Trim(strings.TrimSpace(line[4:]), "'"))
+	case !strings.HasPrefix(line, "MSG "):
+		return nil, nil
+	}
+
+	// MSG <subject> <sid> [reply-to] <#bytes>
+	fields := strings.Fields(line)
+	n, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("coherence: nats: malformed %q", line)
+	}
+	payload := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return nil, err
+	}
+	return payload[:n], nil
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
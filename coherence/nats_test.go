@@ -0,0 +1,155 @@
+package coherence
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is a NATS server supporting CONNECT with a token, PING, PUB
+// and SUB.
+type fakeNATS struct {
+	l     net.Listener
+	token string
+
+	mu    sync.Mutex
+	subs  map[net.Conn]string // connection to subscribed subject
+	conns []net.Conn
+}
+
+func startFakeNATS(t *testing.T, token string) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{l: l, token: token, subs: make(map[net.Conn]string)}
+	t.Cleanup(func() { l.Close(); s.dropConns() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, nc)
+			s.mu.Unlock()
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+// dropConns closes all client connections.
+func (s *fakeNATS) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nc := range s.conns {
+		nc.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeNATS) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *fakeNATS) serve(nc net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+
+	r := bufio.NewReader(nc)
+	io.WriteString(nc, `INFO {"server_id":"fake","max_payload":1048576}`+"\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			var connect struct {
+				Token string `json:"auth_token"`
+			}
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &connect)
+			if connect.Token != s.token {
+				io.WriteString(nc, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			io.WriteString(nc, "PONG\r\n")
+		case "SUB":
+			s.mu.Lock()
+			s.subs[nc] = fields[1]
+			s.mu.Unlock()
+		case "PUB":
+			n, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			for sub, subject := range s.subs {
+				if subject == fields[1] {
+					io.WriteString(sub, "MSG "+subject+" 1 "+fields[2]+"\r\n"+string(payload))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestNATSBus(t *testing.T) {
+	server := startFakeNATS(t, "secret")
+	addr := server.l.Addr().String()
+
+	bus := NewNATSBus(addr, "invalidations", NATSOptions{Token: "secret"})
+	defer bus.Close()
+	caches, cos := fleet(t, bus, 2, Options{RetryInterval: 10 * time.Millisecond})
+	waitFor(t, func() bool { return server.subscribers() == 2 })
+
+	caches[1].Set("k", "old")
+	if err := cos[0].Set("k", "new"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitFor(t, func() bool { _, err := caches[1].Get("k"); return err != nil })
+
+	// After the server drops every connection, publishing reconnects and
+	// the subscriptions are restored.
+	server.dropConns()
+	waitFor(t, func() bool { return server.subscribers() == 2 })
+	caches[1].Set("k", "old")
+	if err := cos[0].Delete("k"); err != nil {
+		t.Fatalf("Publish after reconnecting failed: %v", err)
+	}
+	waitFor(t, func() bool { _, err := caches[1].Get("k"); return err != nil })
+}
+
+func TestNATSBusAuth(t *testing.T) {
+	server := startFakeNATS(t, "secret")
+	bus := NewNATSBus(server.l.Addr().String(), "s", NATSOptions{Token: "wrong"})
+	defer bus.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var natsErr natsError
+	if err := bus.Publish(ctx, []byte("x")); !errors.As(err, &natsErr) {
+		t.Errorf("Expected an auth error, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package coherence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gocache/internal/resp"
+)
+
+// DefaultDialTimeout is the dial timeout used when RedisOptions.DialTimeout
+// is 0.
+const DefaultDialTimeout = 5 * time.Second
+
+// RedisOptions configures a RedisBus.
+type RedisOptions struct {
+	// Username and Password authenticate with AUTH if Password is set.
+	// Username may be empty for servers without ACL users.
+	Username string
+	Password string
+
+	// DialTimeout bounds connecting to the server.
+	DialTimeout time.Duration
+}
+
+// RedisBus is a Bus on a Redis pub/sub channel. It speaks the Redis
+// protocol directly, so it needs no client library.
+type RedisBus struct {
+	addr    string
+	channel string
+	options RedisOptions
+
+	mu  sync.Mutex // guards pub and serializes publishing
+	pub *resp.Conn
+}
+
+// NewRedisBus returns a bus on channel of the Redis server at addr. It
+// connects lazily, on first use.
+func NewRedisBus(addr, channel string, options RedisOptions) *RedisBus {
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DefaultDialTimeout
+	}
+	return &RedisBus{addr: addr, channel: channel, options: options}
+}
+
+// Publish publishes msg on the channel with PUBLISH, reconnecting once if
+// the connection was lost.
+func (b *RedisBus) Publish(ctx context.Context, msg []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if b.pub == nil {
+			conn, err := b.dial(ctx)
+			if err != nil {
+				return err
+			}
+			b.pub = conn
+		}
+		_, err := b.pub.Do(ctx, "PUBLISH", b.channel, string(msg))
+		var redisErr resp.Error
+		if err == nil || errors.As(err, &redisErr) {
+			return err
+		}
+		// The connection is broken; retry once on a new one.
+		b.pub.Close()
+		b.pub = nil
+		if attempt == 1 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// Subscribe subscribes to the channel on a connection of its own and calls
+// handle for each message.
+func (b *RedisBus) Subscribe(ctx context.Context, handle func(msg []byte)) error {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Close the connection to interrupt the blocking read when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.Send("SUBSCRIBE", b.channel); err != nil {
+		return err
+	}
+	for {
+		reply, err := conn.Read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		push, ok := reply.([]interface{})
+		if !ok || len(push) != 3 {
+			continue
+		}
+		if kind, _ := push[0].(string); kind == "message" {
+			data, _ := push[2].(string)
+			handle([]byte(data))
+		}
+	}
+}
+
+// Close closes the publishing connection. Subscriptions end with their
+// contexts.
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pub == nil {
+		return nil
+	}
+	err := b.pub.Close()
+	b.pub = nil
+	return err
+}
+
+// dial connects and authenticates.
+func (b *RedisBus) dial(ctx context.Context) (*resp.Conn, error) {
+	conn, err := resp.Dial(ctx, b.addr, resp.DialOptions{
+		Username: b.options.Username,
+		Password: b.options.Password,
+		Timeout:  b.options.DialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("coherence: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,143 @@
+package coherence
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gocache/internal/resp"
+)
+
+// fakeRedis is a Redis server supporting AUTH, PUBLISH and SUBSCRIBE on
+// one channel.
+type fakeRedis struct {
+	l        net.Listener
+	password string
+
+	mu    sync.Mutex
+	subs  map[*resp.Conn]bool
+	conns []net.Conn
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{l: l, password: password, subs: make(map[*resp.Conn]bool)}
+	t.Cleanup(func() { l.Close(); s.dropConns() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, nc)
+			s.mu.Unlock()
+			go s.serve(resp.NewConn(nc))
+		}
+	}()
+	return s
+}
+
+// dropConns closes all client connections.
+func (s *fakeRedis) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nc := range s.conns {
+		nc.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeRedis) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *fakeRedis) serve(conn *resp.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	authed := s.password == ""
+	for {
+		reply, err := conn.Read()
+		if err != nil {
+			return
+		}
+		args := reply.([]interface{})
+		switch cmd := args[0].(string); {
+		case cmd == "AUTH":
+			authed = args[len(args)-1] == s.password
+			if authed {
+				conn.Write([]byte("+OK\r\n"))
+			} else {
+				conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+			}
+		case !authed:
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+		case cmd == "SUBSCRIBE":
+			s.mu.Lock()
+			s.subs[conn] = true
+			s.mu.Unlock()
+			conn.Send("subscribe", args[1].(string), "1")
+		case cmd == "PUBLISH":
+			s.mu.Lock()
+			for sub := range s.subs {
+				sub.Send("message", args[1].(string), args[2].(string))
+			}
+			n := len(s.subs)
+			s.mu.Unlock()
+			conn.Write([]byte(":" + strconv.Itoa(n) + "\r\n"))
+		}
+	}
+}
+
+func TestRedisBus(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	addr := server.l.Addr().String()
+
+	bus := NewRedisBus(addr, "invalidations", RedisOptions{Password: "secret"})
+	defer bus.Close()
+	caches, cos := fleet(t, bus, 2, Options{RetryInterval: 10 * time.Millisecond})
+	waitFor(t, func() bool { return server.subscribers() == 2 })
+
+	caches[1].Set("k", "old")
+	if err := cos[0].Set("k", "new"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitFor(t, func() bool { _, err := caches[1].Get("k"); return err != nil })
+
+	// After the server drops every connection, publishing reconnects and
+	// the subscriptions are restored.
+	server.dropConns()
+	waitFor(t, func() bool { return server.subscribers() == 2 })
+	caches[1].Set("k", "old")
+	if err := cos[0].Delete("k"); err != nil {
+		t.Fatalf("Publish after reconnecting failed: %v", err)
+	}
+	waitFor(t, func() bool { _, err := caches[1].Get("k"); return err != nil })
+}
+
+func TestRedisBusAuth(t *testing.T) {
+	server := startFakeRedis(t, "secret")
+	bus := NewRedisBus(server.l.Addr().String(), "c", RedisOptions{Password: "wrong"})
+	defer bus.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var redisErr resp.Error
+	if err := bus.Publish(ctx, []byte("x")); !errors.As(err, &redisErr) {
+		t.Errorf("Expected an auth error, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses encoded values. Implementations for formats outside
+// the standard library, such as snappy or zstd, can be plugged in through
+// Options.Compression.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompression compresses values with compress/gzip.
+var GzipCompression Compressor = gzipCompressor{}
+
+// DefaultCompressionThreshold is the encoded size in bytes from which values
+// are compressed when Options.CompressionThreshold is 0.
+const DefaultCompressionThreshold = 1024
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressedValue is a value encoded with the cache's codec and compressed,
+// as held in the store in place of the value itself.
+type compressedValue []byte
+
+// compress returns the value to store for value: a compressedValue if
+// compression is enabled and the encoded value, other than a Blob, reaches
+// the threshold, otherwise value itself.
+func (c *Cache) compress(value interface{}) (interface{}, error) {
+	if _, ok := value.(Blob); c.compression == nil || ok {
+		return value, nil
+	}
+
+	data, err := c.codec.Marshal(&value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.compressionThreshold {
+		return value, nil
+	}
+
+	compressed, err := c.compression.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	return compressedValue(compressed), nil
+}
+
+// expand returns the original value of a stored value, copied if
+// Options.CopyOnGet is set.
+func (c *Cache) expand(stored interface{}) (interface{}, error) {
+	compressed, ok := stored.(compressedValue)
+	if !ok {
+		return c.copyValue(stored)
+	}
+
+	// A decompressed value is decoded afresh, so it is a copy already.
+
+	data, err := c.compression.Decompress(compressed)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := c.codec.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// valueOf is like expand for places that cannot report an error, such as
+// callbacks and notifications. A value that fails to expand is passed on
+// as nil.
+func (c *Cache) valueOf(stored interface{}) interface{} {
+	value, _ := c.expand(stored)
+	return value
+}
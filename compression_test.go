@@ -0,0 +1,64 @@
+package gocache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCacheCompression(t *testing.T) {
+	blob := strings.Repeat(`{"id":1,"name":"widget"},`, 1000)
+
+	for _, storage := range []Storage{MapStorage, BytesStorage} {
+		cache := New(Options{Compression: GzipCompression, Storage: storage})
+		events := cache.Notifications(10)
+
+		cache.Set("blob", blob)
+		cache.Set("small", "tiny")
+
+		item, _ := cache.items.get("blob")
+		compressed, ok := item.Value.(compressedValue)
+		if !ok || len(compressed) >= len(blob)/10 {
+			t.Errorf("storage %d: Expected large value to be compressed, got %T", storage, item.Value)
+		}
+		if item, _ := cache.items.get("small"); item.Value != "tiny" {
+			t.Errorf("storage %d: Expected small value to be stored as is, got %v", storage, item.Value)
+		}
+
+		if value, err := cache.Get("blob"); err != nil || value != blob {
+			t.Errorf("storage %d: Expected decompressed value, got error %v", storage, err)
+		}
+		if items := cache.Items(); items["blob"] != blob {
+			t.Errorf("storage %d: Expected Items to decompress values", storage)
+		}
+
+		cache.Delete("blob")
+		<-events
+		<-events
+		if ev := <-events; ev.Type != EventDeleted || ev.Value != blob {
+			t.Errorf("storage %d: Expected deleted event with original value, got %v", storage, ev.Type)
+		}
+		cache.Stop()
+	}
+}
+
+func TestCacheCompressionSnapshot(t *testing.T) {
+	blob := strings.Repeat("abc", 1000)
+	cache := New(Options{Compression: GzipCompression, CompressionThreshold: 100})
+	defer cache.Stop()
+	cache.Set("blob", blob)
+
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := New(Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if value, _ := restored.Get("blob"); value != blob {
+		t.Error("Expected snapshot to hold the uncompressed value")
+	}
+}
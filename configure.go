@@ -0,0 +1,60 @@
+package gocache
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Configure changes the settings of a running cache without losing its
+// contents, for example when a service receives a configuration push. It
+// applies options.DefaultExpiration, CleanupInterval, MaxEntries and
+// MaxCost, and ignores the other fields, which can only be set with New.
+//
+// A new DefaultExpiration applies to items stored from now on; items
+// already cached keep their expiration. A new CleanupInterval restarts the
+// automatic cleanup, or stops it if 0. If the new limits are below the
+// current contents, items are evicted as by a Set, and OnEvicted is called
+// for them before Configure returns. With Options.AutoTune, the tuner
+// continues from the new limits.
+//
+// Configure returns an error matching ErrInvalidOptions, and changes
+// nothing, if options.Validate fails, or if options sets a limit on a
+// cache created without MaxEntries, MaxCost or MemoryPressure, which has
+// no eviction policy to enforce it.
+func (c *Cache) Configure(options Options) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.policy == nil && (options.MaxEntries > 0 || options.MaxCost > 0) {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: cannot limit a cache created without limits", ErrInvalidOptions)
+	}
+	c.defaultTTL.Store(int64(options.DefaultExpiration))
+	c.maxEntries, c.maxCost = options.MaxEntries, options.MaxCost
+	evicted := c.shrinkToLimits()
+	c.rearmSoftLimits()
+	c.unlock()
+	c.notifyEvicted(evicted)
+
+	if c.cleanupInterval() != options.CleanupInterval {
+		c.startJanitor(options.CleanupInterval)
+	}
+	c.log(slog.LevelInfo, "cache reconfigured",
+		"defaultExpiration", options.DefaultExpiration,
+		"cleanupInterval", options.CleanupInterval,
+		"maxEntries", options.MaxEntries,
+		"maxCost", options.MaxCost,
+		"evicted", len(evicted))
+	return nil
+}
+
+// cleanupInterval returns the interval of the automatic cleanup, or 0 if
+// it is disabled.
+func (c *Cache) cleanupInterval() time.Duration {
+	c.janitor.mu.Lock()
+	defer c.janitor.mu.Unlock()
+	return c.janitor.interval
+}
@@ -0,0 +1,109 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCacheConfigure(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []string
+	cache := New(Options{
+		DefaultExpiration: time.Minute,
+		MaxEntries:        10,
+		Clock:             clock,
+		OnEvicted:         func(key string, _ interface{}) { evicted = append(evicted, key) },
+	})
+	defer cache.Stop()
+
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	cache.Get("key0")
+
+	err := cache.Configure(Options{DefaultExpiration: time.Hour, CleanupInterval: time.Minute, MaxEntries: 3})
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if n := cache.ItemCount(); n != 3 {
+		t.Errorf("Expected 3 items after shrinking MaxEntries, got %d", n)
+	}
+	if len(evicted) != 2 || evicted[0] != "key1" || evicted[1] != "key2" {
+		t.Errorf("Expected key1 and key2 to be evicted, got %v", evicted)
+	}
+	if max, _ := cache.Capacity(); max != 3 {
+		t.Errorf("Expected MaxEntries 3, got %d", max)
+	}
+
+	// key0 keeps its old expiration; new items get the new default, and
+	// the janitor started by Configure removes expired ones.
+	cache.Set("fresh", 1)
+	clock.Advance(2 * time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for cache.ItemCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := cache.Get("fresh"); err != nil || cache.ItemCount() != 1 {
+		t.Errorf("Expected only fresh to remain after cleanup, got %d items, err %v", cache.ItemCount(), err)
+	}
+
+	if err := cache.Configure(Options{MaxEntries: -1}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions for a negative limit, got %v", err)
+	}
+	if max, _ := cache.Capacity(); max != 3 {
+		t.Errorf("Expected an invalid Configure to change nothing, got MaxEntries %d", max)
+	}
+}
+
+func TestCacheConfigureWithoutPolicy(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	if err := cache.Configure(Options{MaxEntries: 10}); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions when limiting an unlimited cache, got %v", err)
+	}
+	if err := cache.Configure(Options{DefaultExpiration: time.Minute}); err != nil {
+		t.Errorf("Configure: %v", err)
+	}
+}
+
+func TestCacheConfigureAfterStop(t *testing.T) {
+	cache := New(Options{CleanupInterval: time.Minute})
+	cache.Stop()
+
+	if err := cache.Configure(Options{CleanupInterval: time.Second}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if cache.janitor.stop != nil {
+		t.Error("Expected Configure not to restart the janitor of a stopped cache")
+	}
+}
+
+func TestCacheConfigureConcurrent(t *testing.T) {
+	cache := New(Options{MaxCost: 100, Cost: func(string, interface{}) int64 { return 10 }})
+	defer cache.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if err := cache.Set(fmt.Sprint("key", i%50), i); err != nil && !errors.Is(err, ErrCostTooLarge) {
+				t.Errorf("Set: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		maxCost := int64(5 + i%3*50)
+		if err := cache.Configure(Options{MaxCost: maxCost, DefaultExpiration: time.Duration(i) * time.Second}); err != nil {
+			t.Fatalf("Configure: %v", err)
+		}
+	}
+	<-done
+
+	if _, maxCost := cache.Capacity(); maxCost != 5 {
+		t.Errorf("Expected MaxCost 5, got %d", maxCost)
+	}
+}
@@ -0,0 +1,28 @@
+package gocache
+
+// copyValue returns a copy of value if Options.CopyOnGet is set, and value
+// itself otherwise.
+func (c *Cache) copyValue(value interface{}) (interface{}, error) {
+	if !c.copyOnGet || value == nil {
+		return value, nil
+	}
+	if _, immutable := value.(Blob); immutable {
+		return value, nil
+	}
+	if c.clone != nil {
+		return c.clone(value), nil
+	}
+
+	// value escapes through Marshal, so take its address in a copy that
+	// is only allocated when it is needed.
+	v := value
+	data, err := c.codec.Marshal(&v)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	if err := c.codec.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
@@ -0,0 +1,56 @@
+package gocache
+
+import (
+	"testing"
+)
+
+func TestCacheCopyOnGet(t *testing.T) {
+	cache := New(Options{CopyOnGet: true})
+	defer cache.Stop()
+
+	cache.Set("list", []int{1, 2, 3})
+	value, err := cache.Get("list")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	value.([]int)[0] = 100
+
+	value, _ = cache.Get("list")
+	if list := value.([]int); list[0] != 1 {
+		t.Errorf("Expected the cached value to be unchanged, got %v", list)
+	}
+	cache.Range(func(e Entry) bool {
+		e.Value.([]int)[1] = 200
+		return true
+	})
+	if list := cache.Items()["list"].([]int); list[1] != 2 {
+		t.Errorf("Expected Range to pass a copy, got %v", list)
+	}
+}
+
+func TestCacheCopyWithClone(t *testing.T) {
+	type profile struct {
+		Name string
+		Tags []string
+	}
+	clones := 0
+	cache := New(Options{Clone: func(value interface{}) interface{} {
+		clones++
+		p := *value.(*profile)
+		p.Tags = append([]string(nil), p.Tags...)
+		return &p
+	}})
+	defer cache.Stop()
+
+	cache.Set("alice", &profile{Name: "alice", Tags: []string{"admin"}})
+	value, _ := cache.Get("alice")
+	value.(*profile).Tags[0] = "guest"
+
+	value, _ = cache.Get("alice")
+	if tags := value.(*profile).Tags; tags[0] != "admin" {
+		t.Errorf("Expected the cached value to be unchanged, got %v", tags)
+	}
+	if clones != 2 {
+		t.Errorf("Expected 2 clones, got %d", clones)
+	}
+}
@@ -0,0 +1,83 @@
+package gocache
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Increment atomically adds delta to the integer stored under key and
+// returns the result. The value must be an int or an int64, and keeps its
+// type; otherwise, or if the result would overflow, Increment returns
+// ErrNotInteger. A missing or expired key is set to delta, as an int64,
+// with the default expiration. An existing key keeps its expiration and
+// priority. Use a negative delta to decrement.
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	return c.increment(key, delta, c.defaultExpiration())
+}
+
+// IncrementWithExpiration is like Increment, but a missing or expired key
+// is set to delta with an expiration of window, which later increments do
+// not extend. The counter thus counts the increments of a fixed window
+// that starts with the first of them, which is the primitive of
+// fixed-window rate limiting:
+//
+//	n, err := cache.IncrementWithExpiration("api:"+client, 1, time.Minute)
+//	if err == nil && n > limit {
+//		// reject the request
+//	}
+//
+// If window is 0, a new counter does not expire.
+func (c *Cache) IncrementWithExpiration(key string, delta int64, window time.Duration) (int64, error) {
+	if window < 0 {
+		return 0, ErrInvalidTTL
+	}
+	return c.increment(key, delta, window)
+}
+
+// increment implements Increment, giving a new counter an expiration of
+// duration.
+func (c *Cache) increment(key string, delta int64, duration time.Duration) (int64, error) {
+	if err := c.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	var result int64
+	_, err := c.modifyExpiring(key, duration, func(value interface{}, found bool) (interface{}, bool, error) {
+		if !found {
+			result = delta
+			return delta, true, nil
+		}
+
+		n, isInt := toInt64(value)
+		if !isInt {
+			return nil, false, fmt.Errorf("%w: %q holds %T", ErrNotInteger, key, value)
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return nil, false, fmt.Errorf("%w: %q would overflow", ErrNotInteger, key)
+		}
+		result = n + delta
+		if _, isPlainInt := value.(int); isPlainInt {
+			if result > math.MaxInt || result < math.MinInt {
+				return nil, false, fmt.Errorf("%w: %q would overflow", ErrNotInteger, key)
+			}
+			return int(result), true, nil
+		}
+		return result, true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// toInt64 returns value as an int64 if it is an int or an int64.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,105 @@
+package gocache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheIncrement(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if n, err := cache.Increment("missing", 5); err != nil || n != 5 {
+		t.Errorf("Expected a missing key to start at delta, got %d (%v)", n, err)
+	}
+	if value, _ := cache.Get("missing"); value != int64(5) {
+		t.Errorf("Expected int64(5), got %#v", value)
+	}
+
+	cache.SetWithExpiration("int", 10, time.Minute)
+	if n, err := cache.Increment("int", -3); err != nil || n != 7 {
+		t.Errorf("Expected 7, got %d (%v)", n, err)
+	}
+	if value, _ := cache.Get("int"); value != 7 {
+		t.Errorf("Expected an int to stay an int, got %#v", value)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("int"); err != ErrKeyExpired {
+		t.Errorf("Expected Increment to keep the expiration, got %v", err)
+	}
+
+	cache.Set("text", "10")
+	if _, err := cache.Increment("text", 1); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger for a string, got %v", err)
+	}
+	cache.Set("max", int64(math.MaxInt64))
+	if _, err := cache.Increment("max", 1); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger on overflow, got %v", err)
+	}
+}
+
+func TestCacheIncrementConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Increment("counter", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("counter"); value != int64(2000) {
+		t.Errorf("Expected 2000, got %v", value)
+	}
+}
+
+func TestCacheIncrementWithExpiration(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, DefaultExpiration: time.Hour})
+	defer cache.Stop()
+
+	for i := int64(1); i <= 3; i++ {
+		if n, err := cache.IncrementWithExpiration("hits", 1, time.Minute); err != nil || n != i {
+			t.Fatalf("Expected %d, got %d (%v)", i, n, err)
+		}
+		clock.Advance(15 * time.Second)
+	}
+	_, expires, _ := cache.GetWithExpiration("hits")
+	if want := clock.Now().Add(15 * time.Second); !expires.Equal(want) {
+		t.Errorf("Expected the window set by the first increment to end at %v, got %v", want, expires)
+	}
+
+	clock.Advance(30 * time.Second)
+	if n, err := cache.IncrementWithExpiration("hits", 1, time.Minute); err != nil || n != 1 {
+		t.Errorf("Expected a new window to start at 1, got %d (%v)", n, err)
+	}
+	if _, err := cache.IncrementWithExpiration("hits", 1, -time.Second); err != ErrInvalidTTL {
+		t.Errorf("Expected ErrInvalidTTL for a negative window, got %v", err)
+	}
+}
+
+func TestCacheIncrementWithExpirationQueued(t *testing.T) {
+	cache, _ := newStalledAsyncCache(t)
+
+	// The counter queued by SetAsync keeps its window.
+	if err := cache.SetAsyncWithExpiration("hits", int64(5), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, queuedExpires, _ := cache.GetWithExpiration("hits")
+	if n, err := cache.IncrementWithExpiration("hits", 1, time.Hour); err != nil || n != 6 {
+		t.Fatalf("Expected the queued counter to reach 6, got %d (%v)", n, err)
+	}
+	if _, expires, _ := cache.GetWithExpiration("hits"); !expires.Equal(queuedExpires) {
+		t.Errorf("Expected the queued window to end at %v, got %v", queuedExpires, expires)
+	}
+}
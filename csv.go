@@ -0,0 +1,107 @@
+package gocache
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// countKeyHit records a hit on key if Options.TrackKeyHits or
+// Options.HotKeys is set.
+func (c *Cache) countKeyHit(key string) {
+	if c.hotKeys != nil {
+		c.hotKeys.hit(key)
+	}
+	if !c.trackKeyHits {
+		return
+	}
+	c.mu.RLock()
+	if i, found := c.keyIndex[key]; found {
+		atomic.AddUint64(&c.keyHits[i], 1)
+	}
+	c.mu.RUnlock()
+}
+
+// storedSize returns the size of an entry with its value as stored: its
+// cost if Options.Cost is set, its compressed size if it is compressed, and
+// its encoded size otherwise.
+func (c *Cache) storedSize(e Entry) (int64, error) {
+	if compressed, ok := e.Value.(compressedValue); ok && c.cost == nil {
+		return int64(len(compressed)), nil
+	}
+	size, err := c.sizeOf(c.valueOf(e.Value), e.Cost)
+	if err != nil {
+		return 0, fmt.Errorf("sizing %q: %w", e.Key, err)
+	}
+	return size, nil
+}
+
+// DumpMetadataCSV writes one CSV record per unexpired item to w, without
+// values, for capacity reviews and audits in a spreadsheet. The columns are:
+//
+//	key      the key
+//	size     the cost of the item if Options.Cost is set, its compressed
+//	         size if it is compressed, and its encoded size otherwise
+//	created  when the current value was set, in RFC 3339 format
+//	expires  when the item expires, or empty if it never does
+//	hits     hits on the key since it was added, or empty unless
+//	         Options.TrackKeyHits is set
+//
+// The first record is a header with the column names. Items are read in
+// chunks, as by RangeChunks, so the dump is not a point-in-time view of a
+// cache that is being modified.
+func (c *Cache) DumpMetadataCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"key", "size", "created", "expires", "hits"})
+
+	err := c.rangeChunks(exportChunkSize, func(entries []Entry) error {
+		var hits []string
+		if c.trackKeyHits {
+			hits = make([]string, len(entries))
+			c.mu.RLock()
+			for i, e := range entries {
+				if j, found := c.keyIndex[e.Key]; found {
+					hits[i] = strconv.FormatUint(atomic.LoadUint64(&c.keyHits[j]), 10)
+				}
+			}
+			c.mu.RUnlock()
+		}
+
+		now := c.clock.Now().UnixNano()
+		for i, e := range entries {
+			if e.expiredAt(now) {
+				continue
+			}
+
+			size, err := c.storedSize(e)
+			if err != nil {
+				return err
+			}
+
+			record := []string{
+				e.Key,
+				strconv.FormatInt(size, 10),
+				time.Unix(0, e.Modified).UTC().Format(time.RFC3339),
+				"",
+				"",
+			}
+			if e.Expiration > 0 {
+				record[3] = time.Unix(0, e.Expiration).UTC().Format(time.RFC3339)
+			}
+			if hits != nil {
+				record[4] = hits[i]
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,54 @@
+package gocache
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestCacheDumpMetadataCSV(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{
+		Clock:        clock,
+		TrackKeyHits: true,
+		Cost:         func(key string, value interface{}) int64 { return int64(len(value.(string))) },
+	})
+	defer cache.Stop()
+
+	cache.Set("forever", "value")
+	cache.SetWithExpiration("short", "v", time.Minute)
+	cache.Set("gone", "x")
+	cache.Get("forever")
+	cache.Get("forever")
+	cache.GetBytes([]byte("short"))
+	cache.Delete("gone")
+
+	var buf bytes.Buffer
+	if err := cache.DumpMetadataCSV(&buf); err != nil {
+		t.Fatalf("DumpMetadataCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 records, got %v", records)
+	}
+
+	created := clock.Now().UTC().Format(time.RFC3339)
+	expires := clock.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	want := map[string][]string{
+		"forever": {"forever", "5", created, "", "2"},
+		"short":   {"short", "1", created, expires, "1"},
+	}
+	for _, record := range records[1:] {
+		expected := want[record[0]]
+		for i := range expected {
+			if record[i] != expected[i] {
+				t.Errorf("Expected %v, got %v", expected, record)
+				break
+			}
+		}
+	}
+}
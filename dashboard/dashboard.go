@@ -0,0 +1,291 @@
+// Package dashboard serves a small web dashboard for a gocache.Cache, so
+// that on-call engineers can inspect its state from a browser: an item
+// browser with search, the TTL of each key, hit rate graphs, and buttons to
+// flush the cache, delete keys and invalidate key prefixes.
+//
+// The dashboard has no authentication of its own. Mount it behind whatever
+// protects the other administrative endpoints of the application:
+//
+//	mux.Handle("/debug/cache/", http.StripPrefix("/debug/cache", requireAdmin(dashboard.New(c))))
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gocache"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// ActionHeader must be set on requests that change the cache. Browsers do
+// not let other sites set custom headers without the dashboard's consent,
+// so requiring it keeps those sites from flushing the cache through the
+// browser of a logged-in engineer.
+const ActionHeader = "X-Gocache-Dashboard"
+
+const (
+	// defaultLimit and maxLimit bound the items returned by a search.
+	defaultLimit = 100
+	maxLimit     = 1000
+
+	// previewLen is the number of characters of a value shown in the
+	// item browser.
+	previewLen = 200
+
+	// chunkSize is the number of items read at a time while searching.
+	chunkSize = 1024
+)
+
+// New returns a handler serving the dashboard for c. The page is served at
+// the root and calls a JSON API under api/:
+//
+//	GET  api/items       unexpired items whose key contains ?q=, sorted by
+//	                     key, at most ?limit= of them
+//	GET  api/stats       the cache's Stats, hit ratio and hourly history
+//	POST api/delete      delete the key ?key=
+//	POST api/expire      set the TTL of ?key= to ?ttl= seconds, 0 for never
+//	POST api/invalidate  delete the keys starting with ?prefix=
+//	POST api/flush       remove all items
+//
+// POST requests must carry ActionHeader. The page uses relative URLs, so
+// the dashboard can be served under a path prefix with http.StripPrefix,
+// as long as the prefix is visited with a trailing slash.
+func New(c *gocache.Cache) http.Handler {
+	d := &dashboard{cache: c}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.index)
+	mux.HandleFunc("/api/items", d.items)
+	mux.HandleFunc("/api/stats", d.stats)
+	mux.HandleFunc("/api/delete", d.action(d.delete))
+	mux.HandleFunc("/api/expire", d.action(d.expire))
+	mux.HandleFunc("/api/invalidate", d.action(d.invalidate))
+	mux.HandleFunc("/api/flush", d.action(d.flush))
+	return mux
+}
+
+type dashboard struct {
+	cache *gocache.Cache
+}
+
+// item describes an item in the item browser.
+type item struct {
+	Key     string     `json:"key"`
+	Type    string     `json:"type"`
+	Preview string     `json:"preview"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+	TTL     float64    `json:"ttl,omitempty"` // seconds left, if it expires
+}
+
+func (d *dashboard) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+func (d *dashboard) items(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+	query := r.URL.Query().Get("q")
+	limit := defaultLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+		limit = min(n, maxLimit)
+	}
+
+	// Keep the limit smallest keys seen so far, so that memory stays
+	// bounded however many keys match.
+	var matches []item
+	var total int
+	now := time.Now()
+	err := d.cache.RangeChunks(chunkSize, func(entries []gocache.Entry) error {
+		for _, e := range entries {
+			if !strings.Contains(e.Key, query) {
+				continue
+			}
+			total++
+			if len(matches) == limit && e.Key >= matches[limit-1].Key {
+				continue
+			}
+			it := item{
+				Key:     e.Key,
+				Type:    fmt.Sprintf("%T", e.Value),
+				Preview: preview(e.Value),
+				Created: time.Unix(0, e.Modified),
+			}
+			if e.Expiration > 0 {
+				expires := time.Unix(0, e.Expiration)
+				it.Expires = &expires
+				it.TTL = max(expires.Sub(now).Seconds(), 0)
+			}
+			i := sort.Search(len(matches), func(i int) bool { return matches[i].Key > e.Key })
+			if len(matches) < limit {
+				matches = append(matches, item{})
+			}
+			copy(matches[i+1:], matches[i:])
+			matches[i] = it
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if matches == nil {
+		matches = []item{}
+	}
+	writeJSON(w, map[string]interface{}{"total": total, "items": matches})
+}
+
+func (d *dashboard) stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+	stats := d.cache.Stats()
+	history := d.cache.StatsHistory()
+	hourly := make([]map[string]interface{}, len(history.Hourly))
+	for i, sample := range history.Hourly {
+		hourly[i] = map[string]interface{}{
+			"start":    sample.Start,
+			"hits":     sample.Hits,
+			"misses":   sample.Misses,
+			"hitRatio": sample.HitRatio(),
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"stats":    stats,
+		"hitRatio": stats.HitRatio(),
+		"hourly":   hourly,
+	})
+}
+
+// action wraps a handler that changes the cache, checking the method and
+// ActionHeader.
+func (d *dashboard) action(fn func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		if r.Header.Get(ActionHeader) == "" {
+			writeError(w, http.StatusForbidden, errors.New("missing "+ActionHeader+" header"))
+			return
+		}
+		if err := fn(r); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, gocache.ErrKeyNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (d *dashboard) delete(r *http.Request) error {
+	if !d.cache.Delete(r.URL.Query().Get("key")) {
+		return gocache.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (d *dashboard) expire(r *http.Request) error {
+	ttl, err := strconv.ParseFloat(r.URL.Query().Get("ttl"), 64)
+	if err != nil || ttl < 0 || ttl > float64(1<<63-1)/float64(time.Second) {
+		return errors.New("invalid ttl")
+	}
+	var at time.Time
+	if ttl > 0 {
+		at = time.Now().Add(time.Duration(ttl * float64(time.Second)))
+	}
+	return d.cache.ExpireAt(r.URL.Query().Get("key"), at)
+}
+
+func (d *dashboard) invalidate(r *http.Request) error {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		return errors.New("missing prefix; use flush to remove all items")
+	}
+	var keys []string
+	d.cache.RangeChunks(chunkSize, func(entries []gocache.Entry) error {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Key, prefix) {
+				keys = append(keys, e.Key)
+			}
+		}
+		return nil
+	})
+	for _, key := range keys {
+		d.cache.Delete(key)
+	}
+	return nil
+}
+
+func (d *dashboard) flush(r *http.Request) error {
+	d.cache.Flush()
+	return nil
+}
+
+// preview formats value for display, shortened to previewLen characters.
+func preview(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		if len(v) > previewLen*utf8.UTFMax {
+			v = v[:previewLen*utf8.UTFMax]
+		}
+		if utf8.Valid(v) {
+			s = string(v)
+		} else {
+			s = fmt.Sprintf("%x", v)
+		}
+	default:
+		s = fmt.Sprintf("%+v", v)
+	}
+
+	var n int
+	for i := range s {
+		if n == previewLen {
+			return s[:i] + "…"
+		}
+		n++
+	}
+	return s
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON error response with the given status.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
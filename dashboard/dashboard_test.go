@@ -0,0 +1,137 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+func TestDashboard(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("user:%d", i), fmt.Sprintf("user %d", i))
+	}
+	cache.SetWithExpiration("session:1", []byte("token"), time.Minute)
+	cache.Get("user:1")
+	cache.Get("missing")
+
+	handler := New(cache)
+	do := func(method, target string, action bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		if action {
+			req.Header.Set(ActionHeader, "1")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/", false)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>gocache dashboard</title>") {
+		t.Errorf("Expected the dashboard page, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/nope", false); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown path, got %d", rec.Code)
+	}
+
+	var result struct {
+		Total int
+		Items []item
+	}
+	rec = do(http.MethodGet, "/api/items?q=user&limit=2", false)
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 5 || len(result.Items) != 2 || result.Items[0].Key != "user:0" || result.Items[1].Key != "user:1" {
+		t.Errorf("Expected 5 matches starting with user:0 and user:1, got %+v", result)
+	}
+	if result.Items[0].Preview != "user 0" || result.Items[0].Type != "string" || result.Items[0].Expires != nil {
+		t.Errorf("Unexpected item %+v", result.Items[0])
+	}
+
+	rec = do(http.MethodGet, "/api/items?q=session", false)
+	json.NewDecoder(rec.Body).Decode(&result)
+	if len(result.Items) != 1 || result.Items[0].TTL <= 0 || result.Items[0].TTL > 60 || result.Items[0].Preview != "token" {
+		t.Errorf("Expected session:1 with a TTL of at most 60s, got %+v", result.Items)
+	}
+
+	var stats struct {
+		Stats    gocache.Stats
+		HitRatio float64
+	}
+	rec = do(http.MethodGet, "/api/stats", false)
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil || stats.Stats.Items != 6 || stats.HitRatio != 0.5 {
+		t.Errorf("Expected 6 items and a hit ratio of 0.5, got %+v (%v)", stats, err)
+	}
+
+	if rec := do(http.MethodPost, "/api/flush", false); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without %s, got %d", ActionHeader, rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/flush", true); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/expire?key=user:2&ttl=30", true); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected expire to return 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, expires, _ := cache.GetWithExpiration("user:2"); time.Until(expires) > 30*time.Second || expires.IsZero() {
+		t.Errorf("Expected user:2 to expire within 30s, got %v", expires)
+	}
+	if rec := do(http.MethodPost, "/api/expire?key=user:2&ttl=-1", true); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative TTL, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/expire?key=missing&ttl=1", true); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/delete?key=user:0", true); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected delete to return 204, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/delete?key=user:0", true); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted key, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/invalidate?prefix=user:", true); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected invalidate to return 204, got %d", rec.Code)
+	}
+	if n := cache.ItemCount(); n != 1 {
+		t.Errorf("Expected only session:1 after invalidating user:, got %d items", n)
+	}
+	if rec := do(http.MethodPost, "/api/invalidate", true); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty prefix, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/flush", true); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected flush to return 204, got %d", rec.Code)
+	}
+	if n := cache.ItemCount(); n != 0 {
+		t.Errorf("Expected an empty cache after flush, got %d items", n)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	long := strings.Repeat("é", previewLen+10)
+	for _, tt := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"text", "text"},
+		{[]byte("bytes"), "bytes"},
+		{[]byte{0xff, 0x00}, "ff00"},
+		{struct{ A int }{1}, "{A:1}"},
+		{long, strings.Repeat("é", previewLen) + "…"},
+		{[]byte(long), strings.Repeat("é", previewLen) + "…"},
+	} {
+		if got := preview(tt.value); got != tt.want {
+			t.Errorf("preview(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
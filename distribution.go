@@ -0,0 +1,53 @@
+package gocache
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ValueSizeBounds are the upper bounds, in bytes, of the buckets of
+// Stats.ValueSizes. A last bucket counts larger values.
+var ValueSizeBounds = [...]int64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// TTLBounds are the upper bounds of the buckets of Stats.TTLs. A bucket
+// after them counts longer TTLs, and a last one values that never expire.
+var TTLBounds = [...]time.Duration{
+	time.Second, 10 * time.Second, time.Minute, 10 * time.Minute,
+	time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// sizeBucket returns the bucket of Stats.ValueSizes counting size.
+func sizeBucket(size int64) int {
+	return sort.Search(len(ValueSizeBounds), func(i int) bool { return size <= ValueSizeBounds[i] })
+}
+
+// ttlBucket returns the bucket of Stats.TTLs counting ttl, where 0 means
+// no expiration.
+func ttlBucket(ttl time.Duration) int {
+	if ttl <= 0 {
+		return len(TTLBounds) + 1
+	}
+	return sort.Search(len(TTLBounds), func(i int) bool { return ttl <= TTLBounds[i] })
+}
+
+// observeSet records the size and TTL of a value stored under key, with
+// expiration as a Unix timestamp in nanoseconds or 0, if
+// Options.TrackDistributions is set.
+func (c *Cache) observeSet(key string, size int64, expiration int64, now time.Time) {
+	if !c.trackDistributions {
+		return
+	}
+	var ttl time.Duration
+	if expiration > 0 {
+		// A value already expired when stored counts as the shortest TTL.
+		ttl = max(time.Duration(expiration-now.UnixNano()), 1)
+	}
+	sb, tb := sizeBucket(size), ttlBucket(ttl)
+	atomic.AddUint64(&c.counters.valueSizes[sb], 1)
+	atomic.AddUint64(&c.counters.ttls[tb], 1)
+	if ns := c.namespaceOf(key); ns != nil {
+		atomic.AddUint64(&ns.counters.valueSizes[sb], 1)
+		atomic.AddUint64(&ns.counters.ttls[tb], 1)
+	}
+}
@@ -0,0 +1,65 @@
+package gocache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueSizeAndTTLDistributions(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{
+		TrackDistributions: true,
+		DefaultExpiration:  time.Hour,
+		Cost:               func(_ string, v interface{}) int64 { return int64(len(v.(string))) },
+		Clock:              clock,
+	})
+	defer c.Stop()
+
+	c.Set("small", "x")
+	c.Set("medium", strings.Repeat("x", 1000))
+	c.SetWithExpiration("flash", "x", 500*time.Millisecond)
+	c.SetWithExpiration("forever", strings.Repeat("x", 5<<20), 0)
+
+	stats := c.Stats()
+	wantSizes := [len(ValueSizeBounds) + 1]uint64{0: 2, 2: 1, 9: 1}
+	if stats.ValueSizes != wantSizes {
+		t.Errorf("ValueSizes = %v, want %v", stats.ValueSizes, wantSizes)
+	}
+	wantTTLs := [len(TTLBounds) + 2]uint64{0: 1, 4: 2, 9: 1}
+	if stats.TTLs != wantTTLs {
+		t.Errorf("TTLs = %v, want %v", stats.TTLs, wantTTLs)
+	}
+
+	c.ResetStats()
+	c.Set("small", "y")
+	if stats := c.Stats(); stats.ValueSizes[0] != 1 || stats.TTLs[4] != 1 {
+		t.Errorf("after ResetStats, got ValueSizes %v and TTLs %v", stats.ValueSizes, stats.TTLs)
+	}
+}
+
+func TestDistributionsOfNamespace(t *testing.T) {
+	c := New(Options{TrackDistributions: true})
+	defer c.Stop()
+	users := c.Namespace("users", NamespaceOptions{})
+
+	users.SetWithExpiration("u1", "alice", 30*time.Second)
+	c.Set("other", "value")
+
+	if stats := users.Stats(); stats.TTLs[2] != 1 || stats.TTLs[len(TTLBounds)+1] != 0 {
+		t.Errorf("namespace TTLs = %v, want one TTL of at most a minute", stats.TTLs)
+	}
+	if stats := c.Stats(); stats.TTLs[2] != 1 || stats.TTLs[len(TTLBounds)+1] != 1 {
+		t.Errorf("cache TTLs = %v", stats.TTLs)
+	}
+}
+
+func TestDistributionsDisabled(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	c.Set("key", "value")
+	if stats := c.Stats(); stats.ValueSizes != [len(ValueSizeBounds) + 1]uint64{} || stats.TTLs != [len(TTLBounds) + 2]uint64{} {
+		t.Errorf("got distributions %v and %v without TrackDistributions", stats.ValueSizes, stats.TTLs)
+	}
+}
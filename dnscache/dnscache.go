@@ -0,0 +1,229 @@
+// Package dnscache caches DNS lookups in a gocache.Cache, for services that
+// resolve a small set of names at a high rate. Concurrent lookups of the
+// same name share a single query.
+//
+// net.Resolver does not report the TTLs of the records it returns, so
+// results are cached for Options.TTL, unless the wrapped resolver also
+// implements TTLLookuper, in which case the records' own TTLs are used,
+// capped at Options.MaxTTL.
+package dnscache
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Lookuper is the part of *net.Resolver that Resolver wraps.
+type Lookuper interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// TTLLookuper is implemented by resolvers that report the TTL of the
+// records they return, such as ones built on a DNS client library. The TTL
+// is the smallest of the records' TTLs.
+type TTLLookuper interface {
+	LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error)
+	LookupSRVTTL(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error)
+}
+
+// Default option values.
+const (
+	DefaultTTL    = 30 * time.Second
+	DefaultMaxTTL = 5 * time.Minute
+)
+
+// Options configures a Resolver. Zero values select the defaults.
+type Options struct {
+	// TTL is how long results are cached when the wrapped resolver does
+	// not report TTLs.
+	TTL time.Duration
+
+	// MaxTTL caps the TTLs reported by a TTLLookuper.
+	MaxTTL time.Duration
+
+	// NegativeTTL is how long "no such host" answers are cached. If 0,
+	// they are not cached. Other errors are never cached.
+	NegativeTTL time.Duration
+
+	// KeyPrefix is prepended to names to form cache keys. If empty,
+	// "dns:" is used.
+	KeyPrefix string
+}
+
+// srvResult is a cached LookupSRV result.
+type srvResult struct {
+	CNAME string
+	Addrs []net.SRV
+}
+
+// notFound is cached for names that do not exist.
+type notFound struct{}
+
+func init() {
+	// Let snapshots made with the default codec hold cached results.
+	gob.Register(srvResult{})
+	gob.Register(notFound{})
+}
+
+// Resolver caches the results of a Lookuper. Its methods have the
+// signatures of the matching *net.Resolver methods.
+type Resolver struct {
+	cache    *gocache.Cache
+	resolver Lookuper
+	options  Options
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// call is a lookup in progress.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// New returns a resolver caching the results of r in c. If r is nil,
+// net.DefaultResolver is used.
+func New(c *gocache.Cache, r Lookuper, options Options) *Resolver {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultTTL
+	}
+	if options.MaxTTL <= 0 {
+		options.MaxTTL = DefaultMaxTTL
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "dns:"
+	}
+	return &Resolver{
+		cache:    c,
+		resolver: r,
+		options:  options,
+		inflight: make(map[string]*call),
+	}
+}
+
+// LookupHost looks up the addresses of host, like net.Resolver.LookupHost.
+// The returned slice is the caller's to modify.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	value, err := r.lookup(ctx, "host:"+host, host, func() (interface{}, time.Duration, error) {
+		if tl, ok := r.resolver.(TTLLookuper); ok {
+			addrs, ttl, err := tl.LookupHostTTL(ctx, host)
+			return addrs, ttl, err
+		}
+		addrs, err := r.resolver.LookupHost(ctx, host)
+		return addrs, 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), value.([]string)...), nil
+}
+
+// LookupSRV looks up the SRV records of a service, like
+// net.Resolver.LookupSRV. The returned records are the caller's to modify.
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	value, err := r.lookup(ctx, "srv:"+target, target, func() (interface{}, time.Duration, error) {
+		var cname string
+		var addrs []*net.SRV
+		var ttl time.Duration
+		var err error
+		if tl, ok := r.resolver.(TTLLookuper); ok {
+			cname, addrs, ttl, err = tl.LookupSRVTTL(ctx, service, proto, name)
+		} else {
+			cname, addrs, err = r.resolver.LookupSRV(ctx, service, proto, name)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		result := srvResult{CNAME: cname, Addrs: make([]net.SRV, len(addrs))}
+		for i, addr := range addrs {
+			result.Addrs[i] = *addr
+		}
+		return result, ttl, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	result := value.(srvResult)
+	addrs := make([]*net.SRV, len(result.Addrs))
+	for i := range result.Addrs {
+		addr := result.Addrs[i]
+		addrs[i] = &addr
+	}
+	return result.CNAME, addrs, nil
+}
+
+// lookup returns the cached result for key, or runs query to get it,
+// sharing the query with concurrent callers. query returns a TTL of 0 if
+// it does not know it.
+func (r *Resolver) lookup(ctx context.Context, key, name string, query func() (interface{}, time.Duration, error)) (interface{}, error) {
+	key = r.options.KeyPrefix + key
+	if value, err := r.cache.Get(key); err == nil {
+		return result(value, name)
+	}
+
+	r.mu.Lock()
+	if c, ok := r.inflight[key]; ok {
+		r.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	r.inflight[key] = c
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.inflight, key)
+		r.mu.Unlock()
+		close(c.done)
+	}()
+
+	value, ttl, err := query()
+	switch {
+	case err == nil:
+		if ttl <= 0 {
+			ttl = r.options.TTL
+		} else {
+			ttl = min(ttl, r.options.MaxTTL)
+		}
+		r.cache.SetWithExpiration(key, value, ttl)
+	case isNotFound(err) && r.options.NegativeTTL > 0:
+		r.cache.SetWithExpiration(key, notFound{}, r.options.NegativeTTL)
+	}
+	c.value, c.err = value, err
+	return value, err
+}
+
+// result turns a cached value into the result of a lookup of name.
+func result(value interface{}, name string) (interface{}, error) {
+	if _, ok := value.(notFound); ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return value, nil
+}
+
+// isNotFound reports whether err says that a name does not exist.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
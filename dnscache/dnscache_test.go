@@ -0,0 +1,148 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// fakeResolver answers from fixed records and counts its queries. Queries
+// block until release is closed, if it is set.
+type fakeResolver struct {
+	queries int64
+	release chan struct{}
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	atomic.AddInt64(&f.queries, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	if host != "db.internal" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []string{"10.0.0.1", "10.0.0.2"}, nil
+}
+
+func (f *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	atomic.AddInt64(&f.queries, 1)
+	return "_api._tcp." + name + ".", []*net.SRV{{Target: "api1." + name + ".", Port: 8080, Priority: 1, Weight: 10}}, nil
+}
+
+// ttlResolver also reports TTLs.
+type ttlResolver struct {
+	fakeResolver
+	ttl time.Duration
+}
+
+func (t *ttlResolver) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := t.LookupHost(ctx, host)
+	return addrs, t.ttl, err
+}
+
+func (t *ttlResolver) LookupSRVTTL(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error) {
+	cname, addrs, err := t.LookupSRV(ctx, service, proto, name)
+	return cname, addrs, t.ttl, err
+}
+
+func newCache(t *testing.T) *gocache.Cache {
+	cache := gocache.New(gocache.Options{})
+	t.Cleanup(cache.Stop)
+	return cache
+}
+
+func TestLookupHost(t *testing.T) {
+	cache := newCache(t)
+	fake := &fakeResolver{}
+	r := New(cache, fake, Options{TTL: time.Minute})
+
+	for n := 0; n < 3; n++ {
+		addrs, err := r.LookupHost(context.Background(), "db.internal")
+		if err != nil || len(addrs) != 2 || addrs[0] != "10.0.0.1" {
+			t.Fatalf("Expected 2 addresses, got %v (%v)", addrs, err)
+		}
+		addrs[0] = "changed"
+	}
+	if fake.queries != 1 {
+		t.Errorf("Expected 1 query, got %d", fake.queries)
+	}
+	_, expires, _ := cache.GetWithExpiration("dns:host:db.internal")
+	if d := time.Until(expires); d <= 0 || d > time.Minute {
+		t.Errorf("Expected the result cached for Options.TTL, got %v", d)
+	}
+
+	// Without NegativeTTL, missing names are queried every time.
+	for n := 0; n < 2; n++ {
+		if _, err := r.LookupHost(context.Background(), "nope.internal"); !isNotFound(err) {
+			t.Errorf("Expected a not-found error, got %v", err)
+		}
+	}
+	if fake.queries != 3 {
+		t.Errorf("Expected misses not to be cached, got %d queries", fake.queries)
+	}
+}
+
+func TestLookupHostNegative(t *testing.T) {
+	fake := &fakeResolver{}
+	r := New(newCache(t), fake, Options{NegativeTTL: time.Minute})
+
+	for n := 0; n < 2; n++ {
+		_, err := r.LookupHost(context.Background(), "nope.internal")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound || dnsErr.Name != "nope.internal" {
+			t.Errorf("Expected a not-found error for nope.internal, got %v", err)
+		}
+	}
+	if fake.queries != 1 {
+		t.Errorf("Expected the miss to be cached, got %d queries", fake.queries)
+	}
+}
+
+func TestLookupHostShared(t *testing.T) {
+	fake := &fakeResolver{release: make(chan struct{})}
+	r := New(newCache(t), fake, Options{})
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if addrs, err := r.LookupHost(context.Background(), "db.internal"); err != nil || len(addrs) != 2 {
+				t.Errorf("Expected 2 addresses, got %v (%v)", addrs, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+	if fake.queries != 1 {
+		t.Errorf("Expected concurrent lookups to share 1 query, got %d", fake.queries)
+	}
+}
+
+func TestLookupSRV(t *testing.T) {
+	cache := newCache(t)
+	resolver := &ttlResolver{ttl: time.Hour}
+	r := New(cache, resolver, Options{MaxTTL: 10 * time.Minute})
+
+	for n := 0; n < 2; n++ {
+		cname, addrs, err := r.LookupSRV(context.Background(), "api", "tcp", "example.com")
+		if err != nil || cname != "_api._tcp.example.com." || len(addrs) != 1 || addrs[0].Port != 8080 {
+			t.Fatalf("Unexpected result %q %v (%v)", cname, addrs, err)
+		}
+		addrs[0].Port = 1
+	}
+	if resolver.queries != 1 {
+		t.Errorf("Expected 1 query, got %d", resolver.queries)
+	}
+	_, expires, _ := cache.GetWithExpiration("dns:srv:_api._tcp.example.com")
+	if d := time.Until(expires); d <= 9*time.Minute || d > 10*time.Minute {
+		t.Errorf("Expected the record TTL capped at MaxTTL, got %v", d)
+	}
+}
@@ -0,0 +1,19 @@
+// Package gocache implements a thread-safe in-memory cache with expiration.
+//
+// # Module layout
+//
+// The gocache package is the core cache and depends only on the standard
+// library. Optional subsystems that bring in network listeners, protocol
+// implementations or cluster membership live in sub-packages, so that
+// programs that embed only the cache do not compile or link them:
+//
+//	gocache               core cache, eviction, codecs, snapshots
+//	gocache/gocachetest   test helpers
+//	gocache/<subsystem>   servers, clients and integrations, one per package
+//
+// Sub-packages are built on the exported API only: Get, Set and friends for
+// data, Range and RangeChunks for iteration, Notifications for change feeds
+// and OnShutdown to take part in the ordered shutdown of Close. A
+// sub-package that needs a third-party dependency gets its own go.mod, so
+// the dependency is only downloaded by programs that import it.
+package gocache
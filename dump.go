@@ -0,0 +1,125 @@
+package gocache
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultDumpValueLength is the length at which DumpTo truncates values
+// when DumpOptions.MaxValueLength is 0.
+const DefaultDumpValueLength = 64
+
+// DumpOptions configures DumpTo.
+type DumpOptions struct {
+	// Values adds a column with each value, formatted with %+v and quoted.
+	// Leave it off when the cache holds secrets or personal data.
+	Values bool
+
+	// MaxValueLength truncates formatted values to this many characters,
+	// or DefaultDumpValueLength if 0. If negative, values are not
+	// truncated.
+	MaxValueLength int
+}
+
+// DumpTo writes every item of the cache to w as a table sorted by key,
+// for a person to read: its remaining TTL, size, priority, version and
+// age, whether it is pinned, leased or expired but not cleaned up yet, and
+// optionally its value. It is meant for bug reports and incident
+// timelines, not for machines: use SaveSnapshot or DumpMetadataCSV for
+// those. The items are collected in chunks, as by RangeChunks, and sorted
+// in memory before anything is written.
+func (c *Cache) DumpTo(w io.Writer, options DumpOptions) error {
+	maxLen := options.MaxValueLength
+	if maxLen == 0 {
+		maxLen = DefaultDumpValueLength
+	}
+
+	var entries []Entry
+	err := c.rangeChunks(exportChunkSize, func(chunk []Entry) error {
+		entries = append(entries, chunk...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	now := c.clock.Now()
+	fmt.Fprintf(w, "gocache dump at %s: %d items\n\n", now.UTC().Format(time.RFC3339), len(entries))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "KEY\tTTL\tSIZE\tPRIORITY\tVERSION\tAGE\tFLAGS"
+	if options.Values {
+		header += "\tVALUE"
+	}
+	fmt.Fprintln(tw, header)
+	for _, e := range entries {
+		size, err := c.storedSize(e)
+		if err != nil {
+			return err
+		}
+
+		ttl := "-"
+		if e.Expiration > 0 {
+			ttl = time.Duration(e.Expiration - now.UnixNano()).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s",
+			strconv.Quote(e.Key), ttl, size, e.Priority, e.Version,
+			now.Sub(time.Unix(0, e.Modified)).Round(time.Millisecond), c.dumpFlags(e, now))
+		if options.Values {
+			value, err := c.expand(e.Value)
+			if err != nil {
+				return fmt.Errorf("expanding %q: %w", e.Key, err)
+			}
+			fmt.Fprintf(tw, "\t%s", strconv.Quote(truncateRunes(fmt.Sprintf("%+v", value), maxLen)))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// dumpFlags returns the states of an entry worth pointing out, separated
+// by commas, or "-".
+func (c *Cache) dumpFlags(e Entry, now time.Time) string {
+	var flags []string
+	if e.expiredAt(now.UnixNano()) {
+		flags = append(flags, "expired")
+	}
+	c.mu.RLock()
+	if _, pinned := c.pinned[e.Key]; pinned {
+		flags = append(flags, "pinned")
+	}
+	if l, leased := c.leases[e.Key]; leased && l.expiration >= now.UnixNano() {
+		flags = append(flags, "leased")
+	}
+	c.mu.RUnlock()
+	if _, compressed := e.Value.(compressedValue); compressed {
+		flags = append(flags, "compressed")
+	}
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
+
+// truncateRunes cuts s to n characters, marking the cut with "...", unless
+// n is negative.
+func truncateRunes(s string, n int) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j] + "..."
+		}
+		i++
+	}
+	return s
+}
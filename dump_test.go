@@ -0,0 +1,64 @@
+package gocache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpTo(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock})
+	defer c.Stop()
+
+	c.SetWithExpiration("session:1", "token", time.Minute)
+	c.Set("config", strings.Repeat("x", 100))
+	c.Pin("config")
+	c.SetWithExpiration("stale", 1, time.Second)
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := c.DumpTo(&buf, DumpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 6 || !strings.Contains(lines[0], ": 3 items") || !strings.HasPrefix(lines[2], "KEY") {
+		t.Fatalf("unexpected dump:\n%s", out)
+	}
+	for i, want := range []string{`"config"`, `"session:1"`, `"stale"`} {
+		if !strings.HasPrefix(lines[3+i], want) {
+			t.Errorf("line %d = %q, want key %s", 3+i, lines[3+i], want)
+		}
+	}
+	if !strings.Contains(lines[3], "pinned") || !strings.Contains(lines[4], " 58s ") || !strings.Contains(lines[5], "expired") {
+		t.Errorf("missing metadata:\n%s", out)
+	}
+	if strings.Contains(out, "token") {
+		t.Errorf("values dumped without DumpOptions.Values:\n%s", out)
+	}
+}
+
+func TestDumpToValues(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	c.Set("long", strings.Repeat("x", 100))
+	c.Set("multiline", "a\nb")
+
+	var buf bytes.Buffer
+	if err := c.DumpTo(&buf, DumpOptions{Values: true, MaxValueLength: 10}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"xxxxxxxxxx..."`) || !strings.Contains(out, `"a\nb"`) {
+		t.Errorf("values not truncated and quoted:\n%s", out)
+	}
+
+	buf.Reset()
+	c.DumpTo(&buf, DumpOptions{Values: true, MaxValueLength: -1})
+	if !strings.Contains(buf.String(), strings.Repeat("x", 100)) {
+		t.Errorf("value truncated with a negative MaxValueLength:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,222 @@
+package gocache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EncryptionKey is an AES key used to encrypt snapshots. The ID is stored in
+// plaintext in the snapshot header so that the right key can be picked when
+// the snapshot is loaded, for example after a key rotation.
+type EncryptionKey struct {
+	ID  string
+	Key []byte // 16, 24 or 32 bytes for AES-128, AES-192 or AES-256
+}
+
+// encryptedChunkSize is the amount of plaintext sealed in each chunk of an
+// encrypted snapshot, so that snapshots of any size are encrypted and
+// authenticated as a stream.
+const encryptedChunkSize = 64 << 10
+
+// finalChunk marks the length of the last chunk of an encrypted stream, so
+// that truncation is detected.
+const finalChunk = 1 << 31
+
+// newSnapshotAEAD returns the AES-GCM cipher for key.
+func newSnapshotAEAD(key EncryptionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot key %q: %w", key.ID, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of chunk i from the stream's base nonce.
+func chunkNonce(base []byte, i uint64) []byte {
+	nonce := append([]byte(nil), base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^i)
+	return nonce
+}
+
+// chunkAD returns the additional data authenticated with chunk i: the key
+// ID, the chunk index and whether it is the final chunk.
+func chunkAD(keyID string, i uint64, final bool) []byte {
+	ad := binary.BigEndian.AppendUint64([]byte(keyID), i)
+	if final {
+		ad = append(ad, 1)
+	}
+	return ad
+}
+
+// encryptWriter seals everything written to it in chunks. Close must be
+// called to write the final chunk.
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	keyID string
+	nonce []byte
+	buf   []byte
+	index uint64
+}
+
+// newEncryptWriter writes the key ID and a random base nonce to w and
+// returns a writer that encrypts to w with key.
+func newEncryptWriter(w io.Writer, key EncryptionKey) (*encryptWriter, error) {
+	aead, err := newSnapshotAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key.ID) > 255 {
+		return nil, errors.New("snapshot key ID longer than 255 bytes")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := append([]byte{byte(len(key.ID))}, key.ID...)
+	if _, err := w.Write(append(header, nonce...)); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:     w,
+		aead:  aead,
+		keyID: key.ID,
+		nonce: nonce,
+		buf:   make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(e.buf) == encryptedChunkSize {
+			if err := e.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		m := min(len(p), encryptedChunkSize-len(e.buf))
+		e.buf = append(e.buf, p[:m]...)
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Close seals the buffered data as the final chunk.
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.nonce, e.index), e.buf, chunkAD(e.keyID, e.index, final))
+	length := uint32(len(sealed))
+	if final {
+		length |= finalChunk
+	}
+
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], length)
+	if _, err := e.w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.buf = e.buf[:0]
+	e.index++
+	return nil
+}
+
+// decryptReader opens the chunks written by encryptWriter.
+type decryptReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	keyID string
+	nonce []byte
+	buf   []byte
+	index uint64
+	done  bool
+}
+
+// newDecryptReader reads the key ID and base nonce from r, picks the
+// matching key from keys and returns a reader of the decrypted stream.
+func newDecryptReader(r io.Reader, keys []EncryptionKey) (*decryptReader, error) {
+	var idLen [1]byte
+	if _, err := io.ReadFull(r, idLen[:]); err != nil {
+		return nil, err
+	}
+	id := make([]byte, idLen[0])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return nil, err
+	}
+
+	var key *EncryptionKey
+	for i := range keys {
+		if keys[i].ID == string(id) {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	aead, err := newSnapshotAEAD(*key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, keyID: key.ID, nonce: nonce}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	var prefix [4]byte
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	final := length&finalChunk != 0
+	length &^= finalChunk
+	if length > encryptedChunkSize+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("%w: chunk of %d bytes", ErrCorruptSnapshot, length)
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.nonce, d.index), sealed, chunkAD(d.keyID, d.index, final))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	d.buf = plain
+	d.index++
+	d.done = final
+	return nil
+}
@@ -0,0 +1,87 @@
+package gocache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCacheEncryptedSnapshot(t *testing.T) {
+	oldKey := EncryptionKey{ID: "2025-01", Key: bytes.Repeat([]byte{1}, 32)}
+	newKey := EncryptionKey{ID: "2026-01", Key: bytes.Repeat([]byte{2}, 16)}
+
+	secret := "ssn=123-45-6789"
+	large := strings.Repeat("x", 3*encryptedChunkSize)
+
+	cache := New(Options{SnapshotKeys: []EncryptionKey{newKey, oldKey}})
+	defer cache.Stop()
+	cache.Set("pii", secret)
+	cache.Set("large", large)
+
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	data := buf.Bytes()
+	if bytes.Contains(data, []byte(secret)) {
+		t.Error("Expected snapshot not to contain plaintext values")
+	}
+	if !bytes.Contains(data, []byte(newKey.ID)) {
+		t.Error("Expected snapshot header to name the key")
+	}
+
+	// A cache that still has the key after rotation can load it.
+	restored := New(Options{SnapshotKeys: []EncryptionKey{{ID: "2027-01", Key: make([]byte, 32)}, newKey}})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(bytes.NewReader(data)); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if value, _ := restored.Get("pii"); value != secret {
+		t.Errorf("Expected decrypted value, got %v", value)
+	}
+	if value, _ := restored.Get("large"); value != large {
+		t.Error("Expected large value to survive chunked encryption")
+	}
+
+	if err := New(Options{SnapshotKeys: []EncryptionKey{oldKey}}).LoadSnapshot(bytes.NewReader(data)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)/2] ^= 0xff
+	if err := New(Options{SnapshotKeys: []EncryptionKey{newKey}}).LoadSnapshot(bytes.NewReader(tampered)); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("Expected tampering to be detected, got %v", err)
+	}
+
+	truncated := data[:len(data)-100]
+	if err := New(Options{SnapshotKeys: []EncryptionKey{newKey}}).LoadSnapshot(bytes.NewReader(truncated)); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("Expected truncation to be detected, got %v", err)
+	}
+}
+
+func TestCacheSnapshotVersion1(t *testing.T) {
+	// Version 1 snapshots have no envelope byte.
+	var buf bytes.Buffer
+	WriteHeader(&buf, 1)
+	buf.WriteByte(snapshotEntry)
+	w := bufio.NewWriter(&buf)
+	writeSnapshotString(w, "key")
+	writeSnapshotInt(w, 0)
+	writeSnapshotInt(w, 0)
+	cache := New(Options{})
+	defer cache.Stop()
+	if err := cache.writeSnapshotValue(w, "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteByte(snapshotEnd)
+	w.Flush()
+
+	if err := cache.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if value, _ := cache.Get("key"); value != "value" {
+		t.Errorf("Expected value from version 1 snapshot, got %v", value)
+	}
+}
@@ -2,6 +2,7 @@ package gocache
 
 import (
 	"errors"
+	"fmt"
 )
 
 // Common errors returned by the cache.
@@ -9,4 +10,68 @@ var (
 	ErrKeyNotFound = errors.New("key not found in cache")
 	ErrKeyExpired  = errors.New("key has expired")
 	ErrNilValue    = errors.New("nil value is not allowed")
+	ErrKeyLeased   = errors.New("key is leased by another caller")
+	ErrInvalidTTL  = errors.New("duration must be positive")
+	ErrKeyExists   = errors.New("key already exists in cache")
+
+	// ErrVersionMismatch is returned by SetIfVersion when the key no longer
+	// has the expected version.
+	ErrVersionMismatch = errors.New("key version does not match")
+
+	// ErrNotFoundCached is returned by Get for a key recorded as missing
+	// upstream with SetNegative, Options.NegativeTTL or Options.MissFilter.
+	// It matches ErrKeyNotFound with errors.Is.
+	ErrNotFoundCached = fmt.Errorf("%w: cached as missing", ErrKeyNotFound)
+
+	// ErrCostTooLarge is returned when the cost of a single item exceeds
+	// Options.MaxCost, so it could never fit in the cache.
+	ErrCostTooLarge = errors.New("item cost exceeds MaxCost")
+
+	// ErrInvalidKey is returned when a key violates Options.MaxKeyLength,
+	// Options.KeyCharset or Options.ValidateKey.
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrValueTooLarge is returned when a value exceeds
+	// Options.MaxValueSize.
+	ErrValueTooLarge = errors.New("value exceeds MaxValueSize")
+
+	// ErrNotInteger is returned by Increment when the value of the key is
+	// not an int or int64, or the result would overflow.
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+
+	// ErrWrongType is returned by operations on a value of a particular
+	// type, such as Append, when the key holds a value of another type.
+	ErrWrongType = errors.New("value has the wrong type for the operation")
+
+	// ErrInvalidScore is returned by ZAdd for a score that is NaN.
+	ErrInvalidScore = errors.New("score is not a number")
+
+	// ErrBitOffset is returned by SetBit and GetBit for a negative offset
+	// or one beyond 2^32-1.
+	ErrBitOffset = errors.New("bit offset is out of range")
+
+	// ErrInvalidOptions is returned by NewCache and Options.Validate for a
+	// configuration that cannot work as intended.
+	ErrInvalidOptions = errors.New("invalid options")
+
+	// ErrAsyncBufferFull is returned by SetAsync when its buffer is full
+	// and AsyncOptions.Backpressure is AsyncDrop.
+	ErrAsyncBufferFull = errors.New("async write buffer is full")
+
+	// ErrInvalidLease is returned when a lease token does not match an
+	// active lease on the key, for example because the lease has expired.
+	ErrInvalidLease = errors.New("lease token is not valid for key")
+
+	// ErrUnsupportedVersion is returned when a snapshot or replication peer
+	// uses a format or protocol version this release cannot handle.
+	ErrUnsupportedVersion = errors.New("unsupported format or protocol version")
+	// ErrInvalidHeader is returned when a stream does not start with a
+	// gocache header.
+	ErrInvalidHeader = errors.New("invalid gocache stream header")
+	// ErrCorruptSnapshot is returned when a snapshot contains an unknown
+	// record type.
+	ErrCorruptSnapshot = errors.New("corrupt snapshot")
+	// ErrUnknownKey is returned when a snapshot was encrypted with a key
+	// that is not in Options.SnapshotKeys.
+	ErrUnknownKey = errors.New("snapshot encryption key not found")
 )
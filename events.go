@@ -0,0 +1,305 @@
+package gocache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies the kind of change described by an Event.
+type EventType int
+
+const (
+	// EventDeleted is emitted when a key is removed with Delete,
+	// DeleteWhere or Flush, or the Flush of its namespace.
+	EventDeleted EventType = iota + 1
+	// EventExpired is emitted when an expired key is removed from the cache,
+	// either lazily by Get or by DeleteExpired.
+	EventExpired
+	// EventSet is emitted when a value is stored under a key.
+	EventSet
+	// EventEvicted is emitted when an item is removed to make room for
+	// another one.
+	EventEvicted
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventDeleted:
+		return "deleted"
+	case EventExpired:
+		return "expired"
+	case EventSet:
+		return "set"
+	case EventEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change to a key in the cache.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value interface{}
+	Time  time.Time
+
+	// Seq numbers events in the order they are emitted, starting at 1.
+	// Events received from Notifications have consecutive numbers unless
+	// some were dropped in between.
+	Seq uint64
+}
+
+// Notifications returns a channel that receives an Event for every set,
+// expiration, eviction and deletion in the cache, similar to Redis keyspace
+// notifications.
+//
+// Events are delivered without blocking the cache: if the channel's buffer is
+// full when an event is emitted, the event is dropped for this subscriber and
+// counted in DroppedNotifications. Callers that cannot afford to miss events
+// should use a buffer large enough for their burst size and drain it promptly,
+// or detect gaps in Event.Seq and resync what they missed, using
+// MissedNamespaces to limit the resync to the namespaces that changed.
+// The channel is closed when Stop is called.
+func (c *Cache) Notifications(buffer int) <-chan Event {
+	if buffer < 0 {
+		buffer = 0
+	}
+	ch := make(chan Event, buffer)
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	if c.subsClosed {
+		close(ch)
+		return ch
+	}
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// DroppedNotifications returns the number of events that could not be
+// delivered because a subscriber's buffer was full.
+func (c *Cache) DroppedNotifications() uint64 {
+	return atomic.LoadUint64(&c.droppedEvents)
+}
+
+// Watch returns a channel that receives an Event whenever key is set,
+// deleted, or expires. Delivery follows the same drop rules as
+// Notifications, with a small buffer. The channel is closed when ctx is done
+// or the cache is stopped with Stop or Close, and the goroutine watching ctx
+// exits then too.
+func (c *Cache) Watch(ctx context.Context, key string) <-chan Event {
+	ch := make(chan Event, watchBuffer)
+
+	c.subsMu.Lock()
+	if c.subsClosed {
+		c.subsMu.Unlock()
+		close(ch)
+		return ch
+	}
+	if c.watchers == nil {
+		c.watchers = make(map[string][]chan Event)
+	}
+	c.watchers[key] = append(c.watchers[key], ch)
+	if c.subsDone == nil {
+		c.subsDone = make(chan struct{})
+	}
+	done := c.subsDone
+	c.subsMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.unwatch(key, ch)
+		case <-done:
+			// closeSubscribers has closed ch.
+		}
+	}()
+
+	return ch
+}
+
+// notifying reports whether any subscriber or watcher would receive an
+// event, so that Flush can skip walking the items otherwise.
+func (c *Cache) notifying() bool {
+	c.subsMu.RLock()
+	defer c.subsMu.RUnlock()
+	return len(c.subscribers) > 0 || len(c.watchers) > 0
+}
+
+// watchBuffer is the channel buffer size used by Watch.
+const watchBuffer = 16
+
+// unwatch removes and closes a channel registered with Watch.
+func (c *Cache) unwatch(key string, ch chan Event) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	chans := c.watchers[key]
+	for i, w := range chans {
+		if w == ch {
+			chans = append(chans[:i], chans[i+1:]...)
+			close(ch)
+			break
+		}
+	}
+	if len(chans) == 0 {
+		delete(c.watchers, key)
+	} else {
+		c.watchers[key] = chans
+	}
+}
+
+// emit delivers an event to all subscribers and watchers of the key without
+// blocking.
+func (c *Cache) emit(t EventType, key string, value interface{}) {
+	c.changed(key, false)
+	c.notify(t, key, value)
+}
+
+// notify is emit without the change feeds, for Flush, which reports
+// itself to them once.
+func (c *Cache) notify(t EventType, key string, value interface{}) {
+	c.subsMu.RLock()
+	defer c.subsMu.RUnlock()
+
+	watchers := c.watchers[key]
+	if len(c.subscribers) == 0 && len(watchers) == 0 {
+		return
+	}
+
+	ev := Event{
+		Type:  t,
+		Key:   key,
+		Value: c.valueOf(value),
+		Time:  c.clock.Now(),
+		Seq:   c.events.record(key),
+	}
+	for _, ch := range c.subscribers {
+		c.deliver(ch, ev)
+	}
+	for _, ch := range watchers {
+		c.deliver(ch, ev)
+	}
+}
+
+// deliver sends ev on ch, dropping it if the channel's buffer is full.
+func (c *Cache) deliver(ch chan Event, ev Event) {
+	select {
+	case ch <- ev:
+	default:
+		atomic.AddUint64(&c.droppedEvents, 1)
+	}
+}
+
+// DefaultEventLogSize is the number of recent events kept for
+// MissedNamespaces when Options.EventLogSize is 0.
+const DefaultEventLogSize = 1024
+
+// eventLog numbers events and keeps the keys of the most recent ones.
+type eventLog struct {
+	mu   sync.Mutex
+	seq  uint64
+	size int
+	keys []string // ring indexed by seq modulo size, allocated on first use
+}
+
+func newEventLog(size int) eventLog {
+	switch {
+	case size == 0:
+		size = DefaultEventLogSize
+	case size < 0:
+		size = 0
+	}
+	return eventLog{size: size}
+}
+
+// record assigns the next sequence number to an event for key.
+func (l *eventLog) record(key string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	if l.size > 0 {
+		if l.keys == nil {
+			l.keys = make([]string, l.size)
+		}
+		l.keys[l.seq%uint64(l.size)] = key
+	}
+	return l.seq
+}
+
+// MissedNamespaces returns the sorted names of the namespaces whose keys
+// were changed by the events numbered from through to, inclusive, with ""
+// standing for keys outside any namespace. A Notifications subscriber that
+// receives event n after event m, with n > m+1, calls it with m+1 and n-1
+// to resync only what it missed.
+//
+// It returns false if some of those events are no longer in the event log
+// (see Options.EventLogSize), in which case the subscriber must resync
+// everything.
+func (c *Cache) MissedNamespaces(from, to uint64) ([]string, bool) {
+	if from == 0 {
+		from = 1
+	}
+
+	l := &c.events
+	l.mu.Lock()
+	if to > l.seq {
+		to = l.seq
+	}
+	if from > to {
+		l.mu.Unlock()
+		return nil, true
+	}
+	if l.seq-from >= uint64(l.size) {
+		l.mu.Unlock()
+		return nil, false
+	}
+	keys := make([]string, 0, to-from+1)
+	for seq := from; seq <= to; seq++ {
+		keys = append(keys, l.keys[seq%uint64(l.size)])
+	}
+	l.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, key := range keys {
+		var name string
+		if ns := c.namespaceOf(key); ns != nil {
+			name = ns.name
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// closeSubscribers closes all notification and watch channels.
+func (c *Cache) closeSubscribers() {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for _, ch := range c.subscribers {
+		close(ch)
+	}
+	for _, chans := range c.watchers {
+		for _, ch := range chans {
+			close(ch)
+		}
+	}
+	c.subscribers = nil
+	c.watchers = nil
+	if c.subsDone != nil && !c.subsClosed {
+		close(c.subsDone)
+	}
+	c.subsClosed = true
+}
@@ -0,0 +1,179 @@
+package gocache
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCacheNotifications(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{DefaultExpiration: time.Minute, Clock: clock})
+	events := cache.Notifications(10)
+
+	cache.Set("key1", "value1")
+	cache.SetWithExpiration("key2", "value2", 50*time.Millisecond)
+
+	cache.Delete("key1")
+
+	clock.Advance(100 * time.Millisecond)
+	cache.DeleteExpired()
+
+	for _, key := range []string{"key1", "key2"} {
+		ev := <-events
+		if ev.Type != EventSet || ev.Key != key {
+			t.Errorf("Expected set event for %s, got %+v", key, ev)
+		}
+	}
+
+	ev := <-events
+	if ev.Type != EventDeleted || ev.Key != "key1" || ev.Value != "value1" {
+		t.Errorf("Expected deleted event for key1, got %+v", ev)
+	}
+
+	ev = <-events
+	if ev.Type != EventExpired || ev.Key != "key2" {
+		t.Errorf("Expected expired event for key2, got %+v", ev)
+	}
+
+	cache.Stop()
+	if _, ok := <-events; ok {
+		t.Error("Expected notification channel to be closed after Stop")
+	}
+}
+
+func TestCacheNotificationsDrop(t *testing.T) {
+	cache := New(Options{DefaultExpiration: time.Minute})
+	defer cache.Stop()
+
+	events := cache.Notifications(1)
+
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Delete("key1")
+	cache.Delete("key2")
+
+	if len(events) != 1 {
+		t.Errorf("Expected 1 buffered event, got %d", len(events))
+	}
+	if dropped := cache.DroppedNotifications(); dropped != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", dropped)
+	}
+}
+
+func TestCacheWatch(t *testing.T) {
+	cache := New(Options{DefaultExpiration: time.Minute})
+	defer cache.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := cache.Watch(ctx, "watched")
+
+	cache.Set("other", "ignored")
+	cache.Set("watched", "value")
+	cache.Delete("watched")
+
+	ev := <-events
+	if ev.Type != EventSet || ev.Value != "value" {
+		t.Errorf("Expected set event, got %+v", ev)
+	}
+	ev = <-events
+	if ev.Type != EventDeleted || ev.Key != "watched" {
+		t.Errorf("Expected deleted event, got %+v", ev)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected no further events after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected watch channel to be closed after cancel")
+	}
+}
+
+func TestCacheWatchFlush(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	watched := cache.Watch(context.Background(), "k")
+	namespaced := cache.Watch(context.Background(), "n:a")
+	events := cache.Notifications(10)
+	ns := cache.Namespace("n", NamespaceOptions{})
+	cache.Set("k", 1)
+	ns.Set("a", 2)
+	<-watched
+	<-namespaced
+	<-events
+	<-events
+
+	ns.Flush()
+	if ev := <-namespaced; ev.Type != EventDeleted || ev.Key != "n:a" || ev.Value != 2 {
+		t.Errorf("Expected a deleted event from the namespace flush, got %+v", ev)
+	}
+	cache.Flush()
+	if ev := <-watched; ev.Type != EventDeleted || ev.Key != "k" || ev.Value != 1 {
+		t.Errorf("Expected a deleted event from the flush, got %+v", ev)
+	}
+
+	var keys []string
+	for len(events) > 0 {
+		ev := <-events
+		if ev.Type != EventDeleted {
+			t.Errorf("Expected deleted events, got %+v", ev)
+		}
+		keys = append(keys, ev.Key)
+	}
+	if len(keys) != 2 || keys[0] != "n:a" || keys[1] != "k" {
+		t.Errorf("Expected deleted events for n:a and k, got %v", keys)
+	}
+}
+
+func TestCacheWatchStop(t *testing.T) {
+	cache := New(Options{})
+	before := runtime.NumGoroutine()
+
+	events := cache.Watch(context.Background(), "watched")
+	cache.Stop()
+
+	if _, ok := <-events; ok {
+		t.Error("Expected the watch channel to be closed by Stop")
+	}
+	waitUntil(t, func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestCacheMissedNamespaces(t *testing.T) {
+	cache := New(Options{EventLogSize: 4})
+	defer cache.Stop()
+	users := cache.Namespace("users", NamespaceOptions{})
+	orders := cache.Namespace("orders", NamespaceOptions{})
+	events := cache.Notifications(1)
+
+	cache.Set("plain", 1) // 1, delivered
+	users.Set("alice", 2) // 2, dropped
+	orders.Set("o1", 3)   // 3, dropped
+	users.Set("bob", 4)   // 4, dropped
+	<-events
+	cache.Set("plain", 5) // 5, delivered
+
+	ev := <-events
+	if ev.Seq != 5 {
+		t.Fatalf("Expected event 5 after the gap, got %d", ev.Seq)
+	}
+	names, ok := cache.MissedNamespaces(2, ev.Seq-1)
+	if !ok || len(names) != 2 || names[0] != "orders" || names[1] != "users" {
+		t.Errorf("Expected [orders users], got %v (%v)", names, ok)
+	}
+
+	for i := 0; i < 4; i++ {
+		cache.Set("plain", i)
+		<-events
+	}
+	if _, ok := cache.MissedNamespaces(2, 4); ok {
+		t.Error("Expected events that left the log to require a full resync")
+	}
+	if names, ok := cache.MissedNamespaces(9, 9); !ok || len(names) != 1 || names[0] != "" {
+		t.Errorf("Expected the root namespace, got %v (%v)", names, ok)
+	}
+}
@@ -0,0 +1,197 @@
+package gocache
+
+import (
+	"container/list"
+)
+
+// EvictionPolicy decides which item to evict when the cache reaches
+// MaxEntries. The cache informs the policy of every key it adds, accesses
+// and removes, and asks it for a victim when room is needed.
+//
+// The cache serializes all calls to a policy, so implementations do not need
+// to be safe for concurrent use. A policy must not call back into the cache.
+type EvictionPolicy interface {
+	// OnAdd is called when a new key is stored.
+	OnAdd(key string)
+	// OnAccess is called when an existing key is read or overwritten. With
+	// Options.ReadOptimized, reads are not synchronized with removals, so
+	// OnAccess may be called for a key that was just removed and should
+	// then be ignored. The key may share memory with a caller's buffer (see
+	// Cache.GetBytes), so it must be copied with strings.Clone if the
+	// policy keeps it after returning.
+	OnAccess(key string)
+	// OnRemove is called when a key is removed for any reason, including
+	// after it was returned by Victim.
+	OnRemove(key string)
+	// Victim returns the key that should be evicted next, or false if the
+	// policy tracks no keys.
+	Victim() (key string, ok bool)
+}
+
+// evict removes items chosen by the eviction policy until an item of the
+// given cost can be stored under key without exceeding MaxEntries or
+// MaxCost. The item currently stored under key, if any, may itself be
+// evicted. Victims are chosen by evictOne. It must be called with c.mu held
+// and returns the evicted items for OnEvicted.
+func (c *Cache) evict(key string, cost int64) []keyValue {
+	var evicted []keyValue
+
+	for {
+		entries, extra := 1, cost
+		if old, found := c.items.get(key); found {
+			entries, extra = 0, cost-old.Cost
+		}
+		overEntries := c.maxEntries > 0 && c.items.len()+entries > c.maxEntries
+		overCost := c.maxCost > 0 && c.totalCost+extra > c.maxCost
+		if !overEntries && !overCost {
+			break
+		}
+		kv, ok := c.evictOne()
+		if !ok {
+			break
+		}
+		evicted = append(evicted, kv)
+	}
+
+	return evicted
+}
+
+// evictOne evicts the next victim of the eviction policy. Pinned victims,
+// and those stored by the transaction being committed, are never evicted.
+// Victims above the lowest stored priority are passed over, so
+// lower-priority items always go first; if only those are left, the one of
+// lowest priority the policy offered is evicted. Victims passed over are
+// taken out of the policy until a victim is found, so that every policy,
+// including those ignoring OnAccess such as FIFO, offers its next
+// candidate, and are then added back as if new. It reports false if there
+// is nothing left to evict. It must be called with c.mu held.
+func (c *Cache) evictOne() (keyValue, bool) {
+	var passed []string
+	defer func() {
+		if len(passed) > 0 {
+			c.policyMu.Lock()
+			for _, key := range passed {
+				if _, pinned := c.pinned[key]; !pinned {
+					c.policy.OnAdd(key)
+				}
+			}
+			c.policyMu.Unlock()
+		}
+	}()
+
+	lowest := c.lowestPriority()
+	fallback := -1 // index in passed of the unpinned victim of lowest priority
+	var fallbackPriority int
+	var victim string
+	for {
+		var ok bool
+		c.policyMu.Lock()
+		victim, ok = c.policy.Victim()
+		c.policyMu.Unlock()
+		if !ok {
+			if fallback < 0 {
+				return keyValue{}, false
+			}
+			victim = passed[fallback]
+			passed = append(passed[:fallback], passed[fallback+1:]...)
+			c.policyMu.Lock()
+			c.policy.OnAdd(victim)
+			c.policyMu.Unlock()
+			break
+		}
+
+		item, found := c.items.get(victim)
+		if !found {
+			// The policy returned a key the cache no longer holds; tell it
+			// to forget it, and ask again.
+			c.remove(victim)
+			continue
+		}
+		_, pinned := c.pinned[victim]
+		_, protected := c.protected[victim]
+		if !pinned && !protected && item.Priority <= lowest {
+			break
+		}
+		c.policyMu.Lock()
+		c.policy.OnRemove(victim)
+		c.policyMu.Unlock()
+		passed = append(passed, victim)
+		if !pinned && !protected && (fallback < 0 || item.Priority < fallbackPriority) {
+			fallback, fallbackPriority = len(passed)-1, item.Priority
+		}
+	}
+
+	item, _ := c.items.get(victim)
+	c.remove(victim)
+	c.countEviction(victim)
+	c.emit(EventEvicted, victim, item.Value)
+	if c.tuner != nil {
+		c.tuner.evicted(victim, c.ghostCapacity())
+	}
+	return keyValue{victim, item.Value}, true
+}
+
+// lowestPriority returns the lowest priority of any stored item. It must be
+// called with c.mu held.
+func (c *Cache) lowestPriority() int {
+	var lowest int
+	first := true
+	for p := range c.priorities {
+		if first || p < lowest {
+			lowest, first = p, false
+		}
+	}
+	return lowest
+}
+
+// unindexPriority records that an item of the given priority was removed.
+// It must be called with c.mu held.
+func (c *Cache) unindexPriority(priority int) {
+	if c.priorities[priority]--; c.priorities[priority] == 0 {
+		delete(c.priorities, priority)
+	}
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	order    *list.List // front is most recently used
+	elements map[string]*list.Element
+}
+
+// NewLRUPolicy returns an EvictionPolicy that evicts the least recently
+// used key. It is the default policy when MaxEntries is set.
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) OnAdd(key string) {
+	if e, found := p.elements[key]; found {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) OnAccess(key string) {
+	if e, found := p.elements[key]; found {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) OnRemove(key string) {
+	if e, found := p.elements[key]; found {
+		p.order.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+func (p *lruPolicy) Victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
@@ -0,0 +1,108 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCacheLRUEviction(t *testing.T) {
+	var evicted []string
+	cache := New(Options{
+		DefaultExpiration: time.Minute,
+		MaxEntries:        2,
+		OnEvicted: func(key string, value interface{}) {
+			evicted = append(evicted, key)
+		},
+	})
+
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Get("key1") // key2 is now least recently used
+	cache.Set("key3", "value3")
+
+	if count := cache.ItemCount(); count != 2 {
+		t.Errorf("Expected 2 items, got %d", count)
+	}
+	if _, err := cache.Get("key2"); err != ErrKeyNotFound {
+		t.Errorf("Expected key2 to be evicted, got %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != "key2" {
+		t.Errorf("Expected OnEvicted for key2, got %v", evicted)
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+
+	// Overwriting an existing key must not evict anything
+	cache.Set("key1", "updated")
+	if count := cache.ItemCount(); count != 2 {
+		t.Errorf("Expected 2 items after overwrite, got %d", count)
+	}
+}
+
+// fifoTestPolicy evicts keys in insertion order, ignoring accesses.
+type fifoTestPolicy struct {
+	keys []string
+}
+
+func (p *fifoTestPolicy) OnAdd(key string)    { p.keys = append(p.keys, key) }
+func (p *fifoTestPolicy) OnAccess(key string) {}
+func (p *fifoTestPolicy) OnRemove(key string) {
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			return
+		}
+	}
+}
+func (p *fifoTestPolicy) Victim() (string, bool) {
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	return p.keys[0], true
+}
+
+func TestCacheCustomEvictionPolicy(t *testing.T) {
+	cache := New(Options{
+		MaxEntries:     2,
+		EvictionPolicy: &fifoTestPolicy{},
+	})
+
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Get("key1")
+	cache.Set("key3", "value3")
+
+	if _, err := cache.Get("key1"); err != ErrKeyNotFound {
+		t.Errorf("Expected key1 to be evicted by custom policy, got %v", err)
+	}
+	if _, err := cache.Get("key2"); err != nil {
+		t.Errorf("Expected key2 to remain, got %v", err)
+	}
+}
+
+func TestCacheSetWithPriority(t *testing.T) {
+	for name := range builtinPolicies(3) {
+		t.Run(name, func(t *testing.T) {
+			cache := New(Options{MaxEntries: 3, EvictionPolicy: builtinPolicies(3)[name]})
+			defer cache.Stop()
+
+			cache.SetWithPriority("auth", "token", 0, 10)
+			cache.Set("key1", "value1")
+			cache.Set("key2", "value2")
+			for i := 0; i < 10; i++ {
+				cache.Get("key1")
+				cache.Get("key2")
+				cache.Set(fmt.Sprint("churn", i), i)
+			}
+
+			if _, err := cache.Get("auth"); err != nil {
+				t.Fatalf("Expected the high-priority item to survive, got %v", err)
+			}
+			if count := cache.ItemCount(); count != 3 {
+				t.Fatalf("Expected 3 items, got %d", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package gocache
+
+// expiredItem is an expired item whose OnExpired callback has not completed
+// yet. Pending expirations are saved in snapshots, so that a callback
+// interrupted by a crash or restart is delivered again when the snapshot is
+// loaded.
+type expiredItem struct {
+	id uint64
+	keyValue
+}
+
+// expire removes key because its item expired, records the expiration and,
+// if OnExpired is set, registers its callback as pending. It must be called
+// with c.mu held; the returned expiredItem must be passed to notifyExpired
+// after the lock is released.
+func (c *Cache) expire(key string, value interface{}) expiredItem {
+	c.remove(key)
+	c.countExpiration(key)
+	c.emit(EventExpired, key, value)
+
+	exp := expiredItem{keyValue: keyValue{key, value}}
+	if c.onExpired != nil {
+		c.expMu.Lock()
+		c.expSeq++
+		exp.id = c.expSeq
+		c.pendingExpired[exp.id] = exp.keyValue
+		c.expMu.Unlock()
+	}
+	return exp
+}
+
+// notifyExpired calls OnExpired for each expiration and marks it delivered
+// once the callback returns. It must be called without holding c.mu.
+func (c *Cache) notifyExpired(expired []expiredItem) {
+	if c.onExpired == nil {
+		return
+	}
+	for _, exp := range expired {
+		c.onExpired(exp.key, c.valueOf(exp.value))
+
+		c.expMu.Lock()
+		delete(c.pendingExpired, exp.id)
+		c.expMu.Unlock()
+	}
+}
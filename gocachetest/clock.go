@@ -0,0 +1,88 @@
+package gocachetest
+
+import (
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Clock is a gocache.Clock whose time only moves when Advance or Set is
+// called. Tickers created from it fire as the clock passes their deadlines,
+// so the cache's cleanup goroutine can be driven deterministically.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker driven by the clock.
+func (c *Clock) NewTicker(d time.Duration) gocache.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t, firing any tickers that come due. Like
+// time.Ticker, a ticker whose reader is slow drops ticks rather than
+// queueing them.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = t
+	for _, tk := range c.tickers {
+		tk.fire(t)
+	}
+}
+
+type ticker struct {
+	mu      sync.Mutex
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+func (t *ticker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for !t.stopped && !t.next.After(now) {
+		select {
+		case t.c <- t.next:
+		default:
+		}
+		t.next = t.next.Add(t.period)
+	}
+}
@@ -0,0 +1,42 @@
+// Package gocachetest provides utilities for testing code that uses
+// gocache: a controllable fake clock, a recording mock cache, and helpers to
+// force keys to expire without waiting.
+package gocachetest
+
+import (
+	"time"
+
+	"gocache"
+)
+
+// Cache is the subset of the gocache.Cache API implemented by Mock.
+// Applications can depend on it instead of *gocache.Cache so tests can
+// substitute a Mock.
+type Cache interface {
+	Get(key string) (interface{}, error)
+	Set(key string, value interface{}) error
+	SetWithExpiration(key string, value interface{}, duration time.Duration) error
+	GetOrSet(key string, fn func() (interface{}, error), opts ...gocache.CallOption) (interface{}, error)
+	Delete(key string) bool
+	Items() map[string]interface{}
+	ItemCount() int
+	Flush()
+}
+
+var (
+	_ Cache = (*gocache.Cache)(nil)
+	_ Cache = (*Mock)(nil)
+)
+
+// Expire makes key expire immediately, as if its TTL had elapsed. It returns
+// gocache.ErrKeyNotFound if the key does not exist.
+func Expire(c *gocache.Cache, key string) error {
+	return c.ExpireAt(key, time.Unix(0, 1))
+}
+
+// ExpireAll makes every item in the cache expire immediately.
+func ExpireAll(c *gocache.Cache) {
+	for key := range c.Items() {
+		c.ExpireAt(key, time.Unix(0, 1))
+	}
+}
@@ -0,0 +1,70 @@
+package gocachetest
+
+import (
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+func TestClockDrivesExpiration(t *testing.T) {
+	clock := NewClock(time.Now())
+	cache := gocache.New(gocache.Options{
+		DefaultExpiration: time.Minute,
+		Clock:             clock,
+	})
+
+	cache.Set("key", "value")
+
+	clock.Advance(30 * time.Second)
+	if _, err := cache.Get("key"); err != nil {
+		t.Errorf("Expected key to be live, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := cache.Get("key"); err != gocache.ErrKeyExpired {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+
+	if err := Expire(cache, "key1"); err != nil {
+		t.Fatalf("Failed to expire key1: %v", err)
+	}
+	if _, err := cache.Get("key1"); err != gocache.ErrKeyExpired {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+	if err := Expire(cache, "missing"); err != gocache.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	ExpireAll(cache)
+	if items := cache.Items(); len(items) != 0 {
+		t.Errorf("Expected no live items, got %v", items)
+	}
+}
+
+func TestMockRecordsCalls(t *testing.T) {
+	mock := NewMock(gocache.Options{DefaultExpiration: time.Minute})
+
+	mock.Set("key", "value")
+	mock.Get("key")
+	mock.Get("missing")
+
+	if n := mock.CallCount("Get"); n != 2 {
+		t.Errorf("Expected 2 Get calls, got %d", n)
+	}
+	calls := mock.Calls()
+	if len(calls) != 3 || calls[0].Method != "Set" || calls[0].Args[0] != "key" {
+		t.Errorf("Unexpected calls: %+v", calls)
+	}
+
+	mock.Clock.Advance(2 * time.Minute)
+	if _, err := mock.Get("key"); err != gocache.ErrKeyExpired {
+		t.Errorf("Expected ErrKeyExpired after advancing the clock, got %v", err)
+	}
+}
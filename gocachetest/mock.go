@@ -0,0 +1,114 @@
+package gocachetest
+
+import (
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Call records one method call made on a Mock.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Mock is an in-memory Cache that records every call made to it. It is
+// backed by a real gocache.Cache driven by a fake Clock, so it behaves like
+// the real thing, including expiration, while letting tests assert on how
+// the cache was used.
+type Mock struct {
+	// Cache is the underlying cache. Calls made on it directly are not
+	// recorded.
+	Cache *gocache.Cache
+	// Clock drives expiration in Cache.
+	Clock *Clock
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// NewMock returns a Mock whose underlying cache is created with options.
+// The Clock option is replaced with a fake clock.
+func NewMock(options gocache.Options) *Mock {
+	clock := NewClock(time.Now())
+	options.Clock = clock
+	return &Mock{
+		Cache: gocache.New(options),
+		Clock: clock,
+	}
+}
+
+// Calls returns the calls recorded so far, in order.
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount returns how many times method has been called.
+func (m *Mock) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, call := range m.calls {
+		if call.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset forgets the recorded calls.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *Mock) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+func (m *Mock) Get(key string) (interface{}, error) {
+	m.record("Get", key)
+	return m.Cache.Get(key)
+}
+
+func (m *Mock) Set(key string, value interface{}) error {
+	m.record("Set", key, value)
+	return m.Cache.Set(key, value)
+}
+
+func (m *Mock) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	m.record("SetWithExpiration", key, value, duration)
+	return m.Cache.SetWithExpiration(key, value, duration)
+}
+
+func (m *Mock) GetOrSet(key string, fn func() (interface{}, error), opts ...gocache.CallOption) (interface{}, error) {
+	m.record("GetOrSet", key)
+	return m.Cache.GetOrSet(key, fn, opts...)
+}
+
+func (m *Mock) Delete(key string) bool {
+	m.record("Delete", key)
+	return m.Cache.Delete(key)
+}
+
+func (m *Mock) Items() map[string]interface{} {
+	m.record("Items")
+	return m.Cache.Items()
+}
+
+func (m *Mock) ItemCount() int {
+	m.record("ItemCount")
+	return m.Cache.ItemCount()
+}
+
+func (m *Mock) Flush() {
+	m.record("Flush")
+	m.Cache.Flush()
+}
@@ -0,0 +1,135 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold hashes.
+	gob.Register(map[string]interface{}{})
+}
+
+// HSet sets field of the hash stored under key to value, and reports
+// whether the field is new.
+//
+// Hashes are stored as map[string]interface{} values, so Get returns a
+// hash as a map, and a JSON object stored in the cache can be used as a
+// hash. A hash is created by the first HSet to a missing or expired key,
+// with the default expiration, and keeps its expiration and priority when
+// changed, like a list: the TTL applies to the whole hash, not to its
+// fields. Every change replaces the stored map with a new one, so maps
+// returned earlier do not change and concurrent updates of different
+// fields are never lost. Operations on a key holding another type of
+// value return ErrWrongType.
+func (c *Cache) HSet(key, field string, value interface{}) (bool, error) {
+	if value == nil && !c.allowNil {
+		return false, ErrNilValue
+	}
+	var added bool
+	err := c.changeHash(key, func(hash map[string]interface{}) map[string]interface{} {
+		_, exists := hash[field]
+		added = !exists
+		next := make(map[string]interface{}, len(hash)+1)
+		for f, v := range hash {
+			next[f] = v
+		}
+		next[field] = value
+		return next
+	})
+	return added, err
+}
+
+// HGet returns the value of field of the hash stored under key, or
+// ErrKeyNotFound if the hash or the field is missing.
+func (c *Cache) HGet(key, field string) (interface{}, error) {
+	hash, err := c.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := hash[field]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// HDel removes fields from the hash stored under key and returns the
+// number of fields that were in it. A hash emptied by HDel stays in the
+// cache until it expires or is deleted.
+func (c *Cache) HDel(key string, fields ...string) (int, error) {
+	var removed int
+	err := c.changeHash(key, func(hash map[string]interface{}) map[string]interface{} {
+		next := make(map[string]interface{}, len(hash))
+		for f, v := range hash {
+			next[f] = v
+		}
+		for _, f := range fields {
+			delete(next, f)
+		}
+		if removed = len(hash) - len(next); removed == 0 {
+			return nil
+		}
+		return next
+	})
+	return removed, err
+}
+
+// HGetAll returns a copy of the hash stored under key. A missing hash is
+// empty.
+func (c *Cache) HGetAll(key string) (map[string]interface{}, error) {
+	hash, err := c.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]interface{}, len(hash))
+	for f, v := range hash {
+		all[f] = v
+	}
+	return all, nil
+}
+
+// HLen returns the number of fields of the hash stored under key, or 0 if
+// it is missing.
+func (c *Cache) HLen(key string) (int, error) {
+	hash, err := c.getHash(key)
+	return len(hash), err
+}
+
+// getHash returns the hash stored under key, or nil if it is missing.
+func (c *Cache) getHash(key string) (map[string]interface{}, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds %T, not a hash", ErrWrongType, key, value)
+	}
+	return hash, nil
+}
+
+// changeHash atomically replaces the hash stored under key, or nil if it
+// is missing, with the hash returned by fn, unless that is nil.
+func (c *Cache) changeHash(key string, fn func(hash map[string]interface{}) map[string]interface{}) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+
+	_, err := c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		var hash map[string]interface{}
+		if found {
+			var ok bool
+			if hash, ok = value.(map[string]interface{}); !ok {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a hash", ErrWrongType, key, value)
+			}
+		}
+		next := fn(hash)
+		return next, next != nil, nil
+	})
+	return err
+}
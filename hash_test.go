@@ -0,0 +1,78 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheHash(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if added, err := cache.HSet("user:1", "name", "alice"); err != nil || !added {
+		t.Errorf("Expected a new field, got %v (%v)", added, err)
+	}
+	cache.ExpireAt("user:1", clock.Now().Add(time.Minute))
+	if added, err := cache.HSet("user:1", "name", "alicia"); err != nil || added {
+		t.Errorf("Expected an existing field, got %v (%v)", added, err)
+	}
+	before, _ := cache.HGetAll("user:1")
+	cache.HSet("user:1", "age", 30)
+
+	if value, err := cache.HGet("user:1", "name"); err != nil || value != "alicia" {
+		t.Errorf("Expected alicia, got %v (%v)", value, err)
+	}
+	if _, err := cache.HGet("user:1", "email"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for a missing field, got %v", err)
+	}
+	if all, _ := cache.HGetAll("user:1"); len(all) != 2 || all["age"] != 30 {
+		t.Errorf("Expected 2 fields, got %v", all)
+	}
+	if len(before) != 1 {
+		t.Errorf("Expected an earlier HGetAll to be unchanged, got %v", before)
+	}
+
+	if n, err := cache.HDel("user:1", "age", "email"); err != nil || n != 1 {
+		t.Errorf("Expected 1 field removed, got %d (%v)", n, err)
+	}
+	if n, _ := cache.HLen("user:1"); n != 1 {
+		t.Errorf("Expected 1 field, got %d", n)
+	}
+	if _, err := cache.HSet("user:1", "nil", nil); err != ErrNilValue {
+		t.Errorf("Expected ErrNilValue, got %v", err)
+	}
+	cache.Set("text", "x")
+	if _, err := cache.HGet("text", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.HGet("user:1", "name"); err != ErrKeyNotFound {
+		t.Errorf("Expected the hash to keep its expiration, got %v", err)
+	}
+}
+
+func TestCacheHashConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				cache.HSet("fields", fmt.Sprint(i, "-", n), n)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := cache.HLen("fields"); n != 400 {
+		t.Errorf("Expected 400 fields, got %d", n)
+	}
+}
@@ -0,0 +1,124 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold HyperLogLogs.
+	gob.Register(HyperLogLog{})
+}
+
+// hllPrecision is the number of hash bits that select a register of a
+// HyperLogLog. With 2^14 registers, counts have a standard error of
+// about 0.81%.
+const hllPrecision = 14
+
+// HyperLogLog is the value of a key holding a HyperLogLog, a sketch that
+// estimates the number of distinct items added to it in a fixed 16 KB,
+// whatever the number of items. It holds one register per byte.
+type HyperLogLog []byte
+
+// Count returns the estimated number of distinct items added to h.
+func (h HyperLogLog) Count() uint64 {
+	if len(h) == 0 {
+		return 0
+	}
+	m := float64(len(h))
+	var sum float64
+	zeros := 0
+	for _, r := range h {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small counts.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// hllRegister returns the register that item updates and the value it
+// raises it to: the position of the first set bit of the rest of the hash.
+func hllRegister(item string) (int, byte) {
+	f := fnv.New64a()
+	f.Write([]byte(item))
+	// FNV alone distributes short, similar items poorly; finish with the
+	// avalanche step of MurmurHash3.
+	h := f.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	index := int(h >> (64 - hllPrecision))
+	rest := h<<hllPrecision | 1<<(hllPrecision-1)
+	return index, byte(bits.LeadingZeros64(rest) + 1)
+}
+
+// PFAdd adds items to the HyperLogLog stored under key and reports whether
+// its estimate may have changed. The HyperLogLog is created by the first
+// PFAdd to a missing or expired key, with the default expiration, and
+// keeps its expiration and priority when changed, like a list. It takes
+// 16 KB however many items are added, so it suits counting unique
+// visitors and similar large cardinalities with an error of about 1%.
+// Operations on a key holding another type of value return ErrWrongType.
+func (c *Cache) PFAdd(key string, items ...string) (bool, error) {
+	if err := c.validateKey(key); err != nil {
+		return false, err
+	}
+
+	return c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		var h HyperLogLog
+		if found {
+			var ok bool
+			if h, ok = value.(HyperLogLog); !ok || len(h) != 1<<hllPrecision {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a HyperLogLog", ErrWrongType, key, value)
+			}
+		}
+
+		var next HyperLogLog
+		if !found {
+			next = make(HyperLogLog, 1<<hllPrecision)
+		}
+		for _, item := range items {
+			index, rank := hllRegister(item)
+			if next == nil {
+				if h[index] >= rank {
+					continue
+				}
+				// Copy on the first change only: once most registers are
+				// set, most adds change nothing and store nothing.
+				next = append(HyperLogLog(nil), h...)
+			}
+			next[index] = max(next[index], rank)
+		}
+		return next, next != nil, nil
+	})
+}
+
+// PFCount returns the estimated number of distinct items added to the
+// HyperLogLog stored under key, or 0 if it is missing.
+func (c *Cache) PFCount(key string) (uint64, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	h, ok := value.(HyperLogLog)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q holds %T, not a HyperLogLog", ErrWrongType, key, value)
+	}
+	return h.Count(), nil
+}
@@ -0,0 +1,54 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestCacheHyperLogLog(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	if n, err := cache.PFCount("visitors"); err != nil || n != 0 {
+		t.Errorf("Expected a missing HyperLogLog to count 0, got %d (%v)", n, err)
+	}
+	if changed, err := cache.PFAdd("visitors", "a", "b", "c", "a"); err != nil || !changed {
+		t.Errorf("Expected the first add to change the estimate, got %v (%v)", changed, err)
+	}
+	if n, _ := cache.PFCount("visitors"); n != 3 {
+		t.Errorf("Expected 3 distinct items, got %d", n)
+	}
+	_, version, _ := cache.GetVersioned("visitors")
+	if changed, _ := cache.PFAdd("visitors", "b"); changed {
+		t.Error("Expected adding a known item not to change the estimate")
+	}
+	if _, v, _ := cache.GetVersioned("visitors"); v != version {
+		t.Error("Expected adding a known item not to store anything")
+	}
+
+	for _, want := range []int{1000, 100000} {
+		cache.Delete("large")
+		batch := make([]string, 0, 1000)
+		for i := 0; i < want; i++ {
+			batch = append(batch, fmt.Sprint("user-", i))
+			if len(batch) == cap(batch) || i == want-1 {
+				cache.PFAdd("large", batch...)
+				batch = batch[:0]
+			}
+		}
+		n, _ := cache.PFCount("large")
+		if e := math.Abs(float64(n)-float64(want)) / float64(want); e > 0.03 {
+			t.Errorf("Expected about %d distinct items, got %d (error %.2f%%)", want, n, 100*e)
+		}
+	}
+
+	cache.Set("text", "x")
+	if _, err := cache.PFAdd("text", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.PFCount("text"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
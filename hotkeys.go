@@ -0,0 +1,134 @@
+package gocache
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyHits is a key and the number of hits on it, as reported by TopKeys.
+type KeyHits struct {
+	Key  string
+	Hits uint64
+}
+
+// hotKeys finds the most frequently hit keys with the Space-Saving
+// algorithm: it counts the hits of a bounded number of keys, and a hit on
+// a key it does not count replaces the key with the fewest hits, taking
+// over its count. The count of a key is thus an upper bound of its hits,
+// over by at most the count it took over, and every key hit more often
+// than total hits / capacity is counted.
+type hotKeys struct {
+	mu      sync.Mutex
+	entries hotHeap // min-heap by hits
+	index   map[string]*hotEntry
+	size    int
+}
+
+type hotEntry struct {
+	key  string
+	hits uint64
+	pos  int // index in entries
+}
+
+func newHotKeys(size int) *hotKeys {
+	if size <= 0 {
+		return nil
+	}
+	return &hotKeys{index: make(map[string]*hotEntry, size), size: size}
+}
+
+// hit counts a hit on key. key is copied if it is retained.
+func (h *hotKeys) hit(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e, ok := h.index[key]; ok {
+		e.hits++
+		heap.Fix(&h.entries, e.pos)
+		return
+	}
+	key = strings.Clone(key)
+	if len(h.entries) < h.size {
+		e := &hotEntry{key: key, hits: 1}
+		h.index[key] = e
+		heap.Push(&h.entries, e)
+		return
+	}
+	e := h.entries[0]
+	delete(h.index, e.key)
+	e.key = key
+	e.hits++
+	h.index[key] = e
+	heap.Fix(&h.entries, 0)
+}
+
+// top returns the n keys with the most hits, most hit first.
+func (h *hotKeys) top(n int) []KeyHits {
+	h.mu.Lock()
+	top := make([]KeyHits, len(h.entries))
+	for i, e := range h.entries {
+		top[i] = KeyHits{Key: e.key, Hits: e.hits}
+	}
+	h.mu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Hits != top[j].Hits {
+			return top[i].Hits > top[j].Hits
+		}
+		return top[i].Key < top[j].Key
+	})
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// reset forgets all counts.
+func (h *hotKeys) reset() {
+	h.mu.Lock()
+	h.entries = nil
+	h.index = make(map[string]*hotEntry, h.size)
+	h.mu.Unlock()
+}
+
+// hotHeap implements heap.Interface for hotKeys.
+type hotHeap []*hotEntry
+
+func (h hotHeap) Len() int           { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].hits < h[j].hits }
+func (h hotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *hotHeap) Push(x interface{}) {
+	e := x.(*hotEntry)
+	e.pos = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *hotHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// TopKeys returns the n keys hit most often since the cache was created or
+// last flushed, with their hits, most hit first, to spot keys that deserve
+// a longer TTL or handling of their own. It returns nil unless
+// Options.HotKeys is set.
+//
+// The hits are counted with a sketch of Options.HotKeys keys, so they are
+// approximate: a key that entered the sketch by displacing a colder one
+// inherits its count, which may overstate its hits. Keys hit more than
+// 1/HotKeys of all hits are always reported.
+func (c *Cache) TopKeys(n int) []KeyHits {
+	if c.hotKeys == nil || n <= 0 {
+		return nil
+	}
+	return c.hotKeys.top(n)
+}
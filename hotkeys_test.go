@@ -0,0 +1,70 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopKeys(t *testing.T) {
+	c := New(Options{HotKeys: 50})
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint("key", i), i)
+	}
+	// Two hot keys among many more cold ones than the sketch holds.
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 100; i++ {
+			if i%3 == round%3 {
+				c.Get(fmt.Sprint("key", i))
+			}
+		}
+		for i := 0; i < 4; i++ {
+			c.Get("key42")
+		}
+		c.Get("key7")
+		c.Get("key7")
+	}
+
+	top := c.TopKeys(2)
+	if len(top) != 2 {
+		t.Fatalf("TopKeys(2) returned %d keys", len(top))
+	}
+	if top[0].Key != "key42" || top[1].Key != "key7" {
+		t.Fatalf("TopKeys(2) = %v, want key42 then key7", top)
+	}
+	if top[0].Hits < 200 {
+		t.Fatalf("key42 has %d hits, want at least 200", top[0].Hits)
+	}
+}
+
+func TestTopKeysCountsOnlyHits(t *testing.T) {
+	c := New(Options{HotKeys: 10})
+	defer c.Stop()
+
+	c.Set("a", 1)
+	for i := 0; i < 5; i++ {
+		c.Get("a")
+		c.Get("missing")
+	}
+	top := c.TopKeys(10)
+	if len(top) != 1 || top[0] != (KeyHits{"a", 5}) {
+		t.Fatalf("TopKeys = %v, want [{a 5}]", top)
+	}
+
+	c.Flush()
+	if top := c.TopKeys(10); len(top) != 0 {
+		t.Fatalf("TopKeys after Flush = %v, want none", top)
+	}
+}
+
+func TestTopKeysDisabled(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Get("a")
+	if top := c.TopKeys(1); top != nil {
+		t.Fatalf("TopKeys = %v, want nil", top)
+	}
+}
@@ -0,0 +1,93 @@
+// Package echo adapts an httpcache.Middleware to the Echo web framework.
+// It is a module of its own, so that only applications using Echo depend
+// on it:
+//
+//	import httpcacheecho "gocache/httpcache/echo"
+//
+//	products := httpcache.New(c, httpcache.Options{TTL: time.Minute})
+//	e.GET("/products/:id", getProduct, httpcacheecho.Middleware(products))
+//
+// Unlike wrapping Middleware.Handler with echo.WrapMiddleware, it leaves
+// the errors returned by handlers to Echo's HTTPErrorHandler, and caches
+// nothing for them.
+package echo
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gocache/httpcache"
+)
+
+// Middleware returns an Echo middleware that serves cacheable requests
+// from m's cache and otherwise calls the next handler, caching the
+// response it writes if it returns no error. Responses are cached by the
+// rules of m.
+func Middleware(m *httpcache.Middleware) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if !m.Cacheable(r) {
+				return next(c)
+			}
+			res := c.Response()
+			if resp, ok := m.Lookup(r); ok {
+				resp.Serve(res)
+				return nil
+			}
+
+			res.Header().Set(httpcache.StatusHeader, "MISS")
+			rec := &recorder{ResponseWriter: res.Writer, max: m.MaxBodySize()}
+			res.Writer = rec
+			err := next(c)
+			res.Writer = rec.ResponseWriter
+			if err == nil && rec.header != nil && !rec.overflow {
+				m.Store(r, httpcache.Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()})
+			}
+			return err
+		}
+	}
+}
+
+// recorder passes a response through to the client, recording it.
+type recorder struct {
+	http.ResponseWriter
+	max int
+
+	status   int
+	header   http.Header // copy of the headers when they were written
+	body     bytes.Buffer
+	overflow bool // the body exceeded max and was not recorded in full
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.header == nil && status >= 200 {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+		rec.header.Del(httpcache.StatusHeader)
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.header == nil {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > rec.max {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, for
+// example to flush it.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
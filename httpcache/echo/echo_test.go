@@ -0,0 +1,90 @@
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gocache"
+	"gocache/httpcache"
+)
+
+func newServer(m *httpcache.Middleware, calls *int) *echo.Echo {
+	e := echo.New()
+	e.GET("/products/:id", func(c echo.Context) error {
+		*calls++
+		return c.JSON(http.StatusOK, map[string]string{"id": c.Param("id")})
+	}, Middleware(m))
+	e.GET("/missing", func(c echo.Context) error {
+		*calls++
+		return echo.ErrNotFound
+	}, Middleware(m))
+	e.GET("/large", func(c echo.Context) error {
+		*calls++
+		return c.String(http.StatusOK, strings.Repeat("x", 100))
+	}, Middleware(m))
+	return e
+}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	calls := 0
+	e := newServer(httpcache.New(c, httpcache.Options{TTL: time.Minute, MaxBodySize: 50}), &calls)
+
+	first := get(e, "/products/1")
+	if first.Code != http.StatusOK || first.Header().Get(httpcache.StatusHeader) != "MISS" {
+		t.Fatalf("Expected a 200 MISS, got %d %q", first.Code, first.Header().Get(httpcache.StatusHeader))
+	}
+	second := get(e, "/products/1")
+	if second.Header().Get(httpcache.StatusHeader) != "HIT" {
+		t.Errorf("Expected a HIT, got %q", second.Header().Get(httpcache.StatusHeader))
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("Expected the cached body and headers, got %q %v", second.Body.String(), second.Header())
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, got %d", calls)
+	}
+
+	// Handler errors reach Echo's error handler and are not cached, and
+	// neither are bodies above MaxBodySize.
+	if w := get(e, "/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the error handler's 404, got %d", w.Code)
+	}
+	for _, target := range []string{"/missing", "/large"} {
+		calls = 0
+		get(e, target)
+		if w := get(e, target); w.Header().Get(httpcache.StatusHeader) != "MISS" || calls != 2 {
+			t.Errorf("Expected %s not to be cached, got %q after %d calls", target, w.Header().Get(httpcache.StatusHeader), calls)
+		}
+	}
+	if w := get(e, "/large"); w.Body.Len() != 100 {
+		t.Errorf("Expected the full body of an uncached response, got %d bytes", w.Body.Len())
+	}
+}
+
+func TestMiddlewareBypass(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	calls := 0
+	m := httpcache.New(c, httpcache.Options{}).WithBypass(func(r *http.Request) bool {
+		return r.URL.Query().Has("fresh")
+	})
+	e := newServer(m, &calls)
+
+	get(e, "/products/1")
+	if w := get(e, "/products/1?fresh"); w.Header().Get(httpcache.StatusHeader) != "" || calls != 2 {
+		t.Errorf("Expected the bypassed request to reach the handler, got %q after %d calls", w.Header().Get(httpcache.StatusHeader), calls)
+	}
+}
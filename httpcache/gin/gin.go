@@ -0,0 +1,81 @@
+// Package gin adapts an httpcache.Middleware to the Gin web framework,
+// whose handlers write through their gin.Context rather than an
+// http.ResponseWriter. It is a module of its own, so that only
+// applications using Gin depend on it:
+//
+//	import httpcachegin "gocache/httpcache/gin"
+//
+//	products := httpcache.New(c, httpcache.Options{TTL: time.Minute})
+//	r.GET("/products/:id", httpcachegin.Middleware(products), getProduct)
+package gin
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+
+	"gocache/httpcache"
+)
+
+// Middleware returns a Gin middleware that serves cacheable requests from
+// m's cache, aborting the chain, and otherwise runs the rest of the chain,
+// caching the response it writes. Responses are cached by the rules of m.
+func Middleware(m *httpcache.Middleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.Cacheable(c.Request) {
+			c.Next()
+			return
+		}
+		if resp, ok := m.Lookup(c.Request); ok {
+			resp.Serve(c.Writer)
+			c.Abort()
+			return
+		}
+
+		c.Header(httpcache.StatusHeader, "MISS")
+		rec := &recorder{ResponseWriter: c.Writer, max: m.MaxBodySize()}
+		c.Writer = rec
+		c.Next()
+		c.Writer = rec.ResponseWriter
+		if c.IsAborted() || rec.overflow {
+			return
+		}
+
+		header := rec.Header().Clone()
+		header.Del(httpcache.StatusHeader)
+		m.Store(c.Request, httpcache.Response{Status: rec.Status(), Header: header, Body: rec.body.Bytes()})
+	}
+}
+
+// recorder passes a response through to the client, recording its body.
+// Gin's writer keeps the status and headers, which are read once the
+// handlers are done.
+type recorder struct {
+	gin.ResponseWriter
+	max int
+
+	body     bytes.Buffer
+	overflow bool // the body exceeded max and was not recorded in full
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.record(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+func (rec *recorder) WriteString(s string) (int, error) {
+	rec.record([]byte(s))
+	return rec.ResponseWriter.WriteString(s)
+}
+
+func (rec *recorder) record(p []byte) {
+	if rec.overflow {
+		return
+	}
+	if rec.body.Len()+len(p) > rec.max {
+		rec.overflow = true
+		rec.body = bytes.Buffer{}
+		return
+	}
+	rec.body.Write(p)
+}
@@ -0,0 +1,91 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gocache"
+	"gocache/httpcache"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newRouter(m *httpcache.Middleware, calls *int) *gin.Engine {
+	r := gin.New()
+	r.GET("/products/:id", Middleware(m), func(c *gin.Context) {
+		*calls++
+		c.Header("Content-Type", "application/json")
+		c.String(http.StatusOK, `{"id":%q}`, c.Param("id"))
+	})
+	r.GET("/missing", Middleware(m), func(c *gin.Context) {
+		*calls++
+		c.AbortWithStatus(http.StatusNotFound)
+	})
+	r.GET("/large", Middleware(m), func(c *gin.Context) {
+		*calls++
+		c.String(http.StatusOK, strings.Repeat("x", 100))
+	})
+	return r
+}
+
+func get(r http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	calls := 0
+	r := newRouter(httpcache.New(c, httpcache.Options{TTL: time.Minute, MaxBodySize: 50}), &calls)
+
+	first := get(r, "/products/1")
+	if first.Code != http.StatusOK || first.Header().Get(httpcache.StatusHeader) != "MISS" {
+		t.Fatalf("Expected a 200 MISS, got %d %q", first.Code, first.Header().Get(httpcache.StatusHeader))
+	}
+	second := get(r, "/products/1")
+	if second.Header().Get(httpcache.StatusHeader) != "HIT" {
+		t.Errorf("Expected a HIT, got %q", second.Header().Get(httpcache.StatusHeader))
+	}
+	if second.Body.String() != `{"id":"1"}` || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the cached body and headers, got %q %v", second.Body.String(), second.Header())
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, got %d", calls)
+	}
+
+	// Errors and bodies above MaxBodySize are not cached.
+	for _, target := range []string{"/missing", "/large"} {
+		calls = 0
+		get(r, target)
+		if w := get(r, target); w.Header().Get(httpcache.StatusHeader) != "MISS" || calls != 2 {
+			t.Errorf("Expected %s not to be cached, got %q after %d calls", target, w.Header().Get(httpcache.StatusHeader), calls)
+		}
+	}
+	if w := get(r, "/large"); w.Body.Len() != 100 {
+		t.Errorf("Expected the full body of an uncached response, got %d bytes", w.Body.Len())
+	}
+}
+
+func TestMiddlewareBypass(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	calls := 0
+	m := httpcache.New(c, httpcache.Options{}).WithBypass(func(r *http.Request) bool {
+		return r.URL.Query().Has("fresh")
+	})
+	r := newRouter(m, &calls)
+
+	get(r, "/products/1")
+	if w := get(r, "/products/1?fresh"); w.Header().Get(httpcache.StatusHeader) != "" || calls != 2 {
+		t.Errorf("Expected the bypassed request to reach the handler, got %q after %d calls", w.Header().Get(httpcache.StatusHeader), calls)
+	}
+}
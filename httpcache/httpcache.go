@@ -0,0 +1,345 @@
+// Package httpcache caches the responses of net/http handlers in a
+// gocache.Cache:
+//
+//	products := httpcache.New(c, httpcache.Options{TTL: time.Minute})
+//	mux.Handle("/products/", products.Handler(productsHandler))
+//
+// Responses to GET requests are cached by method and URL, plus the values of
+// Options.Headers, and served with their original status, headers and body
+// until their TTL runs out. Each middleware has its own options, so routes
+// that need different TTLs wrap their handlers with different middlewares,
+// which may share a cache.
+//
+// Only 200 responses that are safe to share are cached: responses that set
+// cookies, carry Cache-Control no-store or private, or vary on request
+// headers outside Options.Headers are passed through uncached, as are
+// requests with an Authorization header unless it is in Options.Headers.
+//
+// WithTTL and WithBypass derive middlewares for routes that need a
+// different TTL or skip the cache for some requests. The gin and echo
+// sub-packages, which are modules of their own, adapt a Middleware to
+// those frameworks:
+//
+//	r.GET("/products/:id", httpcachegin.Middleware(products.WithTTL(time.Hour)), getProduct)
+//
+// Adapters for other frameworks whose handlers do not write to the
+// http.ResponseWriter passed to them build on Lookup, Response.Serve and
+// Store in the same way: they serve the response found by Lookup and stop,
+// or else run the handler while recording what it writes and pass the
+// result to Store.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocache"
+)
+
+// StatusHeader is set on every response to a cacheable request: "HIT" if
+// it was served from the cache, "MISS" if it was not.
+const StatusHeader = "X-Cache"
+
+// DefaultMaxBodySize is the largest response body cached when
+// Options.MaxBodySize is 0.
+const DefaultMaxBodySize = 1 << 20
+
+// Options configures a Middleware.
+type Options struct {
+	// TTL is how long responses are cached. If 0, the cache's default
+	// expiration is used.
+	TTL time.Duration
+
+	// Headers lists the request headers whose values are part of the cache
+	// key, such as "Accept-Language", so that requests differing in them get
+	// separate responses.
+	Headers []string
+
+	// Bypass, if set, reports whether a request must skip the cache. Its
+	// response is neither served from nor stored in the cache.
+	Bypass func(r *http.Request) bool
+
+	// KeyPrefix is prepended to the cache keys, such as a namespace name
+	// and colon. If empty, "httpcache:" is used.
+	KeyPrefix string
+
+	// MaxBodySize is the largest response body that is cached. If 0,
+	// DefaultMaxBodySize is used.
+	MaxBodySize int
+}
+
+// Response is a cached response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Stored time.Time
+}
+
+func init() {
+	// Let snapshots made with the default codec hold Responses.
+	gob.Register(Response{})
+}
+
+// Middleware caches handler responses in a cache.
+type Middleware struct {
+	cache   *gocache.Cache
+	options Options
+	headers []string // canonical forms of options.Headers
+}
+
+// New returns a middleware caching responses in c with the given options.
+func New(c *gocache.Cache, options Options) *Middleware {
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "httpcache:"
+	}
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = DefaultMaxBodySize
+	}
+	headers := make([]string, len(options.Headers))
+	for i, h := range options.Headers {
+		headers[i] = textproto.CanonicalMIMEHeaderKey(h)
+	}
+	return &Middleware{cache: c, options: options, headers: headers}
+}
+
+// Handler returns a handler that serves cacheable requests from the cache
+// and calls next for the others, caching its responses.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Cacheable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := m.Key(r)
+		if resp, ok := m.lookup(key); ok {
+			resp.Serve(w)
+			return
+		}
+
+		w.Header().Set(StatusHeader, "MISS")
+		rec := &recorder{ResponseWriter: w, max: m.options.MaxBodySize}
+		next.ServeHTTP(rec, r)
+		if rec.header == nil {
+			// The handler wrote nothing, which net/http sends as an
+			// empty 200 response.
+			rec.WriteHeader(http.StatusOK)
+		}
+		if !rec.overflow {
+			m.store(key, Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()})
+		}
+	})
+}
+
+// WithTTL returns a middleware that shares the cache and options of m but
+// caches responses for ttl, for routes that need a different TTL.
+func (m *Middleware) WithTTL(ttl time.Duration) *Middleware {
+	m2 := *m
+	m2.options.TTL = ttl
+	return &m2
+}
+
+// WithBypass returns a middleware that shares the cache and options of m
+// but also bypasses the cache for requests for which bypass returns true.
+func (m *Middleware) WithBypass(bypass func(r *http.Request) bool) *Middleware {
+	m2 := *m
+	if prev := m.options.Bypass; prev != nil {
+		m2.options.Bypass = func(r *http.Request) bool { return prev(r) || bypass(r) }
+	} else {
+		m2.options.Bypass = bypass
+	}
+	return &m2
+}
+
+// Cacheable reports whether the response to r may be served from and
+// stored in the cache.
+func (m *Middleware) Cacheable(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" && !m.varies("Authorization") {
+		return false
+	}
+	return m.options.Bypass == nil || !m.options.Bypass(r)
+}
+
+// Lookup returns the cached response to r, if there is one and r is
+// cacheable. It is exported for adapters to web frameworks whose handlers
+// do not write to an http.ResponseWriter.
+func (m *Middleware) Lookup(r *http.Request) (Response, bool) {
+	if !m.Cacheable(r) {
+		return Response{}, false
+	}
+	return m.lookup(m.Key(r))
+}
+
+// lookup returns the response cached under key.
+func (m *Middleware) lookup(key string) (Response, bool) {
+	value, err := m.cache.Get(key)
+	if err != nil {
+		return Response{}, false
+	}
+	resp, ok := value.(Response)
+	return resp, ok
+}
+
+// Store caches resp as the response to r if r is cacheable and resp is
+// safe to share, and reports whether it did. resp.Stored defaults to now.
+// Like Lookup, it is exported for framework adapters.
+func (m *Middleware) Store(r *http.Request, resp Response) bool {
+	if !m.Cacheable(r) {
+		return false
+	}
+	return m.store(m.Key(r), resp)
+}
+
+// store caches resp under key if it is safe to share.
+func (m *Middleware) store(key string, resp Response) bool {
+	if !m.storable(resp) {
+		return false
+	}
+	if resp.Stored.IsZero() {
+		resp.Stored = time.Now()
+	}
+	var err error
+	if m.options.TTL > 0 {
+		err = m.cache.SetWithExpiration(key, resp, m.options.TTL)
+	} else {
+		err = m.cache.Set(key, resp)
+	}
+	return err == nil
+}
+
+// MaxBodySize returns the largest response body m caches, so that
+// adapters recording responses can stop once a body is too large.
+func (m *Middleware) MaxBodySize() int {
+	return m.options.MaxBodySize
+}
+
+// Key returns the cache key of a request.
+func (m *Middleware) Key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(m.options.KeyPrefix)
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range m.headers {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// Invalidate removes the cached response for a request, such as a GET of
+// a resource that was just changed.
+func (m *Middleware) Invalidate(r *http.Request) {
+	m.cache.Delete(m.Key(r))
+}
+
+// storable reports whether a response may be cached.
+func (m *Middleware) storable(resp Response) bool {
+	if resp.Status != http.StatusOK || len(resp.Body) > m.options.MaxBodySize {
+		return false
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, directive := range splitList(resp.Header.Values("Cache-Control")) {
+		name, _, _ := strings.Cut(directive, "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "no-store", "private":
+			return false
+		}
+	}
+	for _, name := range splitList(resp.Header.Values("Vary")) {
+		if name == "*" || !m.varies(textproto.CanonicalMIMEHeaderKey(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// varies reports whether the canonical header name is part of the key.
+func (m *Middleware) varies(name string) bool {
+	for _, h := range m.headers {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve writes the cached response to w, with StatusHeader set to "HIT"
+// and an Age header.
+func (resp Response) Serve(w http.ResponseWriter) {
+	header := w.Header()
+	for name, values := range resp.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(StatusHeader, "HIT")
+	header.Set("Age", strconv.FormatInt(int64(max(time.Since(resp.Stored), 0)/time.Second), 10))
+	header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// recorder passes a response through to the client, recording it.
+type recorder struct {
+	http.ResponseWriter
+	max int
+
+	status   int
+	header   http.Header // copy of the headers when they were written
+	body     bytes.Buffer
+	overflow bool // the body exceeded max and was not recorded in full
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.header == nil && status >= 200 {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+		rec.header.Del(StatusHeader)
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.header == nil {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > rec.max {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, for
+// example to flush it.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// splitList splits comma-separated header values into trimmed elements.
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				list = append(list, elem)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,200 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// counter is a handler that counts its calls and reports the count in the
+// body, with configurable extra headers.
+type counter struct {
+	calls  int
+	status int
+	header http.Header
+	body   string
+}
+
+func (h *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	for name, values := range h.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	fmt.Fprintf(w, "%s%s call %d", h.body, r.URL.Path, h.calls)
+}
+
+func get(t *testing.T, handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	next := &counter{}
+	handler := New(cache, Options{TTL: time.Minute}).Handler(next)
+
+	rec := get(t, handler, "/a?x=1", nil)
+	if rec.Body.String() != "/a call 1" || rec.Header().Get(StatusHeader) != "MISS" {
+		t.Errorf("Expected a miss for call 1, got %q (%s)", rec.Body, rec.Header().Get(StatusHeader))
+	}
+	rec = get(t, handler, "/a?x=1", nil)
+	if rec.Body.String() != "/a call 1" || rec.Header().Get(StatusHeader) != "HIT" {
+		t.Errorf("Expected a hit serving call 1, got %q (%s)", rec.Body, rec.Header().Get(StatusHeader))
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/plain" || rec.Header().Get("Age") != "0" {
+		t.Errorf("Expected the cached status and headers, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := get(t, handler, "/a?x=2", nil); rec.Body.String() != "/a call 2" {
+		t.Errorf("Expected a different query to miss, got %q", rec.Body)
+	}
+
+	_, expires, err := cache.GetWithExpiration("httpcache:GET /a?x=1")
+	if err != nil || time.Until(expires) > time.Minute || expires.IsZero() {
+		t.Errorf("Expected the response cached for a minute, got %v (%v)", expires, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/a?x=1", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "/a call 3" || rec.Header().Get(StatusHeader) != "" {
+		t.Errorf("Expected POST to pass through, got %q", rec.Body)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	next := &counter{}
+	m := New(cache, Options{Headers: []string{"accept-language"}})
+	handler := m.Handler(next)
+
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	get(t, handler, "/", en)
+	get(t, handler, "/", fr)
+	if rec := get(t, handler, "/", en); rec.Body.String() != "/ call 1" {
+		t.Errorf("Expected the English response, got %q", rec.Body)
+	}
+	if rec := get(t, handler, "/", fr); rec.Body.String() != "/ call 2" {
+		t.Errorf("Expected the French response, got %q", rec.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header = en
+	m.Invalidate(req)
+	if rec := get(t, handler, "/", en); rec.Body.String() != "/ call 3" {
+		t.Errorf("Expected a miss after Invalidate, got %q", rec.Body)
+	}
+}
+
+func TestMiddlewareUncacheable(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		next    *counter
+		options Options
+		header  http.Header
+	}{
+		{"error status", &counter{status: http.StatusInternalServerError}, Options{}, nil},
+		{"cookie", &counter{header: http.Header{"Set-Cookie": {"a=b"}}}, Options{}, nil},
+		{"no-store", &counter{header: http.Header{"Cache-Control": {"max-age=60, no-store"}}}, Options{}, nil},
+		{"private", &counter{header: http.Header{"Cache-Control": {`private="x"`}}}, Options{}, nil},
+		{"vary", &counter{header: http.Header{"Vary": {"Accept-Encoding"}}}, Options{}, nil},
+		{"vary star", &counter{header: http.Header{"Vary": {"*"}}}, Options{}, nil},
+		{"too large", &counter{body: strings.Repeat("x", 100)}, Options{MaxBodySize: 10}, nil},
+		{"authorization", &counter{}, Options{}, http.Header{"Authorization": {"Bearer x"}}},
+		{"bypass", &counter{}, Options{Bypass: func(r *http.Request) bool { return r.URL.Path == "/" }}, nil},
+	} {
+		cache := gocache.New(gocache.Options{})
+		handler := New(cache, tt.options).Handler(tt.next)
+		get(t, handler, "/", tt.header)
+		rec := get(t, handler, "/", tt.header)
+		if tt.next.calls != 2 || rec.Header().Get(StatusHeader) == "HIT" {
+			t.Errorf("%s: expected the response not to be cached, got %d calls", tt.name, tt.next.calls)
+		}
+		if tt.name == "too large" && rec.Body.Len() != 100+len("/ call 2") {
+			t.Errorf("%s: expected the full body to be passed through, got %d bytes", tt.name, rec.Body.Len())
+		}
+		cache.Stop()
+	}
+
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	next := &counter{header: http.Header{"Vary": {"Authorization"}}}
+	handler := New(cache, Options{Headers: []string{"Authorization"}}).Handler(next)
+	auth := http.Header{"Authorization": {"Bearer x"}}
+	get(t, handler, "/", auth)
+	if rec := get(t, handler, "/", auth); next.calls != 1 || rec.Header().Get(StatusHeader) != "HIT" {
+		t.Errorf("Expected responses varying on a keyed Authorization to be cached, got %d calls", next.calls)
+	}
+}
+
+func TestMiddlewareRoutes(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	m := New(cache, Options{TTL: time.Minute, Bypass: func(r *http.Request) bool {
+		return r.URL.Query().Has("nocache")
+	}})
+
+	next := &counter{}
+	long := m.WithTTL(time.Hour).WithBypass(func(r *http.Request) bool {
+		return r.Header.Get("X-Debug") != ""
+	}).Handler(next)
+	get(t, long, "/long", nil)
+	_, expires, _ := cache.GetWithExpiration("httpcache:GET /long")
+	if d := time.Until(expires); d <= time.Minute || d > time.Hour {
+		t.Errorf("Expected the route's TTL of an hour, got %v", d)
+	}
+	get(t, long, "/long", http.Header{"X-Debug": {"1"}})
+	get(t, long, "/long?nocache", nil)
+	if next.calls != 3 {
+		t.Errorf("Expected both bypass predicates to apply, got %d calls", next.calls)
+	}
+	if m.options.TTL != time.Minute || m.options.Bypass(httptest.NewRequest(http.MethodGet, "/", http.NoBody)) {
+		t.Error("Expected WithTTL and WithBypass to leave the original middleware unchanged")
+	}
+}
+
+func TestLookupStore(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	m := New(cache, Options{})
+
+	// An adapter records the response its framework produced and stores it.
+	req := httptest.NewRequest(http.MethodGet, "/item", nil)
+	if _, ok := m.Lookup(req); ok {
+		t.Fatal("Expected no cached response")
+	}
+	resp := Response{Status: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{}`)}
+	if !m.Store(req, resp) {
+		t.Fatal("Expected the response to be stored")
+	}
+	if m.Store(req, Response{Status: http.StatusNotFound}) {
+		t.Error("Expected a 404 response not to be stored")
+	}
+
+	cached, ok := m.Lookup(req)
+	if !ok || cached.Stored.IsZero() {
+		t.Fatalf("Expected the stored response with a time, got %+v", cached)
+	}
+	rec := httptest.NewRecorder()
+	cached.Serve(rec)
+	if rec.Body.String() != "{}" || rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get(StatusHeader) != "HIT" {
+		t.Errorf("Expected the stored response to be served, got %q %v", rec.Body, rec.Header())
+	}
+}
@@ -0,0 +1,330 @@
+// Package httpserver serves a gocache.Cache over HTTP, so that processes
+// not written in Go, such as sidecars, can share the cache.
+package httpserver
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocache"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold the objects and arrays
+	// that PUT stores.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// TTLHeader carries the time to live of an item in seconds, such as "30"
+// or "1.5". A PUT without it stores the item with the cache's default
+// expiration; a GET response has it only if the item expires.
+const TTLHeader = "X-Cache-TTL"
+
+// Limits on request bodies. Larger bodies are rejected with 413, so that a
+// client cannot make the server read without bound.
+const (
+	// MaxValueSize is the largest JSON body a PUT accepts, like the
+	// default item size limit of memcached.
+	MaxValueSize = 1 << 20
+	// MaxSnapshotSize is the largest snapshot RestoreHandler accepts.
+	MaxSnapshotSize = 1 << 30
+)
+
+// maxTTL is the largest TTLHeader, in seconds, that fits a time.Duration.
+const maxTTL = float64(math.MaxInt64 / time.Second)
+
+// New returns a handler serving c with a REST API. Bodies are JSON values,
+// so values read back are JSON types (float64, map[string]interface{} and
+// so on).
+//
+//	GET    /keys/{key}  the value of key, with its version as ETag and
+//	                    TTLHeader if it expires
+//	PUT    /keys/{key}  set key to the JSON body, with TTLHeader if given;
+//	                    If-Match sets it only if its ETag is unchanged, and
+//	                    If-None-Match: * only if it is missing
+//	DELETE /keys/{key}  delete key
+//	GET    /keys        the unexpired keys as a JSON array, sorted;
+//	                    ?prefix= restricts them to a prefix
+//	GET    /stats       the cache's Stats as a JSON object
+//	POST   /flush       remove all items
+//	GET    /dump        a snapshot, as served by DumpHandler
+//	POST   /restore     load the snapshot in the body, as RestoreHandler
+//
+// Conditional PUTs give clients optimistic concurrency, as with
+// Cache.SetIfVersion: a PUT whose condition fails is rejected with 412 and
+// should be retried from the GET. Missing and expired keys are reported
+// with 404. Errors have a JSON body
+// of the form {"error": "..."}. To serve the API under a path prefix, wrap
+// the handler with http.StripPrefix.
+func New(c *gocache.Cache) http.Handler {
+	s := &server{cache: c}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/", s.key)
+	mux.HandleFunc("/keys", s.keys)
+	mux.HandleFunc("/stats", s.stats)
+	mux.HandleFunc("/flush", s.flush)
+	mux.Handle("/dump", DumpHandler(c))
+	mux.Handle("/restore", RestoreHandler(c))
+	return mux
+}
+
+type server struct {
+	cache *gocache.Cache
+}
+
+func (s *server) key(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	if key == "" {
+		writeError(w, http.StatusNotFound, errors.New("missing key"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		value, version, expires, err := s.cache.GetVersionedWithExpiration(key)
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		if version != 0 {
+			w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(version, 10)))
+		}
+		if !expires.IsZero() {
+			ttl := math.Max(time.Until(expires).Seconds(), 0)
+			w.Header().Set(TTLHeader, strconv.FormatFloat(ttl, 'f', -1, 64))
+		}
+		writeJSON(w, http.StatusOK, value)
+
+	case http.MethodPut:
+		var value interface{}
+		body := http.MaxBytesReader(w, r.Body, MaxValueSize)
+		if err := json.NewDecoder(body).Decode(&value); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		version, conditional, ok := precondition(r)
+		if !ok {
+			writeError(w, http.StatusBadRequest, errors.New("invalid If-Match or If-None-Match header"))
+			return
+		}
+		var err error
+		if header := r.Header.Get(TTLHeader); header != "" {
+			ttl, perr := strconv.ParseFloat(header, 64)
+			if perr != nil || !(ttl > 0 && ttl <= maxTTL) {
+				writeError(w, http.StatusBadRequest, errors.New("invalid "+TTLHeader+" header"))
+				return
+			}
+			duration := time.Duration(ttl * float64(time.Second))
+			if conditional {
+				err = s.cache.SetIfVersionWithExpiration(key, value, duration, version)
+			} else {
+				err = s.cache.SetWithExpiration(key, value, duration)
+			}
+		} else if conditional {
+			err = s.cache.SetIfVersion(key, value, version)
+		} else {
+			err = s.cache.Set(key, value)
+		}
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if !s.cache.Delete(key) {
+			writeCacheError(w, gocache.ErrKeyNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// precondition returns the version a PUT is conditional on: the ETag of
+// If-Match, or 0 for If-None-Match: *. It reports whether the PUT is
+// conditional, and false for ok if the headers are invalid.
+func precondition(r *http.Request) (version uint64, conditional, ok bool) {
+	if match := r.Header.Get("If-Match"); match != "" {
+		tag, err := strconv.Unquote(strings.TrimSpace(match))
+		if err != nil {
+			return 0, false, false
+		}
+		version, err = strconv.ParseUint(tag, 10, 64)
+		return version, true, err == nil && version != 0
+	}
+	if none := r.Header.Get("If-None-Match"); none != "" {
+		return 0, true, strings.TrimSpace(none) == "*"
+	}
+	return 0, false, true
+}
+
+func (s *server) keys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	keys := []string{}
+	s.cache.Range(func(e gocache.Entry) bool {
+		if strings.HasPrefix(e.Key, prefix) {
+			keys = append(keys, e.Key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *server) stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.cache.Stats())
+}
+
+func (s *server) flush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	s.cache.Flush()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON error response with the given status.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeBodyError writes an error reading a request body: 413 if the body
+// is too large, and 400 otherwise.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
+
+// writeCacheError writes an error returned by the cache with a matching
+// status.
+func writeCacheError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gocache.ErrKeyNotFound), errors.Is(err, gocache.ErrKeyExpired):
+		status = http.StatusNotFound
+	case errors.Is(err, gocache.ErrInvalidKey), errors.Is(err, gocache.ErrNilValue):
+		status = http.StatusBadRequest
+	case errors.Is(err, gocache.ErrKeyLeased):
+		status = http.StatusConflict
+	case errors.Is(err, gocache.ErrVersionMismatch):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, gocache.ErrValueTooLarge), errors.Is(err, gocache.ErrCostTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	writeError(w, status, err)
+}
+
+// methodNotAllowed rejects a request whose method is not one of allowed.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+}
+
+// DumpHandler returns a handler that streams a snapshot of c to GET
+// requests, in the format read by Cache.LoadSnapshot, so that a remote
+// backup of a large cache can be restored with LoadSnapshot or
+// LoadSnapshotFile.
+//
+// The snapshot is written with Cache.SaveSnapshot, which reads the cache in
+// bounded chunks and holds no lock while writing. The response is sent with
+// chunked transfer encoding and every write blocks until the client has
+// accepted the previous data, so a slow client slows the dump down instead
+// of making the server buffer it. If the client goes away, the dump stops.
+func DumpHandler(c *gocache.Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="gocache.snapshot"`)
+		if err := c.SaveSnapshot(&contextWriter{ctx: r.Context(), w: w}); err != nil {
+			// The status has been sent already; abort the response so the
+			// client sees a truncated body rather than a complete one.
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// RestoreHandler returns a handler that loads the snapshot in the body of
+// POST requests into c with Cache.LoadSnapshot, so that a backup taken from
+// DumpHandler can be restored remotely. Existing items are kept unless the
+// snapshot has the same keys. Snapshots that cannot be read are rejected
+// with 400, and those larger than MaxSnapshotSize with 413, but the items
+// read before the problem was found stay loaded.
+func RestoreHandler(c *gocache.Cache) http.Handler {
+	return restoreHandler(c, MaxSnapshotSize)
+}
+
+// restoreHandler is RestoreHandler with snapshots limited to limit bytes.
+func restoreHandler(c *gocache.Cache, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		var tooLarge *http.MaxBytesError
+		err := c.LoadSnapshot(http.MaxBytesReader(w, r.Body, limit))
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.As(err, &tooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+			errors.Is(err, gocache.ErrInvalidHeader), errors.Is(err, gocache.ErrCorruptSnapshot),
+			errors.Is(err, gocache.ErrUnsupportedVersion), errors.Is(err, gocache.ErrUnknownKey):
+			writeError(w, http.StatusBadRequest, err)
+		default:
+			writeCacheError(w, err)
+		}
+	})
+}
+
+// contextWriter fails writes once ctx is done.
+type contextWriter struct {
+	ctx context.Context
+	w   http.ResponseWriter
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
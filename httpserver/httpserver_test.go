@@ -0,0 +1,301 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gocache"
+)
+
+func TestDumpHandler(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	for i := 0; i < 3000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+	}
+
+	server := httptest.NewServer(DumpHandler(cache))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	restored := gocache.New(gocache.Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(resp.Body); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if n := restored.ItemCount(); n != 3000 {
+		t.Errorf("Expected 3000 restored items, got %d", n)
+	}
+	if value, err := restored.Get("key42"); err != nil || value != "value42" {
+		t.Errorf("Expected value42, got %v (%v)", value, err)
+	}
+}
+
+func TestDumpHandlerMethod(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+
+	rec := httptest.NewRecorder()
+	DumpHandler(cache).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestRestoreHandler(t *testing.T) {
+	source := gocache.New(gocache.Options{})
+	defer source.Stop()
+	source.Set("a", "1")
+	source.Set("b", "2")
+	var snapshot bytes.Buffer
+	if err := source.SaveSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	handler := RestoreHandler(cache)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", &snapshot))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if value, err := cache.Get("b"); err != nil || value != "2" {
+		t.Errorf("Expected 2, got %v (%v)", value, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not a snapshot")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad snapshot, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestServer(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	server := httptest.NewServer(New(cache))
+	defer server.Close()
+
+	do := func(method, path, body string, header http.Header) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := do(http.MethodPut, "/keys/user:1", `{"name":"alice"}`, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected PUT to return 204, got %d", resp.StatusCode)
+	}
+	ttl := http.Header{TTLHeader: {"60"}}
+	if resp := do(http.MethodPut, "/keys/session", `"token"`, ttl); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected PUT with TTL to return 204, got %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodPut, "/keys/bad", `{`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected invalid JSON to return 400, got %d", resp.StatusCode)
+	}
+
+	resp := do(http.MethodGet, "/keys/user:1", "", nil)
+	var user map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil || user["name"] != "alice" {
+		t.Errorf("Expected alice, got %v (%v)", user, err)
+	}
+	if resp.Header.Get(TTLHeader) != "" {
+		t.Errorf("Expected no TTL for an item that never expires, got %q", resp.Header.Get(TTLHeader))
+	}
+	resp = do(http.MethodGet, "/keys/session", "", nil)
+	if seconds, err := strconv.ParseFloat(resp.Header.Get(TTLHeader), 64); err != nil || seconds <= 0 || seconds > 60 {
+		t.Errorf("Expected a TTL of at most 60s, got %q", resp.Header.Get(TTLHeader))
+	}
+
+	resp = do(http.MethodGet, "/keys?prefix=user:", "", nil)
+	var keys []string
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil || len(keys) != 1 || keys[0] != "user:1" {
+		t.Errorf("Expected [user:1], got %v (%v)", keys, err)
+	}
+
+	resp = do(http.MethodGet, "/stats", "", nil)
+	var stats gocache.Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil || stats.Hits != 2 || stats.Items != 2 {
+		t.Errorf("Expected 2 hits and 2 items, got %+v (%v)", stats, err)
+	}
+
+	// JSON objects must survive a snapshot made with the default codec.
+	resp = do(http.MethodGet, "/dump", "", nil)
+	restored := gocache.New(gocache.Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(resp.Body); err != nil {
+		t.Fatalf("LoadSnapshot of /dump failed: %v", err)
+	}
+	if user, _ := restored.Get("user:1"); user.(map[string]interface{})["name"] != "alice" {
+		t.Errorf("Expected alice in the dump, got %v", user)
+	}
+
+	if resp := do(http.MethodDelete, "/keys/user:1", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected DELETE to return 204, got %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodGet, "/keys/user:1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a deleted key to return 404, got %d", resp.StatusCode)
+	}
+	if resp := do(http.MethodPost, "/flush", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected flush to return 204, got %d", resp.StatusCode)
+	}
+	if n := cache.ItemCount(); n != 0 {
+		t.Errorf("Expected an empty cache after flush, got %d items", n)
+	}
+	if resp := do(http.MethodGet, "/flush", "", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET /flush to return 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerConditionalPut(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	handler := New(cache)
+
+	put := func(body string, header http.Header) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/keys/doc", strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	etag := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys/doc", nil))
+		return rec.Header().Get("ETag")
+	}
+
+	create := http.Header{"If-None-Match": {"*"}}
+	if code := put(`1`, create); code != http.StatusNoContent {
+		t.Fatalf("Expected If-None-Match: * of a missing key to return 204, got %d", code)
+	}
+	if code := put(`2`, create); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected If-None-Match: * of an existing key to return 412, got %d", code)
+	}
+
+	tag := etag()
+	if tag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	if code := put(`3`, http.Header{"If-Match": {tag}}); code != http.StatusNoContent {
+		t.Errorf("Expected If-Match with the current ETag to return 204, got %d", code)
+	}
+	if code := put(`4`, http.Header{"If-Match": {tag}}); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected If-Match with a stale ETag to return 412, got %d", code)
+	}
+	if value, _ := cache.Get("doc"); value != float64(3) {
+		t.Errorf("Expected 3, got %v", value)
+	}
+	if etag() == tag {
+		t.Error("Expected the ETag to change with the value")
+	}
+	if code := put(`5`, http.Header{"If-Match": {"W/x"}}); code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid If-Match to return 400, got %d", code)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	handler := New(cache)
+
+	put := func(body string, ttl string) int {
+		req := httptest.NewRequest(http.MethodPut, "/keys/key", strings.NewReader(body))
+		if ttl != "" {
+			req.Header.Set(TTLHeader, ttl)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	large := `"` + strings.Repeat("x", MaxValueSize) + `"`
+	if code := put(large, ""); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a body over MaxValueSize to return 413, got %d", code)
+	}
+	for _, ttl := range []string{"1e300", "9223372037", "NaN", "-1", "0"} {
+		if code := put(`"value"`, ttl); code != http.StatusBadRequest {
+			t.Errorf("Expected a TTL of %s to return 400, got %d", ttl, code)
+		}
+	}
+	if code := put(`"value"`, "9223372036"); code != http.StatusNoContent {
+		t.Errorf("Expected the largest TTL to return 204, got %d", code)
+	}
+	if n := cache.ItemCount(); n != 1 {
+		t.Errorf("Expected only the valid PUT to store an item, got %d items", n)
+	}
+}
+
+func TestRestoreHandlerLimit(t *testing.T) {
+	source := gocache.New(gocache.Options{})
+	defer source.Stop()
+	for i := 0; i < 100; i++ {
+		source.Set(fmt.Sprint("key", i), strings.Repeat("x", 100))
+	}
+	var snapshot bytes.Buffer
+	if err := source.SaveSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	rec := httptest.NewRecorder()
+	restoreHandler(cache, 1000).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", &snapshot))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a snapshot over the limit to return 413, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRestoreHandlerCorrupt(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+
+	// Plain snapshots whose first record claims a key far larger than the
+	// body.
+	bodies := map[string][]byte{
+		"oversized": binary.AppendUvarint([]byte("GOCA\x00\x03\x00\x01"), 1<<42),
+		"truncated": append(binary.AppendUvarint([]byte("GOCA\x00\x03\x00\x01"), 1<<29), "key"...),
+	}
+	for name, body := range bodies {
+		rec := httptest.NewRecorder()
+		restoreHandler(cache, MaxSnapshotSize).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected a corrupt snapshot to return 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package resp is a minimal client side of the Redis protocol (RESP2),
+// shared by the packages that talk to Redis servers so that none of them
+// needs a client library.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply from the server, such as
+// "WRONGPASS invalid username-password pair".
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// DialOptions configures Dial.
+type DialOptions struct {
+	// Username and Password authenticate with AUTH if Password is set.
+	// Username may be empty for servers without ACL users.
+	Username string
+	Password string
+
+	// DB selects the database with SELECT, if not 0.
+	DB int
+
+	// Timeout bounds connecting to the server, if not 0.
+	Timeout time.Duration
+}
+
+// Conn is a connection speaking the Redis protocol. It is not safe for
+// concurrent use.
+type Conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewConn returns a Conn on nc.
+func NewConn(nc net.Conn) *Conn {
+	return &Conn{Conn: nc, r: bufio.NewReader(nc)}
+}
+
+// Dial connects to addr, authenticates and selects the database.
+func Dial(ctx context.Context, addr string, options DialOptions) (*Conn, error) {
+	d := net.Dialer{Timeout: options.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := NewConn(nc)
+	if options.Password != "" {
+		args := []string{"AUTH", options.Password}
+		if options.Username != "" {
+			args = []string{"AUTH", options.Username, options.Password}
+		}
+		if _, err := conn.Do(ctx, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if options.DB != 0 {
+		if _, err := conn.Do(ctx, "SELECT", strconv.Itoa(options.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Do sends a command and reads its reply, within ctx's deadline.
+func (c *Conn) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if err := c.Send(args...); err != nil {
+		return nil, err
+	}
+	return c.Read()
+}
+
+// Send writes a command, or any array of strings, as an array of bulk
+// strings.
+func (c *Conn) Send(args ...string) error {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	_, err := io.WriteString(c.Conn, b.String())
+	return err
+}
+
+// Read reads a reply: a string, an int64, a slice of replies, or nil for a
+// null reply. Error replies are returned as Error.
+func (c *Conn) Read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$', '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if line[0] == '$' {
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(c.r, data); err != nil {
+				return nil, err
+			}
+			return string(data[:n]), nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.Read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,45 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestConnDo(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn, peer := NewConn(client), NewConn(server)
+
+	replies := []string{
+		"+OK\r\n",
+		":42\r\n",
+		"$5\r\nhello\r\n",
+		"$-1\r\n",
+		"*2\r\n$1\r\na\r\n:1\r\n",
+		"-ERR unknown command\r\n",
+	}
+	go func() {
+		defer server.Close()
+		for _, reply := range replies {
+			if _, err := peer.Read(); err != nil {
+				return
+			}
+			server.Write([]byte(reply))
+		}
+	}()
+
+	want := []interface{}{"OK", int64(42), "hello", nil, []interface{}{"a", int64(1)}}
+	for i, w := range want {
+		got, err := conn.Do(context.Background(), "CMD", "arg")
+		if err != nil || !reflect.DeepEqual(got, w) {
+			t.Errorf("Expected reply %d to be %#v, got %#v (%v)", i, w, got, err)
+		}
+	}
+	var respErr Error
+	if _, err := conn.Do(context.Background(), "BAD"); !errors.As(err, &respErr) || string(respErr) != "ERR unknown command" {
+		t.Errorf("Expected an Error reply, got %v", err)
+	}
+}
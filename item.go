@@ -7,13 +7,26 @@ import (
 // Item represents a value stored in the cache along with its expiration time.
 type Item struct {
 	Value      interface{}
-	Expiration int64 // Unix timestamp in nanoseconds
+	Expiration int64  // Unix timestamp in nanoseconds
+	Cost       int64  // Cost counted against Options.MaxCost
+	Priority   int    // Lower priorities are evicted first
+	Modified   int64  // Unix timestamp in nanoseconds of the Set that stored the value
+	Version    uint64 // Increases with every Set in the cache
+}
+
+// Entry is an item together with its key.
+type Entry struct {
+	Key string
+	Item
 }
 
 // Expired returns true if the item has expired.
 func (item *Item) Expired() bool {
-	if item.Expiration == 0 {
-		return false
-	}
-	return time.Now().UnixNano() > item.Expiration
+	return item.expiredAt(time.Now().UnixNano())
+}
+
+// expiredAt reports whether the item has expired at now, a Unix timestamp
+// in nanoseconds.
+func (item *Item) expiredAt(now int64) bool {
+	return item.Expiration > 0 && now > item.Expiration
 }
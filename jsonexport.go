@@ -0,0 +1,110 @@
+package gocache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportChunkSize is the number of items exports read at a time.
+const exportChunkSize = 1024
+
+// jsonEntry is an entry of a JSON export.
+type jsonEntry struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Expires  *time.Time      `json:"expires,omitempty"`
+	Priority int             `json:"priority,omitempty"`
+}
+
+// ExportJSON writes the unexpired items of the cache to w as a JSON array
+// with one object per line, such as
+//
+//	{"key":"user:1","value":{"name":"alice"},"expires":"2024-05-01T12:00:00Z"}
+//
+// so that the contents can be inspected and edited with standard tools and
+// reloaded with ImportJSON. Values are encoded with encoding/json regardless
+// of Options.Codec. Items without an expiration have no "expires" field, and
+// items of priority 0 no "priority" field.
+//
+// Items are read in chunks, as by RangeChunks, so the export is not a
+// point-in-time view of a cache that is being modified.
+func (c *Cache) ExportJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	first := true
+	err := c.RangeChunks(exportChunkSize, func(entries []Entry) error {
+		for _, e := range entries {
+			value, err := JSONCodec.Marshal(e.Value)
+			if err != nil {
+				return fmt.Errorf("exporting %q: %w", e.Key, err)
+			}
+			entry := jsonEntry{Key: e.Key, Value: value, Priority: e.Priority}
+			if e.Expiration > 0 {
+				expires := time.Unix(0, e.Expiration).UTC()
+				entry.Expires = &expires
+			}
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("exporting %q: %w", e.Key, err)
+			}
+
+			if !first {
+				bw.WriteString(",")
+			}
+			first = false
+			bw.WriteString("\n")
+			bw.Write(line)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("\n]\n")
+	return bw.Flush()
+}
+
+// ImportJSON adds the items of a JSON array written by ExportJSON to the
+// cache, keeping their expiration times and priorities. Items that have
+// expired in the meantime are skipped. Values are decoded with
+// encoding/json, so they come back as JSON types (float64,
+// map[string]interface{} and so on). Keys are checked like on Set.
+func (c *Cache) ImportJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("JSON export must be an array, found %v", tok)
+	}
+
+	for dec.More() {
+		var entry jsonEntry
+		if err := dec.Decode(&entry); err != nil {
+			return err
+		}
+		if err := c.validateKey(entry.Key); err != nil {
+			return err
+		}
+		var value interface{}
+		if err := JSONCodec.Unmarshal(entry.Value, &value); err != nil {
+			return fmt.Errorf("importing %q: %w", entry.Key, err)
+		}
+
+		var duration time.Duration
+		if entry.Expires != nil {
+			duration = entry.Expires.Sub(c.clock.Now())
+			if duration <= 0 {
+				continue
+			}
+		}
+		if err := c.set(entry.Key, value, duration, 0, entry.Priority); err != nil {
+			return fmt.Errorf("importing %q: %w", entry.Key, err)
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,68 @@
+package gocache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheJSONRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("name", "alice")
+	cache.SetWithExpiration("count", 42, time.Minute)
+	cache.SetWithExpiration("expired", "gone", time.Second)
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := cache.ExportJSON(&buf); err != nil {
+		t.Fatalf("ExportJSON failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "expired") {
+		t.Errorf("Expected expired items to be skipped, got %s", buf.String())
+	}
+
+	restored := New(Options{Clock: clock})
+	defer restored.Stop()
+	if err := restored.ImportJSON(&buf); err != nil {
+		t.Fatalf("ImportJSON failed: %v", err)
+	}
+	if value, err := restored.Get("name"); err != nil || value != "alice" {
+		t.Errorf("Expected alice, got %v (%v)", value, err)
+	}
+	if value, err := restored.Get("count"); err != nil || value != float64(42) {
+		t.Errorf("Expected 42 as a JSON number, got %v (%v)", value, err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := restored.Get("count"); err != ErrKeyExpired {
+		t.Errorf("Expected the imported item to keep its expiration, got %v", err)
+	}
+}
+
+func TestCacheImportJSONEdited(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	input := `[
+{"key":"config","value":{"enabled":true}},
+{"key":"old","value":1,"expires":"2000-01-01T00:00:00Z"}
+]`
+	if err := cache.ImportJSON(strings.NewReader(input)); err != nil {
+		t.Fatalf("ImportJSON failed: %v", err)
+	}
+	value, err := cache.Get("config")
+	if config, ok := value.(map[string]interface{}); err != nil || !ok || config["enabled"] != true {
+		t.Errorf("Expected the config object, got %v (%v)", value, err)
+	}
+	if _, err := cache.Get("old"); err != ErrKeyNotFound {
+		t.Errorf("Expected the expired entry to be skipped, got %v", err)
+	}
+
+	if err := cache.ImportJSON(strings.NewReader(`{"key":"x"}`)); err == nil {
+		t.Error("Expected an error for input that is not an array")
+	}
+}
@@ -0,0 +1,77 @@
+package gocache
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyEncoder appends the canonical byte form of key to dst and returns the
+// extended slice. Distinct keys must encode differently. The encoding is
+// the key's identity in the cache, so it is also what stores hash.
+type KeyEncoder[K comparable] func(dst []byte, key K) []byte
+
+// Keyed is a view of a Cache with keys of type K, such as a struct of a
+// tenant ID and a resource ID, so that callers do not have to format a
+// string for every lookup. Keys are encoded into a pooled buffer and looked
+// up with GetBytes, so a hit does not allocate.
+type Keyed[K comparable] struct {
+	cache  *Cache
+	encode KeyEncoder[K]
+	bufs   sync.Pool
+}
+
+// NewKeyed returns a view of c with keys of type K, encoded with encode.
+func NewKeyed[K comparable](c *Cache, encode KeyEncoder[K]) *Keyed[K] {
+	return &Keyed[K]{
+		cache:  c,
+		encode: encode,
+		bufs: sync.Pool{New: func() interface{} {
+			b := make([]byte, 0, 64)
+			return &b
+		}},
+	}
+}
+
+// withKey calls fn with the encoding of key, which is only valid until fn
+// returns.
+func (k *Keyed[K]) withKey(key K, fn func(b []byte)) {
+	buf := k.bufs.Get().(*[]byte)
+	*buf = k.encode((*buf)[:0], key)
+	fn(*buf)
+	k.bufs.Put(buf)
+}
+
+// Cache returns the underlying cache.
+func (k *Keyed[K]) Cache() *Cache {
+	return k.cache
+}
+
+// Get returns the value stored for key.
+func (k *Keyed[K]) Get(key K) (value interface{}, err error) {
+	k.withKey(key, func(b []byte) {
+		value, err = k.cache.GetBytes(b)
+	})
+	return value, err
+}
+
+// Set adds an item with the default expiration.
+func (k *Keyed[K]) Set(key K, value interface{}) error {
+	return k.SetWithExpiration(key, value, k.cache.defaultExpiration())
+}
+
+// SetWithExpiration adds an item with the given expiration duration. If
+// duration is 0, the item never expires.
+func (k *Keyed[K]) SetWithExpiration(key K, value interface{}, duration time.Duration) (err error) {
+	k.withKey(key, func(b []byte) {
+		err = k.cache.SetBytes(b, value, duration)
+	})
+	return err
+}
+
+// Delete removes the item stored for key.
+func (k *Keyed[K]) Delete(key K) (deleted bool) {
+	k.withKey(key, func(b []byte) {
+		deleted = k.cache.Delete(string(b))
+	})
+	return deleted
+}
@@ -0,0 +1,51 @@
+package gocache
+
+import (
+	"strconv"
+	"testing"
+)
+
+type tenantResource struct {
+	tenant   uint32
+	resource string
+}
+
+func appendTenantResource(dst []byte, key tenantResource) []byte {
+	dst = strconv.AppendUint(dst, uint64(key.tenant), 10)
+	dst = append(dst, '/')
+	return append(dst, key.resource...)
+}
+
+func TestKeyed(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	keyed := NewKeyed(cache, appendTenantResource)
+
+	key := tenantResource{tenant: 7, resource: "invoice"}
+	if err := keyed.Set(key, "data"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, err := keyed.Get(key); err != nil || value != "data" {
+		t.Errorf("Expected data, got %v (%v)", value, err)
+	}
+	if value, err := cache.Get("7/invoice"); err != nil || value != "data" {
+		t.Errorf("Expected the encoded key in the cache, got %v (%v)", value, err)
+	}
+	if _, err := keyed.Get(tenantResource{tenant: 8, resource: "invoice"}); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for another tenant, got %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		keyed.Get(key)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected Get hit not to allocate, got %v allocations", allocs)
+	}
+
+	if !keyed.Delete(key) {
+		t.Error("Expected Delete to remove the key")
+	}
+	if _, err := keyed.Get(key); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound after Delete, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package gocache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keyLocks are the locks taken with LockKey.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock of one key, held while its channel has a value. It
+// is removed from keyLocks once no caller holds or waits for it.
+type keyLock struct {
+	held chan struct{}
+	refs int
+}
+
+// LockKey locks key for the caller, waiting until no other caller holds
+// the lock or ctx is done, in which case it returns ctx.Err(). Use a
+// context with a timeout to bound the wait. Call unlock to release the
+// lock; calling it again has no effect.
+//
+// The lock serializes read-modify-write flows that span several
+// operations, such as a Get, a call to another service and a Set, among
+// callers that take it. It is advisory: operations on the key that do not
+// take the lock are not blocked, and the key does not need to exist in
+// the cache. To keep other writers out too, use Lease.
+func (c *Cache) LockKey(ctx context.Context, key string) (unlock func(), err error) {
+	if err := c.validateKey(key); err != nil {
+		return nil, err
+	}
+	return c.keyLocks.lock(ctx, key)
+}
+
+// UpdateInPlace replaces the value of key with the result of fn, which is
+// called with the current value and whether the key holds an unexpired
+// item, and stores it for the returned duration, or without expiration if
+// it is 0. If fn returns an error, nothing is stored and UpdateInPlace
+// returns it. A replaced item keeps its priority.
+//
+// fn runs holding the lock of key that LockKey takes, so concurrent calls
+// of UpdateInPlace and holders of LockKey on the key are serialized, and
+// read-modify-write of cached aggregates such as slices, maps and counters
+// needs no other synchronization. The lock does not block other writers:
+// if one changes the key while fn runs, fn is called again with the new
+// value. Other goroutines may Get the value while fn runs, so fn should
+// return a modified copy rather than change old, unless Options.CopyOnGet
+// is set.
+//
+// The value is written through to Options.BackingStore, like Set.
+// UpdateInPlace returns ErrKeyLeased if another caller holds a lease on
+// the key.
+func (c *Cache) UpdateInPlace(key string, fn func(old interface{}, exists bool) (new interface{}, ttl time.Duration, err error)) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	unlock, err := c.keyLocks.lock(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for {
+		c.applyQueued(key)
+		now := c.clock.Now().UnixNano()
+
+		c.mu.RLock()
+		old, found := c.items.get(key)
+		c.mu.RUnlock()
+		if found && old.expiredAt(now) {
+			found = false
+		}
+
+		var value interface{}
+		if found {
+			if value, err = c.expand(old.Value); err != nil {
+				return err
+			}
+		}
+		value, ttl, err := fn(value, found)
+		if err != nil {
+			return err
+		}
+
+		var expiration int64
+		if ttl > 0 {
+			expiration = c.clock.Now().Add(ttl).UnixNano()
+		}
+		priority := 0
+		if found {
+			priority = old.Priority
+		}
+		// Store the value only if the item is still the one read above;
+		// otherwise another writer got in between and fn is retried.
+		stored, err := c.setIf(key, value, expiration, 0, priority, func(cur Item, curFound bool) bool {
+			if !found {
+				return !curFound
+			}
+			return curFound && cur.Version == old.Version
+		})
+		if err != nil {
+			return err
+		}
+		if stored {
+			return c.storeThrough(context.Background(), key, value, 0)
+		}
+	}
+}
+
+// lock waits for and takes the lock of key, and returns the function that
+// releases it.
+func (g *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	if g.locks == nil {
+		g.locks = make(map[string]*keyLock)
+	}
+	l, ok := g.locks[key]
+	if !ok {
+		l = &keyLock{held: make(chan struct{}, 1)}
+		g.locks[key] = l
+	}
+	l.refs++
+	g.mu.Unlock()
+
+	select {
+	case l.held <- struct{}{}:
+	case <-ctx.Done():
+		g.release(key, l)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.held
+			g.release(key, l)
+		})
+	}, nil
+}
+
+// release drops a reference to the lock of key taken by LockKey.
+func (g *keyLocks) release(key string, l *keyLock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(g.locks, key)
+	}
+}
@@ -0,0 +1,141 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheLockKey(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	unlock, err := cache.LockKey(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cache.LockKey(ctx, "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected locking a held key to time out, got %v", err)
+	}
+	other, err := cache.LockKey(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("Expected another key to be lockable, got %v", err)
+	}
+	other()
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := cache.LockKey(context.Background(), "key")
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Expected LockKey to wait for the holder")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	unlock() // no effect
+	(<-locked)()
+
+	if n := len(cache.keyLocks.locks); n != 0 {
+		t.Errorf("Expected released locks to be removed, got %d", n)
+	}
+}
+
+func TestCacheLockKeySerializes(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("counter", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				unlock, err := cache.LockKey(context.Background(), "counter")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				value, _ := cache.Get("counter")
+				cache.Set("counter", value.(int)+1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("counter"); value != 800 {
+		t.Errorf("Expected 800 increments, got %v", value)
+	}
+}
+
+func TestCacheUpdateInPlace(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	appendItem := func(item string) func(interface{}, bool) (interface{}, time.Duration, error) {
+		return func(old interface{}, exists bool) (interface{}, time.Duration, error) {
+			var list []string
+			if exists {
+				list = old.([]string)
+			}
+			return append(append([]string(nil), list...), item), time.Minute, nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				if err := cache.UpdateInPlace("list", appendItem("x")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, expires, err := cache.GetWithExpiration("list")
+	if err != nil || len(value.([]string)) != 400 {
+		t.Fatalf("Expected 400 appends, got %v (%v)", value, err)
+	}
+	if !expires.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Expected the item to expire in a minute, got %v", expires)
+	}
+
+	failed := errors.New("failed")
+	err = cache.UpdateInPlace("list", func(interface{}, bool) (interface{}, time.Duration, error) {
+		return nil, 0, failed
+	})
+	if err != failed {
+		t.Errorf("Expected the error of fn, got %v", err)
+	}
+	if value, _ := cache.Get("list"); len(value.([]string)) != 400 {
+		t.Error("Expected a failed update to leave the value unchanged")
+	}
+
+	clock.Advance(time.Hour)
+	cache.UpdateInPlace("list", func(old interface{}, exists bool) (interface{}, time.Duration, error) {
+		if exists {
+			t.Error("Expected an expired item not to exist")
+		}
+		return []string{"fresh"}, 0, nil
+	})
+	if _, expires, _ := cache.GetWithExpiration("list"); !expires.IsZero() {
+		t.Errorf("Expected no expiration for a ttl of 0, got %v", expires)
+	}
+}
@@ -0,0 +1,49 @@
+package gocache
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// keyRules holds the key constraints from Options.
+type keyRules struct {
+	maxLength int
+	charset   map[rune]bool
+	validate  func(string) error
+}
+
+func newKeyRules(options Options) keyRules {
+	rules := keyRules{
+		maxLength: options.MaxKeyLength,
+		validate:  options.ValidateKey,
+	}
+	if options.KeyCharset != "" {
+		rules.charset = make(map[rune]bool)
+		for _, r := range options.KeyCharset {
+			rules.charset[r] = true
+		}
+	}
+	return rules
+}
+
+// validateKey checks key against MaxKeyLength, KeyCharset and ValidateKey,
+// returning an error wrapping ErrInvalidKey if it violates any of them.
+func (c *Cache) validateKey(key string) error {
+	rules := &c.keyRules
+	if rules.maxLength > 0 && len(key) > rules.maxLength {
+		return fmt.Errorf("%w: length %d exceeds %d", ErrInvalidKey, len(key), rules.maxLength)
+	}
+	if rules.charset != nil {
+		for i, r := range key {
+			if r == utf8.RuneError || !rules.charset[r] {
+				return fmt.Errorf("%w: character %q at offset %d is not allowed", ErrInvalidKey, r, i)
+			}
+		}
+	}
+	if rules.validate != nil {
+		if err := rules.validate(key); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidKey, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package gocache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCacheKeyValidation(t *testing.T) {
+	errReserved := errors.New("reserved prefix")
+	cache := New(Options{
+		MaxKeyLength: 16,
+		KeyCharset:   "abcdefghijklmnopqrstuvwxyz0123456789-_",
+		ValidateKey: func(key string) error {
+			if strings.HasPrefix(key, "sys-") {
+				return errReserved
+			}
+			return nil
+		},
+	})
+	defer cache.Stop()
+
+	if err := cache.Set("user-42", 1); err != nil {
+		t.Errorf("Expected valid key to be accepted, got %v", err)
+	}
+
+	invalid := []string{"this-key-is-far-too-long", "User-42", "user 42", "sys-config", "caf\xe9"}
+	for _, key := range invalid {
+		if err := cache.Set(key, 1); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey for %q, got %v", key, err)
+		}
+	}
+	if err := cache.Set("sys-config", 1); !errors.Is(err, errReserved) {
+		t.Errorf("Expected ValidateKey error to be wrapped, got %v", err)
+	}
+	if count := cache.ItemCount(); count != 1 {
+		t.Errorf("Expected invalid keys not to be stored, got %d items", count)
+	}
+
+	// Namespace keys are validated without their prefix.
+	if err := cache.Namespace("users", NamespaceOptions{}).Set("u1", 1); err != nil {
+		t.Errorf("Expected namespace key to be accepted, got %v", err)
+	}
+}
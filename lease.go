@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseToken identifies a lease held on a key. The zero value is never
+// issued and means "no lease".
+type LeaseToken uint64
+
+// lease records the holder and expiration of a lease on a key.
+type lease struct {
+	token      LeaseToken
+	expiration int64 // Unix timestamp in nanoseconds
+}
+
+// Lease claims exclusive ownership of key for the given duration. Until the
+// lease expires or is released, Set, SetWithExpiration and Delete on the key
+// fail, and only SetWithLease and DeleteWithLease with the returned token
+// may modify it. The key does not need to exist in the cache.
+//
+// Returns ErrKeyLeased if another caller already holds a lease on the key.
+func (c *Cache) Lease(key string, ttl time.Duration) (LeaseToken, error) {
+	if ttl <= 0 {
+		return 0, ErrInvalidTTL
+	}
+
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if l, found := c.leases[key]; found && now.UnixNano() <= l.expiration {
+		return 0, ErrKeyLeased
+	}
+
+	c.leaseSeq++
+	token := LeaseToken(c.leaseSeq)
+	c.leases[key] = lease{
+		token:      token,
+		expiration: now.Add(ttl).UnixNano(),
+	}
+
+	return token, nil
+}
+
+// ReleaseLease gives up a lease before it expires.
+// Returns ErrInvalidLease if token does not match the active lease on key.
+func (c *Cache) ReleaseLease(key string, token LeaseToken) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if l, found := c.leases[key]; !found || l.token != token || c.clock.Now().UnixNano() > l.expiration {
+		return ErrInvalidLease
+	}
+	delete(c.leases, key)
+	return nil
+}
+
+// SetWithLease is like SetWithExpiration but for a key leased with Lease.
+// Returns ErrInvalidLease if token does not match the active lease on key.
+func (c *Cache) SetWithLease(key string, value interface{}, duration time.Duration, token LeaseToken) error {
+	if token == 0 {
+		return ErrInvalidLease
+	}
+	return c.setThrough(context.Background(), key, value, duration, token, 0)
+}
+
+// DeleteWithLease is like Delete but for a key leased with Lease.
+// Returns ErrInvalidLease if token does not match the active lease on key.
+func (c *Cache) DeleteWithLease(key string, token LeaseToken) (bool, error) {
+	if token == 0 {
+		return false, ErrInvalidLease
+	}
+	deleted, err := c.delete(key, token)
+	if err != nil {
+		return false, err
+	}
+	return deleted, c.deleteThrough(context.Background(), key)
+}
+
+// checkLease verifies that token may modify key. A zero token is allowed
+// only if the key has no active lease. It must be called with c.mu held.
+func (c *Cache) checkLease(key string, token LeaseToken, now int64) error {
+	l, found := c.leases[key]
+	if found && now > l.expiration {
+		delete(c.leases, key)
+		found = false
+	}
+
+	switch {
+	case found && l.token == token:
+		return nil
+	case found && token == 0:
+		return ErrKeyLeased
+	case token != 0:
+		return ErrInvalidLease
+	}
+	return nil
+}
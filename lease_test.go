@@ -0,0 +1,64 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheLease(t *testing.T) {
+	cache := New(Options{DefaultExpiration: time.Minute})
+
+	cache.Set("job", "pending")
+
+	token, err := cache.Lease("job", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to lease key: %v", err)
+	}
+
+	if _, err := cache.Lease("job", time.Minute); err != ErrKeyLeased {
+		t.Errorf("Expected ErrKeyLeased for second lease, got %v", err)
+	}
+	if err := cache.Set("job", "stolen"); err != ErrKeyLeased {
+		t.Errorf("Expected ErrKeyLeased for Set, got %v", err)
+	}
+	if cache.Delete("job") {
+		t.Error("Delete of leased key returned true, expected false")
+	}
+	if err := cache.SetWithLease("job", "stolen", 0, token+1); err != ErrInvalidLease {
+		t.Errorf("Expected ErrInvalidLease for wrong token, got %v", err)
+	}
+
+	if err := cache.SetWithLease("job", "done", 0, token); err != nil {
+		t.Errorf("Failed to set with lease: %v", err)
+	}
+	value, _ := cache.Get("job")
+	if value != "done" {
+		t.Errorf("Expected 'done', got '%v'", value)
+	}
+
+	if err := cache.ReleaseLease("job", token); err != nil {
+		t.Errorf("Failed to release lease: %v", err)
+	}
+	if err := cache.Set("job", "next"); err != nil {
+		t.Errorf("Failed to set after release: %v", err)
+	}
+}
+
+func TestCacheLeaseExpiration(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{DefaultExpiration: time.Minute, Clock: clock})
+
+	token, err := cache.Lease("job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to lease key: %v", err)
+	}
+
+	clock.Advance(100 * time.Millisecond)
+
+	if err := cache.Set("job", "value"); err != nil {
+		t.Errorf("Failed to set after lease expired: %v", err)
+	}
+	if _, err := cache.DeleteWithLease("job", token); err != ErrInvalidLease {
+		t.Errorf("Expected ErrInvalidLease for expired token, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ShutdownPhase orders the steps Close takes to shut the cache down.
+// Phases run in ascending order, and hooks within a phase run in the order
+// they were registered.
+type ShutdownPhase int
+
+const (
+	// PhaseStopIntake stops accepting new work: network servers, refresh
+	// schedulers and replication listeners.
+	PhaseStopIntake ShutdownPhase = iota
+	// PhaseDrain flushes queued work such as write-behind and async writes.
+	PhaseDrain
+	// PhaseSnapshot writes final snapshots once no more changes can arrive.
+	PhaseSnapshot
+	// PhaseClose releases resources: background goroutines, connections
+	// and notification channels. It runs even if ctx has expired.
+	PhaseClose
+)
+
+// String returns the name of the phase.
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhaseStopIntake:
+		return "stop-intake"
+	case PhaseDrain:
+		return "drain"
+	case PhaseSnapshot:
+		return "snapshot"
+	case PhaseClose:
+		return "close"
+	default:
+		return "unknown"
+	}
+}
+
+// shutdownHook is a step registered with OnShutdown.
+type shutdownHook struct {
+	phase ShutdownPhase
+	name  string
+	fn    func(ctx context.Context) error
+}
+
+// lifecycle tracks shutdown hooks and runs them once.
+type lifecycle struct {
+	mu    sync.Mutex
+	hooks []shutdownHook
+	once  sync.Once
+	err   error
+}
+
+// OnShutdown registers fn to run during Close in the given phase. Subsystems
+// built on the cache (servers, persistence, replication) use it so that the
+// whole composition shuts down in a well-defined order. Hooks registered
+// after Close has started are not run.
+func (c *Cache) OnShutdown(phase ShutdownPhase, name string, fn func(ctx context.Context) error) {
+	c.lifecycle.mu.Lock()
+	defer c.lifecycle.mu.Unlock()
+
+	c.lifecycle.hooks = append(c.lifecycle.hooks, shutdownHook{phase: phase, name: name, fn: fn})
+}
+
+// Close shuts the cache and every subsystem registered with OnShutdown down
+// in order: stop intake, drain queues, write final snapshots, then release
+// resources. The cache's own queues are drained in PhaseDrain, and
+// Options.SnapshotFile, if set, is written in PhaseSnapshot. Each hook
+// receives ctx and should give up when it is done; once ctx has expired,
+// the remaining hooks are skipped except those of PhaseClose. Close returns the errors of all failed or skipped hooks, and
+// subsequent calls return the same result.
+func (c *Cache) Close(ctx context.Context) error {
+	c.lifecycle.once.Do(func() {
+		c.lifecycle.err = c.shutdown(ctx)
+	})
+	return c.lifecycle.err
+}
+
+// Closer returns an io.Closer whose Close calls Close with a background
+// context, for code that manages resources as io.Closers. The cache cannot
+// implement io.Closer itself, because its Close takes a context.
+func (c *Cache) Closer() io.Closer {
+	return closer{c}
+}
+
+// closer adapts a Cache to io.Closer.
+type closer struct {
+	c *Cache
+}
+
+func (c closer) Close() error {
+	return c.c.Close(context.Background())
+}
+
+// shutdown runs the registered hooks phase by phase.
+func (c *Cache) shutdown(ctx context.Context) error {
+	c.lifecycle.mu.Lock()
+	hooks := append([]shutdownHook(nil), c.lifecycle.hooks...)
+	c.lifecycle.mu.Unlock()
+
+	hooks = append(hooks,
+		shutdownHook{phase: PhaseStopIntake, name: "refresh-ahead", fn: c.stopRefresh},
+		shutdownHook{phase: PhaseDrain, name: "async-writes", fn: c.stopAsync},
+		shutdownHook{phase: PhaseDrain, name: "write-behind", fn: c.drainWriteBehind},
+		shutdownHook{phase: PhaseSnapshot, name: "snapshot-file", fn: func(context.Context) error {
+			if c.snapshotFile == "" {
+				return nil
+			}
+			return c.SaveSnapshotFile(c.snapshotFile)
+		}},
+		shutdownHook{phase: PhaseClose, name: "janitor", fn: func(context.Context) error {
+			c.stopJanitor()
+			return nil
+		}},
+		shutdownHook{phase: PhaseClose, name: "memory-watcher", fn: func(context.Context) error {
+			c.stopMemoryWatcher()
+			return nil
+		}},
+		shutdownHook{phase: PhaseClose, name: "auto-tune", fn: func(context.Context) error {
+			c.stopAutoTune()
+			return nil
+		}},
+		shutdownHook{phase: PhaseClose, name: "trace", fn: func(context.Context) error {
+			return c.stopTrace()
+		}},
+		shutdownHook{phase: PhaseClose, name: "notifications", fn: func(context.Context) error {
+			c.closeSubscribers()
+			return nil
+		}},
+	)
+
+	var errs []error
+	for phase := PhaseStopIntake; phase <= PhaseClose; phase++ {
+		for _, h := range hooks {
+			if h.phase != phase {
+				continue
+			}
+			if err := ctx.Err(); err != nil && phase != PhaseClose {
+				errs = append(errs, fmt.Errorf("%s %s: skipped: %w", phase, h.name, err))
+				continue
+			}
+			if err := h.fn(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", phase, h.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheCloseOrder(t *testing.T) {
+	cache := New(Options{CleanupInterval: time.Minute})
+	notifications := cache.Notifications(1)
+
+	var order []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	cache.OnShutdown(PhaseClose, "connections", record("connections"))
+	cache.OnShutdown(PhaseSnapshot, "snapshot", record("snapshot"))
+	cache.OnShutdown(PhaseDrain, "write-behind", record("write-behind"))
+	cache.OnShutdown(PhaseStopIntake, "server", record("server"))
+	cache.OnShutdown(PhaseDrain, "async-set", record("async-set"))
+
+	if err := cache.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := "server,write-behind,async-set,snapshot,connections"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("Expected order %s, got %s", want, got)
+	}
+	if _, ok := <-notifications; ok {
+		t.Error("Expected notification channel to be closed")
+	}
+
+	// Close and Stop are idempotent
+	if err := cache.Close(context.Background()); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+	cache.Stop()
+	if len(order) != 5 {
+		t.Errorf("Expected hooks to run once, got %v", order)
+	}
+}
+
+func TestCacheCloseDeadline(t *testing.T) {
+	cache := New(Options{})
+
+	var closed bool
+	cache.OnShutdown(PhaseDrain, "slow-drain", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	cache.OnShutdown(PhaseSnapshot, "snapshot", func(context.Context) error {
+		t.Error("Snapshot should be skipped after the deadline")
+		return nil
+	})
+	cache.OnShutdown(PhaseClose, "connections", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := cache.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error, got %v", err)
+	}
+	if !closed {
+		t.Error("Expected close phase to run after the deadline")
+	}
+}
+
+func TestCacheCloseSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	cache := New(Options{CleanupInterval: time.Minute, SnapshotFile: path})
+	cache.Set("key", "value")
+	if err := cache.SetAsync("queued", "value"); err != nil {
+		t.Fatalf("SetAsync failed: %v", err)
+	}
+
+	var closer io.Closer = cache.Closer()
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	restored := New(Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("LoadSnapshotFile failed: %v", err)
+	}
+	for _, key := range []string{"key", "queued"} {
+		if _, err := restored.Get(key); err != nil {
+			t.Errorf("Expected %s in the final snapshot, got %v", key, err)
+		}
+	}
+}
+
+func TestCacheStopConcurrent(t *testing.T) {
+	cache := New(Options{CleanupInterval: time.Minute, MaxEntries: 10, AutoTune: AutoTuneOptions{Enabled: true}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Stop()
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Concurrent Stop calls did not return")
+	}
+	if err := cache.Close(context.Background()); err != nil {
+		t.Errorf("Close after Stop failed: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold lists.
+	gob.Register([]interface{}{})
+}
+
+// LPush inserts values at the head of the list stored under key, one
+// after the other, so that the last of them ends up first, and returns
+// the length of the list.
+//
+// Lists are stored as []interface{} values, head first, so Get returns a
+// list as a slice. A list is created by the first push to a missing or
+// expired key, with the default expiration, and keeps its expiration and
+// priority when changed, like a value changed by Increment. Every change
+// replaces the stored slice with a new one, so slices returned earlier do
+// not change; lists suit small queues and recent-items feeds rather than
+// long sequences. Operations on a key holding another type of value
+// return ErrWrongType. A list emptied by pops stays in the cache until it
+// expires or is deleted.
+func (c *Cache) LPush(key string, values ...interface{}) (int, error) {
+	return c.changeList(key, func(list []interface{}) ([]interface{}, error) {
+		pushed := make([]interface{}, 0, len(values)+len(list))
+		for i := len(values) - 1; i >= 0; i-- {
+			pushed = append(pushed, values[i])
+		}
+		return append(pushed, list...), nil
+	})
+}
+
+// RPush appends values to the tail of the list stored under key and
+// returns the length of the list.
+func (c *Cache) RPush(key string, values ...interface{}) (int, error) {
+	return c.changeList(key, func(list []interface{}) ([]interface{}, error) {
+		pushed := make([]interface{}, 0, len(list)+len(values))
+		pushed = append(pushed, list...)
+		return append(pushed, values...), nil
+	})
+}
+
+// LPop removes and returns the head of the list stored under key. It
+// returns ErrKeyNotFound if the list is missing or empty.
+func (c *Cache) LPop(key string) (interface{}, error) {
+	var head interface{}
+	_, err := c.changeList(key, func(list []interface{}) ([]interface{}, error) {
+		if len(list) == 0 {
+			return nil, ErrKeyNotFound
+		}
+		head = list[0]
+		return append([]interface{}(nil), list[1:]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// RPop removes and returns the tail of the list stored under key. It
+// returns ErrKeyNotFound if the list is missing or empty.
+func (c *Cache) RPop(key string) (interface{}, error) {
+	var tail interface{}
+	_, err := c.changeList(key, func(list []interface{}) ([]interface{}, error) {
+		if len(list) == 0 {
+			return nil, ErrKeyNotFound
+		}
+		tail = list[len(list)-1]
+		return append([]interface{}(nil), list[:len(list)-1]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// LRange returns the elements of the list stored under key from index
+// start to index stop, both included. Negative indexes count from the
+// tail, so that -1 is the last element; LRange(key, 0, -1) returns the
+// whole list. Indexes out of range are clamped, and a missing list is
+// empty.
+func (c *Cache) LRange(key string, start, stop int) ([]interface{}, error) {
+	list, err := c.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	n := len(list)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []interface{}{}, nil
+	}
+	return append([]interface{}(nil), list[start:stop+1]...), nil
+}
+
+// LLen returns the length of the list stored under key, or 0 if it is
+// missing.
+func (c *Cache) LLen(key string) (int, error) {
+	list, err := c.getList(key)
+	return len(list), err
+}
+
+// getList returns the list stored under key, or nil if it is missing.
+func (c *Cache) getList(key string) ([]interface{}, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds %T, not a list", ErrWrongType, key, value)
+	}
+	return list, nil
+}
+
+// changeList atomically replaces the list stored under key, or an empty
+// list if it is missing, with the result of fn, and returns its length.
+func (c *Cache) changeList(key string, fn func(list []interface{}) ([]interface{}, error)) (int, error) {
+	if err := c.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	var n int
+	_, err := c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		var list []interface{}
+		if found {
+			var ok bool
+			if list, ok = value.([]interface{}); !ok {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a list", ErrWrongType, key, value)
+			}
+		}
+		list, err := fn(list)
+		if err != nil {
+			return nil, false, err
+		}
+		n = len(list)
+		return list, true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
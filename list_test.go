@@ -0,0 +1,123 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheList(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, DefaultExpiration: time.Minute})
+	defer cache.Stop()
+
+	if n, err := cache.RPush("list", "b", "c"); err != nil || n != 2 {
+		t.Errorf("Expected length 2, got %d (%v)", n, err)
+	}
+	if n, err := cache.LPush("list", "a", "z"); err != nil || n != 4 {
+		t.Errorf("Expected length 4, got %d (%v)", n, err)
+	}
+	before, _ := cache.LRange("list", 0, -1)
+	if want := []interface{}{"z", "a", "b", "c"}; !reflect.DeepEqual(before, want) {
+		t.Errorf("Expected %v, got %v", want, before)
+	}
+
+	for _, tt := range []struct {
+		start, stop int
+		want        []interface{}
+	}{
+		{1, 2, []interface{}{"a", "b"}},
+		{-2, -1, []interface{}{"b", "c"}},
+		{-10, 10, []interface{}{"z", "a", "b", "c"}},
+		{3, 1, []interface{}{}},
+		{5, 8, []interface{}{}},
+	} {
+		if got, err := cache.LRange("list", tt.start, tt.stop); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LRange(%d, %d): expected %v, got %v (%v)", tt.start, tt.stop, tt.want, got, err)
+		}
+	}
+
+	if head, err := cache.LPop("list"); err != nil || head != "z" {
+		t.Errorf("Expected z, got %v (%v)", head, err)
+	}
+	if tail, err := cache.RPop("list"); err != nil || tail != "c" {
+		t.Errorf("Expected c, got %v (%v)", tail, err)
+	}
+	if n, _ := cache.LLen("list"); n != 2 {
+		t.Errorf("Expected length 2, got %d", n)
+	}
+	if len(before) != 4 {
+		t.Error("Expected an earlier LRange to be unchanged")
+	}
+	cache.LPop("list")
+	cache.LPop("list")
+	if _, err := cache.LPop("list"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for an empty list, got %v", err)
+	}
+
+	if n, err := cache.LLen("missing"); err != nil || n != 0 {
+		t.Errorf("Expected a missing list to be empty, got %d (%v)", n, err)
+	}
+	cache.Set("text", "x")
+	if _, err := cache.RPush("text", 1); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.LRange("text", 0, -1); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n, _ := cache.LLen("list"); n != 0 {
+		t.Errorf("Expected the list to expire, got length %d", n)
+	}
+}
+
+func TestCacheListConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				cache.RPush("queue", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	popped := 0
+	for {
+		if _, err := cache.LPop("queue"); err != nil {
+			break
+		}
+		popped++
+	}
+	if popped != 800 {
+		t.Errorf("Expected 800 elements, got %d", popped)
+	}
+}
+
+func TestCacheListSnapshot(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.RPush("list", "a", "b")
+
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restored.LRange("list", 0, -1); !reflect.DeepEqual(got, []interface{}{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Loader loads the value of a key missing from the cache, returning how
+// long to cache it, or 0 for the default expiration. It returns an error
+// matching ErrKeyNotFound if the key does not exist. See Options.Loader.
+type Loader func(ctx context.Context, key string) (interface{}, time.Duration, error)
+
+// loaded is the result of loading a key.
+type loaded struct {
+	value interface{}
+	ttl   time.Duration
+}
+
+// loadGroup shares the loads of a key between concurrent misses.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*memoCall[loaded]
+}
+
+// GetContext is like Get, but passes ctx to Options.Loader or
+// Options.BackingStore if the key has to be loaded.
+func (c *Cache) GetContext(ctx context.Context, key string) (interface{}, error) {
+	item, err := c.getItemContext(ctx, key)
+	return item.Value, err
+}
+
+// loadMissing loads a key missing from the cache with Options.Loader, or
+// else from Options.BackingStore, and caches it unless the key was set in
+// the meantime. Concurrent misses of a key share a single load, made with
+// the context of the first. If the load panics, the panic propagates to
+// the caller that made it, and the others get an error.
+func (c *Cache) loadMissing(ctx context.Context, key string) (Item, error) {
+	g := &c.loads
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return c.loadedItem(call.value, call.err)
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*memoCall[loaded])
+	}
+	call := &memoCall[loaded]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("loader panicked: %v", r)
+			// Re-panic once the waiters have been released.
+			defer panic(r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = c.loadSource(ctx, key)
+	if call.err != nil && !errors.Is(call.err, ErrKeyNotFound) {
+		c.log(slog.LevelWarn, "load failed", "key", key, "error", call.err)
+	}
+	if call.err != nil {
+		c.cacheNotFound(key, call.err)
+		return Item{}, call.err
+	}
+	// Do not overwrite a value set while loading, which is newer. A value
+	// the cache refuses, for example because it is too large, is still
+	// returned.
+	c.storeLoaded(key, call.value, func(_ Item, found bool) bool { return !found })
+	return c.loadedItem(call.value, nil)
+}
+
+// loadSource loads key with Options.Loader, or else from Options.BackingStore.
+func (c *Cache) loadSource(ctx context.Context, key string) (loaded, error) {
+	var l loaded
+	var err error
+	if c.loader != nil {
+		l.value, l.ttl, err = c.loader(ctx, key)
+	} else {
+		l.value, err = c.backing.load(ctx, key)
+	}
+	if err == nil && l.ttl <= 0 {
+		l.ttl = c.defaultExpiration()
+	}
+	return l, err
+}
+
+// storeLoaded caches a loaded value if cond allows it.
+func (c *Cache) storeLoaded(key string, l loaded, cond func(old Item, found bool) bool) (bool, error) {
+	var expiration int64
+	if l.ttl > 0 {
+		expiration = c.clock.Now().Add(l.ttl).UnixNano()
+	}
+	return c.setIf(key, l.value, expiration, 0, 0, cond)
+}
+
+// loadedItem returns a loaded value as an item.
+func (c *Cache) loadedItem(l loaded, err error) (Item, error) {
+	if err != nil {
+		return Item{}, err
+	}
+	item := Item{Value: l.value}
+	if l.ttl > 0 {
+		item.Expiration = c.clock.Now().Add(l.ttl).UnixNano()
+	}
+	return item, nil
+}
@@ -0,0 +1,150 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderLoadsMissesOnce(t *testing.T) {
+	var calls int32
+	c := New(Options{Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "value of " + key, 0, nil
+	}})
+	defer c.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get("a"); err != nil || v != "value of a" {
+				t.Errorf("Expected the loaded value, got %v, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Expected the loader to be called once, got %d calls", n)
+	}
+	if c.ItemCount() != 1 {
+		t.Fatalf("Expected 1 item, got %d", c.ItemCount())
+	}
+	if v, _ := c.Get("a"); v != "value of a" || atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("Expected the cached value to be served without loading")
+	}
+}
+
+func TestLoaderTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{
+		Clock:             clock,
+		DefaultExpiration: time.Hour,
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			if key == "short" {
+				return 1, time.Minute, nil
+			}
+			return 2, 0, nil
+		},
+	})
+	defer c.Stop()
+
+	if _, exp, err := c.GetWithExpiration("short"); err != nil || !exp.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("Expected short to expire after the loader's TTL, got %v, %v", exp, err)
+	}
+	if _, exp, err := c.GetWithExpiration("default"); err != nil || !exp.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("Expected default to expire after the default expiration, got %v, %v", exp, err)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	failure := errors.New("database down")
+	c := New(Options{Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		if key == "missing" {
+			return nil, 0, ErrKeyNotFound
+		}
+		return nil, 0, failure
+	}})
+	defer c.Stop()
+
+	if _, err := c.Get("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Expected ErrKeyNotFound for a missing key, got %v", err)
+	}
+	if _, err := c.Get("broken"); !errors.Is(err, failure) {
+		t.Fatalf("Expected %v, got %v", failure, err)
+	}
+	if c.ItemCount() != 0 {
+		t.Fatalf("Expected 0 items, got %d", c.ItemCount())
+	}
+}
+
+func TestGetContextPassesContext(t *testing.T) {
+	type ctxKey struct{}
+	c := New(Options{Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		return ctx.Value(ctxKey{}), 0, nil
+	}})
+	defer c.Stop()
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "from context")
+	if v, err := c.GetContext(ctx, "a"); err != nil || v != "from context" {
+		t.Fatalf("Expected the value from the context, got %v, %v", v, err)
+	}
+}
+
+func TestLoaderPrecedesBackingStore(t *testing.T) {
+	store := newMemoryStore()
+	store.values["a"] = "from store"
+	c := New(Options{
+		BackingStore: store,
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			return "from loader", 0, nil
+		},
+	})
+	defer c.Stop()
+
+	if v, err := c.Get("a"); err != nil || v != "from loader" {
+		t.Fatalf("Expected the value from the loader, got %v, %v", v, err)
+	}
+	if store.loads != 0 {
+		t.Fatalf("Expected no loads from the store, got %d", store.loads)
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := New(Options{Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}})
+	defer c.Stop()
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.Get("key")
+	}()
+	<-started
+
+	// Callers missing the key meanwhile wait on the same call.
+	c.loads.mu.Lock()
+	call := c.loads.calls["key"]
+	c.loads.mu.Unlock()
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("Expected the panic to propagate, got %v", r)
+	}
+	<-call.done
+	if _, err := c.loadedItem(call.value, call.err); err == nil {
+		t.Error("Expected an error for callers waiting on a panicking load, got nil")
+	}
+	if n := c.ItemCount(); n != 0 {
+		t.Errorf("Expected nothing cached by a panicking load, got %d items", n)
+	}
+}
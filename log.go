@@ -0,0 +1,28 @@
+package gocache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// log writes a message to Options.Logger if level is at least
+// Options.LogLevel. Messages are prefixed with "gocache: " so that they
+// stand out in the application's log stream.
+func (c *Cache) log(level slog.Level, msg string, args ...any) {
+	if c.logger == nil || level < c.logLevel {
+		return
+	}
+	c.logger.Log(context.Background(), level, "gocache: "+msg, args...)
+}
+
+// logPersistence logs the outcome of saving or loading the cache, started
+// at start: msg at slog.LevelInfo on success, and the error at
+// slog.LevelError otherwise.
+func (c *Cache) logPersistence(msg string, start time.Time, err error) {
+	if err != nil {
+		c.log(slog.LevelError, msg+" with error", "error", err)
+		return
+	}
+	c.log(slog.LevelInfo, msg, "items", c.ItemCount(), "duration", c.clock.Now().Sub(start))
+}
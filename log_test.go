@@ -0,0 +1,94 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer is a bytes.Buffer safe for a logger writing in the background.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newTestLogger(buf *logBuffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLoggerLogsLoaderFailuresAndSnapshots(t *testing.T) {
+	var buf logBuffer
+	c := New(Options{
+		Logger: newTestLogger(&buf),
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			if key == "absent" {
+				return nil, 0, ErrKeyNotFound
+			}
+			return nil, 0, errors.New("database down")
+		},
+	})
+	defer c.Stop()
+
+	c.GetContext(context.Background(), "user:1")
+	c.GetContext(context.Background(), "absent")
+	c.Set("key", "value")
+	var snapshot bytes.Buffer
+	if err := c.SaveSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	c.LoadSnapshot(strings.NewReader("not a snapshot"))
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=WARN msg="gocache: load failed" key=user:1 error="database down"`,
+		`level=INFO msg="gocache: snapshot saved" items=1`,
+		`level=ERROR msg="gocache: snapshot loaded with error"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "absent") {
+		t.Errorf("a missing key was logged as a failure:\n%s", out)
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	clock := newFakeClock()
+	var buf logBuffer
+	c := New(Options{Logger: newTestLogger(&buf), CleanupInterval: time.Minute, Clock: clock})
+	defer c.Stop()
+
+	c.SetWithExpiration("key", "value", time.Second)
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return c.ItemCount() == 0 })
+	time.Sleep(10 * time.Millisecond)
+	if strings.Contains(buf.String(), "janitor") {
+		t.Errorf("janitor run logged below LogLevel:\n%s", buf.String())
+	}
+
+	var debug logBuffer
+	d := New(Options{Logger: newTestLogger(&debug), LogLevel: slog.LevelDebug, CleanupInterval: time.Minute, Clock: clock})
+	defer d.Stop()
+
+	d.SetWithExpiration("key", "value", time.Second)
+	clock.Advance(time.Minute)
+	waitUntil(t, func() bool { return strings.Contains(debug.String(), `msg="gocache: janitor run" expired=1`) })
+}
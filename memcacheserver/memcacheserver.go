@@ -0,0 +1,489 @@
+// Package memcacheserver serves a gocache.Cache over the memcached text
+// protocol, so that applications written for memcached can use a gocache
+// process without code changes.
+//
+// The supported commands are get, set, add, replace, delete, incr,
+// decr, touch, stats, version and quit. Data stored with flags 0 is kept as
+// a string, or as an int64 if it is the canonical decimal form of one, so
+// that it reads naturally from Go and other servers; data with other flags
+// is kept as a Value. Values of other types set from Go are served if they
+// are strings, byte slices or integers, and reported as missing otherwise.
+package memcacheserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Value is data stored with non-zero flags.
+type Value struct {
+	Flags uint32
+	Data  string
+}
+
+func init() {
+	// Let snapshots made with the default codec hold Values.
+	gob.Register(Value{})
+}
+
+// maxRelativeExpiration is the largest expiration time that memcached
+// treats as relative to now; larger ones are Unix timestamps.
+const maxRelativeExpiration = 60 * 60 * 24 * 30
+
+// maxExptime is the latest Unix timestamp the cache can expire an item at,
+// its expirations being nanosecond timestamps. Larger expiration times are
+// rejected rather than overflowing into the past.
+const maxExptime = math.MaxInt64 / int64(time.Second)
+
+// maxDataLen bounds the size of a stored value.
+const maxDataLen = 1 << 20
+
+// Server serves a cache over the memcached text protocol.
+type Server struct {
+	cache   *gocache.Cache
+	started time.Time
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("memcacheserver: server closed")
+
+// New returns a server for c. It registers a hook that closes the server in
+// the PhaseStopIntake phase of Cache.Close.
+func New(c *gocache.Cache) *Server {
+	s := &Server{
+		cache:     c,
+		started:   time.Now(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	c.OnShutdown(gocache.PhaseStopIntake, "memcacheserver", func(ctx context.Context) error {
+		return s.Close()
+	})
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each one in its own goroutine
+// until l fails or Close is called, in which case it returns
+// ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops all listeners and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else if args[0] == "quit" {
+			w.Flush()
+			return
+		} else if err := s.execute(r, w, args); err != nil {
+			// The request could not be read to its end, so the stream
+			// cannot be resynchronized.
+			w.Flush()
+			return
+		}
+		// Flush once the client has no more pipelined commands buffered.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// execute runs one command and writes its reply. It returns an error only
+// if the connection must be closed.
+func (s *Server) execute(r *bufio.Reader, w *bufio.Writer, args []string) error {
+	switch args[0] {
+	case "get":
+		s.get(w, args[1:])
+	case "set", "add", "replace":
+		return s.store(r, w, args)
+	case "delete":
+		s.delete(w, args[1:])
+	case "incr", "decr":
+		s.incr(w, args)
+	case "touch":
+		s.touch(w, args[1:])
+	case "stats":
+		s.stats(w)
+	case "version":
+		w.WriteString("VERSION gocache\r\n")
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string) {
+	if len(keys) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		value, err := s.cache.Get(key)
+		if err != nil {
+			continue
+		}
+		flags, data, ok := encode(value)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "VALUE %s %d %d\r\n%s\r\n", key, flags, len(data), data)
+	}
+	w.WriteString("END\r\n")
+}
+
+func (s *Server) store(r *bufio.Reader, w *bufio.Writer, args []string) error {
+	if len(args) != 5 && len(args) != 6 {
+		w.WriteString("ERROR\r\n")
+		return nil
+	}
+	key := args[1]
+	flags, ferr := strconv.ParseUint(args[2], 10, 32)
+	exptime, eerr := strconv.ParseInt(args[3], 10, 64)
+	size, serr := strconv.Atoi(args[4])
+	noreply := len(args) == 6 && args[5] == "noreply"
+	if ferr != nil || eerr != nil || exptime > maxExptime || serr != nil || size < 0 || size > maxDataLen {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		if serr != nil || size < 0 || size > maxDataLen {
+			return errors.New("bad data length")
+		}
+		// Skip the data block.
+		_, err := io.CopyN(io.Discard, r, int64(size)+2)
+		return err
+	}
+
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	if string(buf[size:]) != "\r\n" {
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return nil
+	}
+	value := decode(uint32(flags), string(buf[:size]))
+
+	ttl, expired := expiration(exptime, s.cache.Clock().Now())
+	var err error
+	switch {
+	case expired:
+		// An expiration in the past stores nothing and drops the key.
+		if args[0] == "set" || s.exists(key) == (args[0] == "replace") {
+			s.cache.Delete(key)
+		} else {
+			err = gocache.ErrKeyExists
+		}
+	case args[0] == "set":
+		err = s.cache.SetWithExpiration(key, value, ttl)
+	case args[0] == "add":
+		err = s.cache.Add(key, value, ttl)
+	case args[0] == "replace":
+		err = s.cache.Replace(key, value, ttl)
+	}
+
+	if noreply {
+		return nil
+	}
+	switch {
+	case err == nil:
+		w.WriteString("STORED\r\n")
+	case errors.Is(err, gocache.ErrKeyExists), errors.Is(err, gocache.ErrKeyNotFound), errors.Is(err, gocache.ErrKeyLeased):
+		w.WriteString("NOT_STORED\r\n")
+	default:
+		w.WriteString("SERVER_ERROR " + oneLine(err.Error()) + "\r\n")
+	}
+	return nil
+}
+
+func (s *Server) delete(w *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	deleted := s.cache.Delete(args[0])
+	if len(args) > 1 && args[len(args)-1] == "noreply" {
+		return
+	}
+	if deleted {
+		w.WriteString("DELETED\r\n")
+	} else {
+		w.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+func (s *Server) incr(w *bufio.Writer, args []string) {
+	if len(args) != 3 && len(args) != 4 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	key := args[1]
+	noreply := len(args) == 4 && args[3] == "noreply"
+	delta, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+
+	for {
+		value, err := s.cache.Get(key)
+		if err != nil {
+			if !noreply {
+				w.WriteString("NOT_FOUND\r\n")
+			}
+			return
+		}
+		n, ok := number(value)
+		if !ok {
+			if !noreply {
+				w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			}
+			return
+		}
+
+		// Like memcached, incr wraps around at 2^64 and decr stops at 0.
+		if args[0] == "incr" {
+			n += delta
+		} else if n < delta {
+			n = 0
+		} else {
+			n -= delta
+		}
+
+		var updated interface{}
+		if v, isValue := value.(Value); isValue {
+			updated = Value{Flags: v.Flags, Data: strconv.FormatUint(n, 10)}
+		} else {
+			updated = decode(0, strconv.FormatUint(n, 10))
+		}
+		swapped, err := s.cache.CompareAndSwap(key, value, updated)
+		if err != nil {
+			if !noreply {
+				w.WriteString("SERVER_ERROR " + oneLine(err.Error()) + "\r\n")
+			}
+			return
+		}
+		if swapped {
+			if !noreply {
+				w.WriteString(strconv.FormatUint(n, 10) + "\r\n")
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) touch(w *bufio.Writer, args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || exptime > maxExptime {
+		w.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+	noreply := len(args) == 3 && args[2] == "noreply"
+
+	found := s.exists(args[0])
+	if found {
+		now := s.cache.Clock().Now()
+		ttl, expired := expiration(exptime, now)
+		switch {
+		case expired:
+			s.cache.Delete(args[0])
+		case ttl == 0:
+			err = s.cache.ExpireAt(args[0], time.Time{})
+		default:
+			err = s.cache.ExpireAt(args[0], now.Add(ttl))
+		}
+		found = err == nil
+	}
+	if noreply {
+		return
+	}
+	if found {
+		w.WriteString("TOUCHED\r\n")
+	} else {
+		w.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+func (s *Server) stats(w *bufio.Writer) {
+	stats := s.cache.Stats()
+	now := time.Now()
+	for _, stat := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(now.Sub(s.started).Seconds())},
+		{"time", now.Unix()},
+		{"version", "gocache"},
+		{"curr_items", stats.Items},
+		{"get_hits", stats.Hits},
+		{"get_misses", stats.Misses},
+		{"evictions", stats.Evictions},
+		{"expired_unfetched", stats.Expirations},
+	} {
+		fmt.Fprintf(w, "STAT %s %v\r\n", stat.name, stat.value)
+	}
+	w.WriteString("END\r\n")
+}
+
+// exists reports whether key holds an unexpired item.
+func (s *Server) exists(key string) bool {
+	_, _, err := s.cache.GetWithExpiration(key)
+	return err == nil
+}
+
+// expiration converts a memcached expiration time, at most maxExptime, to
+// a TTL from now, 0 meaning never, and reports whether it lies in the past.
+func expiration(exptime int64, now time.Time) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= maxRelativeExpiration:
+		return time.Duration(exptime) * time.Second, false
+	}
+	ttl := time.Unix(exptime, 0).Sub(now)
+	return ttl, ttl <= 0
+}
+
+// decode returns the value to store for data sent with flags.
+func decode(flags uint32, data string) interface{} {
+	if flags != 0 {
+		return Value{Flags: flags, Data: data}
+	}
+	if n, err := strconv.ParseInt(data, 10, 64); err == nil && strconv.FormatInt(n, 10) == data {
+		return n
+	}
+	return data
+}
+
+// encode returns the flags and data to send for a stored value, if it can
+// be sent.
+func encode(value interface{}) (uint32, string, bool) {
+	switch v := value.(type) {
+	case Value:
+		return v.Flags, v.Data, true
+	case string:
+		return 0, v, true
+	case []byte:
+		return 0, string(v), true
+	case int64:
+		return 0, strconv.FormatInt(v, 10), true
+	case int:
+		return 0, strconv.Itoa(v), true
+	case uint64:
+		return 0, strconv.FormatUint(v, 10), true
+	}
+	return 0, "", false
+}
+
+// number returns the unsigned integer held by a stored value, if any.
+func number(value interface{}) (uint64, bool) {
+	_, data, ok := encode(value)
+	if !ok || len(data) == 0 || len(data) > len(strconv.FormatUint(math.MaxUint64, 10)) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(data, 10, 64)
+	return n, err == nil
+}
+
+// oneLine replaces line breaks in s so that it fits a reply line.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
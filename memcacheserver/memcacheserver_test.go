@@ -0,0 +1,195 @@
+package memcacheserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// client sends commands and returns the reply lines up to a terminating one.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) do(command string) string {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(command + "\r\n")); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+	return c.reply()
+}
+
+// reply reads a single-line reply, or the lines of a multi-line one up to
+// END, joined with "|".
+func (c *client) reply() string {
+	c.t.Helper()
+	var lines []string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("read failed: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		lines = append(lines, line)
+		if line == "END" {
+			break
+		}
+		if strings.HasPrefix(line, "VALUE ") || strings.HasPrefix(line, "STAT ") {
+			if strings.HasPrefix(line, "VALUE ") {
+				data, _ := c.r.ReadString('\n')
+				lines = append(lines, strings.TrimSuffix(data, "\r\n"))
+			}
+			continue
+		}
+		break
+	}
+	return strings.Join(lines, "|")
+}
+
+func startServer(t *testing.T) (*gocache.Cache, *client) {
+	cache := gocache.New(gocache.Options{})
+	server := New(cache)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(l) }()
+	t.Cleanup(func() {
+		cache.Close(context.Background())
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cache, &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestServerCommands(t *testing.T) {
+	cache, c := startServer(t)
+
+	for _, tt := range []struct {
+		command, want string
+	}{
+		{"set name 0 0 5\r\nalice", "STORED"},
+		{"get name", "VALUE name 0 5|alice|END"},
+		{"get missing", "END"},
+		{"set blob 42 0 3\r\nabc", "STORED"},
+		{"get name blob missing", "VALUE name 0 5|alice|VALUE blob 42 3|abc|END"},
+		{"add name 0 0 3\r\nbob", "NOT_STORED"},
+		{"add other 0 0 3\r\nbob", "STORED"},
+		{"replace missing 0 0 1\r\nx", "NOT_STORED"},
+		{"replace other 0 0 5\r\ncarol", "STORED"},
+		{"get other", "VALUE other 0 5|carol|END"},
+		{"set counter 0 0 2\r\n10", "STORED"},
+		{"incr counter 5", "15"},
+		{"decr counter 20", "0"},
+		{"incr missing 1", "NOT_FOUND"},
+		{"incr name 1", "CLIENT_ERROR cannot increment or decrement non-numeric value"},
+		{"incr counter x", "CLIENT_ERROR invalid numeric delta argument"},
+		{"set max 0 0 20\r\n18446744073709551615", "STORED"},
+		{"incr max 2", "1"},
+		{"touch name 100", "TOUCHED"},
+		{"touch missing 100", "NOT_FOUND"},
+		{"delete name", "DELETED"},
+		{"delete name", "NOT_FOUND"},
+		{"get name", "END"},
+		{"set gone 0 -1 1\r\nx", "STORED"},
+		{"get gone", "END"},
+		{"set bad x 0 1\r\nx", "CLIENT_ERROR bad command line format"},
+		{"get bad", "END"},
+		{"set huge 0 9223372036854775807 1\r\nx", "CLIENT_ERROR bad command line format"},
+		{"get huge", "END"},
+		{"set far 0 9223372036 1\r\nx", "STORED"},
+		{"get far", "VALUE far 0 1|x|END"},
+		{"touch other 9223372036854775807", "CLIENT_ERROR invalid exptime argument"},
+	} {
+		if got := c.do(tt.command); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.command, tt.want, got)
+		}
+	}
+
+	if got, _ := cache.Get("counter"); got != int64(0) {
+		t.Errorf("Expected a counter stored as int64(0), got %#v", got)
+	}
+	if got, _ := cache.Get("blob"); got != (Value{Flags: 42, Data: "abc"}) {
+		t.Errorf("Expected a Value for data with flags, got %#v", got)
+	}
+	_, expiration, _ := cache.GetWithExpiration("other")
+	if !expiration.IsZero() {
+		t.Errorf("Expected no expiration for exptime 0, got %v", expiration)
+	}
+	c.do("touch other 100")
+	_, expiration, _ = cache.GetWithExpiration("other")
+	if d := time.Until(expiration); d < 99*time.Second || d > 100*time.Second {
+		t.Errorf("Expected an expiration in 100s after touch, got %v", d)
+	}
+
+	cache.Set("struct", struct{ A int }{1})
+	if got := c.do("get struct"); got != "END" {
+		t.Errorf("Expected a Go value to be reported missing, got %q", got)
+	}
+
+	stats := c.do("stats")
+	if !strings.Contains(stats, "|STAT curr_items ") || !strings.HasSuffix(stats, "|END") {
+		t.Errorf("Unexpected stats reply %q", stats)
+	}
+
+	// A bad data length leaves the stream unreadable, so the connection
+	// is closed after the error.
+	if got := c.do("set bad 0 0 x"); got != "CLIENT_ERROR bad command line format" {
+		t.Errorf("Expected CLIENT_ERROR, got %q", got)
+	}
+	if _, err := c.r.ReadString('\n'); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestServerNoreplyAndPipelined(t *testing.T) {
+	cache, c := startServer(t)
+
+	c.conn.Write([]byte("set a 0 0 1 noreply\r\n1\r\nincr a 1 noreply\r\ndelete b noreply\r\nget a\r\n"))
+	if got, want := c.reply(), "VALUE a 0 1|2|END"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got, _ := cache.Get("a"); got != int64(2) {
+		t.Errorf("Expected 2, got %#v", got)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	for _, tt := range []struct {
+		exptime int64
+		ttl     time.Duration
+		expired bool
+	}{
+		{0, 0, false},
+		{-1, 0, true},
+		{60, time.Minute, false},
+		{maxRelativeExpiration, maxRelativeExpiration * time.Second, false},
+		{time.Now().Add(-time.Hour).Unix(), 0, true},
+	} {
+		ttl, expired := expiration(tt.exptime, time.Now())
+		if expired != tt.expired || !expired && ttl != tt.ttl {
+			t.Errorf("expiration(%d) = %v, %v, want %v, %v", tt.exptime, ttl, expired, tt.ttl, tt.expired)
+		}
+	}
+
+	ttl, expired := expiration(time.Now().Add(time.Hour).Unix(), time.Now())
+	if expired || ttl < 59*time.Minute || ttl > time.Hour {
+		t.Errorf("Expected a TTL of about an hour for a timestamp, got %v, %v", ttl, expired)
+	}
+}
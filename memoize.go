@@ -0,0 +1,90 @@
+package gocache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memoSeq numbers memoized functions, so that each gets its own keys.
+var memoSeq uint64
+
+// memoCall is a call of a memoized function in progress.
+type memoCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Memoize returns a function that returns the results of fn from c, calling
+// fn only on a miss and caching what it returns for ttl, or forever if ttl
+// is 0. Concurrent calls with the same argument that miss share a single
+// call of fn. Errors are returned to every waiting caller but not cached.
+//
+// Each memoized function stores its results under keys of its own, formed
+// from a unique prefix and the argument formatted with %#v, so K must
+// format distinctly for distinct values. Results read back through the
+// cache's codec, as with Options.Storage set to BytesStorage, must decode
+// to V, or they are treated as misses.
+func Memoize[K comparable, V any](c *Cache, ttl time.Duration, fn func(K) (V, error)) func(K) (V, error) {
+	prefix := "memo:" + strconv.FormatUint(atomic.AddUint64(&memoSeq, 1), 10) + ":"
+
+	var mu sync.Mutex
+	calls := make(map[K]*memoCall[V])
+
+	return func(arg K) (V, error) {
+		key := prefix + memoKey(arg)
+		if value, err := c.Get(key); err == nil {
+			if v, ok := value.(V); ok {
+				return v, nil
+			}
+		}
+
+		mu.Lock()
+		if call, ok := calls[arg]; ok {
+			mu.Unlock()
+			<-call.done
+			return call.value, call.err
+		}
+		call := &memoCall[V]{done: make(chan struct{})}
+		calls[arg] = call
+		mu.Unlock()
+
+		defer func() {
+			if r := recover(); r != nil {
+				call.err = fmt.Errorf("memoized function panicked: %v", r)
+				// Re-panic once the waiters have been released.
+				defer panic(r)
+			}
+			mu.Lock()
+			delete(calls, arg)
+			mu.Unlock()
+			close(call.done)
+		}()
+
+		call.value, call.err = fn(arg)
+		if call.err == nil {
+			// A result that cannot be stored, for example because the
+			// key is too long, is still returned.
+			c.SetWithExpiration(key, call.value, ttl)
+		}
+		return call.value, call.err
+	}
+}
+
+// memoKey formats a memoized function's argument as part of a key.
+func memoKey[K comparable](arg K) string {
+	switch v := any(arg).(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("%#v", arg)
+}
@@ -0,0 +1,109 @@
+package gocache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var calls int64
+	square := Memoize(cache, time.Minute, func(n int) (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return n * n, nil
+	})
+	double := Memoize(cache, time.Minute, func(n int) (int, error) {
+		return 2 * n, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if v, err := square(4); err != nil || v != 16 {
+			t.Fatalf("Expected 16, got %v (%v)", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	if v, _ := double(4); v != 8 {
+		t.Errorf("Expected memoized functions to have separate keys, got %v", v)
+	}
+	if v, _ := square(5); v != 25 || calls != 2 {
+		t.Errorf("Expected a call for a new argument, got %v after %d calls", v, calls)
+	}
+}
+
+func TestMemoizeErrors(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var calls int
+	fail := errors.New("origin down")
+	load := Memoize(cache, 0, func(key struct{ A, B string }) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", fail
+		}
+		return key.A + key.B, nil
+	})
+
+	key := struct{ A, B string }{"a b", ""}
+	if _, err := load(key); !errors.Is(err, fail) {
+		t.Errorf("Expected the error, got %v", err)
+	}
+	if v, err := load(key); err != nil || v != "a b" {
+		t.Errorf("Expected errors not to be cached, got %q (%v)", v, err)
+	}
+	if v, _ := load(struct{ A, B string }{"a", "b "}); v != "ab " {
+		t.Errorf("Expected struct keys that print alike to stay distinct, got %q", v)
+	}
+}
+
+func TestMemoizeShared(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var calls int64
+	release := make(chan struct{})
+	slow := Memoize(cache, time.Minute, func(key string) (string, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return "value of " + key, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := slow("k"); err != nil || v != "value of k" {
+				t.Errorf("Expected the shared result, got %q (%v)", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Expected concurrent misses to share 1 call, got %d", calls)
+	}
+}
+
+func TestMemoizePanic(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	boom := Memoize(cache, time.Minute, func(int) (int, error) {
+		panic("boom")
+	})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("Expected the panic to propagate, got %v", r)
+		}
+	}()
+	boom(1)
+}
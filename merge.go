@@ -0,0 +1,67 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MergePolicy decides which value Merge keeps for a key present in both
+// caches.
+type MergePolicy int
+
+const (
+	// MergeKeepNewest keeps the value that was set most recently.
+	MergeKeepNewest MergePolicy = iota
+	// MergeKeepExisting keeps the value already in the cache.
+	MergeKeepExisting
+	// MergeOverwrite replaces the value in the cache with the other one.
+	MergeOverwrite
+)
+
+// mergeChunkSize is the number of items Merge reads from the other cache at
+// a time.
+const mergeChunkSize = 1024
+
+// Merge adds the unexpired items of other to c, with their expiration times
+// and priorities, so that a warmed standby cache or a loaded snapshot can be
+// folded into a live cache. Keys present in both caches are resolved by
+// policy; expired items in c count as absent. Keys leased in c are left
+// alone. Values are not copied, so both caches hold the same values.
+//
+// Items are read from other in chunks, as by RangeChunks, and each one is
+// merged atomically, but the merge as a whole is not.
+func (c *Cache) Merge(other *Cache, policy MergePolicy) error {
+	return other.RangeChunks(mergeChunkSize, func(entries []Entry) error {
+		now := other.clock.Now()
+		for _, e := range entries {
+			var expiration int64
+			if e.Expiration > 0 {
+				duration := time.Unix(0, e.Expiration).Sub(now)
+				if duration <= 0 {
+					continue
+				}
+				expiration = c.clock.Now().Add(duration).UnixNano()
+			}
+
+			var cond func(Item, bool) bool
+			switch policy {
+			case MergeKeepNewest:
+				modified := e.Modified
+				cond = func(old Item, found bool) bool {
+					return !found || old.Modified < modified
+				}
+			case MergeKeepExisting:
+				cond = func(_ Item, found bool) bool {
+					return !found
+				}
+			}
+
+			_, err := c.setIf(e.Key, e.Value, expiration, 0, e.Priority, cond)
+			if err != nil && !errors.Is(err, ErrKeyLeased) {
+				return fmt.Errorf("merging %q: %w", e.Key, err)
+			}
+		}
+		return nil
+	})
+}
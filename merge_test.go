@@ -0,0 +1,74 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheMerge(t *testing.T) {
+	for _, tt := range []struct {
+		policy MergePolicy
+		older  interface{} // expected value of a key set earlier in the other cache
+		newer  interface{} // expected value of a key set later in the other cache
+	}{
+		{MergeKeepNewest, "live", "standby"},
+		{MergeKeepExisting, "live", "live"},
+		{MergeOverwrite, "standby", "standby"},
+	} {
+		clock := newFakeClock()
+		live := New(Options{Clock: clock})
+		standby := New(Options{Clock: clock})
+
+		standby.Set("older", "standby")
+		clock.Advance(time.Second)
+		live.Set("older", "live")
+		live.Set("newer", "live")
+		live.SetWithExpiration("expired", "live", time.Millisecond)
+		clock.Advance(time.Second)
+		standby.Set("newer", "standby")
+		standby.Set("expired", "standby")
+		standby.SetWithExpiration("only", "standby", time.Minute)
+
+		if err := live.Merge(standby, tt.policy); err != nil {
+			t.Fatalf("Merge(%d) failed: %v", tt.policy, err)
+		}
+
+		if value, _ := live.Get("older"); value != tt.older {
+			t.Errorf("Merge(%d): expected %v for older, got %v", tt.policy, tt.older, value)
+		}
+		if value, _ := live.Get("newer"); value != tt.newer {
+			t.Errorf("Merge(%d): expected %v for newer, got %v", tt.policy, tt.newer, value)
+		}
+		if value, _ := live.Get("expired"); value != "standby" {
+			t.Errorf("Merge(%d): expected the expired item to be replaced, got %v", tt.policy, value)
+		}
+		clock.Advance(2 * time.Minute)
+		if _, err := live.Get("only"); err != ErrKeyExpired {
+			t.Errorf("Merge(%d): expected merged items to keep their expiration, got %v", tt.policy, err)
+		}
+
+		live.Stop()
+		standby.Stop()
+	}
+}
+
+func TestCacheMergeLeased(t *testing.T) {
+	live := New(Options{})
+	defer live.Stop()
+	standby := New(Options{})
+	defer standby.Stop()
+
+	live.Lease("owned", time.Minute)
+	standby.Set("owned", "standby")
+	standby.Set("free", "standby")
+
+	if err := live.Merge(standby, MergeOverwrite); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if _, err := live.Get("owned"); err != ErrKeyNotFound {
+		t.Errorf("Expected the leased key to be left alone, got %v", err)
+	}
+	if value, _ := live.Get("free"); value != "standby" {
+		t.Errorf("Expected free to be merged, got %v", value)
+	}
+}
@@ -0,0 +1,172 @@
+package gocache
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MissFilterOptions configures the miss filter, a bloom filter of keys
+// recently found missing upstream: keys that Options.Loader,
+// Options.BackingStore or the function passed to GetOrSet reported with
+// ErrKeyNotFound. Get and GetOrSet reject such keys with ErrNotFoundCached
+// without taking the cache lock or loading them again, so a burst of
+// lookups for absent records costs a few atomic loads each. Unlike
+// Options.NegativeTTL, the filter uses a fixed amount of memory however
+// many keys miss.
+//
+// Setting a key removes it from the filter, so stored keys are never
+// rejected. A key that appears upstream without being set in the cache is
+// rejected until the filter forgets it, after one to two Windows. The filter
+// may also reject keys it has not seen, with a probability of about
+// FalsePositiveRate while it holds Capacity keys or fewer.
+type MissFilterOptions struct {
+	// Capacity is the number of missing keys the filter is sized for. If
+	// 0, there is no miss filter.
+	Capacity int
+
+	// FalsePositiveRate is the fraction of other keys the filter rejects
+	// when it holds Capacity keys. If 0, 0.001 is used.
+	FalsePositiveRate float64
+
+	// Window is how long the filter remembers a missing key at least. If
+	// 0, one minute is used.
+	Window time.Duration
+}
+
+// Defaults for MissFilterOptions.
+const (
+	defaultMissFilterRate   = 0.001
+	defaultMissFilterWindow = time.Minute
+)
+
+// missFilter remembers missing keys in two bloom filters: keys are added to
+// the current one, and every Window the older one is cleared and becomes
+// the current one.
+type missFilter struct {
+	seed   maphash.Seed
+	hashes int
+	window int64 // nanoseconds
+
+	mu        sync.Mutex // serializes rotations
+	current   atomic.Pointer[bloom]
+	previous  atomic.Pointer[bloom]
+	rotatedAt atomic.Int64
+}
+
+// bloom is a bloom filter whose bits are set and cleared atomically.
+type bloom struct {
+	words []atomic.Uint64
+}
+
+func newMissFilter(options MissFilterOptions, now time.Time) *missFilter {
+	if options.Capacity <= 0 {
+		return nil
+	}
+	rate := options.FalsePositiveRate
+	if rate <= 0 || rate >= 1 {
+		rate = defaultMissFilterRate
+	}
+	window := options.Window
+	if window <= 0 {
+		window = defaultMissFilterWindow
+	}
+
+	// Optimal number of bits and hash functions for the capacity and rate.
+	bits := math.Ceil(-float64(options.Capacity) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	words := int(bits+63) / 64
+	hashes := int(math.Round(float64(words*64) / float64(options.Capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	f := &missFilter{seed: maphash.MakeSeed(), hashes: hashes, window: int64(window)}
+	f.current.Store(&bloom{words: make([]atomic.Uint64, words)})
+	f.previous.Store(&bloom{words: make([]atomic.Uint64, words)})
+	f.rotatedAt.Store(now.UnixNano())
+	return f
+}
+
+// positions returns the two hashes of key, combined into the bit positions
+// of the filter by double hashing.
+func (f *missFilter) positions(key string) (h1, h2 uint64) {
+	h := maphash.String(f.seed, key)
+	return h, h>>32 | 1
+}
+
+// contains reports whether key was added within the last one or two
+// Windows, or is a false positive.
+func (f *missFilter) contains(key string, now time.Time) bool {
+	f.rotate(now)
+	h1, h2 := f.positions(key)
+	return f.current.Load().test(h1, h2, f.hashes) || f.previous.Load().test(h1, h2, f.hashes)
+}
+
+// add records key as missing. It must be called with c.mu held, for reading
+// at least, so that it does not race with remove.
+func (f *missFilter) add(key string, now time.Time) {
+	f.rotate(now)
+	h1, h2 := f.positions(key)
+	b := f.current.Load()
+	n := uint64(len(b.words) * 64)
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % n
+		b.update(bit, func(w uint64) uint64 { return w | 1<<(bit%64) })
+	}
+}
+
+// remove makes contains report false for key by clearing one of its bits,
+// which at worst makes the filter forget other keys early. It must be
+// called with c.mu held for writing.
+func (f *missFilter) remove(key string) {
+	h1, _ := f.positions(key)
+	for _, b := range []*bloom{f.current.Load(), f.previous.Load()} {
+		bit := h1 % uint64(len(b.words)*64)
+		b.update(bit, func(w uint64) uint64 { return w &^ (1 << (bit % 64)) })
+	}
+}
+
+// rotate clears the previous filter and swaps it with the current one if a
+// Window has passed since the last rotation.
+func (f *missFilter) rotate(now time.Time) {
+	if now.UnixNano()-f.rotatedAt.Load() < f.window {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now.UnixNano()-f.rotatedAt.Load() < f.window {
+		return
+	}
+	old := f.previous.Load()
+	for i := range old.words {
+		old.words[i].Store(0)
+	}
+	f.previous.Store(f.current.Load())
+	f.current.Store(old)
+	f.rotatedAt.Store(now.UnixNano())
+}
+
+// test reports whether all bits of a key are set.
+func (b *bloom) test(h1, h2 uint64, hashes int) bool {
+	n := uint64(len(b.words) * 64)
+	for i := 0; i < hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % n
+		if b.words[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// update replaces the word holding bit with fn of it.
+func (b *bloom) update(bit uint64, fn func(uint64) uint64) {
+	w := &b.words[bit/64]
+	for {
+		old := w.Load()
+		if w.CompareAndSwap(old, fn(old)) {
+			return
+		}
+	}
+}
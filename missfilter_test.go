@@ -0,0 +1,82 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMissFilterRejectsMissingKeys(t *testing.T) {
+	var calls int32
+	c := New(Options{
+		MissFilter: MissFilterOptions{Capacity: 1000},
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, 0, ErrKeyNotFound
+		},
+	})
+	defer c.Stop()
+
+	if _, err := c.Get("missing"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound from the first Get, got %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get("missing"); err != ErrNotFoundCached {
+			t.Fatalf("Expected ErrNotFoundCached, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Expected the loader to be called once, got %d calls", n)
+	}
+
+	c.Set("missing", "found")
+	if v, err := c.Get("missing"); err != nil || v != "found" {
+		t.Fatalf("Expected found after Set, got %v, %v", v, err)
+	}
+}
+
+func TestMissFilterForgets(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock, MissFilter: MissFilterOptions{Capacity: 1000, Window: time.Minute}})
+	defer c.Stop()
+
+	fn := func() (interface{}, error) { return nil, ErrKeyNotFound }
+	c.GetOrSet("missing", fn)
+	if _, err := c.GetOrSet("missing", fn); err != ErrNotFoundCached {
+		t.Fatalf("Expected ErrNotFoundCached, got %v", err)
+	}
+
+	clock.Advance(61 * time.Second)
+	if _, err := c.Get("missing"); err != ErrNotFoundCached {
+		t.Fatalf("Expected ErrNotFoundCached after one window, got %v", err)
+	}
+	clock.Advance(61 * time.Second)
+	if _, err := c.Get("missing"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound after two windows, got %v", err)
+	}
+}
+
+func TestMissFilterFalsePositiveRate(t *testing.T) {
+	f := newMissFilter(MissFilterOptions{Capacity: 10000, FalsePositiveRate: 0.01}, time.Now())
+	now := time.Now()
+	for i := 0; i < 10000; i++ {
+		f.add(fmt.Sprint("missing", i), now)
+	}
+	for i := 0; i < 10000; i++ {
+		if !f.contains(fmt.Sprint("missing", i), now) {
+			t.Fatalf("Expected key %d to be found", i)
+		}
+	}
+
+	var positives int
+	for i := 0; i < 10000; i++ {
+		if f.contains(fmt.Sprint("other", i), now) {
+			positives++
+		}
+	}
+	if positives > 300 {
+		t.Fatalf("Expected about 100 false positives in 10000, got %d", positives)
+	}
+}
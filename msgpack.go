@@ -0,0 +1,654 @@
+package gocache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// msgpackCodec implements the MessagePack format
+// (https://github.com/msgpack/msgpack/blob/master/spec.md) for the Go types
+// that commonly end up in a cache: booleans, numbers, strings, byte slices,
+// slices, arrays, maps, structs, pointers, interfaces and time.Time.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	e := msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("msgpack: Unmarshal requires a non-nil pointer")
+	}
+	d := msgpackDecoder{buf: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return errors.New("msgpack: trailing data")
+	}
+	return nil
+}
+
+// MessagePack format bytes.
+const (
+	mpNil      = 0xc0
+	mpFalse    = 0xc2
+	mpTrue     = 0xc3
+	mpBin8     = 0xc4
+	mpBin16    = 0xc5
+	mpBin32    = 0xc6
+	mpExt8     = 0xc7
+	mpFloat32  = 0xca
+	mpFloat64  = 0xcb
+	mpUint8    = 0xcc
+	mpUint16   = 0xcd
+	mpUint32   = 0xce
+	mpUint64   = 0xcf
+	mpInt8     = 0xd0
+	mpInt16    = 0xd1
+	mpInt32    = 0xd2
+	mpInt64    = 0xd3
+	mpFixExt4  = 0xd6
+	mpFixExt8  = 0xd7
+	mpStr8     = 0xd9
+	mpStr16    = 0xda
+	mpStr32    = 0xdb
+	mpArray16  = 0xdc
+	mpArray32  = 0xdd
+	mpMap16    = 0xde
+	mpMap32    = 0xdf
+	mpFixMap   = 0x80
+	mpFixArray = 0x90
+	mpFixStr   = 0xa0
+
+	mpTimestampExt = 0xff // extension type -1
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, mpNil)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, mpTrue)
+		} else {
+			e.buf = append(e.buf, mpFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, mpFloat32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, mpFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		e.encodeHeader(v.Len(), mpFixMap, 16, mpMap16, mpMap32)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			e.encodeTime(v.Interface().(time.Time))
+			return nil
+		}
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, mpInt8, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, mpInt16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, mpInt32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, mpInt64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, mpUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, mpUint16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, mpUint32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, mpUint64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+// encodeHeader writes a length-prefixed header using the fix format for
+// lengths below fixLimit and the 16 or 32 bit format otherwise.
+func (e *msgpackEncoder) encodeHeader(n int, fix byte, fixLimit int, code16, code32 byte) {
+	switch {
+	case n < fixLimit:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	if n := len(s); n >= 32 && n <= math.MaxUint8 {
+		e.buf = append(e.buf, mpStr8, byte(n))
+	} else {
+		e.encodeHeader(n, mpFixStr, 32, mpStr16, mpStr32)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, mpBin8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, mpBin16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, mpBin32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value) error {
+	e.encodeHeader(v.Len(), mpFixArray, 16, mpArray16, mpArray32)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	fields := msgpackFields(v.Type())
+	e.encodeHeader(len(fields), mpFixMap, 16, mpMap16, mpMap32)
+	for _, f := range fields {
+		e.encodeString(f.name)
+		if err := e.encode(v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTime writes t using the timestamp 96 extension.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	e.buf = append(e.buf, mpExt8, 12, mpTimestampExt)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(t.Unix()))
+}
+
+// msgpackField describes an exported struct field.
+type msgpackField struct {
+	name  string
+	index int
+}
+
+// msgpackFields returns the exported fields of t with their encoded names.
+func msgpackFields(t reflect.Type) []msgpackField {
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, msgpackField{name: name, index: i})
+	}
+	return fields
+}
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+type msgpackDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// decodeAny decodes the next value into its natural Go representation:
+// int64 (or uint64 for integers that do not fit in it), float32, float64, string, []byte, []interface{},
+// map[string]interface{} (or map[interface{}]interface{} when keys are not
+// all strings), time.Time, bool or nil.
+func (d *msgpackDecoder) decodeAny() (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xe0 == mpFixStr:
+		return d.readString(int(code & 0x1f))
+	case code&0xf0 == mpFixArray:
+		return d.readArray(int(code & 0x0f))
+	case code&0xf0 == mpFixMap:
+		return d.readMap(int(code & 0x0f))
+	}
+
+	switch code {
+	case mpNil:
+		return nil, nil
+	case mpFalse:
+		return false, nil
+	case mpTrue:
+		return true, nil
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		n, err := d.readUint(1 << (code - mpUint8))
+		if err != nil || n > math.MaxInt64 {
+			return n, err
+		}
+		return int64(n), nil
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		size := 1 << (code - mpInt8)
+		n, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case mpFloat32:
+		n, err := d.readUint(4)
+		return math.Float32frombits(uint32(n)), err
+	case mpFloat64:
+		n, err := d.readUint(8)
+		return math.Float64frombits(n), err
+	case mpStr8, mpStr16, mpStr32:
+		n, err := d.readUint(1 << (code - mpStr8))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(int(n))
+	case mpBin8, mpBin16, mpBin32:
+		n, err := d.readUint(1 << (code - mpBin8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n))
+		return append([]byte(nil), b...), err
+	case mpArray16, mpArray32:
+		n, err := d.readUint(2 << (code - mpArray16))
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(int(n))
+	case mpMap16, mpMap32:
+		n, err := d.readUint(2 << (code - mpMap16))
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(int(n))
+	case mpFixExt4:
+		return d.readExt(4)
+	case mpFixExt8:
+		return d.readExt(8)
+	case mpExt8:
+		n, err := d.readUint(1)
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format byte 0x%02x", code)
+}
+
+func (d *msgpackDecoder) readString(n int) (string, error) {
+	b, err := d.read(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) readArray(n int) ([]interface{}, error) {
+	out := make([]interface{}, 0, min(n, len(d.buf)-d.pos))
+	for i := 0; i < n; i++ {
+		v, err := d.decodeAny()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (d *msgpackDecoder) readMap(n int) (interface{}, error) {
+	keys := make([]interface{}, 0, min(n, len(d.buf)-d.pos))
+	values := make([]interface{}, 0, cap(keys))
+	allStrings := true
+	for i := 0; i < n; i++ {
+		k, err := d.decodeAny()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decodeAny()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			allStrings = false
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	if allStrings {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack: unhashable map key of type %T", k)
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// readExt reads an extension payload of n bytes. Only the timestamp
+// extension is supported.
+func (d *msgpackDecoder) readExt(n int) (interface{}, error) {
+	typ, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if typ[0] != mpTimestampExt {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", int8(typ[0]))
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+// decode decodes the next value into v, which must be settable.
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	raw, err := d.decodeAny()
+	if err != nil {
+		return err
+	}
+	return assignMsgpack(v, raw)
+}
+
+// assignMsgpack stores a value produced by decodeAny into v, converting it
+// to v's type.
+func assignMsgpack(v reflect.Value, raw interface{}) error {
+	if raw == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(raw)
+	mismatch := fmt.Errorf("msgpack: cannot decode %T into %s", raw, v.Type())
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if !rv.Type().Implements(v.Type()) {
+			return mismatch
+		}
+		v.Set(rv)
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignMsgpack(v.Elem(), raw)
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n := raw.(type) {
+		case int64:
+			v.SetInt(n)
+		case uint64:
+			v.SetInt(int64(n))
+		default:
+			return mismatch
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch n := raw.(type) {
+		case int64:
+			v.SetUint(uint64(n))
+		case uint64:
+			v.SetUint(n)
+		default:
+			return mismatch
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := raw.(type) {
+		case float32:
+			v.SetFloat(float64(n))
+		case float64:
+			v.SetFloat(n)
+		case int64:
+			v.SetFloat(float64(n))
+		case uint64:
+			v.SetFloat(float64(n))
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		switch s := raw.(type) {
+		case string:
+			v.SetString(s)
+		case []byte:
+			v.SetString(string(s))
+		default:
+			return mismatch
+		}
+	case reflect.Slice:
+		switch s := raw.(type) {
+		case []byte:
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				return mismatch
+			}
+			v.SetBytes(s)
+		case string:
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				return mismatch
+			}
+			v.SetBytes([]byte(s))
+		case []interface{}:
+			out := reflect.MakeSlice(v.Type(), len(s), len(s))
+			for i, elem := range s {
+				if err := assignMsgpack(out.Index(i), elem); err != nil {
+					return err
+				}
+			}
+			v.Set(out)
+		default:
+			return mismatch
+		}
+	case reflect.Array:
+		s, ok := raw.([]interface{})
+		if !ok || len(s) != v.Len() {
+			return mismatch
+		}
+		for i, elem := range s {
+			if err := assignMsgpack(v.Index(i), elem); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		out := reflect.MakeMap(v.Type())
+		set := func(k, val interface{}) error {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := assignMsgpack(key, k); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := assignMsgpack(elem, val); err != nil {
+				return err
+			}
+			out.SetMapIndex(key, elem)
+			return nil
+		}
+		switch m := raw.(type) {
+		case map[string]interface{}:
+			for k, val := range m {
+				if err := set(k, val); err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, val := range m {
+				if err := set(k, val); err != nil {
+					return err
+				}
+			}
+		default:
+			return mismatch
+		}
+		v.Set(out)
+	case reflect.Struct:
+		if t, ok := raw.(time.Time); ok && v.Type() == timeType {
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		m, ok := raw.(map[string]interface{})
+		if !ok || v.Type() == timeType {
+			return mismatch
+		}
+		for _, f := range msgpackFields(v.Type()) {
+			if val, found := m[f.name]; found {
+				if err := assignMsgpack(v.Field(f.index), val); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return mismatch
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package gocache
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// namespaceSeparator separates the namespace name from the key within the
+// underlying cache.
+const namespaceSeparator = ":"
+
+// NamespaceOptions configures a logical cache created with Cache.Namespace.
+// Zero values inherit the settings of the underlying cache.
+type NamespaceOptions struct {
+	// DefaultExpiration is the expiration used by Set. If 0, the cache's
+	// DefaultExpiration is used; if negative, items never expire by default.
+	DefaultExpiration time.Duration
+
+	// Priority is the eviction priority of items stored through the
+	// namespace. When the cache is full, items of lower priority are always
+	// evicted before items of higher priority. The default priority is 0.
+	Priority int
+
+	// Codec encodes the namespace's values in snapshots and replication
+	// streams. If nil, the cache's codec is used.
+	Codec Codec
+
+	// MaxStaleness is the freshness SLA of the namespace: values returned by
+	// Get should have been set at most this long ago. If set, the age of
+	// sampled hits is checked against it and counted in
+	// Stats.StalenessChecks and Stats.StalenessViolations. Values loaded
+	// from a snapshot count as set when they were loaded.
+	MaxStaleness time.Duration
+
+	// StalenessSampling checks one in every StalenessSampling hits against
+	// MaxStaleness. If 0, every hit is checked.
+	StalenessSampling int
+}
+
+// Namespace is a logical cache hosted in a shared Cache. Its keys are stored
+// in the underlying cache prefixed with the namespace name and a colon, so
+// namespaces share capacity limits but keep their own defaults and stats.
+type Namespace struct {
+	cache    *Cache
+	name     string
+	prefix   string
+	options  NamespaceOptions
+	counters counters
+}
+
+// Namespace returns the namespace with the given name, creating it with
+// options on first use. Later calls with the same name return the existing
+// namespace and ignore options. The name must not contain a colon.
+func (c *Cache) Namespace(name string, options NamespaceOptions) *Namespace {
+	if strings.Contains(name, namespaceSeparator) {
+		panic("gocache: namespace name must not contain " + namespaceSeparator)
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	if options.Codec == nil {
+		options.Codec = c.codec
+	}
+	ns := &Namespace{
+		cache:   c,
+		name:    name,
+		prefix:  name + namespaceSeparator,
+		options: options,
+	}
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]*Namespace)
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// namespaceOf returns the namespace that key belongs to, or nil.
+func (c *Cache) namespaceOf(key string) *Namespace {
+	name, _, ok := strings.Cut(key, namespaceSeparator)
+	if !ok {
+		return nil
+	}
+
+	c.nsMu.RLock()
+	defer c.nsMu.RUnlock()
+	return c.namespaces[name]
+}
+
+// codecOf returns the codec of the namespace key belongs to, or the
+// cache's codec.
+func (c *Cache) codecOf(key string) Codec {
+	if ns := c.namespaceOf(key); ns != nil {
+		return ns.options.Codec
+	}
+	return c.codec
+}
+
+// Name returns the name of the namespace.
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// Codec returns the codec used for the namespace's values.
+func (ns *Namespace) Codec() Codec {
+	return ns.options.Codec
+}
+
+// Set adds an item to the namespace with its default expiration.
+func (ns *Namespace) Set(key string, value interface{}) error {
+	duration := ns.options.DefaultExpiration
+	switch {
+	case duration == 0:
+		duration = ns.cache.defaultExpiration()
+	case duration < 0:
+		duration = 0
+	}
+	return ns.SetWithExpiration(key, value, duration)
+}
+
+// SetWithExpiration adds an item to the namespace with the given expiration
+// duration. If duration is 0, the item never expires. Key constraints apply
+// to key without the namespace prefix.
+func (ns *Namespace) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	if err := ns.cache.validateKey(key); err != nil {
+		return err
+	}
+	if err := ns.cache.set(ns.prefix+key, value, duration, 0, ns.options.Priority); err != nil {
+		return err
+	}
+	return ns.cache.storeThrough(context.Background(), ns.prefix+key, value, 0)
+}
+
+// Get returns the value stored in the namespace for the given key.
+func (ns *Namespace) Get(key string) (interface{}, error) {
+	item, err := ns.cache.getItem(ns.prefix + key)
+	if err != nil {
+		atomic.AddUint64(&ns.counters.misses, 1)
+		return nil, err
+	}
+	hits := atomic.AddUint64(&ns.counters.hits, 1)
+	if ns.options.MaxStaleness > 0 {
+		ns.checkStaleness(item, hits)
+	}
+	return item.Value, nil
+}
+
+// checkStaleness checks the age of item, the hits-th hit, against the
+// namespace's freshness SLA if the hit is sampled.
+func (ns *Namespace) checkStaleness(item Item, hits uint64) {
+	if every := ns.options.StalenessSampling; every > 1 && hits%uint64(every) != 0 {
+		return
+	}
+	atomic.AddUint64(&ns.counters.stalenessChecks, 1)
+	age := time.Duration(ns.cache.clock.Now().UnixNano() - item.Modified)
+	if age > ns.options.MaxStaleness {
+		atomic.AddUint64(&ns.counters.stalenessViolations, 1)
+	}
+}
+
+// GetOrSet gets the value from the namespace if it exists and is not
+// expired. Otherwise, it sets the value using the provided function.
+func (ns *Namespace) GetOrSet(key string, fn func() (interface{}, error), opts ...CallOption) (interface{}, error) {
+	o := applyCallOptions(opts)
+
+	if !o.bypassCache {
+		if value, err := ns.Get(key); err == nil || err == ErrNotFoundCached {
+			return value, err
+		}
+	}
+
+	value, err := ns.cache.load(ns.prefix+key, fn, func(value interface{}) error {
+		return ns.Set(key, value)
+	}, o)
+	if err != nil && !o.noStore {
+		ns.cache.cacheNotFound(ns.prefix+key, err)
+	}
+	return value, err
+}
+
+// Delete removes the item with the given key from the namespace.
+func (ns *Namespace) Delete(key string) bool {
+	return ns.cache.Delete(ns.prefix + key)
+}
+
+// Items returns a copy of all unexpired items in the namespace, keyed
+// without the namespace prefix.
+func (ns *Namespace) Items() map[string]interface{} {
+	c := ns.cache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := make(map[string]interface{})
+	now := c.clock.Now().UnixNano()
+
+	c.items.rangeItems(func(k string, v Item) bool {
+		if key, ok := strings.CutPrefix(k, ns.prefix); ok && !v.expiredAt(now) {
+			items[key] = c.valueOf(v.Value)
+		}
+		return true
+	})
+
+	return items
+}
+
+// ItemCount returns the number of items in the namespace, including expired
+// items.
+func (ns *Namespace) ItemCount() int {
+	c := ns.cache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var n int
+	c.items.rangeItems(func(k string, _ Item) bool {
+		if strings.HasPrefix(k, ns.prefix) {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// Flush removes all items from the namespace. Subscribers and watchers
+// receive an EventDeleted for each item removed.
+func (ns *Namespace) Flush() {
+	c := ns.cache
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.async.supersedePrefix(ns.prefix)
+	var keys []string
+	c.items.rangeItems(func(k string, _ Item) bool {
+		if strings.HasPrefix(k, ns.prefix) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	for _, k := range keys {
+		item, _ := c.items.get(k)
+		c.remove(k)
+		c.emit(EventDeleted, k, item.Value)
+	}
+	for k := range c.negatives {
+		if strings.HasPrefix(k, ns.prefix) {
+			delete(c.negatives, k)
+		}
+	}
+	c.shield.invalidate(ns.prefix, c.clock.Now())
+}
+
+// Stats returns a snapshot of the namespace's activity counters since it
+// was created or ResetStats was last called. Only reads made through the
+// namespace count as hits and misses.
+func (ns *Namespace) Stats() Stats {
+	stats := ns.counters.sinceReset()
+	stats.Items = ns.ItemCount()
+	return stats
+}
+
+// ResetStats starts the namespace's activity counters from zero without
+// affecting the stats of the cache or of other namespaces.
+func (ns *Namespace) ResetStats() {
+	ns.counters.reset()
+}
@@ -0,0 +1,187 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNamespaceDefaults(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{DefaultExpiration: time.Minute, Clock: clock})
+	defer cache.Stop()
+
+	sessions := cache.Namespace("sessions", NamespaceOptions{DefaultExpiration: time.Second, Codec: JSONCodec})
+	config := cache.Namespace("config", NamespaceOptions{DefaultExpiration: -1})
+	users := cache.Namespace("users", NamespaceOptions{})
+
+	sessions.Set("s1", "alice")
+	config.Set("mode", "dark")
+	users.Set("u1", "bob")
+
+	if value, _ := cache.Get("sessions:s1"); value != "alice" {
+		t.Errorf("Expected prefixed key in cache, got %v", value)
+	}
+	if sessions.Codec() != JSONCodec || users.Codec() != GobCodec {
+		t.Error("Expected namespace codec to default to the cache codec")
+	}
+	if cache.Namespace("sessions", NamespaceOptions{}) != sessions {
+		t.Error("Expected Namespace to return the existing namespace")
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, err := sessions.Get("s1"); err != ErrKeyExpired {
+		t.Errorf("Expected session to expire, got %v", err)
+	}
+	if _, err := users.Get("u1"); err != nil {
+		t.Errorf("Expected user to use cache default expiration, got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := config.Get("mode"); err != nil {
+		t.Errorf("Expected config to never expire, got %v", err)
+	}
+
+	stats := sessions.Stats()
+	if stats.Misses != 1 || stats.Expirations != 1 || stats.Hits != 0 {
+		t.Errorf("Unexpected session stats: %+v", stats)
+	}
+	if stats := config.Stats(); stats.Hits != 1 || stats.Items != 1 {
+		t.Errorf("Unexpected config stats: %+v", stats)
+	}
+}
+
+func TestNamespaceItemsAndFlush(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	a := cache.Namespace("a", NamespaceOptions{})
+	b := cache.Namespace("b", NamespaceOptions{})
+	a.Set("k1", 1)
+	a.Set("k2", 2)
+	b.Set("k1", 3)
+
+	items := a.Items()
+	if len(items) != 2 || items["k1"] != 1 || items["k2"] != 2 {
+		t.Errorf("Unexpected namespace items: %v", items)
+	}
+
+	a.Flush()
+	if a.ItemCount() != 0 || cache.ItemCount() != 1 {
+		t.Errorf("Expected flush to only remove namespace items, got %d in cache", cache.ItemCount())
+	}
+	if value, _ := b.Get("k1"); value != 3 {
+		t.Errorf("Expected other namespace to be untouched, got %v", value)
+	}
+}
+
+func TestNamespaceFlushAsync(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	ns := cache.Namespace("n", NamespaceOptions{})
+	cache.Set("other", 1)
+	for i := 0; i < 5000; i++ {
+		cache.SetAsync(fmt.Sprintf("n:k%d", i), i)
+	}
+	cache.SetAsync("queued", 2)
+	ns.Flush()
+
+	if _, err := ns.Get("k4999"); err == nil {
+		t.Error("Expected the flush to supersede the queued writes of the namespace")
+	}
+	if err := cache.FlushAsync(context.Background()); err != nil {
+		t.Fatalf("FlushAsync failed: %v", err)
+	}
+	if ns.ItemCount() != 0 {
+		t.Errorf("Expected no items in the namespace, got %d", ns.ItemCount())
+	}
+	if value, _ := cache.Get("queued"); value != 2 {
+		t.Errorf("Expected writes outside the namespace to be applied, got %v", value)
+	}
+}
+
+func TestNamespacePriority(t *testing.T) {
+	cache := New(Options{MaxEntries: 3})
+	defer cache.Stop()
+
+	auth := cache.Namespace("auth", NamespaceOptions{Priority: 10})
+	pages := cache.Namespace("pages", NamespaceOptions{})
+
+	auth.Set("token", "secret")
+	pages.Set("p1", "one")
+	pages.Set("p2", "two")
+	pages.Set("p3", "three")
+	pages.Set("p4", "four")
+
+	if _, err := auth.Get("token"); err != nil {
+		t.Errorf("Expected high-priority item to survive eviction, got %v", err)
+	}
+	if _, err := pages.Get("p1"); err != ErrKeyNotFound {
+		t.Errorf("Expected oldest low-priority item to be evicted, got %v", err)
+	}
+	if stats := pages.Stats(); stats.Evictions != 2 {
+		t.Errorf("Expected 2 namespace evictions, got %d", stats.Evictions)
+	}
+}
+
+func TestNamespaceStaleness(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+	prices := cache.Namespace("prices", NamespaceOptions{MaxStaleness: time.Minute})
+	sampled := cache.Namespace("sampled", NamespaceOptions{MaxStaleness: time.Minute, StalenessSampling: 2})
+
+	prices.Set("btc", 1)
+	sampled.Set("eth", 2)
+	prices.Get("btc")
+	clock.Advance(2 * time.Minute)
+	prices.Get("btc")
+	prices.Get("missing")
+	for i := 0; i < 4; i++ {
+		sampled.Get("eth")
+	}
+
+	if stats := prices.Stats(); stats.StalenessChecks != 2 || stats.StalenessViolations != 1 {
+		t.Errorf("Expected 2 checks and 1 violation, got %+v", stats)
+	}
+	if stats := sampled.Stats(); stats.StalenessChecks != 2 || stats.StalenessViolations != 2 {
+		t.Errorf("Expected 2 sampled checks and 2 violations, got %+v", stats)
+	}
+
+	prices.Set("btc", 3)
+	prices.Get("btc")
+	if stats := prices.Stats(); stats.StalenessChecks != 3 || stats.StalenessViolations != 1 {
+		t.Errorf("Expected a fresh value after Set, got %+v", stats)
+	}
+}
+
+func TestNamespaceCodecInSnapshots(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Namespace("sessions", NamespaceOptions{Codec: JSONCodec}).Set("s1", "alice")
+	cache.Set("plain", "bob")
+
+	var snapshot bytes.Buffer
+	if err := cache.SaveSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(snapshot.Bytes(), []byte(`"alice"`)) {
+		t.Error("Expected the namespace's value to be encoded with its codec")
+	}
+
+	restored := New(Options{})
+	defer restored.Stop()
+	restored.Namespace("sessions", NamespaceOptions{Codec: JSONCodec})
+	if err := restored.LoadSnapshot(bytes.NewReader(snapshot.Bytes())); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if value, err := restored.Get("sessions:s1"); err != nil || value != "alice" {
+		t.Errorf("Expected alice, got %v (%v)", value, err)
+	}
+	if value, err := restored.Get("plain"); err != nil || value != "bob" {
+		t.Errorf("Expected bob, got %v (%v)", value, err)
+	}
+}
@@ -0,0 +1,77 @@
+package gocache
+
+import (
+	"errors"
+	"time"
+)
+
+// SetNegative records that key does not exist upstream for ttl, so that
+// repeated lookups of a missing record are answered by the cache instead
+// of the backend. Until ttl has passed or the key is set, Get returns
+// ErrNotFoundCached, and GetOrSet, Options.Loader and Options.BackingStore
+// are not called for it. An item cached under key is removed.
+func (c *Cache) SetNegative(key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	c.setNegative(key, ttl, false)
+	return nil
+}
+
+// setNegative records key as missing for ttl. If ifMissing is set, nothing
+// is recorded when the key holds an unexpired item, such as one set while
+// a load was finding that the key does not exist.
+func (c *Cache) setNegative(key string, ttl time.Duration, ifMissing bool) {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, found := c.items.get(key); found {
+		if ifMissing && !item.expiredAt(now.UnixNano()) {
+			return
+		}
+		c.remove(key)
+		c.emit(EventDeleted, key, item.Value)
+	}
+	if c.negatives == nil {
+		c.negatives = make(map[string]int64)
+	}
+	c.negatives[key] = now.Add(ttl).UnixNano()
+}
+
+// negativeCached reports whether key is recorded as missing.
+func (c *Cache) negativeCached(key string) bool {
+	c.mu.RLock()
+	expiration, ok := c.negatives[key]
+	c.mu.RUnlock()
+	return ok && c.clock.Now().UnixNano() <= expiration
+}
+
+// cacheNotFound records key as missing for Options.NegativeTTL and in
+// Options.MissFilter if err reports that the key does not exist upstream.
+func (c *Cache) cacheNotFound(key string, err error) {
+	if !errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrNotFoundCached) {
+		return
+	}
+	if c.negativeTTL > 0 {
+		c.setNegative(key, c.negativeTTL, true)
+	}
+	if c.missFilter != nil {
+		now := c.clock.Now()
+		c.mu.RLock()
+		if item, found := c.items.get(key); !found || item.expiredAt(now.UnixNano()) {
+			c.missFilter.add(key, now)
+		}
+		c.mu.RUnlock()
+	}
+}
+
+// SetNegative records that key does not exist upstream for ttl. See
+// Cache.SetNegative.
+func (ns *Namespace) SetNegative(key string, ttl time.Duration) error {
+	return ns.cache.SetNegative(ns.prefix+key, ttl)
+}
@@ -0,0 +1,109 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetNegative(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock})
+	defer c.Stop()
+
+	c.Set("user:1", "alice")
+	if err := c.SetNegative("user:1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Get("user:1")
+	if err != ErrNotFoundCached || !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Expected ErrNotFoundCached matching ErrKeyNotFound, got %v", err)
+	}
+	if _, err := c.Get("user:2"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound for an unknown key, got %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := c.Get("user:1"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound after the ttl, got %v", err)
+	}
+
+	c.SetNegative("user:1", time.Minute)
+	c.Set("user:1", "bob")
+	if v, err := c.Get("user:1"); err != nil || v != "bob" {
+		t.Fatalf("Expected bob after Set, got %v, %v", v, err)
+	}
+
+	if err := c.SetNegative("user:1", 0); err != ErrInvalidTTL {
+		t.Fatalf("Expected ErrInvalidTTL for a ttl of 0, got %v", err)
+	}
+}
+
+func TestGetOrSetNegative(t *testing.T) {
+	c := New(Options{NegativeTTL: time.Minute})
+	defer c.Stop()
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrKeyNotFound
+	}
+	if _, err := c.GetOrSet("user:1", fn); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound from the first GetOrSet, got %v", err)
+	}
+	if _, err := c.GetOrSet("user:1", fn); err != ErrNotFoundCached {
+		t.Fatalf("Expected ErrNotFoundCached from the second GetOrSet, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Expected fn to be called once, got %d calls", n)
+	}
+
+	other := errors.New("database down")
+	c.GetOrSet("user:2", func() (interface{}, error) { return nil, other })
+	if _, err := c.Get("user:2"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound after a failure, got %v", err)
+	}
+}
+
+func TestLoaderNegative(t *testing.T) {
+	var calls int32
+	c := New(Options{
+		NegativeTTL: time.Minute,
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, 0, ErrKeyNotFound
+		},
+	})
+	defer c.Stop()
+
+	c.Get("missing")
+	if _, err := c.Get("missing"); err != ErrNotFoundCached {
+		t.Fatalf("Expected ErrNotFoundCached, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("Expected the loader to be called once, got %d calls", n)
+	}
+
+	c.Flush()
+	c.Get("missing")
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("Expected the loader to be called again after Flush, got %d calls", n)
+	}
+}
+
+func TestNamespaceSetNegative(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	ns := c.Namespace("users", NamespaceOptions{})
+
+	ns.SetNegative("1", time.Minute)
+	if _, err := ns.Get("1"); err != ErrNotFoundCached {
+		t.Fatalf("Expected ErrNotFoundCached, got %v", err)
+	}
+	ns.Flush()
+	if _, err := ns.Get("1"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound after Flush, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"time"
+)
+
+// Option configures a cache created with NewCache.
+type Option func(*Options)
+
+// NewCache returns a cache configured by opts, applied in order to zero
+// Options, or an error matching ErrInvalidOptions if the resulting
+// configuration is invalid, as reported by Options.Validate. It is New
+// with validation; use WithOptions to start from an Options struct.
+func NewCache(opts ...Option) (*Cache, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return New(options), nil
+}
+
+// WithOptions replaces the whole configuration with options, typically as
+// the first option, so that later options change it.
+func WithOptions(options Options) Option {
+	return func(o *Options) { *o = options }
+}
+
+// WithDefaultExpiration sets Options.DefaultExpiration.
+func WithDefaultExpiration(d time.Duration) Option {
+	return func(o *Options) { o.DefaultExpiration = d }
+}
+
+// WithCleanupInterval sets Options.CleanupInterval.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(o *Options) { o.CleanupInterval = d }
+}
+
+// WithMaxEntries sets Options.MaxEntries.
+func WithMaxEntries(n int) Option {
+	return func(o *Options) { o.MaxEntries = n }
+}
+
+// WithMaxCost sets Options.MaxCost and Options.Cost.
+func WithMaxCost(max int64, cost func(key string, value interface{}) int64) Option {
+	return func(o *Options) { o.MaxCost, o.Cost = max, cost }
+}
+
+// WithEvictionPolicy sets Options.EvictionPolicy.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(o *Options) { o.EvictionPolicy = policy }
+}
+
+// WithOnEvicted sets Options.OnEvicted.
+func WithOnEvicted(fn func(key string, value interface{})) Option {
+	return func(o *Options) { o.OnEvicted = fn }
+}
+
+// WithCodec sets Options.Codec.
+func WithCodec(codec Codec) Option {
+	return func(o *Options) { o.Codec = codec }
+}
+
+// WithClock sets Options.Clock.
+func WithClock(clock Clock) Option {
+	return func(o *Options) { o.Clock = clock }
+}
+
+// WithLogger sets Options.Logger and Options.LogLevel.
+func WithLogger(logger *slog.Logger, level slog.Level) Option {
+	return func(o *Options) { o.Logger, o.LogLevel = logger, level }
+}
+
+// WithLoader sets Options.Loader.
+func WithLoader(loader Loader) Option {
+	return func(o *Options) { o.Loader = loader }
+}
+
+// WithBackingStore sets Options.BackingStore.
+func WithBackingStore(store BackingStore) Option {
+	return func(o *Options) { o.BackingStore = store }
+}
+
+// Validate reports configurations that New accepts but that cannot work
+// as intended: negative durations, sizes and limits, fractions out of
+// range, and options that conflict or have no effect without another,
+// such as an EvictionPolicy without any capacity limit. The error matches
+// ErrInvalidOptions and lists every problem found.
+func (o Options) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidOptions}, args...)...))
+		}
+	}
+	nonNegative := func(name string, n int64) {
+		check(n >= 0, "%s is negative", name)
+	}
+	fraction := func(name string, f float64) {
+		check(f >= 0 && f <= 1, "%s %v is not between 0 and 1", name, f)
+	}
+
+	nonNegative("DefaultExpiration", int64(o.DefaultExpiration))
+	nonNegative("CleanupInterval", int64(o.CleanupInterval))
+	nonNegative("NegativeTTL", int64(o.NegativeTTL))
+	nonNegative("RefreshAhead", int64(o.RefreshAhead))
+	nonNegative("MaxEntries", int64(o.MaxEntries))
+	nonNegative("MaxCost", o.MaxCost)
+	nonNegative("MaxValueSize", o.MaxValueSize)
+	nonNegative("MaxKeyLength", int64(o.MaxKeyLength))
+	nonNegative("CompressionThreshold", int64(o.CompressionThreshold))
+	nonNegative("HotKeys", int64(o.HotKeys))
+	nonNegative("EventLogSize", int64(o.EventLogSize))
+	nonNegative("Async.BufferSize", int64(o.Async.BufferSize))
+	nonNegative("AutoTune.Interval", int64(o.AutoTune.Interval))
+	nonNegative("MemoryPressure.Interval", int64(o.MemoryPressure.Interval))
+	nonNegative("MissFilter.Capacity", int64(o.MissFilter.Capacity))
+	nonNegative("WriteBehind.QueueSize", int64(o.WriteBehind.QueueSize))
+
+	fraction("SoftLimit", o.SoftLimit)
+	fraction("MemoryPressure.LimitFraction", o.MemoryPressure.LimitFraction)
+	fraction("MemoryPressure.EvictFraction", o.MemoryPressure.EvictFraction)
+	fraction("MissFilter.FalsePositiveRate", o.MissFilter.FalsePositiveRate)
+
+	limited := o.MaxEntries > 0 || o.MaxCost > 0
+	// Without a threshold, memory pressure is watched if the process has
+	// a memory limit.
+	watched := o.MemoryPressure.Threshold > 0 || debug.SetMemoryLimit(-1) != math.MaxInt64
+	check(o.SoftLimit == 0 || limited, "SoftLimit needs MaxEntries or MaxCost")
+	check(o.EvictionPolicy == nil || limited || watched, "EvictionPolicy needs MaxEntries, MaxCost or MemoryPressure")
+	check(!o.AutoTune.Enabled || limited, "AutoTune needs MaxEntries or MaxCost")
+	check(o.AutoTune.Max == 0 || o.AutoTune.Min <= o.AutoTune.Max, "AutoTune.Min %d is above AutoTune.Max %d", o.AutoTune.Min, o.AutoTune.Max)
+	check(!o.WriteBehind.Enabled || o.BackingStore != nil, "WriteBehind needs a BackingStore")
+	check(!o.ReadOptimized || !o.CopyOnWrite, "ReadOptimized and CopyOnWrite are exclusive")
+	check(o.Storage >= MapStorage && o.Storage <= ArenaStorage, "unknown Storage %d", o.Storage)
+	check(o.Storage == MapStorage || (!o.ReadOptimized && !o.CopyOnWrite), "ReadOptimized and CopyOnWrite need MapStorage")
+
+	return errors.Join(errs...)
+}
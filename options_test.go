@@ -0,0 +1,67 @@
+package gocache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCache(t *testing.T) {
+	var evicted []string
+	cache, err := NewCache(
+		WithOptions(Options{AllowNilValues: true}),
+		WithDefaultExpiration(time.Minute),
+		WithMaxEntries(2),
+		WithEvictionPolicy(NewFIFOPolicy()),
+		WithOnEvicted(func(key string, _ interface{}) { evicted = append(evicted, key) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Stop()
+
+	cache.Set("a", nil)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("Expected a to be evicted first, got %v", evicted)
+	}
+	if _, expires, _ := cache.GetWithExpiration("c"); expires.IsZero() {
+		t.Error("Expected the default expiration to be applied")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, tt := range []struct {
+		options Options
+		problem string
+	}{
+		{Options{DefaultExpiration: -time.Second}, "DefaultExpiration is negative"},
+		{Options{MaxEntries: -1}, "MaxEntries is negative"},
+		{Options{SoftLimit: 1.5, MaxEntries: 10}, "SoftLimit 1.5 is not between 0 and 1"},
+		{Options{SoftLimit: 0.8}, "SoftLimit needs MaxEntries or MaxCost"},
+		{Options{ReadOptimized: true, CopyOnWrite: true}, "ReadOptimized and CopyOnWrite are exclusive"},
+		{Options{Storage: BytesStorage, CopyOnWrite: true}, "need MapStorage"},
+		{Options{Storage: Storage(9)}, "unknown Storage 9"},
+		{Options{WriteBehind: WriteBehindOptions{Enabled: true}}, "WriteBehind needs a BackingStore"},
+		{Options{AutoTune: AutoTuneOptions{Enabled: true}}, "AutoTune needs MaxEntries or MaxCost"},
+		{Options{MaxEntries: 10, AutoTune: AutoTuneOptions{Enabled: true, Min: 100, Max: 50}}, "AutoTune.Min 100 is above AutoTune.Max 50"},
+	} {
+		err := tt.options.Validate()
+		if !errors.Is(err, ErrInvalidOptions) || !strings.Contains(err.Error(), tt.problem) {
+			t.Errorf("Expected %q, got %v", tt.problem, err)
+		}
+	}
+
+	err := Options{CleanupInterval: -1, MaxCost: -1}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "CleanupInterval") || !strings.Contains(err.Error(), "MaxCost") {
+		t.Errorf("Expected every problem to be reported, got %v", err)
+	}
+	if err := (Options{MaxEntries: 100, SoftLimit: 0.8, EvictionPolicy: NewLRUPolicy()}).Validate(); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+	if _, err := NewCache(WithCleanupInterval(-time.Second)); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected NewCache to reject invalid options, got %v", err)
+	}
+}
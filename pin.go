@@ -0,0 +1,50 @@
+package gocache
+
+// Pin exempts the item stored under key from eviction when the cache is
+// full, for small datasets that must stay resident alongside a large,
+// churning cache. A pinned item still expires, and the pin is dropped when
+// the item is deleted, expires or is flushed; overwriting the item keeps
+// it. If only pinned items are left to evict, the cache exceeds MaxEntries
+// or MaxCost rather than evict them.
+//
+// Returns ErrKeyNotFound if the key does not exist or has expired.
+func (c *Cache) Pin(key string) error {
+	c.applyQueued(key)
+	now := c.clock.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, found := c.items.get(key)
+	if !found || item.expiredAt(now) {
+		return ErrKeyNotFound
+	}
+	if c.pinned == nil {
+		c.pinned = make(map[string]struct{})
+	}
+	if _, pinned := c.pinned[key]; !pinned && c.policy != nil {
+		// Pinned keys are kept out of the policy, so that it never offers
+		// them as victims.
+		c.policyMu.Lock()
+		c.policy.OnRemove(key)
+		c.policyMu.Unlock()
+	}
+	c.pinned[key] = struct{}{}
+	return nil
+}
+
+// Unpin makes a pinned item evictable again. It reports whether the key
+// was pinned.
+func (c *Cache) Unpin(key string) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	_, pinned := c.pinned[key]
+	delete(c.pinned, key)
+	if _, found := c.items.get(key); pinned && found && c.policy != nil {
+		c.policyMu.Lock()
+		c.policy.OnAdd(key)
+		c.policyMu.Unlock()
+	}
+	return pinned
+}
@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// builtinPolicies returns a new instance of each built-in eviction policy
+// for a cache of capacity items.
+func builtinPolicies(capacity int) map[string]EvictionPolicy {
+	return map[string]EvictionPolicy{
+		"LRU":     NewLRUPolicy(),
+		"TinyLFU": NewTinyLFUPolicy(capacity),
+		"CLOCK":   NewClockPolicy(),
+		"FIFO":    NewFIFOPolicy(),
+		"random":  NewRandomPolicy(),
+	}
+}
+
+func TestPinSurvivesEviction(t *testing.T) {
+	for name := range builtinPolicies(3) {
+		t.Run(name, func(t *testing.T) {
+			testPinSurvivesEviction(t, builtinPolicies(3)[name])
+		})
+	}
+}
+
+func testPinSurvivesEviction(t *testing.T, policy EvictionPolicy) {
+	c := New(Options{MaxEntries: 3, EvictionPolicy: policy})
+	defer c.Stop()
+
+	c.Set("config", "resident")
+	if err := c.Pin("config"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprint("churn", i), i)
+	}
+	if _, err := c.Get("config"); err != nil {
+		t.Fatalf("Expected the pinned item to survive eviction, got %v", err)
+	}
+	if n := c.ItemCount(); n != 3 {
+		t.Fatalf("Expected 3 items, got %d", n)
+	}
+
+	if !c.Unpin("config") {
+		t.Fatal("Expected Unpin to report the key as pinned")
+	}
+	// Read the new keys too, so that TinyLFU prefers them over config.
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("more", i)
+		c.Set(key, i)
+		c.Get(key)
+		c.Get(key)
+	}
+	if _, err := c.Get("config"); err != ErrKeyNotFound {
+		t.Fatalf("Expected the unpinned item to be evicted, got %v", err)
+	}
+}
+
+func TestPinnedItemsExceedLimit(t *testing.T) {
+	c := New(Options{MaxEntries: 2})
+	defer c.Stop()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Pin("a")
+	c.Pin("b")
+	c.Set("c", 3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := c.Get(key); err != nil {
+			t.Fatalf("Expected pinned %s to be kept over the limit, got %v", key, err)
+		}
+	}
+}
+
+func TestPinExpiresAndDeletes(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock})
+	defer c.Stop()
+
+	if err := c.Pin("missing"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound for a missing key, got %v", err)
+	}
+
+	c.SetWithExpiration("short", 1, time.Minute)
+	c.Pin("short")
+	clock.Advance(2 * time.Minute)
+	if _, err := c.Get("short"); err != ErrKeyExpired {
+		t.Fatalf("Expected ErrKeyExpired for an expired pinned item, got %v", err)
+	}
+
+	c.Set("deleted", 1)
+	c.Pin("deleted")
+	c.Delete("deleted")
+	if c.Unpin("deleted") {
+		t.Fatal("Expected Delete to drop the pin")
+	}
+}
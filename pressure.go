@@ -0,0 +1,135 @@
+package gocache
+
+import (
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Default memory pressure settings.
+const (
+	DefaultMemoryPressureInterval      = time.Second
+	DefaultMemoryPressureLimitFraction = 0.9
+	DefaultMemoryPressureEvictFraction = 0.1
+)
+
+// MemoryPressureOptions configures shedding of items when the process runs
+// short of memory. A watcher samples the memory the Go runtime holds from
+// the operating system, as runtime.MemStats reports it, and when it is
+// above the threshold, evicts the coldest items according to the eviction
+// policy, so that the cache gives memory back before the process is
+// killed for running out of it. Pinned items are not evicted, and items of
+// lower priority go first.
+type MemoryPressureOptions struct {
+	// Threshold is the memory use, in bytes, above which items are evicted.
+	// If 0, it is LimitFraction of the soft memory limit set with
+	// debug.SetMemoryLimit or GOMEMLIMIT; if there is no limit either, the
+	// watcher is disabled.
+	Threshold uint64
+
+	// LimitFraction is the fraction of the memory limit used as the
+	// threshold when Threshold is 0. If 0, 0.9 is used.
+	LimitFraction float64
+
+	// Interval is the time between samples. Sampling briefly stops the
+	// world, so keep it in seconds rather than milliseconds. If 0, one
+	// second is used.
+	Interval time.Duration
+
+	// EvictFraction is the fraction of the items evicted each time the
+	// threshold is exceeded. Memory is only returned once the garbage
+	// collector has run, so the next sample may still be above the
+	// threshold; an Interval of several garbage collection cycles avoids
+	// evicting more than needed. If 0, 0.1 is used.
+	EvictFraction float64
+
+	// OnPressure, if set, is called after items have been evicted, with
+	// the memory use that triggered it and the number of items evicted.
+	OnPressure func(used uint64, evicted int)
+}
+
+// readMemory returns the memory the Go runtime holds from the operating
+// system, the quantity the soft memory limit applies to. Tests replace it.
+var readMemory = func() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Sys - m.HeapReleased
+}
+
+// memoryWatcher evicts items while memory use is above a threshold.
+type memoryWatcher struct {
+	options MemoryPressureOptions
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func newMemoryWatcher(options MemoryPressureOptions) *memoryWatcher {
+	if options.Threshold == 0 {
+		limit := debug.SetMemoryLimit(-1)
+		if limit == math.MaxInt64 {
+			return nil
+		}
+		if options.LimitFraction <= 0 || options.LimitFraction > 1 {
+			options.LimitFraction = DefaultMemoryPressureLimitFraction
+		}
+		options.Threshold = uint64(float64(limit) * options.LimitFraction)
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultMemoryPressureInterval
+	}
+	if options.EvictFraction <= 0 || options.EvictFraction > 1 {
+		options.EvictFraction = DefaultMemoryPressureEvictFraction
+	}
+	return &memoryWatcher{options: options, stop: make(chan struct{})}
+}
+
+// watchMemory samples memory use every interval until the watcher stops.
+func (c *Cache) watchMemory(ticker Ticker) {
+	defer ticker.Stop()
+
+	w := c.memory
+	for {
+		select {
+		case <-ticker.C():
+			if used := readMemory(); used > w.options.Threshold {
+				n := c.shed(w.options.EvictFraction)
+				c.log(slog.LevelInfo, "evicted under memory pressure", "used", used, "threshold", w.options.Threshold, "evicted", n)
+				if w.options.OnPressure != nil {
+					w.options.OnPressure(used, n)
+				}
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// shed evicts the given fraction of the items, at least one, and returns
+// the number evicted.
+func (c *Cache) shed(fraction float64) int {
+	c.mu.Lock()
+	n := int(math.Ceil(float64(c.items.len()) * fraction))
+	var evicted []keyValue
+	for len(evicted) < n {
+		kv, ok := c.evictOne()
+		if !ok {
+			break
+		}
+		evicted = append(evicted, kv)
+	}
+	c.unlock()
+
+	c.notifyEvicted(evicted)
+	return len(evicted)
+}
+
+// stopMemoryWatcher stops the memory watcher, if any. It is safe to call
+// more than once.
+func (c *Cache) stopMemoryWatcher() {
+	if c.memory != nil {
+		c.memory.once.Do(func() { close(c.memory.stop) })
+	}
+}
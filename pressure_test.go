@@ -0,0 +1,85 @@
+package gocache
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeMemory replaces readMemory for the duration of a test.
+func fakeMemory(t *testing.T, used *uint64) {
+	read := readMemory
+	readMemory = func() uint64 { return atomic.LoadUint64(used) }
+	t.Cleanup(func() { readMemory = read })
+}
+
+func TestMemoryPressureEvictsColdItems(t *testing.T) {
+	used := uint64(500)
+	fakeMemory(t, &used)
+
+	clock := newFakeClock()
+	shed := make(chan int, 1)
+	c := New(Options{
+		Clock: clock,
+		MemoryPressure: MemoryPressureOptions{
+			Threshold:  1000,
+			OnPressure: func(used uint64, evicted int) { shed <- evicted },
+		},
+	})
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	c.Pin("0")
+	for i := 50; i < 100; i++ {
+		c.Get(fmt.Sprint(i))
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case n := <-shed:
+		t.Fatalf("evicted %d items below the threshold", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	atomic.StoreUint64(&used, 2000)
+	clock.Advance(time.Second)
+	select {
+	case n := <-shed:
+		if n != 10 {
+			t.Fatalf("evicted %d items, want 10", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no items evicted above the threshold")
+	}
+	if n := c.ItemCount(); n != 90 {
+		t.Fatalf("ItemCount = %d, want 90", n)
+	}
+	if _, err := c.Get("0"); err != nil {
+		t.Fatalf("pinned item evicted: %v", err)
+	}
+	for i := 50; i < 100; i++ {
+		if _, err := c.Get(fmt.Sprint(i)); err != nil {
+			t.Fatalf("recently used item %d evicted", i)
+		}
+	}
+	if stats := c.Stats(); stats.Evictions != 10 {
+		t.Fatalf("Evictions = %d, want 10", stats.Evictions)
+	}
+}
+
+func TestMemoryPressureUsesMemoryLimit(t *testing.T) {
+	if w := newMemoryWatcher(MemoryPressureOptions{}); w != nil {
+		t.Fatal("watcher enabled without threshold or memory limit")
+	}
+
+	old := debug.SetMemoryLimit(1 << 30)
+	defer debug.SetMemoryLimit(old)
+	w := newMemoryWatcher(MemoryPressureOptions{LimitFraction: 0.5})
+	if w == nil || w.options.Threshold != 1<<29 {
+		t.Fatalf("watcher = %+v, want a threshold of half the limit", w)
+	}
+}
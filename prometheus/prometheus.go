@@ -0,0 +1,112 @@
+// Package prometheus exports the Stats of a gocache.Cache to Prometheus,
+// including the value-size and TTL histograms of
+// gocache.Options.TrackDistributions. It is a module of its own, so that
+// only applications using Prometheus depend on it:
+//
+//	prometheus.MustRegister(gocacheprom.NewCollector(c, prometheus.Labels{"cache": "sessions"}))
+//
+// The metrics are read from Stats when Prometheus scrapes them. Stats
+// counts from the last ResetStats, which Prometheus sees as a counter
+// reset.
+package prometheus
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"gocache"
+)
+
+// Collector is a prometheus.Collector of a cache's Stats.
+type Collector struct {
+	cache *gocache.Cache
+
+	hits                *prometheus.Desc
+	misses              *prometheus.Desc
+	expirations         *prometheus.Desc
+	evictions           *prometheus.Desc
+	items               *prometheus.Desc
+	expiredOverwrites   *prometheus.Desc
+	softLimitWarnings   *prometheus.Desc
+	valueSizes          *prometheus.Desc
+	ttls                *prometheus.Desc
+	valuesWithoutExpiry *prometheus.Desc
+}
+
+// NewCollector returns a collector of the Stats of c, whose metrics carry
+// labels, such as the name of the cache when a process has several.
+func NewCollector(c *gocache.Cache, labels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("gocache_"+name, help, nil, labels)
+	}
+	return &Collector{
+		cache:               c,
+		hits:                desc("hits_total", "Get calls that found an unexpired item."),
+		misses:              desc("misses_total", "Get calls that found no item or an expired one."),
+		expirations:         desc("expirations_total", "Items removed because they expired."),
+		evictions:           desc("evictions_total", "Items removed to make room for new ones."),
+		items:               desc("items", "Items currently stored, including expired ones."),
+		expiredOverwrites:   desc("expired_overwrites_total", "Set calls that overwrote an expired item before it was cleaned up."),
+		softLimitWarnings:   desc("soft_limit_warnings_total", "Times usage crossed Options.SoftLimit."),
+		valueSizes:          desc("value_size_bytes", "Sizes of the values stored, if Options.TrackDistributions is set."),
+		ttls:                desc("ttl_seconds", "TTLs of the values stored with an expiration, if Options.TrackDistributions is set."),
+		valuesWithoutExpiry: desc("values_without_expiration_total", "Values stored without an expiration, if Options.TrackDistributions is set."),
+	}
+}
+
+// Describe sends the descriptors of the collector's metrics to ch.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.hits, c.misses, c.expirations, c.evictions, c.items,
+		c.expiredOverwrites, c.softLimitWarnings,
+		c.valueSizes, c.ttls, c.valuesWithoutExpiry,
+	} {
+		ch <- d
+	}
+}
+
+// Collect reads the cache's Stats and sends its metrics to ch.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.cache.Stats()
+	counter := func(d *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v))
+	}
+	counter(c.hits, s.Hits)
+	counter(c.misses, s.Misses)
+	counter(c.expirations, s.Expirations)
+	counter(c.evictions, s.Evictions)
+	ch <- prometheus.MustNewConstMetric(c.items, prometheus.GaugeValue, float64(s.Items))
+	counter(c.expiredOverwrites, s.ExpiredOverwrites)
+	counter(c.softLimitWarnings, s.SoftLimitWarnings)
+
+	sizeBounds := make([]float64, len(gocache.ValueSizeBounds))
+	for i, b := range gocache.ValueSizeBounds {
+		sizeBounds[i] = float64(b)
+	}
+	ch <- histogram(c.valueSizes, sizeBounds, s.ValueSizes[:])
+
+	ttlBounds := make([]float64, len(gocache.TTLBounds))
+	for i, b := range gocache.TTLBounds {
+		ttlBounds[i] = b.Seconds()
+	}
+	// The last bucket of Stats.TTLs counts the values that never expire,
+	// which have no place on the histogram's scale.
+	ch <- histogram(c.ttls, ttlBounds, s.TTLs[:len(s.TTLs)-1])
+	counter(c.valuesWithoutExpiry, s.TTLs[len(s.TTLs)-1])
+}
+
+// histogram returns a histogram of the counts of Stats buckets, one per
+// bound and a last one for larger observations. The cache does not track
+// the sum of the observations, so it is reported as NaN.
+func histogram(d *prometheus.Desc, bounds []float64, counts []uint64) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(bounds))
+	var total uint64
+	for i, n := range counts {
+		total += n
+		if i < len(bounds) {
+			buckets[bounds[i]] = total
+		}
+	}
+	return prometheus.MustNewConstHistogram(d, total, math.NaN(), buckets)
+}
@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"gocache"
+)
+
+// gather returns the metrics of c by name.
+func gather(t *testing.T, c *Collector) map[string]*dto.Metric {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	metrics := make(map[string]*dto.Metric)
+	for _, f := range families {
+		metrics[f.GetName()] = f.GetMetric()[0]
+	}
+	return metrics
+}
+
+func TestCollector(t *testing.T) {
+	c := gocache.New(gocache.Options{
+		TrackDistributions: true,
+		Cost:               func(_ string, value interface{}) int64 { return int64(len(value.(string))) },
+	})
+	defer c.Stop()
+	c.SetWithExpiration("short", "x", 30*time.Second)
+	c.SetWithExpiration("long", string(make([]byte, 100)), 30*24*time.Hour)
+	c.Set("forever", "y")
+	c.Get("short")
+	c.Get("missing")
+
+	metrics := gather(t, NewCollector(c, prometheus.Labels{"cache": "test"}))
+	for name, want := range map[string]float64{
+		"gocache_hits_total":                      1,
+		"gocache_misses_total":                    1,
+		"gocache_evictions_total":                 0,
+		"gocache_values_without_expiration_total": 1,
+	} {
+		if got := metrics[name].GetCounter().GetValue(); got != want {
+			t.Errorf("Expected %s of %v, got %v", name, want, got)
+		}
+	}
+	if got := metrics["gocache_items"].GetGauge().GetValue(); got != 3 {
+		t.Errorf("Expected 3 items, got %v", got)
+	}
+	if label := metrics["gocache_items"].GetLabel()[0]; label.GetName() != "cache" || label.GetValue() != "test" {
+		t.Errorf("Expected the cache label, got %v", label)
+	}
+
+	sizes := metrics["gocache_value_size_bytes"].GetHistogram()
+	if sizes.GetSampleCount() != 3 || !math.IsNaN(sizes.GetSampleSum()) {
+		t.Errorf("Expected 3 sizes with an unknown sum, got %d, %v", sizes.GetSampleCount(), sizes.GetSampleSum())
+	}
+	if b := sizes.GetBucket()[0]; b.GetUpperBound() != 64 || b.GetCumulativeCount() != 2 {
+		t.Errorf("Expected 2 values of at most 64 bytes, got %d of at most %v", b.GetCumulativeCount(), b.GetUpperBound())
+	}
+	if b := sizes.GetBucket()[1]; b.GetUpperBound() != 256 || b.GetCumulativeCount() != 3 {
+		t.Errorf("Expected 3 values of at most 256 bytes, got %d of at most %v", b.GetCumulativeCount(), b.GetUpperBound())
+	}
+
+	// The value stored without an expiration is not on the TTL scale, and
+	// the 30 day TTL is above the last bound.
+	ttls := metrics["gocache_ttl_seconds"].GetHistogram()
+	buckets := ttls.GetBucket()
+	if ttls.GetSampleCount() != 2 || len(buckets) != len(gocache.TTLBounds) {
+		t.Fatalf("Expected 2 TTLs in %d buckets, got %d in %d", len(gocache.TTLBounds), ttls.GetSampleCount(), len(buckets))
+	}
+	if b := buckets[2]; b.GetUpperBound() != 60 || b.GetCumulativeCount() != 1 {
+		t.Errorf("Expected 1 TTL of at most a minute, got %d of at most %v", b.GetCumulativeCount(), b.GetUpperBound())
+	}
+	if b := buckets[len(buckets)-1]; b.GetCumulativeCount() != 1 {
+		t.Errorf("Expected 1 TTL of at most a week, got %d", b.GetCumulativeCount())
+	}
+}
@@ -0,0 +1,121 @@
+package gocache
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// QuotaResource names a capacity limit of the cache.
+type QuotaResource string
+
+const (
+	// QuotaEntries is the item count limited by Options.MaxEntries.
+	QuotaEntries QuotaResource = "entries"
+	// QuotaCost is the total cost limited by Options.MaxCost.
+	QuotaCost QuotaResource = "cost"
+)
+
+// QuotaWarning reports that usage of a resource crossed the soft limit.
+type QuotaWarning struct {
+	Resource  QuotaResource
+	Used      int64 // Current usage
+	Threshold int64 // Usage at which the warning fires
+	Limit     int64 // Hard limit at which eviction starts
+}
+
+// costOf returns the cost of storing value under key.
+func (c *Cache) costOf(key string, value interface{}) int64 {
+	if c.cost == nil {
+		return 1
+	}
+	return c.cost(key, value)
+}
+
+// checkValueSize returns ErrValueTooLarge if value, whose cost has been
+// computed already, exceeds MaxValueSize.
+func (c *Cache) checkValueSize(key string, value interface{}, cost int64) error {
+	if c.maxValueSize <= 0 {
+		return nil
+	}
+
+	size, err := c.sizeOf(value, cost)
+	if err != nil {
+		return err
+	}
+	if size > c.maxValueSize {
+		return fmt.Errorf("%w: %q is %d, limit %d", ErrValueTooLarge, key, size, c.maxValueSize)
+	}
+	return nil
+}
+
+// sizeOf returns the size of value, whose cost has been computed already:
+// its cost if Options.Cost is set, and otherwise the length of its encoding,
+// or of its content for a Blob.
+func (c *Cache) sizeOf(value interface{}, cost int64) (int64, error) {
+	if c.cost != nil {
+		return cost, nil
+	}
+	if blob, ok := value.(Blob); ok {
+		return blob.size, nil
+	}
+	data, err := c.codec.Marshal(&value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// checkSoftLimits returns a warning for each resource whose usage has
+// crossed the soft limit since the last check. It must be called with c.mu
+// held.
+func (c *Cache) checkSoftLimits() []QuotaWarning {
+	if c.softLimit <= 0 {
+		return nil
+	}
+
+	var warnings []QuotaWarning
+	check := func(resource QuotaResource, used, limit int64) {
+		if limit <= 0 {
+			return
+		}
+		threshold := c.softThreshold(limit)
+		above := used >= threshold
+		if above && !c.softCrossed[resource] {
+			atomic.AddUint64(&c.counters.softLimitWarnings, 1)
+			if c.onSoftLimit != nil {
+				warnings = append(warnings, QuotaWarning{
+					Resource:  resource,
+					Used:      used,
+					Threshold: threshold,
+					Limit:     limit,
+				})
+			}
+		}
+		c.softCrossed[resource] = above
+	}
+
+	check(QuotaEntries, int64(c.items.len()), int64(c.maxEntries))
+	check(QuotaCost, c.totalCost, c.maxCost)
+	return warnings
+}
+
+// rearmSoftLimits clears the crossed state of resources whose usage has
+// dropped below the soft limit, so the next crossing warns again. It must
+// be called with c.mu held.
+func (c *Cache) rearmSoftLimits() {
+	if c.softLimit <= 0 {
+		return
+	}
+	if c.softCrossed[QuotaEntries] && int64(c.items.len()) < c.softThreshold(int64(c.maxEntries)) {
+		c.softCrossed[QuotaEntries] = false
+	}
+	if c.softCrossed[QuotaCost] && c.totalCost < c.softThreshold(c.maxCost) {
+		c.softCrossed[QuotaCost] = false
+	}
+}
+
+// softThreshold returns the usage at which the soft limit for limit is
+// crossed.
+func (c *Cache) softThreshold(limit int64) int64 {
+	return int64(c.softLimit * float64(limit))
+}
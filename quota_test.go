@@ -0,0 +1,89 @@
+package gocache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCacheMaxCost(t *testing.T) {
+	cache := New(Options{
+		MaxCost: 10,
+		Cost: func(key string, value interface{}) int64 {
+			return int64(len(value.(string)))
+		},
+	})
+
+	cache.Set("key1", "aaaa")
+	cache.Set("key2", "bbbb")
+	cache.Set("key3", "cccc") // evicts key1
+
+	if _, err := cache.Get("key1"); err != ErrKeyNotFound {
+		t.Errorf("Expected key1 to be evicted, got %v", err)
+	}
+	if count := cache.ItemCount(); count != 2 {
+		t.Errorf("Expected 2 items, got %d", count)
+	}
+
+	if err := cache.Set("huge", "xxxxxxxxxxx"); err != ErrCostTooLarge {
+		t.Errorf("Expected ErrCostTooLarge, got %v", err)
+	}
+}
+
+func TestCacheSoftLimitWarnings(t *testing.T) {
+	var warnings []QuotaWarning
+	cache := New(Options{
+		MaxEntries: 10,
+		SoftLimit:  0.8,
+		OnSoftLimit: func(w QuotaWarning) {
+			warnings = append(warnings, w)
+		},
+	})
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		cache.Set(key, key)
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(warnings))
+	}
+	w := warnings[0]
+	if w.Resource != QuotaEntries || w.Used != 8 || w.Threshold != 8 || w.Limit != 10 {
+		t.Errorf("Unexpected warning: %+v", w)
+	}
+
+	// Dropping below the threshold re-arms the warning.
+	cache.Delete("a")
+	cache.Delete("b")
+	cache.Set("a", "a")
+	cache.Set("b", "b")
+	if len(warnings) != 2 {
+		t.Errorf("Expected warning to fire again after dropping below, got %d", len(warnings))
+	}
+	if stats := cache.Stats(); stats.SoftLimitWarnings != 2 {
+		t.Errorf("Expected 2 soft limit warnings in stats, got %d", stats.SoftLimitWarnings)
+	}
+}
+
+func TestCacheMaxValueSize(t *testing.T) {
+	cache := New(Options{MaxValueSize: 1024})
+	defer cache.Stop()
+
+	if err := cache.Set("small", "ok"); err != nil {
+		t.Errorf("Expected small value to be accepted, got %v", err)
+	}
+	err := cache.Set("huge", make([]byte, 4096))
+	if !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	if _, err := cache.Get("huge"); err != ErrKeyNotFound {
+		t.Error("Expected oversized value not to be stored")
+	}
+
+	sized := New(Options{MaxValueSize: 10, Cost: func(key string, value interface{}) int64 {
+		return int64(len(value.(string)))
+	}})
+	defer sized.Stop()
+	if err := sized.Set("key", "twelve chars"); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected cost to be used as size, got %v", err)
+	}
+}
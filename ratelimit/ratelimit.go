@@ -0,0 +1,149 @@
+// Package ratelimit throttles requests per key, such as per client or per
+// API token, with counters kept in a gocache.Cache. Every limiter of a
+// cache shares it, so throttling needs no other dependency.
+//
+//	limiter := ratelimit.NewSlidingWindow(c, ratelimit.Options{Limit: 100, Window: time.Minute})
+//	if ok, err := limiter.Allow(clientIP); err == nil && !ok {
+//		http.Error(w, "too many requests", http.StatusTooManyRequests)
+//		return
+//	}
+package ratelimit
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gocache"
+)
+
+// DefaultKeyPrefix is prepended to the keys of the counters when
+// Options.KeyPrefix is empty.
+const DefaultKeyPrefix = "ratelimit:"
+
+// Limiter decides whether a request for a key is allowed.
+type Limiter interface {
+	// Allow reports whether a request for key is allowed, and counts it.
+	Allow(key string) (bool, error)
+	// AllowN is Allow for n requests at once, such as a batch.
+	AllowN(key string, n int) (bool, error)
+}
+
+// Options configures a limiter.
+type Options struct {
+	// Limit is the number of requests allowed per key in a Window. It must
+	// be positive.
+	Limit int
+
+	// Window is the length of the period over which requests are counted.
+	// It must be positive.
+	Window time.Duration
+
+	// KeyPrefix is prepended to keys to form the keys of the counters. If
+	// empty, DefaultKeyPrefix is used. Limiters sharing a cache need
+	// different prefixes.
+	KeyPrefix string
+
+	// Clock provides the time that windows are aligned to by
+	// SlidingWindow. It should be the clock of the cache. If nil, the
+	// system clock is used.
+	Clock gocache.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Limit <= 0 || o.Window <= 0 {
+		panic("ratelimit: Limit and Window must be positive")
+	}
+	if o.KeyPrefix == "" {
+		o.KeyPrefix = DefaultKeyPrefix
+	}
+	return o
+}
+
+// FixedWindow is a Limiter allowing Limit requests per key in a window
+// that starts with the first request for the key and lasts Window. It is
+// the cheapest limiter, with one counter per key, but lets through up to
+// twice Limit in a short burst straddling the end of a window.
+//
+// Rejected requests are counted too, so a client that keeps retrying
+// stays throttled until the window ends.
+type FixedWindow struct {
+	cache   *gocache.Cache
+	options Options
+}
+
+// NewFixedWindow returns a fixed-window limiter counting in c. It panics
+// if options.Limit or options.Window is not positive.
+func NewFixedWindow(c *gocache.Cache, options Options) *FixedWindow {
+	return &FixedWindow{cache: c, options: options.withDefaults()}
+}
+
+// Allow reports whether a request for key is allowed.
+func (l *FixedWindow) Allow(key string) (bool, error) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests for key are allowed.
+func (l *FixedWindow) AllowN(key string, n int) (bool, error) {
+	count, err := l.cache.IncrementWithExpiration(l.options.KeyPrefix+key, int64(n), l.options.Window)
+	if err != nil {
+		return false, err
+	}
+	return count <= int64(l.options.Limit), nil
+}
+
+// SlidingWindow is a Limiter allowing about Limit requests per key in any
+// period of length Window. It counts requests in windows aligned to
+// multiples of Window and estimates the count over the last Window as the
+// count of the current window plus the count of the previous one, weighted
+// by how much of it overlaps the last Window. This smooths the bursts a
+// FixedWindow lets through at window boundaries, at the cost of two
+// counters per key.
+//
+// Rejected requests are counted too, so a client that keeps retrying
+// stays throttled until it slows down.
+type SlidingWindow struct {
+	cache   *gocache.Cache
+	options Options
+}
+
+// NewSlidingWindow returns a sliding-window limiter counting in c. It
+// panics if options.Limit or options.Window is not positive.
+func NewSlidingWindow(c *gocache.Cache, options Options) *SlidingWindow {
+	return &SlidingWindow{cache: c, options: options.withDefaults()}
+}
+
+// Allow reports whether a request for key is allowed.
+func (l *SlidingWindow) Allow(key string) (bool, error) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests for key are allowed.
+func (l *SlidingWindow) AllowN(key string, n int) (bool, error) {
+	now := time.Now()
+	if l.options.Clock != nil {
+		now = l.options.Clock.Now()
+	}
+	window := int64(l.options.Window)
+	index := now.UnixNano() / window
+	elapsed := float64(now.UnixNano()%window) / float64(window)
+
+	prefix := l.options.KeyPrefix + key + ":"
+	// A counter lives for two windows, so that it is still there as the
+	// previous window of the next one.
+	current, err := l.cache.IncrementWithExpiration(prefix+strconv.FormatInt(index, 10), int64(n), 2*l.options.Window)
+	if err != nil {
+		return false, err
+	}
+	var previous int64
+	value, err := l.cache.Get(prefix + strconv.FormatInt(index-1, 10))
+	switch {
+	case err == nil:
+		previous, _ = value.(int64)
+	case !errors.Is(err, gocache.ErrKeyNotFound) && !errors.Is(err, gocache.ErrKeyExpired):
+		return false, err
+	}
+
+	estimate := float64(previous)*(1-elapsed) + float64(current)
+	return estimate <= float64(l.options.Limit), nil
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"gocache"
+	"gocache/gocachetest"
+)
+
+func TestFixedWindow(t *testing.T) {
+	clock := gocachetest.NewClock(time.Unix(1000, 0))
+	cache := gocache.New(gocache.Options{Clock: clock})
+	defer cache.Stop()
+	var limiter Limiter = NewFixedWindow(cache, Options{Limit: 3, Window: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		if ok, err := limiter.Allow("client"); err != nil || !ok {
+			t.Fatalf("Expected request %d to be allowed, got %v (%v)", i, ok, err)
+		}
+	}
+	if ok, _ := limiter.Allow("client"); ok {
+		t.Error("Expected the fourth request to be rejected")
+	}
+	if ok, _ := limiter.Allow("other"); !ok {
+		t.Error("Expected another key to have its own limit")
+	}
+
+	clock.Advance(61 * time.Second)
+	if ok, _ := limiter.AllowN("client", 3); !ok {
+		t.Error("Expected a new window to allow 3 requests")
+	}
+	if ok, _ := limiter.AllowN("client", 1); ok {
+		t.Error("Expected the window to be used up")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	// Start at the beginning of an aligned window.
+	clock := gocachetest.NewClock(time.Unix(600, 0))
+	cache := gocache.New(gocache.Options{Clock: clock})
+	defer cache.Stop()
+	limiter := NewSlidingWindow(cache, Options{Limit: 10, Window: time.Minute, Clock: clock})
+
+	if ok, err := limiter.AllowN("client", 10); err != nil || !ok {
+		t.Fatalf("Expected 10 requests to be allowed, got %v (%v)", ok, err)
+	}
+	if ok, _ := limiter.Allow("client"); ok {
+		t.Error("Expected the 11th request to be rejected")
+	}
+
+	// A quarter into the next window, the 11 requests of the previous
+	// one weigh 8.25, leaving room for 1 more.
+	clock.Advance(75 * time.Second)
+	if ok, _ := limiter.Allow("client"); !ok {
+		t.Error("Expected a request to be allowed as the window slides")
+	}
+	if ok, _ := limiter.Allow("client"); ok {
+		t.Error("Expected the next request to be rejected")
+	}
+
+	// Two windows later, nothing is left.
+	clock.Advance(2 * time.Minute)
+	if ok, _ := limiter.AllowN("client", 10); !ok {
+		t.Error("Expected a full window to be allowed")
+	}
+}
+
+func TestOptionsPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a zero Limit to panic")
+		}
+	}()
+	NewFixedWindow(gocache.New(gocache.Options{}), Options{Window: time.Second})
+}
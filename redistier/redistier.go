@@ -0,0 +1,148 @@
+// Package redistier provides a gocache.Tier on a Redis server, for use as
+// the L2 of a gocache.TieredCache. It speaks the Redis protocol directly,
+// so it needs no client library, and keeps a pool of connections.
+package redistier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gocache"
+	"gocache/internal/resp"
+)
+
+// Default option values.
+const (
+	DefaultDialTimeout = 5 * time.Second
+	DefaultPoolSize    = 8
+)
+
+// Options configures a Tier.
+type Options struct {
+	// Username and Password authenticate connections if Password is set.
+	Username string
+	Password string
+
+	// DB selects the database, if not 0.
+	DB int
+
+	// KeyPrefix is prepended to every key, to share a Redis server with
+	// other data.
+	KeyPrefix string
+
+	// DialTimeout bounds connecting to the server. If 0, it is
+	// DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// PoolSize is the number of idle connections kept for reuse. If 0, it
+	// is DefaultPoolSize.
+	PoolSize int
+}
+
+// Error is an error reply from the Redis server, such as
+// "WRONGPASS invalid username-password pair".
+type Error = resp.Error
+
+// Tier is a gocache.Tier on a Redis server. It is safe for concurrent use.
+type Tier struct {
+	addr    string
+	options Options
+	idle    chan *resp.Conn
+}
+
+var _ gocache.Tier = (*Tier)(nil)
+
+// New returns a tier on the Redis server at addr, such as
+// "localhost:6379". It connects lazily.
+func New(addr string, options Options) *Tier {
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DefaultDialTimeout
+	}
+	if options.PoolSize <= 0 {
+		options.PoolSize = DefaultPoolSize
+	}
+	return &Tier{addr: addr, options: options, idle: make(chan *resp.Conn, options.PoolSize)}
+}
+
+// Get returns the value of key, or gocache.ErrKeyNotFound.
+func (t *Tier) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := t.do(ctx, "GET", t.options.KeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, gocache.ErrKeyNotFound
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %v to GET", reply)
+	}
+	return []byte(s), nil
+}
+
+// Set stores value under key for ttl, rounded up to a millisecond, or
+// without expiration if ttl is 0.
+func (t *Tier) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", t.options.KeyPrefix + key, string(value)}
+	if ttl > 0 {
+		ms := (ttl + time.Millisecond - 1) / time.Millisecond
+		args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+	}
+	_, err := t.do(ctx, args...)
+	return err
+}
+
+// Delete removes key.
+func (t *Tier) Delete(ctx context.Context, key string) error {
+	_, err := t.do(ctx, "DEL", t.options.KeyPrefix+key)
+	return err
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// they are returned.
+func (t *Tier) Close() error {
+	for {
+		select {
+		case conn := <-t.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs a command on a pooled connection. Connections that fail, other
+// than with an error reply, are discarded.
+func (t *Tier) do(ctx context.Context, args ...string) (interface{}, error) {
+	var conn *resp.Conn
+	select {
+	case conn = <-t.idle:
+	default:
+		var err error
+		conn, err = resp.Dial(ctx, t.addr, resp.DialOptions{
+			Username: t.options.Username,
+			Password: t.options.Password,
+			DB:       t.options.DB,
+			Timeout:  t.options.DialTimeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := conn.Do(ctx, args...)
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case t.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
@@ -0,0 +1,102 @@
+package redistier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gocache"
+	"gocache/internal/resp"
+)
+
+// fakeRedisServer serves GET, SET with PX, DEL and AUTH from a map.
+func fakeRedisServer(t *testing.T, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				conn := resp.NewConn(nc)
+				authed := password == ""
+				for {
+					reply, err := conn.Read()
+					if err != nil {
+						return
+					}
+					args := reply.([]interface{})
+					mu.Lock()
+					switch cmd := args[0].(string); {
+					case cmd == "AUTH":
+						authed = args[len(args)-1] == password
+						if authed {
+							nc.Write([]byte("+OK\r\n"))
+						} else {
+							nc.Write([]byte("-WRONGPASS invalid password\r\n"))
+						}
+					case !authed:
+						nc.Write([]byte("-NOAUTH Authentication required.\r\n"))
+					case cmd == "GET":
+						if v, ok := values[args[1].(string)]; ok {
+							nc.Write([]byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"))
+						} else {
+							nc.Write([]byte("$-1\r\n"))
+						}
+					case cmd == "SET":
+						values[args[1].(string)] = args[2].(string)
+						nc.Write([]byte("+OK\r\n"))
+					case cmd == "DEL":
+						delete(values, args[1].(string))
+						nc.Write([]byte(":1\r\n"))
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestTier(t *testing.T) {
+	ctx := context.Background()
+	addr := fakeRedisServer(t, "secret")
+
+	tier := New(addr, Options{Password: "secret", KeyPrefix: "app:"})
+	defer tier.Close()
+	cache := gocache.NewTiered(gocache.New(gocache.Options{}), tier, gocache.TieredOptions{})
+	if err := cache.SetWithExpiration(ctx, "user:1", 30, time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cache.L1().Flush()
+	value, err := cache.Get(ctx, "user:1")
+	if err != nil || value != 30 {
+		t.Errorf("Expected the value from Redis, got %v (%v)", value, err)
+	}
+	if err := cache.Delete(ctx, "user:1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := tier.Get(ctx, "user:1"); !errors.Is(err, gocache.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	bad := New(addr, Options{Password: "wrong"})
+	defer bad.Close()
+	var redisErr Error
+	if _, err := bad.Get(ctx, "k"); !errors.As(err, &redisErr) {
+		t.Errorf("Expected an Error, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// refreshJobs tracks the goroutines reloading items in the background, so
+// that they can be canceled and waited for on shutdown.
+type refreshJobs struct {
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	onError func(key string, err error)
+}
+
+// start runs fn in a goroutine with a context canceled on shutdown. It
+// reports false if the jobs have been stopped.
+func (j *refreshJobs) start(fn func(ctx context.Context)) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stopped {
+		return false
+	}
+	if j.ctx == nil {
+		j.ctx, j.cancel = context.WithCancel(context.Background())
+	}
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		fn(j.ctx)
+	}()
+	return true
+}
+
+// reportRefresh passes an error of a background reload to
+// Options.OnRefreshError and logs it, unless it is due to shutdown or the
+// source no longer has the key.
+func (c *Cache) reportRefresh(ctx context.Context, key string, err error) {
+	if err == nil || errors.Is(err, ErrKeyNotFound) || ctx.Err() != nil {
+		return
+	}
+	c.log(slog.LevelWarn, "background reload failed", "key", key, "error", err)
+	if c.refreshJobs.onError != nil {
+		c.refreshJobs.onError(key, err)
+	}
+}
+
+// stopRefresh cancels the background reloads and waits for them to return,
+// or for ctx to be done. No reloads start afterwards.
+func (c *Cache) stopRefresh(ctx context.Context) error {
+	j := &c.refreshJobs
+	j.mu.Lock()
+	j.stopped = true
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshAhead reloads items read shortly before they expire.
+type refreshAhead struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+}
+
+func newRefreshAhead(options Options) *refreshAhead {
+	if options.RefreshAhead <= 0 || (options.Loader == nil && options.BackingStore == nil) {
+		return nil
+	}
+	return &refreshAhead{window: options.RefreshAhead, inflight: make(map[string]struct{})}
+}
+
+// maybeRefresh starts reloading key in the background if item, just read,
+// expires within Options.RefreshAhead and key is not being reloaded yet.
+func (c *Cache) maybeRefresh(key string, item Item) {
+	r := c.refresh
+	if r == nil || item.Expiration == 0 {
+		return
+	}
+	if item.Expiration-c.clock.Now().UnixNano() > int64(r.window) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.inflight[key]; ok {
+		return
+	}
+	started := c.refreshJobs.start(func(ctx context.Context) {
+		c.refreshItem(ctx, key, item.Version)
+		r.mu.Lock()
+		delete(r.inflight, key)
+		r.mu.Unlock()
+	})
+	if started {
+		r.inflight[key] = struct{}{}
+	}
+}
+
+// refreshItem reloads key and replaces its item, unless the item has been
+// set, deleted or has expired since it was read at version. A key the
+// source no longer has is left to expire.
+func (c *Cache) refreshItem(ctx context.Context, key string, version uint64) {
+	l, err := c.loadSource(ctx, key)
+	if err == nil {
+		_, err = c.storeLoaded(key, l, func(old Item, found bool) bool {
+			return found && old.Version == version
+		})
+	}
+	c.reportRefresh(ctx, key, err)
+}
+
+// RefreshFunc returns the current value of key for SetWithRefresh.
+type RefreshFunc func(ctx context.Context, key string) (interface{}, error)
+
+// SetWithRefresh stores value under key for ttl, like SetWithExpiration, and
+// keeps it up to date in the background, for data such as configuration or
+// feature flags that must always be served from the cache. When four fifths
+// of ttl have passed, refresh is called and its value replaces the item for
+// another ttl, on and on. If refresh fails, the error is passed to
+// Options.OnRefreshError and the call is retried every tenth of ttl until
+// the item expires.
+//
+// Refreshing stops once the key is set again, deleted, evicted or expired,
+// and when the cache is stopped or closed.
+func (c *Cache) SetWithRefresh(key string, value interface{}, ttl time.Duration, refresh RefreshFunc) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	version, _, err := c.setVersion(key, value, c.clock.Now().Add(ttl).UnixNano(), nil)
+	if err != nil {
+		return err
+	}
+	if err := c.storeThrough(context.Background(), key, value, 0); err != nil {
+		return err
+	}
+	c.refreshJobs.start(func(ctx context.Context) {
+		c.refreshLoop(ctx, key, version, ttl, refresh)
+	})
+	return nil
+}
+
+// refreshLoop refreshes key, stored at version, until it changes.
+func (c *Cache) refreshLoop(ctx context.Context, key string, version uint64, ttl time.Duration, refresh RefreshFunc) {
+	wait := ttl - ttl/5
+	for c.sleep(ctx, wait) {
+		c.mu.RLock()
+		item, found := c.items.get(key)
+		c.mu.RUnlock()
+		if !found || item.Version != version || item.expiredAt(c.clock.Now().UnixNano()) {
+			return
+		}
+
+		value, err := refresh(ctx, key)
+		if err == nil {
+			var next uint64
+			var stored bool
+			next, stored, err = c.setVersion(key, value, c.clock.Now().Add(ttl).UnixNano(), func(old Item, found bool) bool {
+				return found && old.Version == version
+			})
+			if err == nil && !stored {
+				return
+			}
+			if stored {
+				version = next
+			}
+		}
+		if err != nil {
+			c.reportRefresh(ctx, key, err)
+			wait = ttl / 10
+			continue
+		}
+		wait = ttl - ttl/5
+	}
+}
+
+// setVersion is setIf returning the version of the stored item.
+func (c *Cache) setVersion(key string, value interface{}, expiration int64, cond func(old Item, found bool) bool) (uint64, bool, error) {
+	var version uint64
+	stored, err := c.setIf(key, value, expiration, 0, 0, func(old Item, found bool) bool {
+		if cond != nil && !cond(old, found) {
+			return false
+		}
+		// setIf assigns the next version once cond returns true, without
+		// releasing the lock.
+		version = c.versionSeq + 1
+		return true
+	})
+	return version, stored, err
+}
+
+// sleep waits for d on the cache's clock and reports whether ctx is still
+// live.
+func (c *Cache) sleep(ctx context.Context, d time.Duration) bool {
+	t := c.clock.NewTicker(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
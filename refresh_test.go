@@ -0,0 +1,201 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAheadReloadsHotKeys(t *testing.T) {
+	clock := newFakeClock()
+	var calls int32
+	c := New(Options{
+		Clock:        clock,
+		RefreshAhead: 10 * time.Second,
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			n := atomic.AddInt32(&calls, 1)
+			return int(n), time.Minute, nil
+		},
+	})
+	defer c.Stop()
+
+	if v, _ := c.Get("a"); v != 1 {
+		t.Fatalf("Get = %v, want 1", v)
+	}
+	clock.Advance(45 * time.Second)
+	c.Get("a")
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times outside the window, want 1", n)
+	}
+
+	clock.Advance(10 * time.Second)
+	if v, _ := c.Get("a"); v != 1 {
+		t.Fatalf("Get in the window = %v, want the current value 1", v)
+	}
+	waitUntil(t, func() bool {
+		v, _ := c.Get("a")
+		return v == 2
+	})
+	_, exp, _ := c.GetWithExpiration("a")
+	if want := clock.Now().Add(time.Minute); !exp.Equal(want) {
+		t.Fatalf("expiration = %v, want %v", exp, want)
+	}
+}
+
+func TestRefreshAheadKeepsNewerValues(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	var calls int32
+	c := New(Options{
+		Clock:        clock,
+		RefreshAhead: 10 * time.Second,
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				<-release
+			}
+			return "loaded", time.Minute, nil
+		},
+	})
+	defer c.Stop()
+
+	c.Get("a")
+	clock.Advance(55 * time.Second)
+	c.Get("a")
+	c.Get("a")
+	c.Set("a", "newer")
+	close(release)
+	c.stopRefresh(context.Background())
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("loader called %d times, want 2", n)
+	}
+	if v, _ := c.Get("a"); v != "newer" {
+		t.Fatalf("Get = %v, want newer", v)
+	}
+}
+
+func TestRefreshAheadReportsErrors(t *testing.T) {
+	clock := newFakeClock()
+	failure := errors.New("database down")
+	var calls int32
+	reported := make(chan string, 1)
+	c := New(Options{
+		Clock:          clock,
+		RefreshAhead:   10 * time.Second,
+		OnRefreshError: func(key string, err error) { reported <- key },
+		Loader: func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+			if atomic.AddInt32(&calls, 1) > 1 {
+				return nil, 0, failure
+			}
+			return "loaded", time.Minute, nil
+		},
+	})
+	defer c.Stop()
+
+	c.Get("a")
+	clock.Advance(55 * time.Second)
+	if v, _ := c.Get("a"); v != "loaded" {
+		t.Fatalf("Get = %v, want loaded", v)
+	}
+	select {
+	case key := <-reported:
+		if key != "a" {
+			t.Fatalf("reported key %q, want a", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refresh error not reported")
+	}
+	if v, _ := c.Get("a"); v != "loaded" {
+		t.Fatalf("Get after failed refresh = %v, want loaded", v)
+	}
+}
+
+func TestSetWithRefresh(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	var calls int32
+	err := c.SetWithRefresh("flags", 0, 50*time.Millisecond, func(ctx context.Context, key string) (interface{}, error) {
+		return int(atomic.AddInt32(&calls, 1)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool {
+		v, err := c.Get("flags")
+		return err == nil && v.(int) >= 3
+	})
+}
+
+func TestSetWithRefreshStopsWhenKeyChanges(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock})
+	defer c.Stop()
+
+	var calls int32
+	c.SetWithRefresh("flags", "initial", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "refreshed", nil
+	})
+	c.SetWithExpiration("flags", "manual", time.Hour)
+
+	// Wait for the refresh loop to sleep, then wake it up; it must return
+	// without refreshing.
+	waitUntil(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.tickers) == 1
+	})
+	clock.Advance(time.Minute)
+	done := make(chan struct{})
+	go func() {
+		c.refreshJobs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the refresh loop to return after Set")
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("Expected no refresh after Set, got %d calls", n)
+	}
+	if v, _ := c.Get("flags"); v != "manual" {
+		t.Fatalf("Expected manual, got %v", v)
+	}
+}
+
+func TestSetWithRefreshRetriesFailures(t *testing.T) {
+	failure := errors.New("config server down")
+	var reported int32
+	c := New(Options{OnRefreshError: func(key string, err error) { atomic.AddInt32(&reported, 1) }})
+	defer c.Stop()
+
+	var calls int32
+	c.SetWithRefresh("flags", "initial", 100*time.Millisecond, func(ctx context.Context, key string) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, failure
+		}
+		return "refreshed", nil
+	})
+	waitUntil(t, func() bool {
+		v, _ := c.Get("flags")
+		return v == "refreshed"
+	})
+	if n := atomic.LoadInt32(&reported); n != 1 {
+		t.Fatalf("reported %d errors, want 1", n)
+	}
+}
+
+func TestSetWithRefreshInvalidTTL(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	err := c.SetWithRefresh("flags", 1, 0, func(ctx context.Context, key string) (interface{}, error) { return 2, nil })
+	if !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("err = %v, want ErrInvalidTTL", err)
+	}
+}
@@ -0,0 +1,536 @@
+// Package replication streams a cache from a primary to standby replicas
+// over TCP: a full sync of all items, then every Set, Delete, expiration
+// change and Flush as it happens, so that a replica is a warm standby.
+//
+// The primary serves replicas with Serve:
+//
+//	l, _ := net.Listen("tcp", ":7000")
+//	go replication.Serve(cache, l, replication.Options{})
+//
+// and a standby follows it with Replicate, in a loop to survive network
+// failures:
+//
+//	for ctx.Err() == nil {
+//		conn, err := net.Dial("tcp", "primary:7000")
+//		if err == nil {
+//			err = replication.Replicate(ctx, standby, conn)
+//		}
+//		log.Print(err)
+//		time.Sleep(time.Second)
+//	}
+//
+// Values are encoded with gocache.Cache.MarshalValue, so the primary and
+// its replicas must use the same codecs and create the same namespaces.
+// The stream is not encrypted; use a TLS listener to protect it.
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Versions of the replication protocol. Each side of a stream starts with
+// a header carrying its ProtocolVersion, and rejects a peer whose version
+// is outside [MinProtocolVersion, ProtocolVersion] with
+// gocache.ErrUnsupportedVersion, so that replicating between incompatible
+// releases fails at once.
+const (
+	// ProtocolVersion is the replication protocol spoken by this release.
+	ProtocolVersion uint16 = 1
+	// MinProtocolVersion is the oldest replication protocol this release
+	// accepts from a peer.
+	MinProtocolVersion uint16 = 1
+)
+
+// Message types of the replication protocol. After the handshake, the
+// primary sends a msgSync followed by sync records up to syncEnd, then a
+// stream of the other messages.
+const (
+	msgSync   byte = 1 // full sync: syncEntry records, then syncEnd
+	msgEntry  byte = 2 // key, remaining TTL, priority, value
+	msgDelete byte = 3 // key
+	msgFlush  byte = 4 // remove all items
+	msgPing   byte = 5 // keepalive
+)
+
+// Records of a full sync. They match the snapshot format's records.
+const (
+	syncEnd   byte = 0 // end of the full sync
+	syncEntry byte = 3 // key, remaining TTL, priority, value
+)
+
+// syncChunkSize is the number of items read from the cache at a time
+// during a full sync.
+const syncChunkSize = 1024
+
+// heartbeat is the interval at which an idle primary pings its replicas.
+// A replica gives up on a primary it has not heard from for three
+// intervals.
+const heartbeat = time.Second
+
+// Options configures Serve.
+type Options struct {
+	// Clock must be the clock of the cache, if it was created with
+	// gocache.Options.Clock. It is used to send the time left before
+	// items expire.
+	Clock gocache.Clock
+}
+
+func (o Options) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock.Now()
+	}
+	return time.Now()
+}
+
+// Serve accepts replicas on l and streams c to each of them: first a full
+// sync of all items, then every change as it happens. Replication is
+// asynchronous; a replica lags the primary by the time it takes to send
+// the changes.
+//
+// Changes are sent as the current state of the keys that changed, so a
+// replica that falls behind receives a key changed many times only once,
+// and the primary never buffers more than one pending change per key.
+//
+// Serve blocks until l fails or is closed, and then closes the replica
+// connections it accepted. When the cache is closed, in
+// gocache.PhaseStopIntake, every Serve for it closes its listener and
+// connections and returns nil.
+func Serve(c *gocache.Cache, l net.Listener, options Options) error {
+	p := primaryOf(c)
+	r := &replicas{l: l, conns: make(map[net.Conn]struct{}), stop: make(chan struct{})}
+	if !p.add(r) {
+		l.Close()
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			// Close the connections before waiting for them: their
+			// goroutines may be blocked on a slow replica.
+			closed := p.remove(r)
+			wg.Wait()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		if !p.track(r, conn) {
+			conn.Close()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveReplica(c, conn, r.stop, options)
+			p.untrack(r, conn)
+			conn.Close()
+		}()
+	}
+}
+
+// primary holds the listeners served for a cache, so that a single
+// shutdown hook closes all of them.
+type primary struct {
+	mu       sync.Mutex
+	closed   bool
+	replicas map[*replicas]struct{}
+}
+
+// replicas is the listener of one Serve call and the connections it
+// accepted.
+type replicas struct {
+	l       net.Listener
+	conns   map[net.Conn]struct{}
+	stop    chan struct{}
+	stopped bool
+}
+
+var (
+	primariesMu sync.Mutex
+	primaries   = make(map[*gocache.Cache]*primary)
+)
+
+// primaryOf returns the primary of c, registering its shutdown hook the
+// first time. The primary is forgotten when the cache is closed.
+func primaryOf(c *gocache.Cache) *primary {
+	primariesMu.Lock()
+	defer primariesMu.Unlock()
+	if p, ok := primaries[c]; ok {
+		return p
+	}
+	p := &primary{replicas: make(map[*replicas]struct{})}
+	primaries[c] = p
+	c.OnShutdown(gocache.PhaseStopIntake, "replication", func(context.Context) error {
+		primariesMu.Lock()
+		delete(primaries, c)
+		primariesMu.Unlock()
+		return p.close()
+	})
+	return p
+}
+
+// add registers r. It returns false if the cache is already closed.
+func (p *primary) add(r *replicas) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.replicas[r] = struct{}{}
+	return true
+}
+
+// remove stops r and forgets it. It returns whether the cache was closed.
+func (p *primary) remove(r *replicas) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r.close()
+	delete(p.replicas, r)
+	return p.closed
+}
+
+// track records a connection accepted by r. It returns false if r has
+// been stopped.
+func (p *primary) track(r *replicas, conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.stopped {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	return true
+}
+
+func (p *primary) untrack(r *replicas, conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(r.conns, conn)
+}
+
+// close stops every Serve of the cache.
+func (p *primary) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var errs []error
+	for r := range p.replicas {
+		errs = append(errs, r.close())
+	}
+	return errors.Join(errs...)
+}
+
+// close closes the listener and connections of r. The caller holds the
+// primary's lock.
+func (r *replicas) close() error {
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	close(r.stop)
+	for conn := range r.conns {
+		conn.Close()
+	}
+	return r.l.Close()
+}
+
+// serveReplica streams c to one replica until the connection fails or
+// stop is closed.
+func serveReplica(c *gocache.Cache, conn net.Conn, stop <-chan struct{}, options Options) error {
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	if err := handshake(br, bw); err != nil {
+		return err
+	}
+
+	// Open the feed first, so that changes made during the full sync are
+	// sent after it.
+	feed := c.NewChangeFeed()
+	defer feed.Close()
+
+	bw.WriteByte(msgSync)
+	err := c.RangeChunks(syncChunkSize, func(entries []gocache.Entry) error {
+		now := options.now().UnixNano()
+		for _, e := range entries {
+			bw.WriteByte(syncEntry)
+			if err := writeEntry(c, bw, e, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteByte(syncEnd)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-feed.Ready():
+			keys, flushed := feed.Take()
+			if flushed {
+				bw.WriteByte(msgFlush)
+			}
+			for _, key := range keys {
+				if err := writeChange(c, bw, key, options.now().UnixNano()); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			bw.WriteByte(msgPing)
+		case <-stop:
+			return nil
+		}
+		conn.SetWriteDeadline(time.Now().Add(3 * heartbeat))
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// writeChange writes the current state of key: its item, or its deletion
+// if it is missing or expired.
+func writeChange(c *gocache.Cache, bw *bufio.Writer, key string, now int64) error {
+	e, found := c.Peek(key)
+	if !found {
+		bw.WriteByte(msgDelete)
+		writeString(bw, key)
+		return nil
+	}
+	bw.WriteByte(msgEntry)
+	return writeEntry(c, bw, e, now)
+}
+
+// writeEntry writes the key, remaining TTL, priority and value of e. The
+// TTL is 0 for items that never expire, and -1 for items that have just
+// expired.
+func writeEntry(c *gocache.Cache, bw *bufio.Writer, e gocache.Entry, now int64) error {
+	var ttl int64
+	if e.Expiration > 0 {
+		if ttl = e.Expiration - now; ttl <= 0 {
+			ttl = -1
+		}
+	}
+	data, err := c.MarshalValue(e.Key, e.Value)
+	if err != nil {
+		return err
+	}
+	writeString(bw, e.Key)
+	writeInt(bw, ttl)
+	writeInt(bw, int64(e.Priority))
+	writeBytes(bw, data)
+	return nil
+}
+
+// Replicate makes c a replica of the primary at the other end of conn,
+// which serves it with Serve. It replaces the contents of c with a full
+// sync, then applies the primary's changes until ctx is done, in which
+// case it returns ctx.Err(), or the connection fails. It closes conn
+// before returning.
+//
+// To keep a standby in sync across network failures, call Replicate in a
+// loop with a new connection each time; every call starts with a full
+// sync. To promote the standby, cancel ctx and use the cache: it holds the
+// primary's items as of the last change received.
+func Replicate(ctx context.Context, c *gocache.Cache, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err := replicate(c, conn)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func replicate(c *gocache.Cache, conn net.Conn) error {
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	conn.SetDeadline(time.Now().Add(3 * heartbeat))
+	if err := handshake(br, bw); err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * heartbeat))
+		kind, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case msgSync:
+			c.Flush()
+			// The deadline must leave time for a large full sync.
+			conn.SetReadDeadline(time.Time{})
+			if err := readSync(c, br); err != nil {
+				return err
+			}
+		case msgEntry:
+			if err := readEntry(c, br); err != nil {
+				return err
+			}
+		case msgDelete:
+			key, err := readString(br)
+			if err != nil {
+				return err
+			}
+			c.Delete(key)
+		case msgFlush:
+			c.Flush()
+		case msgPing:
+		default:
+			return fmt.Errorf("%w: unknown replication message %d", gocache.ErrCorruptSnapshot, kind)
+		}
+	}
+}
+
+// readSync reads the records of a full sync and stores their items.
+func readSync(c *gocache.Cache, br *bufio.Reader) error {
+	for {
+		kind, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case syncEnd:
+			return nil
+		case syncEntry:
+			if err := readEntry(c, br); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unknown sync record %d", gocache.ErrCorruptSnapshot, kind)
+		}
+	}
+}
+
+// readEntry reads an entry written by writeEntry and stores its item.
+func readEntry(c *gocache.Cache, br *bufio.Reader) error {
+	key, err := readString(br)
+	if err != nil {
+		return err
+	}
+	ttl, err := binary.ReadVarint(br)
+	if err != nil {
+		return err
+	}
+	priority, err := binary.ReadVarint(br)
+	if err != nil {
+		return err
+	}
+	data, err := readBytes(br)
+	if err != nil {
+		return err
+	}
+	value, err := c.UnmarshalValue(key, data)
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		// Expired in transit.
+		c.Delete(key)
+		return nil
+	}
+	if err := c.SetWithPriority(key, value, time.Duration(ttl), int(priority)); err != nil {
+		return fmt.Errorf("replicating %q: %w", key, err)
+	}
+	return nil
+}
+
+// handshake sends this release's protocol header and checks the peer's.
+func handshake(br *bufio.Reader, bw *bufio.Writer) error {
+	if err := gocache.WriteHeader(bw, ProtocolVersion); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := gocache.ReadHeader(br, MinProtocolVersion, ProtocolVersion)
+	return err
+}
+
+// maxRecord is the largest key or value a message may hold, as in
+// snapshots. Messages claiming more are rejected as corrupt.
+const maxRecord = 1 << 30
+
+// readChunk is the size above which records are read without allocating
+// their whole length up front.
+const readChunk = 64 << 10
+
+// Write errors of a bufio.Writer are sticky and reported by Flush, so the
+// helpers below do not return them.
+
+func writeInt(w *bufio.Writer, v int64) {
+	w.Write(binary.AppendVarint(nil, v))
+}
+
+func writeBytes(w *bufio.Writer, b []byte) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	w.Write(b)
+}
+
+func writeString(w *bufio.Writer, s string) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	w.WriteString(s)
+}
+
+// readBytes reads a record written by writeBytes. Its length comes from
+// the peer, so it is bounded by maxRecord, and long records are read into
+// a buffer that grows with the data actually received.
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecord {
+		return nil, fmt.Errorf("%w: record of %d bytes", gocache.ErrCorruptSnapshot, n)
+	}
+	if n <= readChunk {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, truncated(err)
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(readChunk)
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, truncated(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// truncated reports a record cut short by the end of the stream as
+// io.ErrUnexpectedEOF.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	b, err := readBytes(r)
+	return string(b), err
+}
@@ -0,0 +1,222 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// waitUntil polls cond until it holds or a second has passed.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	primary := gocache.New(gocache.Options{})
+	primary.Set("existing", "1")
+	primary.SetWithExpiration("expiring", "2", time.Hour)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- Serve(primary, l, Options{}) }()
+
+	replica := gocache.New(gocache.Options{})
+	defer replica.Stop()
+	replica.Set("stale", "x")
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicated := make(chan error, 1)
+	go func() { replicated <- Replicate(context.Background(), replica, conn) }()
+
+	// The full sync replaces the replica's contents.
+	waitUntil(t, func() bool { _, err := replica.Get("existing"); return err == nil })
+	if _, err := replica.Get("stale"); err == nil {
+		t.Error("Expected the full sync to remove stale keys")
+	}
+	if _, expires, _ := replica.GetWithExpiration("expiring"); expires.IsZero() || time.Until(expires) > time.Hour {
+		t.Errorf("Expected the TTL to be replicated, got %v", expires)
+	}
+
+	primary.SetWithPriority("vip", "4", 0, 5)
+	waitUntil(t, func() bool { e, found := replica.Peek("vip"); return found && e.Priority == 5 })
+
+	primary.Set("new", "3")
+	waitUntil(t, func() bool { v, _ := replica.Get("new"); return v == "3" })
+	primary.Delete("existing")
+	waitUntil(t, func() bool { _, err := replica.Get("existing"); return err != nil })
+	at := time.Now().Add(time.Minute).Truncate(time.Second)
+	primary.ExpireAt("new", at)
+	waitUntil(t, func() bool {
+		_, expires, _ := replica.GetWithExpiration("new")
+		return !expires.IsZero() && expires.Sub(at).Abs() < time.Second
+	})
+	primary.Flush()
+	waitUntil(t, func() bool { return replica.ItemCount() == 0 })
+
+	// Closing the primary stops serving and disconnects the replica.
+	if err := primary.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected Serve to return nil, got %v", err)
+	}
+	if err := <-replicated; err == nil {
+		t.Error("Expected Replicate to fail when the primary goes away")
+	}
+}
+
+func TestReplicateCancel(t *testing.T) {
+	primary := gocache.New(gocache.Options{})
+	defer primary.Close(context.Background())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Serve(primary, l, Options{})
+
+	replica := gocache.New(gocache.Options{})
+	defer replica.Stop()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Replicate(ctx, replica, conn) }()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestReplicationVersionMismatch(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// A peer speaking a newer protocol.
+	go func() {
+		br := bufio.NewReader(server)
+		gocache.ReadHeader(br, 0, math.MaxUint16)
+		bw := bufio.NewWriter(server)
+		gocache.WriteHeader(bw, ProtocolVersion+1)
+		bw.Flush()
+	}()
+
+	replica := gocache.New(gocache.Options{})
+	defer replica.Stop()
+	if err := Replicate(context.Background(), replica, client); !errors.Is(err, gocache.ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestReplicationServeListeners(t *testing.T) {
+	primary := gocache.New(gocache.Options{})
+	primary.Set("k", "v")
+
+	// A listener that fails stops its own replicas without waiting for
+	// the cache to close.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- Serve(primary, l, Options{}) }()
+	replica := gocache.New(gocache.Options{})
+	defer replica.Stop()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicated := make(chan error, 1)
+	go func() { replicated <- Replicate(context.Background(), replica, conn) }()
+	waitUntil(t, func() bool { _, err := replica.Get("k"); return err == nil })
+	l.Close()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Expected Serve to return the listener's error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Serve to return when its listener fails")
+	}
+	if err := <-replicated; err == nil {
+		t.Error("Expected Replicate to fail when its connection is closed")
+	}
+
+	// Several Serve calls share one shutdown hook.
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() { done <- Serve(primary, l, Options{}) }()
+	}
+	waitUntil(t, func() bool {
+		p := primaryOf(primary)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.replicas) == 3
+	})
+	if err := primary.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Expected Serve to return nil, got %v", err)
+		}
+	}
+	primariesMu.Lock()
+	defer primariesMu.Unlock()
+	if _, ok := primaries[primary]; ok {
+		t.Error("Expected the closed cache to be forgotten")
+	}
+}
+
+func TestReadBytesLength(t *testing.T) {
+	for _, n := range []uint64{1 << 42, math.MaxUint64} {
+		r := bufio.NewReader(strings.NewReader(string(binary.AppendUvarint(nil, n)) + "ab"))
+		if _, err := readBytes(r); !errors.Is(err, gocache.ErrCorruptSnapshot) {
+			t.Errorf("Expected ErrCorruptSnapshot for a length of %d, got %v", n, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		n    uint64
+		data string
+	}{
+		{5, "ab"},
+		{maxRecord, strings.Repeat("x", 100000)},
+	} {
+		r := bufio.NewReader(strings.NewReader(string(binary.AppendUvarint(nil, tc.n)) + tc.data))
+		if _, err := readBytes(r); err != io.ErrUnexpectedEOF {
+			t.Errorf("Expected io.ErrUnexpectedEOF for a length of %d, got %v", tc.n, err)
+		}
+	}
+
+	r := bufio.NewReader(strings.NewReader(string(binary.AppendUvarint(nil, readChunk+1)) + strings.Repeat("x", readChunk+1)))
+	if b, err := readBytes(r); err != nil || len(b) != readChunk+1 {
+		t.Errorf("Expected %d bytes, got %d (%v)", readChunk+1, len(b), err)
+	}
+}
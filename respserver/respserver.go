@@ -0,0 +1,574 @@
+// Package respserver serves a gocache.Cache over a subset of the Redis
+// protocol (RESP), so that redis-cli and Redis client libraries can talk to
+// an embedded cache during development and in small deployments.
+//
+// The supported commands are GET, SET with EX or PX, DEL, EXPIRE, TTL,
+// KEYS, FLUSHALL, INCR, PING, COMMAND and QUIT. Values set through the
+// server are stored as strings, or as int64 if they are the canonical
+// decimal form of one, as Redis does, so that INCR works on them. Values of
+// other types set from Go are reported with a WRONGTYPE error.
+package respserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Server serves a cache over RESP.
+type Server struct {
+	cache *gocache.Cache
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("respserver: server closed")
+
+// New returns a server for c. It registers a hook that closes the server in
+// the PhaseStopIntake phase of Cache.Close.
+func New(c *gocache.Cache) *Server {
+	s := &Server{
+		cache:     c,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	c.OnShutdown(gocache.PhaseStopIntake, "respserver", func(ctx context.Context) error {
+		return s.Close()
+	})
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each one in its own goroutine
+// until l fails or Close is called, in which case it returns
+// ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops all listeners and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				writeError(w, "ERR Protocol error: "+string(perr))
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := strings.EqualFold(args[0], "QUIT")
+		if quit {
+			writeSimple(w, "OK")
+		} else {
+			s.execute(w, args)
+		}
+		// Flush once the client has no more pipelined commands buffered.
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// execute runs one command and writes its reply.
+func (s *Server) execute(w *bufio.Writer, args []string) {
+	name := strings.ToUpper(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if len(args)-1 < cmd.minArgs || (cmd.maxArgs >= 0 && len(args)-1 > cmd.maxArgs) {
+		writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+	cmd.run(s, w, args[1:])
+}
+
+// command describes a supported command. maxArgs is -1 for no limit.
+type command struct {
+	minArgs, maxArgs int
+	run              func(s *Server, w *bufio.Writer, args []string)
+}
+
+var commands = map[string]command{
+	"PING":     {0, 1, (*Server).ping},
+	"COMMAND":  {0, -1, (*Server).command},
+	"GET":      {1, 1, (*Server).get},
+	"SET":      {2, 4, (*Server).set},
+	"DEL":      {1, -1, (*Server).del},
+	"EXPIRE":   {2, 2, (*Server).expire},
+	"TTL":      {1, 1, (*Server).ttl},
+	"KEYS":     {1, 1, (*Server).keys},
+	"FLUSHALL": {0, 1, (*Server).flushAll},
+	"INCR":     {1, 1, (*Server).incr},
+}
+
+func (s *Server) ping(w *bufio.Writer, args []string) {
+	if len(args) == 1 {
+		writeBulk(w, args[0])
+		return
+	}
+	writeSimple(w, "PONG")
+}
+
+// command answers COMMAND, which redis-cli sends on startup, with an empty
+// list.
+func (s *Server) command(w *bufio.Writer, args []string) {
+	writeArray(w, nil)
+}
+
+func (s *Server) get(w *bufio.Writer, args []string) {
+	value, err := s.cache.Get(args[0])
+	if isMissing(err) {
+		writeNull(w)
+		return
+	}
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	str, ok := toString(value)
+	if !ok {
+		writeWrongType(w)
+		return
+	}
+	writeBulk(w, str)
+}
+
+func (s *Server) set(w *bufio.Writer, args []string) {
+	key, value := args[0], args[1]
+	var ttl time.Duration
+	if len(args) > 2 {
+		if len(args) != 4 {
+			writeError(w, "ERR syntax error")
+			return
+		}
+		var unit time.Duration
+		switch strings.ToUpper(args[2]) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+		n, err := strconv.ParseInt(args[3], 10, 64)
+		ok := err == nil && n > 0
+		if ok {
+			ttl, ok = s.duration(n, unit)
+		}
+		if !ok {
+			writeError(w, "ERR invalid expire time in 'set' command")
+			return
+		}
+	}
+
+	// Like Redis, SET without an expiration makes the key persistent rather
+	// than applying the cache's default expiration.
+	if err := s.cache.SetWithExpiration(key, fromString(value), ttl); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeSimple(w, "OK")
+}
+
+func (s *Server) del(w *bufio.Writer, args []string) {
+	var n int64
+	for _, key := range args {
+		if s.cache.Delete(key) {
+			n++
+		}
+	}
+	writeInt(w, n)
+}
+
+func (s *Server) expire(w *bufio.Writer, args []string) {
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+	// Peek does not run the cache's Loader for a missing key.
+	if _, found := s.cache.Peek(args[0]); !found {
+		writeInt(w, 0)
+		return
+	}
+	if seconds <= 0 {
+		s.cache.Delete(args[0])
+		writeInt(w, 1)
+		return
+	}
+	ttl, ok := s.duration(seconds, time.Second)
+	if !ok {
+		writeError(w, "ERR invalid expire time in 'expire' command")
+		return
+	}
+	if err := s.cache.ExpireAt(args[0], s.cache.Clock().Now().Add(ttl)); err != nil {
+		if isMissing(err) {
+			writeInt(w, 0)
+			return
+		}
+		writeCacheError(w, err)
+		return
+	}
+	writeInt(w, 1)
+}
+
+// duration returns n positive units as a duration. It reports false if
+// the duration overflows, or if the expiration it sets would not fit the
+// cache's nanosecond timestamps.
+func (s *Server) duration(n int64, unit time.Duration) (time.Duration, bool) {
+	if n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	d := time.Duration(n) * unit
+	if d > time.Duration(math.MaxInt64-s.cache.Clock().Now().UnixNano()) {
+		return 0, false
+	}
+	return d, true
+}
+
+func (s *Server) ttl(w *bufio.Writer, args []string) {
+	_, expires, err := s.cache.GetWithExpiration(args[0])
+	switch {
+	case isMissing(err):
+		writeInt(w, -2)
+	case err != nil:
+		writeCacheError(w, err)
+	case expires.IsZero():
+		writeInt(w, -1)
+	default:
+		remaining := expires.Sub(s.cache.Clock().Now())
+		writeInt(w, int64((remaining+500*time.Millisecond)/time.Second))
+	}
+}
+
+func (s *Server) keys(w *bufio.Writer, args []string) {
+	pattern := args[0]
+	var keys []string
+	s.cache.Range(func(e gocache.Entry) bool {
+		if matchGlob(pattern, e.Key) {
+			keys = append(keys, e.Key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+	writeArray(w, keys)
+}
+
+func (s *Server) flushAll(w *bufio.Writer, args []string) {
+	if len(args) == 1 && !strings.EqualFold(args[0], "SYNC") && !strings.EqualFold(args[0], "ASYNC") {
+		writeError(w, "ERR syntax error")
+		return
+	}
+	s.cache.Flush()
+	writeSimple(w, "OK")
+}
+
+func (s *Server) incr(w *bufio.Writer, args []string) {
+	n, err := s.cache.Increment(args[0], 1)
+	if errors.Is(err, gocache.ErrNotInteger) {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeInt(w, n)
+}
+
+// isMissing reports whether err means the key does not exist.
+func isMissing(err error) bool {
+	return errors.Is(err, gocache.ErrKeyNotFound) || errors.Is(err, gocache.ErrKeyExpired)
+}
+
+// fromString returns the value to store for a string sent by a client.
+func fromString(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+		return n
+	}
+	return s
+}
+
+// toString returns the string form of a stored value, if it has one.
+func toString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case int:
+		return strconv.Itoa(v), true
+	}
+	return "", false
+}
+
+// writeCacheError writes an error returned by the cache.
+func writeCacheError(w *bufio.Writer, err error) {
+	writeError(w, "ERR "+err.Error())
+}
+
+func writeWrongType(w *bufio.Writer) {
+	writeError(w, "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+// protocolError is a malformed request.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return string(e)
+}
+
+// maxBulkLen bounds the size of a single argument.
+const maxBulkLen = 512 << 20
+
+// readCommand reads a command, either a RESP array of bulk strings or an
+// inline command of space-separated words.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > 1024*1024 {
+		return nil, protocolError("invalid multibulk length")
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%.1s'", line))
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by CRLF or LF, without the terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// Write errors of a bufio.Writer are sticky and reported by Flush, so the
+// helpers below do not return them.
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeArray(w *bufio.Writer, items []string) {
+	w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		writeBulk(w, item)
+	}
+}
+
+// matchGlob reports whether s matches a Redis glob pattern: * matches any
+// sequence, ? any character, [abc] and [a-z] a character class, [^a] its
+// negation, and \ escapes the next character.
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if s == "" {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// An unterminated class matches a literal '['.
+				if s[0] != '[' {
+					return false
+				}
+				pattern, s = pattern[1:], s[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, s[0]) {
+				return false
+			}
+			pattern, s = pattern[end+2:], s[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return s == ""
+}
+
+// matchClass reports whether c is in a character class such as "abc",
+// "a-z" or "^0-9".
+func matchClass(class string, c byte) bool {
+	negate := strings.HasPrefix(class, "^")
+	if negate {
+		class = class[1:]
+	}
+	match := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if lo <= c && c <= hi {
+				match = true
+			}
+			i += 2
+		} else if class[i] == c {
+			match = true
+		}
+	}
+	return match != negate
+}
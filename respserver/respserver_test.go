@@ -0,0 +1,221 @@
+package respserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+// client sends commands and returns raw replies, one line per element.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) do(args ...string) string {
+	c.t.Helper()
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	line, err := readLine(c.r)
+	if err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	switch line[0] {
+	case '$':
+		if line == "$-1" {
+			return "(nil)"
+		}
+		data, _ := readLine(c.r)
+		return data
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		var items []string
+		for i := 0; i < n; i++ {
+			items = append(items, c.reply())
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	}
+	return line
+}
+
+func startServer(t *testing.T) (*gocache.Cache, *client) {
+	return startServerWith(t, gocache.Options{DefaultExpiration: time.Hour})
+}
+
+func startServerWith(t *testing.T, options gocache.Options) (*gocache.Cache, *client) {
+	cache := gocache.New(options)
+	server := New(cache)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(l) }()
+	t.Cleanup(func() {
+		cache.Close(context.Background())
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cache, &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestServerCommands(t *testing.T) {
+	cache, c := startServer(t)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"SET", "name", "alice"}, "+OK"},
+		{[]string{"GET", "name"}, "alice"},
+		{[]string{"GET", "missing"}, "(nil)"},
+		{[]string{"TTL", "name"}, ":-1"},
+		{[]string{"TTL", "missing"}, ":-2"},
+		{[]string{"SET", "session", "token", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "session"}, ":100"},
+		{[]string{"SET", "short", "x", "PX", "2500"}, "+OK"},
+		{[]string{"TTL", "short"}, ":2"},
+		{[]string{"EXPIRE", "name", "30"}, ":1"},
+		{[]string{"TTL", "name"}, ":30"},
+		{[]string{"EXPIRE", "missing", "30"}, ":0"},
+		{[]string{"SET", "bad", "x", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"INCR", "counter"}, ":1"},
+		{[]string{"SET", "counter", "41"}, "+OK"},
+		{[]string{"INCR", "counter"}, ":42"},
+		{[]string{"GET", "counter"}, "42"},
+		{[]string{"INCR", "name"}, "-ERR value is not an integer or out of range"},
+		{[]string{"KEYS", "s*"}, "[session short]"},
+		{[]string{"KEYS", "c?unt[a-f]r"}, "[counter]"},
+		{[]string{"DEL", "name", "session", "missing"}, ":2"},
+		{[]string{"GET", "name"}, "(nil)"},
+		{[]string{"NOPE"}, "-ERR unknown command 'NOPE'"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+	} {
+		if got := c.do(tt.args...); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, got)
+		}
+	}
+
+	cache.Set("struct", struct{ A int }{1})
+	if got := c.do("GET", "struct"); !strings.HasPrefix(got, "-WRONGTYPE") {
+		t.Errorf("Expected WRONGTYPE for a Go value, got %q", got)
+	}
+
+	if got := c.do("FLUSHALL"); got != "+OK" {
+		t.Errorf("Expected +OK, got %q", got)
+	}
+	if n := cache.ItemCount(); n != 0 {
+		t.Errorf("Expected an empty cache after FLUSHALL, got %d items", n)
+	}
+}
+
+// fixedClock is a clock stopped at a time far from the system clock's.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+func (c fixedClock) NewTicker(d time.Duration) gocache.Ticker { return stoppedTicker{} }
+
+type stoppedTicker struct{}
+
+func (stoppedTicker) C() <-chan time.Time { return nil }
+func (stoppedTicker) Stop()               {}
+
+func TestServerExpirations(t *testing.T) {
+	loads := 0
+	cache, c := startServerWith(t, gocache.Options{
+		Clock: fixedClock{time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Loader: func(context.Context, string) (interface{}, time.Duration, error) {
+			loads++
+			return "loaded", 0, nil
+		},
+	})
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "session", "token", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "session"}, ":100"},
+		{[]string{"EXPIRE", "session", "30"}, ":1"},
+		{[]string{"TTL", "session"}, ":30"},
+		{[]string{"EXPIRE", "missing", "30"}, ":0"},
+		{[]string{"SET", "huge", "x", "EX", "9223372036854775807"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "huge", "x", "PX", "9223372036854775"}, "-ERR invalid expire time in 'set' command"},
+		// The expiration would be past the year 2262.
+		{[]string{"SET", "huge", "x", "EX", "3000000000"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"EXPIRE", "session", "9223372036854775807"}, "-ERR invalid expire time in 'expire' command"},
+		{[]string{"TTL", "session"}, ":30"},
+	} {
+		if got := c.do(tt.args...); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.want, got)
+		}
+	}
+	if loads != 0 {
+		t.Errorf("Expected EXPIRE not to load missing keys, got %d loads", loads)
+	}
+	if _, found := cache.Peek("huge"); found {
+		t.Error("Expected no key set with an invalid expire time")
+	}
+}
+
+func TestServerInlineAndPipelined(t *testing.T) {
+	_, c := startServer(t)
+
+	c.conn.Write([]byte("SET a 1\r\nINCR a\r\nGET a\r\n"))
+	for _, want := range []string{"+OK", ":2", "2"} {
+		if got := c.reply(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users", false},
+		{"h?llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"a/*", "a/b/c", true},
+	} {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
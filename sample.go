@@ -0,0 +1,124 @@
+package gocache
+
+import (
+	"math/rand"
+)
+
+// Sample returns up to n live entries chosen uniformly at random, without
+// repetition. It holds the read lock only while picking the entries, so it
+// is cheap enough to call regularly from estimators (average value size,
+// staleness distribution and so on) that cannot afford a full scan.
+//
+// Fewer than n entries are returned if the cache holds fewer live items, or
+// if most of the picked items turn out to be expired.
+//
+// The first call indexes the keys of the cache, which takes the write lock
+// once and from then on costs a string per key; caches that never call
+// Sample do not pay for the index.
+func (c *Cache) Sample(n int) []Entry {
+	if n <= 0 {
+		return nil
+	}
+
+	now := c.clock.Now().UnixNano()
+
+	c.mu.RLock()
+	if c.keyIndex == nil {
+		c.mu.RUnlock()
+		c.indexKeys()
+		c.mu.RLock()
+	}
+	defer c.mu.RUnlock()
+
+	size := len(c.keys)
+	if size == 0 {
+		return nil
+	}
+
+	entries := make([]Entry, 0, min(n, size))
+	add := func(i int) {
+		key := c.keys[i]
+		if item, _ := c.items.get(key); !item.expiredAt(now) {
+			item.Value = c.valueOf(item.Value)
+			entries = append(entries, Entry{Key: key, Item: item})
+		}
+	}
+
+	// When most of the cache is requested, a shuffled walk is cheaper than
+	// rejecting repeated picks.
+	if n*2 >= size {
+		for _, i := range rand.Perm(size) {
+			if len(entries) == n {
+				break
+			}
+			add(i)
+		}
+		return entries
+	}
+
+	seen := make(map[int]struct{}, n)
+	for attempts := 0; len(entries) < n && attempts < 4*n; attempts++ {
+		i := rand.Intn(size)
+		if _, dup := seen[i]; dup {
+			continue
+		}
+		seen[i] = struct{}{}
+		add(i)
+	}
+	return entries
+}
+
+// indexKeys starts indexing keys for picking by index, indexing the keys
+// already stored.
+func (c *Cache) indexKeys() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.keyIndex != nil {
+		return
+	}
+	c.keyIndex = make(map[string]int, c.items.len())
+	c.keys = make([]string, 0, c.items.len())
+	c.items.rangeItems(func(key string, _ Item) bool {
+		c.indexKey(key)
+		return true
+	})
+}
+
+// indexKey records a newly added key so it can be picked by index, once
+// keys are indexed. It must be called with c.mu held.
+func (c *Cache) indexKey(key string) {
+	if c.keyIndex == nil {
+		return
+	}
+	c.keyIndex[key] = len(c.keys)
+	c.keys = append(c.keys, key)
+	if c.trackKeyHits {
+		c.keyHits = append(c.keyHits, 0)
+	}
+}
+
+// unindexKey forgets a removed key by moving the last key into its slot.
+// It must be called with c.mu held.
+func (c *Cache) unindexKey(key string) {
+	i, found := c.keyIndex[key]
+	if !found {
+		return
+	}
+
+	last := len(c.keys) - 1
+	if i != last {
+		moved := c.keys[last]
+		c.keys[i] = moved
+		c.keyIndex[moved] = i
+		if c.trackKeyHits {
+			c.keyHits[i] = c.keyHits[last]
+		}
+	}
+	c.keys[last] = ""
+	c.keys = c.keys[:last]
+	if c.trackKeyHits {
+		c.keyHits = c.keyHits[:last]
+	}
+	delete(c.keyIndex, key)
+}
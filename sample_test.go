@@ -0,0 +1,69 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCacheSample(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	for i := 0; i < 50; i++ {
+		cache.Delete(fmt.Sprintf("key%d", i*2))
+	}
+	cache.SetWithExpiration("expiring", "value", time.Second)
+	clock.Advance(2 * time.Second)
+
+	entries := cache.Sample(10)
+	if len(entries) != 10 {
+		t.Fatalf("Expected 10 entries, got %d", len(entries))
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if seen[e.Key] {
+			t.Errorf("Entry %s sampled twice", e.Key)
+		}
+		seen[e.Key] = true
+
+		if e.Key == "expiring" {
+			t.Error("Expired entry was sampled")
+		} else if e.Value.(int)%2 == 0 {
+			t.Errorf("Deleted entry %s was sampled", e.Key)
+		}
+	}
+
+	if entries := cache.Sample(1000); len(entries) != 50 {
+		t.Errorf("Expected all 50 live entries, got %d", len(entries))
+	}
+}
+
+func TestCacheSampleIndexesLazily(t *testing.T) {
+	cache := New(Options{Storage: BytesStorage})
+	defer cache.Stop()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i)
+	}
+	if cache.keyIndex != nil || len(cache.keys) != 0 {
+		t.Fatalf("Expected no key index before Sample, got %d keys", len(cache.keys))
+	}
+
+	if entries := cache.Sample(20); len(entries) != 10 {
+		t.Fatalf("Expected 10 entries, got %d", len(entries))
+	}
+	cache.Delete("key0")
+	cache.Set("new", 1)
+	seen := make(map[string]bool)
+	for _, e := range cache.Sample(20) {
+		seen[e.Key] = true
+	}
+	if len(seen) != 10 || seen["key0"] || !seen["new"] {
+		t.Errorf("Expected the index to follow changes after Sample, got %v", seen)
+	}
+}
@@ -0,0 +1,12 @@
+module gocache/sessionstore/gorilla
+
+go 1.23
+
+require (
+	github.com/gorilla/sessions v1.4.0
+	gocache v0.0.0
+)
+
+require github.com/gorilla/securecookie v1.1.2 // indirect
+
+replace gocache => ../..
@@ -0,0 +1,88 @@
+// Package gorilla implements the gorilla/sessions Store interface on a
+// sessionstore.Store. It is a module of its own, so that only applications
+// using gorilla/sessions depend on it:
+//
+//	sessions := gorilla.New(sessionstore.New(c, sessionstore.Options{}), nil)
+//	session, _ := sessions.Get(r, "session")
+//	session.Values["user"] = userID
+//	session.Save(r, w)
+//
+// Session IDs are random and only meaningful to the process that holds the
+// cache, so the cookie carries the ID alone, unsigned.
+package gorilla
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+
+	"gocache/sessionstore"
+)
+
+// Store is a sessions.Store keeping session values in a sessionstore.Store
+// and their IDs in cookies.
+type Store struct {
+	sessions *sessionstore.Store
+
+	// Options are the cookie options of new sessions. Sessions expire
+	// after the idle timeout of the sessionstore.Store, whatever their
+	// MaxAge; saving a session with a negative MaxAge deletes it.
+	Options *sessions.Options
+}
+
+var _ sessions.Store = (*Store)(nil)
+
+// New returns a store keeping sessions in s. If options is nil, cookies
+// are session cookies for path "/", Secure, HttpOnly and SameSite=Lax.
+func New(s *sessionstore.Store, options *sessions.Options) *Store {
+	if options == nil {
+		options = &sessions.Options{Path: "/", Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+	}
+	return &Store{sessions: s, Options: options}
+}
+
+// Get returns the session with the given name for r, loading it once per
+// request through the sessions registry.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session named by the cookie name of r, or a new session
+// if there is no such cookie or its session has expired.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	if cookie, err := r.Cookie(name); err == nil {
+		if values, err := s.sessions.Load(cookie.Value); err == nil {
+			session.ID, session.Values, session.IsNew = cookie.Value, values, false
+		}
+	}
+	return session, nil
+}
+
+// Save stores the values of session, giving it an ID if it is new, and
+// sets its cookie on w. A session with a negative MaxAge is deleted, along
+// with its cookie.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			s.sessions.Delete(session.ID)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		id, err := s.sessions.NewID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	if err := s.sessions.Save(session.ID, session.Values); err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
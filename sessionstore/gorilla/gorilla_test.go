@@ -0,0 +1,80 @@
+package gorilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+
+	"gocache"
+	"gocache/sessionstore"
+)
+
+// request returns a request carrying the cookies set on w, if any.
+func request(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if w != nil {
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+	}
+	return r
+}
+
+func TestStore(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	store := New(sessionstore.New(c, sessionstore.Options{}), nil)
+
+	session, err := store.Get(request(nil), "session")
+	if err != nil || !session.IsNew {
+		t.Fatalf("Expected a new session, got %+v (%v)", session, err)
+	}
+	session.Values["user"] = "alice"
+	w := httptest.NewRecorder()
+	if err := session.Save(request(nil), w); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != session.ID || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("Expected a secure HttpOnly cookie with the session ID, got %+v", cookies)
+	}
+
+	loaded, err := store.Get(request(w), "session")
+	if err != nil || loaded.IsNew || loaded.ID != session.ID || loaded.Values["user"] != "alice" {
+		t.Errorf("Expected the saved session, got %+v (%v)", loaded, err)
+	}
+
+	// A negative MaxAge deletes the session and its cookie.
+	loaded.Options.MaxAge = -1
+	deleted := httptest.NewRecorder()
+	if err := loaded.Save(request(w), deleted); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if cookies := deleted.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("Expected an expired cookie, got %+v", cookies)
+	}
+	if again, _ := store.Get(request(w), "session"); !again.IsNew {
+		t.Error("Expected a deleted session to come back as new")
+	}
+}
+
+func TestStoreOptions(t *testing.T) {
+	c := gocache.New(gocache.Options{})
+	defer c.Stop()
+	store := New(sessionstore.New(c, sessionstore.Options{}), &sessions.Options{Path: "/app"})
+
+	session, _ := store.New(request(nil), "session")
+	session.Options.Path = "/app/admin"
+	if store.Options.Path != "/app" {
+		t.Errorf("Expected sessions to get a copy of the store options, got %q", store.Options.Path)
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(request(nil), w, session); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Path != "/app/admin" {
+		t.Errorf("Expected the session's cookie path, got %+v", cookies)
+	}
+}
@@ -0,0 +1,116 @@
+// Package sessionstore keeps web sessions in a gocache.Cache, so that small
+// deployments can hold them in process instead of in Redis. Sessions expire
+// after a period of inactivity rather than at a fixed time.
+//
+// Session values are maps of the shape used by gorilla/sessions. The
+// gorilla sub-package, a module of its own so that this package does not
+// depend on gorilla/sessions, implements the sessions.Store interface on a
+// Store:
+//
+//	store := gorilla.New(sessionstore.New(c, sessionstore.Options{}), nil)
+//	session, _ := store.Get(r, "session")
+//
+// Session IDs are random and only meaningful to the process that holds the
+// cache, so the cookie needs no signing; it should still be Secure and
+// HttpOnly.
+package sessionstore
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"gocache"
+)
+
+// DefaultIdleTimeout is the idle timeout used when Options.IdleTimeout is 0.
+const DefaultIdleTimeout = 30 * time.Minute
+
+// ErrNotFound is returned by Load for unknown and expired sessions.
+var ErrNotFound = errors.New("sessionstore: session not found")
+
+// Options configures a Store.
+type Options struct {
+	// IdleTimeout is how long a session lives without being loaded or
+	// saved. If 0, DefaultIdleTimeout is used.
+	IdleTimeout time.Duration
+
+	// KeyPrefix is prepended to session IDs to form cache keys. If empty,
+	// "session:" is used.
+	KeyPrefix string
+}
+
+func init() {
+	// Let snapshots made with the default codec hold session values.
+	gob.Register(map[interface{}]interface{}{})
+}
+
+// Store keeps sessions in a cache.
+type Store struct {
+	cache   *gocache.Cache
+	options Options
+}
+
+// New returns a store keeping sessions in c.
+func New(c *gocache.Cache, options Options) *Store {
+	if options.IdleTimeout == 0 {
+		options.IdleTimeout = DefaultIdleTimeout
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "session:"
+	}
+	return &Store{cache: c, options: options}
+}
+
+// NewID returns a new random session ID.
+func (s *Store) NewID() (string, error) {
+	var b [20]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b[:]), nil
+}
+
+// Load returns the values of the session with the given ID and restarts
+// its idle timeout. The map is a copy, so the caller may modify it; the
+// changes are kept only once it is passed to Save. It returns ErrNotFound
+// if the session does not exist or has been idle for too long.
+func (s *Store) Load(id string) (map[interface{}]interface{}, error) {
+	key := s.options.KeyPrefix + id
+	value, err := s.cache.Get(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	stored, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := s.cache.ExpireAt(key, time.Now().Add(s.options.IdleTimeout)); err != nil {
+		return nil, ErrNotFound
+	}
+	return copyValues(stored), nil
+}
+
+// Save stores the values of the session with the given ID, creating the
+// session if needed, and restarts its idle timeout. The values are copied,
+// so the caller may keep modifying the map.
+func (s *Store) Save(id string, values map[interface{}]interface{}) error {
+	return s.cache.SetWithExpiration(s.options.KeyPrefix+id, copyValues(values), s.options.IdleTimeout)
+}
+
+// Delete removes the session with the given ID.
+func (s *Store) Delete(id string) {
+	s.cache.Delete(s.options.KeyPrefix + id)
+}
+
+// copyValues returns a shallow copy of values, so that concurrent requests
+// of the same session do not share a map.
+func copyValues(values map[interface{}]interface{}) map[interface{}]interface{} {
+	c := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
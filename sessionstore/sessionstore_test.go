@@ -0,0 +1,67 @@
+package sessionstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gocache"
+)
+
+func TestStore(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	store := New(cache, Options{IdleTimeout: time.Minute})
+
+	id, err := store.NewID()
+	if err != nil || len(id) != 32 {
+		t.Fatalf("Expected a 32-character ID, got %q (%v)", id, err)
+	}
+	if other, _ := store.NewID(); other == id {
+		t.Error("Expected distinct IDs")
+	}
+	if _, err := store.Load(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a new ID, got %v", err)
+	}
+
+	values := map[interface{}]interface{}{"user": "alice"}
+	if err := store.Save(id, values); err != nil {
+		t.Fatal(err)
+	}
+	values["user"] = "mallory"
+
+	loaded, err := store.Load(id)
+	if err != nil || loaded["user"] != "alice" {
+		t.Fatalf("Expected the saved values, got %v (%v)", loaded, err)
+	}
+	loaded["visits"] = 1
+	if again, _ := store.Load(id); again["visits"] != nil {
+		t.Error("Expected changes to a loaded map not to be kept without Save")
+	}
+
+	store.Delete(id)
+	if _, err := store.Load(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+}
+
+func TestStoreIdleTimeout(t *testing.T) {
+	cache := gocache.New(gocache.Options{})
+	defer cache.Stop()
+	store := New(cache, Options{IdleTimeout: time.Minute})
+	store.Save("a", map[interface{}]interface{}{})
+
+	cache.ExpireAt("session:a", time.Now().Add(time.Second))
+	if _, err := store.Load("a"); err != nil {
+		t.Fatal(err)
+	}
+	_, expires, _ := cache.GetWithExpiration("session:a")
+	if d := time.Until(expires); d < 59*time.Second {
+		t.Errorf("Expected Load to restart the idle timeout, got %v left", d)
+	}
+
+	cache.ExpireAt("session:a", time.Now().Add(-time.Second))
+	if _, err := store.Load("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an idle session, got %v", err)
+	}
+}
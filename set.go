@@ -0,0 +1,131 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold sets.
+	gob.Register(map[string]bool{})
+}
+
+// SAdd adds members to the set stored under key and returns the number of
+// members that were not in it yet.
+//
+// Sets are stored as map[string]bool values, with every member mapped to
+// true, so Get returns a set as a map. A set is created by the first SAdd
+// to a missing or expired key, with the default expiration, and keeps its
+// expiration and priority when changed, like a list. Every change replaces
+// the stored map with a new one, so maps returned earlier do not change
+// and concurrent updates are never lost. Operations on a key holding
+// another type of value return ErrWrongType.
+func (c *Cache) SAdd(key string, members ...string) (int, error) {
+	return c.changeSet(key, func(set map[string]bool) (map[string]bool, int) {
+		next := make(map[string]bool, len(set)+len(members))
+		for m := range set {
+			next[m] = true
+		}
+		for _, m := range members {
+			next[m] = true
+		}
+		added := len(next) - len(set)
+		if added == 0 && set != nil {
+			return nil, 0
+		}
+		return next, added
+	})
+}
+
+// SRem removes members from the set stored under key and returns the
+// number of members that were in it. A set emptied by SRem stays in the
+// cache until it expires or is deleted.
+func (c *Cache) SRem(key string, members ...string) (int, error) {
+	return c.changeSet(key, func(set map[string]bool) (map[string]bool, int) {
+		next := make(map[string]bool, len(set))
+		for m := range set {
+			next[m] = true
+		}
+		for _, m := range members {
+			delete(next, m)
+		}
+		removed := len(set) - len(next)
+		if removed == 0 {
+			return nil, 0
+		}
+		return next, removed
+	})
+}
+
+// SMembers returns the members of the set stored under key, sorted. A
+// missing set is empty.
+func (c *Cache) SMembers(key string) ([]string, error) {
+	set, err := c.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(set))
+	for m := range set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// SIsMember reports whether member is in the set stored under key.
+func (c *Cache) SIsMember(key, member string) (bool, error) {
+	set, err := c.getSet(key)
+	return set[member], err
+}
+
+// SCard returns the number of members of the set stored under key, or 0
+// if it is missing.
+func (c *Cache) SCard(key string) (int, error) {
+	set, err := c.getSet(key)
+	return len(set), err
+}
+
+// getSet returns the set stored under key, or nil if it is missing.
+func (c *Cache) getSet(key string) (map[string]bool, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	set, ok := value.(map[string]bool)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds %T, not a set", ErrWrongType, key, value)
+	}
+	return set, nil
+}
+
+// changeSet atomically replaces the set stored under key, or nil if it is
+// missing, with the set returned by fn, unless that is nil, and returns the
+// count returned by fn.
+func (c *Cache) changeSet(key string, fn func(set map[string]bool) (map[string]bool, int)) (int, error) {
+	if err := c.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	var n int
+	_, err := c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		var set map[string]bool
+		if found {
+			var ok bool
+			if set, ok = value.(map[string]bool); !ok {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a set", ErrWrongType, key, value)
+			}
+		}
+		next, count := fn(set)
+		n = count
+		return next, next != nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
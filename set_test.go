@@ -0,0 +1,87 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheSet(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, DefaultExpiration: time.Minute})
+	defer cache.Stop()
+
+	if n, err := cache.SAdd("seen", "b", "a", "b"); err != nil || n != 2 {
+		t.Errorf("Expected 2 members added, got %d (%v)", n, err)
+	}
+	_, version, _ := cache.GetVersioned("seen")
+	if n, err := cache.SAdd("seen", "a"); err != nil || n != 0 {
+		t.Errorf("Expected no member added, got %d (%v)", n, err)
+	}
+	if _, v, _ := cache.GetVersioned("seen"); v != version {
+		t.Error("Expected adding an existing member not to change the set")
+	}
+	before, _ := cache.SMembers("seen")
+	if n, err := cache.SAdd("seen", "c"); err != nil || n != 1 {
+		t.Errorf("Expected 1 member added, got %d (%v)", n, err)
+	}
+	if members, _ := cache.SMembers("seen"); !reflect.DeepEqual(members, []string{"a", "b", "c"}) {
+		t.Errorf("Expected [a b c], got %v", members)
+	}
+	if !reflect.DeepEqual(before, []string{"a", "b"}) {
+		t.Errorf("Expected an earlier SMembers to be unchanged, got %v", before)
+	}
+
+	if ok, err := cache.SIsMember("seen", "b"); err != nil || !ok {
+		t.Errorf("Expected b to be a member, got %v (%v)", ok, err)
+	}
+	if n, err := cache.SRem("seen", "b", "x"); err != nil || n != 1 {
+		t.Errorf("Expected 1 member removed, got %d (%v)", n, err)
+	}
+	if ok, _ := cache.SIsMember("seen", "b"); ok {
+		t.Error("Expected b to be removed")
+	}
+	if n, _ := cache.SCard("seen"); n != 2 {
+		t.Errorf("Expected 2 members, got %d", n)
+	}
+
+	if n, err := cache.SRem("missing", "a"); err != nil || n != 0 {
+		t.Errorf("Expected nothing removed from a missing set, got %d (%v)", n, err)
+	}
+	if _, err := cache.Get("missing"); err != ErrKeyNotFound {
+		t.Errorf("Expected SRem not to create a set, got %v", err)
+	}
+	cache.Set("text", "x")
+	if _, err := cache.SAdd("text", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n, _ := cache.SCard("seen"); n != 0 {
+		t.Errorf("Expected the set to expire, got %d members", n)
+	}
+}
+
+func TestCacheSetConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				cache.SAdd("ids", fmt.Sprint(i, "-", n))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := cache.SCard("ids"); n != 400 {
+		t.Errorf("Expected 400 members, got %d", n)
+	}
+}
@@ -0,0 +1,174 @@
+package gocache
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShieldOptions configures origin shielding. After a bulk invalidation,
+// such as Flush or Namespace.Flush, every hot key misses at once; with
+// shielding, GetOrSet reloads the invalidated keys through a queue with a
+// concurrency limit and random jitter instead of sending the whole wave of
+// requests to the origin together.
+type ShieldOptions struct {
+	// MaxConcurrent limits the number of concurrent loads of invalidated
+	// keys. Further callers wait in line. If 0, shielding is disabled.
+	MaxConcurrent int
+
+	// Jitter is the maximum random delay before each shielded load.
+	Jitter time.Duration
+
+	// Window is how long after an invalidation its keys are shielded. If 0,
+	// one minute is used.
+	Window time.Duration
+}
+
+// defaultShieldWindow is the shielding window used when Window is 0.
+const defaultShieldWindow = time.Minute
+
+// shield tracks recent bulk invalidations and limits reloads of their keys.
+type shield struct {
+	slots  chan struct{}
+	jitter time.Duration
+	window time.Duration
+
+	mu          sync.Mutex
+	invalidated map[string]time.Time   // key prefix to time of invalidation
+	loading     map[string]*shieldLoad // loads in progress by key
+}
+
+// shieldLoad is a shielded load that later callers for the same key wait
+// for instead of loading again.
+type shieldLoad struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newShield(options ShieldOptions) *shield {
+	if options.MaxConcurrent <= 0 {
+		return nil
+	}
+	window := options.Window
+	if window == 0 {
+		window = defaultShieldWindow
+	}
+	return &shield{
+		slots:       make(chan struct{}, options.MaxConcurrent),
+		jitter:      options.Jitter,
+		window:      window,
+		invalidated: make(map[string]time.Time),
+		loading:     make(map[string]*shieldLoad),
+	}
+}
+
+// invalidate records that all keys starting with prefix were invalidated at
+// now.
+func (s *shield) invalidate(prefix string, now time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalidated[prefix] = now
+}
+
+// covers reports whether key was invalidated within the window before now.
+func (s *shield) covers(key string, now time.Time) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	covered := false
+	for prefix, at := range s.invalidated {
+		if now.Sub(at) > s.window {
+			delete(s.invalidated, prefix)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			covered = true
+		}
+	}
+	return covered
+}
+
+// load computes the value for key with fn and, unless o.noStore is set,
+// stores it with store. Keys covered by a recent bulk invalidation are
+// loaded once at a time per key: later callers wait for the load in
+// progress and share its result. Each load waits for a slot and a random
+// jitter first, and returns the value stored by another caller in the
+// meantime, if any.
+func (c *Cache) load(key string, fn func() (interface{}, error), store func(interface{}) error, o callOptions) (value interface{}, err error) {
+	s := c.shield
+	if !s.covers(key, c.clock.Now()) {
+		return loadAndStore(fn, store, o)
+	}
+
+	s.mu.Lock()
+	if l, ok := s.loading[key]; ok {
+		s.mu.Unlock()
+		<-l.done
+		return l.value, l.err
+	}
+	l := &shieldLoad{done: make(chan struct{})}
+	s.loading[key] = l
+	s.mu.Unlock()
+
+	defer func() {
+		l.value, l.err = value, err
+		s.mu.Lock()
+		delete(s.loading, key)
+		s.mu.Unlock()
+		close(l.done)
+	}()
+
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	if s.jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+
+	if !o.bypassCache {
+		if value, found := c.peek(key); found {
+			return value, nil
+		}
+	}
+
+	return loadAndStore(fn, store, o)
+}
+
+// loadAndStore calls fn and stores its value unless o.noStore is set.
+func loadAndStore(fn func() (interface{}, error), store func(interface{}) error, o callOptions) (interface{}, error) {
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	if o.noStore {
+		return value, nil
+	}
+	if err := store(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// peek returns the unexpired value stored under key without counting a hit
+// or miss or informing the eviction policy.
+func (c *Cache) peek(key string) (interface{}, bool) {
+	c.mu.RLock()
+	item, found := c.items.get(key)
+	c.mu.RUnlock()
+
+	if !found || item.expiredAt(c.clock.Now().UnixNano()) {
+		return nil, false
+	}
+	value, err := c.expand(item.Value)
+	return value, err == nil
+}
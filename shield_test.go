@@ -0,0 +1,79 @@
+package gocache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheShieldAfterFlush(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, Shield: ShieldOptions{MaxConcurrent: 2, Window: time.Minute}})
+	defer cache.Stop()
+
+	cache.Flush()
+
+	var running, peak, loads int32
+	fn := func() (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", i%5)
+			if value, err := cache.GetOrSet(key, fn); err != nil || value != "loaded" {
+				t.Errorf("Unexpected GetOrSet result %v (%v)", value, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent loads, got %d", peak)
+	}
+	if loads != 5 {
+		t.Errorf("Expected one load per key, got %d", loads)
+	}
+
+	// Outside the window, loads are no longer shielded.
+	clock.Advance(2 * time.Minute)
+	if cache.shield.covers("key0", clock.Now()) {
+		t.Error("Expected shielding to end after the window")
+	}
+}
+
+func TestNamespaceShield(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock, Shield: ShieldOptions{MaxConcurrent: 1}})
+	defer cache.Stop()
+
+	users := cache.Namespace("users", NamespaceOptions{})
+	cache.Namespace("pages", NamespaceOptions{}).Flush()
+
+	now := clock.Now()
+	if !cache.shield.covers("pages:home", now) {
+		t.Error("Expected flushed namespace to be shielded")
+	}
+	if cache.shield.covers(users.prefix+"u1", now) {
+		t.Error("Expected other namespaces not to be shielded")
+	}
+
+	if cache := New(Options{}); cache.shield.covers("any", now) {
+		t.Error("Expected shielding to be disabled by default")
+	}
+}
@@ -0,0 +1,82 @@
+package gocache
+
+import (
+	"container/list"
+	"math/rand"
+)
+
+// fifoPolicy evicts the oldest key.
+type fifoPolicy struct {
+	order    *list.List // front is newest
+	elements map[string]*list.Element
+}
+
+// NewFIFOPolicy returns an EvictionPolicy that evicts keys in the order
+// they were added, ignoring accesses and overwrites. Reads cost it nothing.
+func NewFIFOPolicy() EvictionPolicy {
+	return &fifoPolicy{order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (p *fifoPolicy) OnAdd(key string) {
+	if _, found := p.elements[key]; !found {
+		p.elements[key] = p.order.PushFront(key)
+	}
+}
+
+func (p *fifoPolicy) OnAccess(key string) {}
+
+func (p *fifoPolicy) OnRemove(key string) {
+	if e, found := p.elements[key]; found {
+		p.order.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+func (p *fifoPolicy) Victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// randomPolicy evicts a key chosen uniformly at random.
+type randomPolicy struct {
+	keys  []string
+	index map[string]int // position of each key in keys
+}
+
+// NewRandomPolicy returns an EvictionPolicy that evicts a key chosen
+// uniformly at random. It keeps no order at all, so reads cost it nothing
+// and writes only a slice update; it suits workloads without hot keys.
+func NewRandomPolicy() EvictionPolicy {
+	return &randomPolicy{index: make(map[string]int)}
+}
+
+func (p *randomPolicy) OnAdd(key string) {
+	if _, found := p.index[key]; !found {
+		p.index[key] = len(p.keys)
+		p.keys = append(p.keys, key)
+	}
+}
+
+func (p *randomPolicy) OnAccess(key string) {}
+
+func (p *randomPolicy) OnRemove(key string) {
+	i, found := p.index[key]
+	if !found {
+		return
+	}
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i]] = i
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *randomPolicy) Victim() (string, bool) {
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	return p.keys[rand.Intn(len(p.keys))], true
+}
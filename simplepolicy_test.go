@@ -0,0 +1,68 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFIFOPolicy(t *testing.T) {
+	c := New(Options{MaxEntries: 2, EvictionPolicy: NewFIFOPolicy()})
+	defer c.Stop()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.Get("key1")
+	c.Set("key1", "updated")
+	c.Set("key3", "value3")
+
+	if _, err := c.Get("key1"); err != ErrKeyNotFound {
+		t.Fatalf("Expected key1 to be evicted despite access, got %v", err)
+	}
+	if _, err := c.Get("key2"); err != nil {
+		t.Fatalf("Expected key2 to remain, got %v", err)
+	}
+}
+
+func TestRandomPolicy(t *testing.T) {
+	p := NewRandomPolicy()
+	for i := 0; i < 100; i++ {
+		p.OnAdd(fmt.Sprint(i))
+	}
+	p.OnAdd("0")
+	for i := 0; i < 100; i += 2 {
+		p.OnRemove(fmt.Sprint(i))
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < 50 {
+		victim, ok := p.Victim()
+		if !ok {
+			t.Fatal("Victim reported no key")
+		}
+		var n int
+		fmt.Sscan(victim, &n)
+		if n%2 == 0 {
+			t.Fatalf("Victim = %q, which was removed", victim)
+		}
+		seen[victim] = true
+		p.OnRemove(victim)
+	}
+	if _, ok := p.Victim(); ok {
+		t.Fatal("Victim of an empty policy reported a key")
+	}
+}
+
+func TestCacheRandomEviction(t *testing.T) {
+	c := New(Options{MaxEntries: 10, EvictionPolicy: NewRandomPolicy()})
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	if n := c.ItemCount(); n != 10 {
+		t.Fatalf("ItemCount = %d, want 10", n)
+	}
+	if stats := c.Stats(); stats.Evictions != 90 {
+		t.Fatalf("Evictions = %d, want 90", stats.Evictions)
+	}
+}
@@ -0,0 +1,382 @@
+package gocache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Envelopes of the snapshot format. Since format version 2, the header is
+// followed by one of these, then by the records, encrypted or not.
+const (
+	snapshotPlain     byte = 0
+	snapshotEncrypted byte = 1 // key ID and nonce, then AES-GCM chunks
+)
+
+// Record types of the snapshot format. The records end with snapshotEnd.
+const (
+	snapshotEnd     byte = 0 // end of the snapshot
+	snapshotEntry   byte = 1 // key, expiration, priority, value
+	snapshotExpired byte = 2 // key, value of a pending OnExpired callback
+
+	// snapshotRelative is a snapshotEntry whose expiration is the time left
+	// when the snapshot was written: 0 if the entry never expires, negative
+	// if it has expired already. Written by WriteTo since format version 3.
+	snapshotRelative byte = 3 // key, remaining TTL, priority, value
+)
+
+// snapshotChunkSize is the number of items SaveSnapshot reads at a time.
+const snapshotChunkSize = 1024
+
+// maxSnapshotRecord is the largest key or value a snapshot record may
+// hold. Records claiming more are rejected as corrupt.
+const maxSnapshotRecord = 1 << 30
+
+// snapshotReadChunk is the size above which records are read without
+// allocating their whole length up front.
+const snapshotReadChunk = 64 << 10
+
+// SaveSnapshot writes all items, including expired ones that have not been
+// cleaned up, and all pending OnExpired callbacks to w. Values are encoded
+// with the cache's codec, or with the codec of their namespace. If
+// Options.SnapshotKeys is set, the snapshot is encrypted with the first key.
+//
+// Items are read in chunks with RangeChunks, and the lock is not held while
+// they are written, so w may be a slow network connection. As a result the
+// snapshot is not a point-in-time view of a cache that is being modified.
+func (c *Cache) SaveSnapshot(w io.Writer) error {
+	start := c.clock.Now()
+	err := c.saveSnapshot(w, false)
+	c.logPersistence("snapshot saved", start, err)
+	return err
+}
+
+// saveSnapshot is SaveSnapshot, writing expirations relative to now if
+// relative is set.
+func (c *Cache) saveSnapshot(w io.Writer, relative bool) error {
+	out := bufio.NewWriter(w)
+	if err := WriteHeader(out, FormatVersion); err != nil {
+		return err
+	}
+
+	if len(c.snapshotKeys) == 0 {
+		out.WriteByte(snapshotPlain)
+		if err := c.writeSnapshot(out, relative); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	out.WriteByte(snapshotEncrypted)
+	enc, err := newEncryptWriter(out, c.snapshotKeys[0])
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(enc)
+	if err := c.writeSnapshot(bw, relative); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// writeSnapshot writes the snapshot records to bw, with expirations
+// relative to now if relative is set.
+func (c *Cache) writeSnapshot(bw *bufio.Writer, relative bool) error {
+	err := c.writeSnapshotEntries(bw, relative)
+	if err != nil {
+		return err
+	}
+
+	c.expMu.Lock()
+	for _, kv := range c.pendingExpired {
+		bw.WriteByte(snapshotExpired)
+		writeSnapshotString(bw, kv.key)
+		if err = c.writeSnapshotValue(bw, kv.key, c.valueOf(kv.value)); err != nil {
+			break
+		}
+	}
+	c.expMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return bw.WriteByte(snapshotEnd)
+}
+
+// writeSnapshotEntries writes a record for each item to bw, with
+// expirations relative to now if relative is set.
+func (c *Cache) writeSnapshotEntries(bw *bufio.Writer, relative bool) error {
+	return c.rangeChunks(snapshotChunkSize, func(entries []Entry) error {
+		now := c.clock.Now().UnixNano()
+		for _, e := range entries {
+			expiration := e.Expiration
+			if !relative {
+				bw.WriteByte(snapshotEntry)
+			} else {
+				bw.WriteByte(snapshotRelative)
+				if expiration > 0 {
+					expiration = max(expiration-now, -1)
+				}
+			}
+			writeSnapshotString(bw, e.Key)
+			writeSnapshotInt(bw, expiration)
+			writeSnapshotInt(bw, int64(e.Priority))
+			if err := c.writeSnapshotValue(bw, e.Key, c.valueOf(e.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadSnapshot adds the items saved by SaveSnapshot to the cache, keeping
+// their expiration times. Items that expired in the meantime are not
+// added; instead OnExpired is called for them and for the callbacks that
+// were still pending when the snapshot was taken. The snapshot must have
+// been written with the same codecs, so namespaces with their own codec
+// must be created before loading it. Encrypted snapshots are decrypted with
+// the key from Options.SnapshotKeys whose ID they name.
+func (c *Cache) LoadSnapshot(r io.Reader) error {
+	start := c.clock.Now()
+	err := c.loadSnapshot(r)
+	c.logPersistence("snapshot loaded", start, err)
+	return err
+}
+
+// loadSnapshot is LoadSnapshot without logging.
+func (c *Cache) loadSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+	version, err := ReadHeader(br, MinFormatVersion, FormatVersion)
+	if err != nil {
+		return err
+	}
+
+	if version >= 2 {
+		envelope, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch envelope {
+		case snapshotPlain:
+		case snapshotEncrypted:
+			dec, err := newDecryptReader(br, c.snapshotKeys)
+			if err != nil {
+				return err
+			}
+			br = bufio.NewReader(dec)
+		default:
+			return fmt.Errorf("%w: unknown envelope %d", ErrCorruptSnapshot, envelope)
+		}
+	}
+
+	return c.readSnapshot(br)
+}
+
+// readSnapshot reads the snapshot records from br and loads them.
+func (c *Cache) readSnapshot(br *bufio.Reader) error {
+	now := c.clock.Now()
+	var expired []keyValue
+
+	for {
+		kind, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case snapshotEnd:
+			c.notifyExpired(c.pendExpired(expired))
+			return nil
+
+		case snapshotEntry, snapshotRelative:
+			key, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+			expiration, err := binary.ReadVarint(br)
+			if err != nil {
+				return err
+			}
+			priority, err := binary.ReadVarint(br)
+			if err != nil {
+				return err
+			}
+			value, err := c.readSnapshotValue(br, key)
+			if err != nil {
+				return err
+			}
+
+			var duration time.Duration
+			switch {
+			case kind == snapshotRelative:
+				duration = time.Duration(expiration)
+			case expiration > 0:
+				duration = time.Unix(0, expiration).Sub(now)
+			}
+			if expiration != 0 && duration <= 0 {
+				expired = append(expired, keyValue{key, value})
+				continue
+			}
+			if err := c.set(key, value, duration, 0, int(priority)); err != nil {
+				return fmt.Errorf("loading %q: %w", key, err)
+			}
+
+		case snapshotExpired:
+			key, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+			value, err := c.readSnapshotValue(br, key)
+			if err != nil {
+				return err
+			}
+			expired = append(expired, keyValue{key, value})
+
+		default:
+			return fmt.Errorf("%w: unknown record type %d", ErrCorruptSnapshot, kind)
+		}
+	}
+}
+
+// pendExpired registers OnExpired callbacks for items that expired outside
+// of the cache, such as while it was down.
+func (c *Cache) pendExpired(expired []keyValue) []expiredItem {
+	if c.onExpired == nil {
+		return nil
+	}
+
+	c.expMu.Lock()
+	defer c.expMu.Unlock()
+
+	pending := make([]expiredItem, len(expired))
+	for i, kv := range expired {
+		c.expSeq++
+		pending[i] = expiredItem{id: c.expSeq, keyValue: kv}
+		c.pendingExpired[c.expSeq] = kv
+	}
+	return pending
+}
+
+// SaveSnapshotFile writes a snapshot to the named file. The snapshot is
+// written to a temporary file first and renamed into place, so the file
+// always holds a complete snapshot.
+func (c *Cache) SaveSnapshotFile(name string) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = c.SaveSnapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// LoadSnapshotFile loads a snapshot written by SaveSnapshotFile.
+func (c *Cache) LoadSnapshotFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.LoadSnapshot(f)
+}
+
+// writeSnapshotValue writes the value of key, encoded with the codec of
+// its namespace.
+func (c *Cache) writeSnapshotValue(w *bufio.Writer, key string, value interface{}) error {
+	data, err := c.MarshalValue(key, value)
+	if err != nil {
+		return err
+	}
+	writeSnapshotBytes(w, data)
+	return nil
+}
+
+// readSnapshotValue reads the value of key written by writeSnapshotValue.
+func (c *Cache) readSnapshotValue(r *bufio.Reader, key string) (interface{}, error) {
+	data, err := readSnapshotBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.UnmarshalValue(key, data)
+}
+
+// Write errors of a bufio.Writer are sticky and reported by Flush, so the
+// helpers below do not return them.
+
+func writeSnapshotInt(w *bufio.Writer, v int64) {
+	w.Write(binary.AppendVarint(nil, v))
+}
+
+func writeSnapshotBytes(w *bufio.Writer, b []byte) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	w.Write(b)
+}
+
+func writeSnapshotString(w *bufio.Writer, s string) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	w.WriteString(s)
+}
+
+// readSnapshotBytes reads a length-prefixed byte string. The length comes
+// from the stream, so it is checked against maxSnapshotRecord, and long
+// records are read into a buffer that grows with the data actually
+// received: a corrupt length fails at the end of the stream instead of
+// allocating memory it claims.
+func readSnapshotBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotRecord {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrCorruptSnapshot, n)
+	}
+	if n <= snapshotReadChunk {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, truncatedRecord(err)
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(snapshotReadChunk)
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, truncatedRecord(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// truncatedRecord reports a record cut short by the end of the stream as
+// io.ErrUnexpectedEOF.
+func truncatedRecord(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func readSnapshotString(r *bufio.Reader) (string, error) {
+	b, err := readSnapshotBytes(r)
+	return string(b), err
+}
@@ -0,0 +1,176 @@
+package gocache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshotRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("forever", "value1")
+	cache.SetWithExpiration("short", 42, time.Minute)
+
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	if err := cache.SaveSnapshotFile(path); err != nil {
+		t.Fatalf("SaveSnapshotFile failed: %v", err)
+	}
+
+	restored := New(Options{Clock: clock})
+	defer restored.Stop()
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("LoadSnapshotFile failed: %v", err)
+	}
+
+	if value, err := restored.Get("forever"); err != nil || value != "value1" {
+		t.Errorf("Expected value1, got %v (%v)", value, err)
+	}
+	if value, err := restored.Get("short"); err != nil || value != 42 {
+		t.Errorf("Expected 42, got %v (%v)", value, err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := restored.Get("short"); err != ErrKeyExpired {
+		t.Errorf("Expected expiration time to be preserved, got %v", err)
+	}
+}
+
+func TestCacheSnapshotExpiredWhileDown(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.SetWithExpiration("job", "timeout", time.Second)
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+
+	var delivered []string
+	restored := New(Options{Clock: clock, OnExpired: func(key string, value interface{}) {
+		delivered = append(delivered, key+"="+value.(string))
+	}})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+
+	if len(delivered) != 1 || delivered[0] != "job=timeout" {
+		t.Errorf("Expected expiration callback on load, got %v", delivered)
+	}
+	if restored.ItemCount() != 0 {
+		t.Error("Expected expired item not to be loaded")
+	}
+}
+
+func TestCacheSnapshotPendingExpirations(t *testing.T) {
+	clock := newFakeClock()
+
+	// Take a snapshot while the callback is still running, as if the
+	// process crashed before it completed.
+	var buf bytes.Buffer
+	var cache *Cache
+	cache = New(Options{Clock: clock, OnExpired: func(key string, value interface{}) {
+		if err := cache.SaveSnapshot(&buf); err != nil {
+			t.Errorf("SaveSnapshot failed: %v", err)
+		}
+	}})
+	defer cache.Stop()
+
+	cache.SetWithExpiration("job", "timeout", time.Second)
+	clock.Advance(time.Minute)
+	cache.DeleteExpired()
+
+	var saved bytes.Buffer
+	if err := cache.SaveSnapshot(&saved); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	var delivered []string
+	onExpired := func(key string, value interface{}) {
+		delivered = append(delivered, key)
+	}
+
+	restored := New(Options{Clock: clock, OnExpired: onExpired})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0] != "job" {
+		t.Errorf("Expected pending callback to be redelivered, got %v", delivered)
+	}
+
+	// Once the callback completed, it is no longer pending.
+	delivered = nil
+	if err := New(Options{Clock: clock, OnExpired: onExpired}).LoadSnapshot(&saved); err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if len(delivered) != 0 {
+		t.Errorf("Expected no redelivery of completed callbacks, got %v", delivered)
+	}
+}
+
+func TestCacheSnapshotInvalid(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	if err := cache.LoadSnapshot(bytes.NewReader([]byte("nope!!"))); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Expected ErrInvalidHeader, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	WriteHeader(&buf, FormatVersion)
+	buf.WriteByte(9)
+	if err := cache.LoadSnapshot(&buf); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("Expected ErrCorruptSnapshot, got %v", err)
+	}
+}
+
+func TestCacheSnapshotRecordLengths(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	// A plain snapshot whose first entry's key has the given length field,
+	// followed by data.
+	entry := func(length []byte, data string) *bytes.Buffer {
+		var buf bytes.Buffer
+		buf.WriteString("GOCA\x00\x03")
+		buf.WriteByte(snapshotPlain)
+		buf.WriteByte(snapshotEntry)
+		buf.Write(length)
+		buf.WriteString(data)
+		return &buf
+	}
+	oversized := map[string]*bytes.Buffer{
+		"1<<42":      entry(binary.AppendUvarint(nil, 1<<42), ""),
+		"max uint64": entry([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, ""),
+	}
+	for name, snapshot := range oversized {
+		if err := cache.LoadSnapshot(snapshot); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("%s: Expected ErrCorruptSnapshot, got %v", name, err)
+		}
+	}
+
+	truncated := map[string]*bytes.Buffer{
+		"short": entry(binary.AppendUvarint(nil, 5), "ab"),
+		"large": entry(binary.AppendUvarint(nil, maxSnapshotRecord), strings.Repeat("x", 100000)),
+	}
+	for name, snapshot := range truncated {
+		if err := cache.LoadSnapshot(snapshot); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: Expected io.ErrUnexpectedEOF, got %v", name, err)
+		}
+	}
+	if n := cache.ItemCount(); n != 0 {
+		t.Errorf("Expected no items from corrupt snapshots, got %d", n)
+	}
+}
@@ -0,0 +1,336 @@
+package gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats contains counters describing cache activity since the cache was
+// created.
+type Stats struct {
+	Hits        uint64 // Get calls that found an unexpired item
+	Misses      uint64 // Get calls that found no item or an expired one
+	Expirations uint64 // Items removed because they expired
+	Evictions   uint64 // Items removed to make room for new ones
+	Items       int    // Items currently stored, including expired ones
+
+	// ExpiredOverwrites counts Set calls that overwrote an expired item
+	// before it was cleaned up.
+	ExpiredOverwrites uint64
+
+	// SoftLimitWarnings counts how often usage crossed Options.SoftLimit.
+	SoftLimitWarnings uint64
+
+	// StalenessChecks counts the hits of a namespace whose age was checked
+	// against NamespaceOptions.MaxStaleness, and StalenessViolations those
+	// that were older. They are always zero for the cache as a whole.
+	StalenessChecks     uint64
+	StalenessViolations uint64
+
+	// ValueSizes and TTLs are histograms of the values stored by Set and
+	// its variants, if Options.TrackDistributions is set, for capacity
+	// planning and to spot callers setting pathological TTLs. ValueSizes[i]
+	// counts the values of at most ValueSizeBounds[i] bytes and more than
+	// the previous bound, as measured for MaxValueSize; TTLs[i] likewise
+	// counts TTLs by TTLBounds, and its last bucket the values that never
+	// expire.
+	ValueSizes [len(ValueSizeBounds) + 1]uint64
+	TTLs       [len(TTLBounds) + 2]uint64
+}
+
+// HitRatio returns the fraction of Get calls that were hits, or 0 if there
+// were no Get calls.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// counters holds the live, atomically updated activity counters. They only
+// ever grow; ResetStats records a base to subtract instead of zeroing them,
+// so that StatsHistory is not disturbed.
+type counters struct {
+	hits        uint64
+	misses      uint64
+	expirations uint64
+	evictions   uint64
+
+	expiredOverwrites uint64
+	softLimitWarnings uint64
+
+	stalenessChecks     uint64
+	stalenessViolations uint64
+
+	valueSizes [len(ValueSizeBounds) + 1]uint64
+	ttls       [len(TTLBounds) + 2]uint64
+
+	baseMu sync.Mutex
+	base   Stats // totals at the last ResetStats
+}
+
+// load returns the totals of the counters since they were created.
+func (cs *counters) load() Stats {
+	s := Stats{
+		Hits:        atomic.LoadUint64(&cs.hits),
+		Misses:      atomic.LoadUint64(&cs.misses),
+		Expirations: atomic.LoadUint64(&cs.expirations),
+		Evictions:   atomic.LoadUint64(&cs.evictions),
+
+		ExpiredOverwrites: atomic.LoadUint64(&cs.expiredOverwrites),
+		SoftLimitWarnings: atomic.LoadUint64(&cs.softLimitWarnings),
+
+		StalenessChecks:     atomic.LoadUint64(&cs.stalenessChecks),
+		StalenessViolations: atomic.LoadUint64(&cs.stalenessViolations),
+	}
+	for i := range cs.valueSizes {
+		s.ValueSizes[i] = atomic.LoadUint64(&cs.valueSizes[i])
+	}
+	for i := range cs.ttls {
+		s.TTLs[i] = atomic.LoadUint64(&cs.ttls[i])
+	}
+	return s
+}
+
+// sinceReset returns the activity since the last reset.
+func (cs *counters) sinceReset() Stats {
+	cs.baseMu.Lock()
+	defer cs.baseMu.Unlock()
+	return cs.load().sub(cs.base)
+}
+
+// reset makes subsequent calls to sinceReset count from zero.
+func (cs *counters) reset() {
+	cs.baseMu.Lock()
+	defer cs.baseMu.Unlock()
+	cs.base = cs.load()
+}
+
+// Stats returns a snapshot of the cache's activity counters since the cache
+// was created or ResetStats was last called.
+func (c *Cache) Stats() Stats {
+	stats := c.counters.sinceReset()
+	stats.Items = c.ItemCount()
+	return stats
+}
+
+// ResetStats starts the activity counters returned by Stats from zero, for
+// example to measure a clean window during a load test. It does not affect
+// StatsHistory or the stats of namespaces.
+func (c *Cache) ResetStats() {
+	c.counters.reset()
+}
+
+// countExpiration records the expiration of key.
+func (c *Cache) countExpiration(key string) {
+	atomic.AddUint64(&c.counters.expirations, 1)
+	if ns := c.namespaceOf(key); ns != nil {
+		atomic.AddUint64(&ns.counters.expirations, 1)
+	}
+}
+
+// countEviction records the eviction of key.
+func (c *Cache) countEviction(key string) {
+	atomic.AddUint64(&c.counters.evictions, 1)
+	if ns := c.namespaceOf(key); ns != nil {
+		atomic.AddUint64(&ns.counters.evictions, 1)
+	}
+}
+
+// sub returns the activity between base and s. Items is taken from s.
+func (s Stats) sub(base Stats) Stats {
+	d := Stats{
+		Hits:        s.Hits - base.Hits,
+		Misses:      s.Misses - base.Misses,
+		Expirations: s.Expirations - base.Expirations,
+		Evictions:   s.Evictions - base.Evictions,
+		Items:       s.Items,
+
+		ExpiredOverwrites: s.ExpiredOverwrites - base.ExpiredOverwrites,
+		SoftLimitWarnings: s.SoftLimitWarnings - base.SoftLimitWarnings,
+
+		StalenessChecks:     s.StalenessChecks - base.StalenessChecks,
+		StalenessViolations: s.StalenessViolations - base.StalenessViolations,
+	}
+	for i := range s.ValueSizes {
+		d.ValueSizes[i] = s.ValueSizes[i] - base.ValueSizes[i]
+	}
+	for i := range s.TTLs {
+		d.TTLs[i] = s.TTLs[i] - base.TTLs[i]
+	}
+	return d
+}
+
+// Sizes of the downsampled stats rings.
+const (
+	hourlySamples = 48
+	dailySamples  = 30
+)
+
+// Recent activity is kept as totals taken at most every recentResolution,
+// for windows of up to MaxRecentWindow.
+const (
+	MaxRecentWindow  = 15 * time.Minute
+	recentResolution = 10 * time.Second
+)
+
+// StatsSample aggregates cache activity over one period of StatsHistory.
+type StatsSample struct {
+	Start    time.Time
+	Duration time.Duration
+	Stats    // Activity during the period; Items is the size at its end
+}
+
+// StatsHistory holds downsampled stats since the cache was created, oldest
+// first: up to 48 hourly samples and 30 daily samples.
+type StatsHistory struct {
+	Hourly []StatsSample
+	Daily  []StatsSample
+}
+
+// StatsHistory returns hourly and daily aggregates of cache activity, so
+// trends can be shown rather than only instantaneous counters. Only completed
+// periods are included.
+//
+// History advances whenever the cleanup goroutine runs and whenever this
+// method is called. If neither happens for longer than a period, the activity
+// of the gap is attributed to the period in which it started.
+func (c *Cache) StatsHistory() StatsHistory {
+	c.recordHistory(c.clock.Now())
+
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+
+	return StatsHistory{
+		Hourly: c.history.hourly.samples(),
+		Daily:  c.history.daily.samples(),
+	}
+}
+
+// RecentStats returns the cache's activity over about the last window, up
+// to MaxRecentWindow, such as the hit ratio of the last 1, 5 or 15 minutes,
+// so that dashboards show current behavior rather than the average since
+// the cache was created. Items is the current size.
+//
+// Activity is measured from totals taken every 10 seconds when the cleanup
+// goroutine runs and whenever StatsHistory or RecentStats is called, so
+// the sample's Start and Duration give the span actually measured: a
+// little longer than window, or shorter if the cache has not been measured
+// for that long, down to 0 on the first call without a cleanup goroutine.
+// ResetStats does not affect it.
+func (c *Cache) RecentStats(window time.Duration) StatsSample {
+	window = min(window, MaxRecentWindow)
+	now := c.clock.Now()
+	stats := c.counters.load()
+	stats.Items = c.ItemCount()
+
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+
+	c.history.record(now, stats)
+	base := c.history.recent[0]
+	for _, total := range c.history.recent[1:] {
+		if total.Start.After(now.Add(-window)) {
+			break
+		}
+		base = total
+	}
+	return StatsSample{Start: base.Start, Duration: now.Sub(base.Start), Stats: stats.sub(base.Stats)}
+}
+
+// recordHistory closes any periods that have ended at now.
+func (c *Cache) recordHistory(now time.Time) {
+	stats := c.counters.load()
+	stats.Items = c.ItemCount()
+
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+
+	c.history.hourly.advance(now, stats)
+	c.history.daily.advance(now, stats)
+	c.history.record(now, stats)
+}
+
+// history holds the downsampled stats rings and the recent totals.
+type history struct {
+	mu     sync.Mutex
+	hourly sampleRing
+	daily  sampleRing
+	recent []StatsSample // totals as of Start, oldest first
+}
+
+// record adds the totals at now to the recent totals, unless the last were
+// taken less than recentResolution ago, and drops those no longer needed
+// for a window of MaxRecentWindow. It must be called with h.mu held.
+func (h *history) record(now time.Time, stats Stats) {
+	if n := len(h.recent); n > 0 && now.Sub(h.recent[n-1].Start) < recentResolution {
+		return
+	}
+	h.recent = append(h.recent, StatsSample{Start: now, Stats: stats})
+	drop := 0
+	for drop+1 < len(h.recent) && !h.recent[drop+1].Start.After(now.Add(-MaxRecentWindow)) {
+		drop++
+	}
+	h.recent = append(h.recent[:0], h.recent[drop:]...)
+}
+
+// newHistory returns a history whose first periods start at now.
+func newHistory(now time.Time) *history {
+	return &history{
+		hourly: newSampleRing(time.Hour, hourlySamples, now),
+		daily:  newSampleRing(24*time.Hour, dailySamples, now),
+	}
+}
+
+// sampleRing is a fixed-size ring of samples of one period length.
+type sampleRing struct {
+	period time.Duration
+	ring   []StatsSample
+	next   int
+	full   bool
+
+	start time.Time // start of the current period
+	base  Stats     // cumulative stats at the start of the current period
+}
+
+func newSampleRing(period time.Duration, size int, now time.Time) sampleRing {
+	return sampleRing{
+		period: period,
+		ring:   make([]StatsSample, size),
+		start:  now.Truncate(period),
+	}
+}
+
+// advance closes the current period if now is past its end, recording the
+// activity between base and stats.
+func (r *sampleRing) advance(now time.Time, stats Stats) {
+	current := now.Truncate(r.period)
+	if !current.After(r.start) {
+		return
+	}
+
+	r.ring[r.next] = StatsSample{
+		Start:    r.start,
+		Duration: r.period,
+		Stats:    stats.sub(r.base),
+	}
+	r.next = (r.next + 1) % len(r.ring)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	r.start = current
+	r.base = stats
+}
+
+// samples returns the recorded samples, oldest first.
+func (r *sampleRing) samples() []StatsSample {
+	if !r.full {
+		return append([]StatsSample(nil), r.ring[:r.next]...)
+	}
+	out := make([]StatsSample, 0, len(r.ring))
+	out = append(out, r.ring[r.next:]...)
+	return append(out, r.ring[:r.next]...)
+}
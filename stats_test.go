@@ -0,0 +1,167 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{DefaultExpiration: time.Minute, Clock: clock})
+
+	cache.Set("key1", "value1")
+	cache.SetWithExpiration("key2", "value2", 50*time.Millisecond)
+
+	cache.Get("key1")
+	cache.Get("key1")
+	cache.Get("missing")
+
+	clock.Advance(100 * time.Millisecond)
+	cache.Get("key2")
+
+	stats := cache.Stats()
+	if stats.Hits != 2 {
+		t.Errorf("Expected 2 hits, got %d", stats.Hits)
+	}
+	if stats.Misses != 2 {
+		t.Errorf("Expected 2 misses, got %d", stats.Misses)
+	}
+	if stats.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, got %d", stats.Expirations)
+	}
+	if stats.Items != 1 {
+		t.Errorf("Expected 1 item, got %d", stats.Items)
+	}
+	if ratio := stats.HitRatio(); ratio != 0.5 {
+		t.Errorf("Expected hit ratio 0.5, got %v", ratio)
+	}
+}
+
+func TestCacheStatsHistory(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	start := clock.Now()
+
+	cache.Set("key1", "value1")
+	cache.Get("key1")
+	clock.Advance(time.Hour)
+	cache.StatsHistory()
+
+	cache.Get("key1")
+	cache.Get("missing")
+	clock.Advance(time.Hour)
+
+	history := cache.StatsHistory()
+	if len(history.Hourly) != 2 {
+		t.Fatalf("Expected 2 hourly samples, got %d", len(history.Hourly))
+	}
+	if history.Hourly[0].Hits != 1 || history.Hourly[0].Misses != 0 {
+		t.Errorf("Unexpected first sample: %+v", history.Hourly[0])
+	}
+	if history.Hourly[1].Hits != 1 || history.Hourly[1].Misses != 1 {
+		t.Errorf("Unexpected second sample: %+v", history.Hourly[1])
+	}
+	if !history.Hourly[1].Start.After(history.Hourly[0].Start) {
+		t.Error("Expected hourly samples in chronological order")
+	}
+
+	for i := 0; i < hourlySamples+5; i++ {
+		cache.recordHistory(start.Add(time.Duration(3+i) * time.Hour))
+	}
+	history = cache.StatsHistory()
+	if len(history.Hourly) != hourlySamples {
+		t.Errorf("Expected %d hourly samples, got %d", hourlySamples, len(history.Hourly))
+	}
+	if len(history.Daily) == 0 {
+		t.Error("Expected at least one daily sample")
+	}
+}
+
+func TestCacheResetStats(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	users := cache.Namespace("users", NamespaceOptions{})
+	cache.Set("key", "value")
+	users.Set("u1", "alice")
+	cache.Get("key")
+	cache.Get("missing")
+	users.Get("u1")
+
+	cache.ResetStats()
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Items != 2 {
+		t.Errorf("Expected zeroed counters with live item count, got %+v", stats)
+	}
+	if stats := users.Stats(); stats.Hits != 1 {
+		t.Errorf("Expected namespace stats to be unaffected, got %+v", stats)
+	}
+
+	cache.Get("key")
+	if stats := cache.Stats(); stats.Hits != 1 {
+		t.Errorf("Expected counting to resume from zero, got %+v", stats)
+	}
+
+	users.ResetStats()
+	if stats := users.Stats(); stats.Hits != 0 {
+		t.Errorf("Expected namespace stats to reset, got %+v", stats)
+	}
+
+	// History keeps the activity from before the reset.
+	clock.Advance(time.Hour)
+	history := cache.StatsHistory()
+	if len(history.Hourly) != 1 || history.Hourly[0].Hits != 3 {
+		t.Errorf("Expected history to include all hits, got %+v", history.Hourly)
+	}
+}
+
+func TestCacheRecentStats(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if sample := cache.RecentStats(time.Minute); sample.Duration != 0 || sample.Hits != 0 {
+		t.Errorf("Expected an empty first sample, got %+v", sample)
+	}
+
+	cache.Set("key", "value")
+	// Ten minutes of misses, then a minute of hits, measured every 30s.
+	for i := 0; i < 22; i++ {
+		if i < 20 {
+			cache.Get("missing")
+		} else {
+			cache.Get("key")
+		}
+		clock.Advance(30 * time.Second)
+		cache.RecentStats(time.Minute)
+	}
+
+	recent := cache.RecentStats(time.Minute)
+	if recent.Duration != time.Minute || recent.Hits != 2 || recent.Misses != 0 {
+		t.Errorf("Expected the last minute to have 2 hits, got %+v", recent)
+	}
+	if ratio := recent.HitRatio(); ratio != 1 {
+		t.Errorf("Expected a recent hit ratio of 1, got %v", ratio)
+	}
+	longer := cache.RecentStats(5 * time.Minute)
+	if longer.Duration != 5*time.Minute || longer.Hits != 2 || longer.Misses != 8 {
+		t.Errorf("Expected the last 5 minutes to have 2 hits and 8 misses, got %+v", longer)
+	}
+	all := cache.RecentStats(time.Hour)
+	if all.Duration != 11*time.Minute || all.Hits != 2 || all.Misses != 20 {
+		t.Errorf("Expected the window to cover all 11 minutes, got %+v", all)
+	}
+
+	// Totals older than MaxRecentWindow are dropped.
+	clock.Advance(10 * time.Minute)
+	cache.RecentStats(time.Minute)
+	if all := cache.RecentStats(time.Hour); all.Duration != MaxRecentWindow {
+		t.Errorf("Expected the longest window to be bounded, got %v", all.Duration)
+	}
+	if n := len(cache.history.recent); n != 12 {
+		t.Errorf("Expected the totals of the last 15 minutes to be kept, got %d", n)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 20 {
+		t.Errorf("Expected Stats to be unaffected, got %+v", stats)
+	}
+}
@@ -0,0 +1,249 @@
+package gocache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// store holds the items of a cache. Methods that modify the store are
+// called with c.mu held for writing, and get, len and rangeItems with c.mu
+// held for reading or writing. If lockFree reports true, lookup may also be
+// called without holding c.mu at all, concurrently with writers. Changes
+// made under the write lock only need to be visible to lookup after commit,
+// which is called just before the write lock is released.
+type store interface {
+	get(key string) (Item, bool)
+	lookup(key string) (Item, bool)
+	set(key string, item Item)
+	delete(key string)
+	len() int
+	rangeItems(fn func(key string, item Item) bool)
+	clear()
+	commit()
+	lockFree() bool
+}
+
+// newStore returns the store selected by options. Stores that serialize
+// values use codec.
+func newStore(options Options, codec Codec) store {
+	switch options.Storage {
+	case BytesStorage:
+		return newBytesStore(codec)
+	case ArenaStorage:
+		return newArenaStore()
+	}
+	if options.CopyOnWrite {
+		s := &cowStore{}
+		s.published.Store(&map[string]Item{})
+		return s
+	}
+	if options.ReadOptimized {
+		return &syncMapStore{}
+	}
+	return mapStore{}
+}
+
+// mapStore is the default store, a plain map guarded by c.mu.
+type mapStore map[string]Item
+
+func (s mapStore) get(key string) (Item, bool) {
+	item, found := s[key]
+	return item, found
+}
+
+func (s mapStore) lookup(key string) (Item, bool) {
+	return s.get(key)
+}
+
+func (s mapStore) set(key string, item Item) {
+	s[key] = item
+}
+
+func (s mapStore) delete(key string) {
+	delete(s, key)
+}
+
+func (s mapStore) len() int {
+	return len(s)
+}
+
+func (s mapStore) rangeItems(fn func(key string, item Item) bool) {
+	for k, v := range s {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s mapStore) clear() {
+	clear(s)
+}
+
+func (s mapStore) commit() {}
+
+func (s mapStore) lockFree() bool {
+	return false
+}
+
+// syncMapStore keeps items in a sync.Map so that lookups never take a lock.
+// It suits workloads that overwhelmingly read a stable set of keys, where
+// even the read lock of mapStore shows up as contention.
+//
+// A sync.Map makes each change visible at once, so a writer changing
+// several keys, such as a transaction of Update, would show lookups a part
+// of its changes. The first change under the write lock therefore locks
+// writing, and commit unlocks it; lookups made meanwhile wait on writing,
+// so they see either none or all of the changes. Lookups made while no
+// write is in progress take no lock.
+type syncMapStore struct {
+	m       sync.Map
+	count   int // guarded by c.mu
+	writing sync.RWMutex
+	dirty   atomic.Bool // whether writing is locked
+}
+
+func (s *syncMapStore) get(key string) (Item, bool) {
+	v, found := s.m.Load(key)
+	if !found {
+		return Item{}, false
+	}
+	return v.(Item), true
+}
+
+func (s *syncMapStore) lookup(key string) (Item, bool) {
+	if s.dirty.Load() {
+		s.writing.RLock()
+		defer s.writing.RUnlock()
+	}
+	return s.get(key)
+}
+
+// change locks writing before the first change under the write lock.
+func (s *syncMapStore) change() {
+	if !s.dirty.Load() {
+		s.writing.Lock()
+		s.dirty.Store(true)
+	}
+}
+
+func (s *syncMapStore) set(key string, item Item) {
+	s.change()
+	if _, loaded := s.m.Swap(key, item); !loaded {
+		s.count++
+	}
+}
+
+func (s *syncMapStore) delete(key string) {
+	s.change()
+	if _, loaded := s.m.LoadAndDelete(key); loaded {
+		s.count--
+	}
+}
+
+func (s *syncMapStore) len() int {
+	return s.count
+}
+
+func (s *syncMapStore) rangeItems(fn func(key string, item Item) bool) {
+	s.m.Range(func(k, v interface{}) bool {
+		return fn(k.(string), v.(Item))
+	})
+}
+
+func (s *syncMapStore) clear() {
+	s.change()
+	s.m.Range(func(k, _ interface{}) bool {
+		s.m.Delete(k)
+		return true
+	})
+	s.count = 0
+}
+
+func (s *syncMapStore) commit() {
+	if s.dirty.Load() {
+		s.dirty.Store(false)
+		s.writing.Unlock()
+	}
+}
+
+func (s *syncMapStore) lockFree() bool {
+	return true
+}
+
+// cowStore publishes an immutable map through an atomic pointer. Writers
+// copy the published map into dirty on their first change under the write
+// lock and publish the copy on commit, so lookups read a consistent map
+// without any locking.
+type cowStore struct {
+	published atomic.Pointer[map[string]Item]
+	dirty     map[string]Item // guarded by c.mu, nil when nothing changed
+}
+
+// current returns the map writers should read: the pending copy if there
+// is one, otherwise the published map.
+func (s *cowStore) current() map[string]Item {
+	if s.dirty != nil {
+		return s.dirty
+	}
+	return *s.published.Load()
+}
+
+// writable returns the pending copy, creating it if needed.
+func (s *cowStore) writable() map[string]Item {
+	if s.dirty == nil {
+		published := *s.published.Load()
+		s.dirty = make(map[string]Item, len(published)+1)
+		for k, v := range published {
+			s.dirty[k] = v
+		}
+	}
+	return s.dirty
+}
+
+func (s *cowStore) get(key string) (Item, bool) {
+	item, found := s.current()[key]
+	return item, found
+}
+
+func (s *cowStore) lookup(key string) (Item, bool) {
+	item, found := (*s.published.Load())[key]
+	return item, found
+}
+
+func (s *cowStore) set(key string, item Item) {
+	s.writable()[key] = item
+}
+
+func (s *cowStore) delete(key string) {
+	if _, found := s.current()[key]; found {
+		delete(s.writable(), key)
+	}
+}
+
+func (s *cowStore) len() int {
+	return len(s.current())
+}
+
+func (s *cowStore) rangeItems(fn func(key string, item Item) bool) {
+	for k, v := range s.current() {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s *cowStore) clear() {
+	s.dirty = make(map[string]Item)
+}
+
+func (s *cowStore) commit() {
+	if s.dirty != nil {
+		m := s.dirty
+		s.published.Store(&m)
+		s.dirty = nil
+	}
+}
+
+func (s *cowStore) lockFree() bool {
+	return true
+}
@@ -0,0 +1,105 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// storeOptions lists the cache configurations that select each store.
+var storeOptions = map[string]Options{
+	"map":           {},
+	"ReadOptimized": {ReadOptimized: true},
+	"CopyOnWrite":   {CopyOnWrite: true},
+	"BytesStorage":  {Storage: BytesStorage},
+	"ArenaStorage":  {Storage: ArenaStorage},
+}
+
+func TestCacheStores(t *testing.T) {
+	for name, options := range storeOptions {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			options.Clock = clock
+			options.MaxEntries = 3
+			cache := New(options)
+
+			cache.Set("key1", "value1")
+			cache.SetWithExpiration("key2", "value2", time.Second)
+			cache.Set("key3", "value3")
+			cache.Set("key3", "updated")
+
+			if value, err := cache.Get("key3"); err != nil || value != "updated" {
+				t.Errorf("Expected 'updated', got '%v' (%v)", value, err)
+			}
+			if count := cache.ItemCount(); count != 3 {
+				t.Errorf("Expected 3 items, got %d", count)
+			}
+
+			cache.Get("key1")
+			cache.Set("key4", "value4") // evicts key2, the least recently used
+			if _, err := cache.Get("key2"); err != ErrKeyNotFound {
+				t.Errorf("Expected key2 to be evicted, got %v", err)
+			}
+
+			cache.SetWithExpiration("key5", "value5", time.Second)
+			clock.Advance(2 * time.Second)
+			cache.DeleteExpired()
+			if items := cache.Items(); len(items) != 2 {
+				t.Errorf("Expected 2 items after expiration, got %v", items)
+			}
+
+			cache.Delete("key1")
+			cache.Flush()
+			if count := cache.ItemCount(); count != 0 {
+				t.Errorf("Expected 0 items after flush, got %d", count)
+			}
+		})
+	}
+}
+
+func TestCacheStoresConcurrency(t *testing.T) {
+	for name, options := range storeOptions {
+		t.Run(name, func(t *testing.T) {
+			cache := New(options)
+			done := make(chan bool)
+
+			for i := 0; i < 10; i++ {
+				go func(index int) {
+					for j := 0; j < 100; j++ {
+						key := fmt.Sprintf("key%d", j%10)
+						cache.Set(key, j)
+						cache.Get(key)
+						if j%7 == 0 {
+							cache.Delete(key)
+						}
+					}
+					done <- true
+				}(i)
+			}
+
+			for i := 0; i < 10; i++ {
+				<-done
+			}
+		})
+	}
+}
+
+func BenchmarkCacheGetParallel(b *testing.B) {
+	for name, options := range storeOptions {
+		b.Run(name, func(b *testing.B) {
+			cache := New(options)
+			for i := 0; i < 1000; i++ {
+				cache.Set(fmt.Sprintf("key%d", i), i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.Get(fmt.Sprintf("key%d", i%1000))
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package gocache
+
+import (
+	"io"
+)
+
+// WriteTo writes the cache to w like SaveSnapshot, but with the time each
+// item has left to live rather than its absolute expiration time, so that
+// ReadFrom restores the remaining TTLs relative to the time of loading.
+// This suits moving a cache between machines whose clocks disagree, or
+// reloading an export after some time without losing its contents to
+// expiration. Values are encoded with Options.Codec, such as GobCodec or
+// MsgpackCodec. The stream is written item by item and never held in
+// memory as a whole. It implements io.WriterTo.
+func (c *Cache) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := c.saveSnapshot(cw, true)
+	return cw.n, err
+}
+
+// ReadFrom adds the items of a stream written by WriteTo to the cache. It
+// also reads snapshots written by SaveSnapshot, and is then equivalent to
+// LoadSnapshot. It implements io.ReaderFrom; the count it returns may
+// include bytes read ahead of the end of the stream.
+func (c *Cache) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	err := c.LoadSnapshot(cr)
+	return cr.n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,52 @@
+package gocache
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+var (
+	_ io.WriterTo   = (*Cache)(nil)
+	_ io.ReaderFrom = (*Cache)(nil)
+)
+
+func TestCacheWriteToReadFrom(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	cache.Set("forever", "value1")
+	cache.SetWithExpiration("short", 42, time.Minute)
+
+	var buf bytes.Buffer
+	written, err := cache.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if written != int64(buf.Len()) {
+		t.Errorf("Expected WriteTo to report %d bytes, got %d", buf.Len(), written)
+	}
+
+	// The stream is loaded an hour later, by a cache whose clock is behind.
+	later := newFakeClock()
+	later.Advance(time.Hour)
+	restored := New(Options{Clock: later})
+	defer restored.Stop()
+	if _, err := restored.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+
+	if value, err := restored.Get("forever"); err != nil || value != "value1" {
+		t.Errorf("Expected value1, got %v (%v)", value, err)
+	}
+	later.Advance(30 * time.Second)
+	if value, err := restored.Get("short"); err != nil || value != 42 {
+		t.Errorf("Expected the remaining TTL to start at load time, got %v (%v)", value, err)
+	}
+	later.Advance(time.Minute)
+	if _, err := restored.Get("short"); err != ErrKeyExpired {
+		t.Errorf("Expected short to expire after its remaining TTL, got %v", err)
+	}
+}
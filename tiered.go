@@ -0,0 +1,148 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Tier is a shared cache behind the local cache of a TieredCache, such as
+// Redis with the redistier package. Values are exchanged as bytes encoded
+// with the local cache's codec.
+type Tier interface {
+	// Get returns the value of key, or an error matching ErrKeyNotFound
+	// if it is missing.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl, or without expiration if ttl is
+	// 0.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// TieredOptions configures a TieredCache.
+type TieredOptions struct {
+	// L1TTL is how long values are kept in the local cache, including
+	// values copied from the shared tier on a local miss. Keep it short:
+	// changes made by other processes are only seen once it has passed.
+	// If 0, the local cache's default expiration is used.
+	L1TTL time.Duration
+
+	// L2TTL is how long values are kept in the shared tier. If 0, they do
+	// not expire there.
+	L2TTL time.Duration
+}
+
+// TieredCache is a two-level "near cache": a local Cache (L1) in front of a
+// shared Tier (L2) such as Redis. Get reads L1 first, then L2, copying L2
+// hits into L1; Set and Delete write through to both. Concurrent Gets of a
+// key missing from L1 share a single L2 read.
+type TieredCache struct {
+	l1      *Cache
+	l2      Tier
+	options TieredOptions
+
+	mu    sync.Mutex
+	calls map[string]*memoCall[interface{}]
+}
+
+// NewTiered returns a TieredCache with l1 in front of l2.
+func NewTiered(l1 *Cache, l2 Tier, options TieredOptions) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, options: options, calls: make(map[string]*memoCall[interface{}])}
+}
+
+// L1 returns the local cache.
+func (t *TieredCache) L1() *Cache {
+	return t.l1
+}
+
+// Get returns the value of key from L1, or else from L2. It returns an
+// error matching ErrKeyNotFound if neither has the key.
+func (t *TieredCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if value, err := t.l1.Get(key); err == nil {
+		return value, nil
+	}
+
+	t.mu.Lock()
+	if call, ok := t.calls[key]; ok {
+		t.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &memoCall[interface{}]{done: make(chan struct{})}
+	t.calls[key] = call
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.calls, key)
+		t.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = t.fetch(ctx, key)
+	return call.value, call.err
+}
+
+// fetch reads key from L2 and copies it into L1.
+func (t *TieredCache) fetch(ctx context.Context, key string) (interface{}, error) {
+	data, err := t.l2.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := t.l1.codec.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	// A value L1 refuses, for example because it is too large, is still
+	// returned.
+	t.setL1(key, value, 0)
+	return value, nil
+}
+
+// Set stores value under key in L2 with L2TTL, then in L1.
+func (t *TieredCache) Set(ctx context.Context, key string, value interface{}) error {
+	return t.SetWithExpiration(ctx, key, value, t.options.L2TTL)
+}
+
+// SetWithExpiration stores value under key in L2 for duration, then in L1
+// for at most duration. If writing to L2 fails, key is removed from L1, so
+// that it does not keep a value the other processes do not see.
+func (t *TieredCache) SetWithExpiration(ctx context.Context, key string, value interface{}, duration time.Duration) error {
+	if value == nil && !t.l1.allowNil {
+		return ErrNilValue
+	}
+	data, err := t.l1.codec.Marshal(&value)
+	if err != nil {
+		return err
+	}
+	if err := t.l2.Set(ctx, key, data, duration); err != nil {
+		t.l1.Delete(key)
+		return err
+	}
+	return t.setL1(key, value, duration)
+}
+
+// setL1 stores value in L1 for L1TTL, or the default expiration, capped at
+// limit unless it is 0.
+func (t *TieredCache) setL1(key string, value interface{}, limit time.Duration) error {
+	ttl := t.options.L1TTL
+	if ttl == 0 {
+		ttl = t.l1.defaultExpiration()
+	}
+	if limit > 0 && (ttl <= 0 || limit < ttl) {
+		ttl = limit
+	}
+	return t.l1.SetWithExpiration(key, value, ttl)
+}
+
+// Delete removes key from L1 and L2. Other processes keep the key in their
+// L1 until their L1TTL passes.
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	t.l1.Delete(key)
+	if err := t.l2.Delete(ctx, key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mapTier is an in-memory Tier counting its reads.
+type mapTier struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	gets   int32
+	fail   error
+}
+
+func newMapTier() *mapTier {
+	return &mapTier{values: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (m *mapTier) Get(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt32(&m.gets, 1)
+	time.Sleep(10 * time.Millisecond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.values[key]; ok {
+		return v, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (m *mapTier) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail != nil {
+		return m.fail
+	}
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *mapTier) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+	l2 := newMapTier()
+	writer := NewTiered(New(Options{}), l2, TieredOptions{L1TTL: time.Minute, L2TTL: time.Hour})
+	reader := NewTiered(New(Options{}), l2, TieredOptions{L1TTL: time.Minute})
+
+	if err := writer.Set(ctx, "user:1", "alice"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if l2.ttls["user:1"] != time.Hour {
+		t.Errorf("Expected L2TTL in L2, got %v", l2.ttls["user:1"])
+	}
+	if _, expires, _ := writer.L1().GetWithExpiration("user:1"); time.Until(expires) > time.Minute {
+		t.Errorf("Expected L1TTL in L1, got %v", expires)
+	}
+
+	// Concurrent misses share one L2 read, and fill L1.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := reader.Get(ctx, "user:1"); err != nil || value != "alice" {
+				t.Errorf("Expected alice, got %v (%v)", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&l2.gets); n != 1 {
+		t.Errorf("Expected 1 L2 read, got %d", n)
+	}
+	if value, err := reader.L1().Get("user:1"); err != nil || value != "alice" {
+		t.Errorf("Expected the L2 hit in L1, got %v (%v)", value, err)
+	}
+
+	if _, err := reader.Get(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	if err := writer.Delete(ctx, "user:1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := writer.Get(ctx, "user:1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected a deleted key to be gone from both tiers, got %v", err)
+	}
+
+	// A failed write to L2 leaves nothing in L1.
+	writer.L1().Set("k", "old")
+	l2.fail = errors.New("unavailable")
+	if err := writer.Set(ctx, "k", "new"); err == nil {
+		t.Error("Expected the L2 error")
+	}
+	if _, err := writer.L1().Get("k"); err == nil {
+		t.Error("Expected k to be removed from L1 after a failed write")
+	}
+}
@@ -0,0 +1,221 @@
+package gocache
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// Regions of a W-TinyLFU policy.
+const (
+	regionWindow    = iota // recently added keys
+	regionProbation        // main keys not accessed since their admission
+	regionProtected        // main keys accessed again while in probation
+)
+
+// tinyLFUPolicy is the W-TinyLFU policy: new keys enter a small LRU window;
+// when the cache is full, the window's oldest key is admitted to the main
+// region only if it has been used more often than the main region's victim,
+// according to a frequency sketch of recent accesses. The main region is a
+// segmented LRU, where keys accessed again move from probation to a
+// protected segment.
+type tinyLFUPolicy struct {
+	windowCap    int
+	protectedCap int
+
+	window    *list.List // front is most recently used
+	probation *list.List
+	protected *list.List
+	elements  map[string]*list.Element
+
+	sketch *countMinSketch
+}
+
+// tinyLFUEntry is a key in one of the regions.
+type tinyLFUEntry struct {
+	key    string
+	region int
+}
+
+// NewTinyLFUPolicy returns an EvictionPolicy implementing W-TinyLFU for a
+// cache of about capacity items, which should be MaxEntries, or MaxCost
+// divided by the average item cost. It keeps the hit rate high for
+// workloads mixing popular keys with scans of one-off keys, which an LRU
+// would let flush the popular ones out, at the cost of a few bytes of
+// frequency counters per item.
+func NewTinyLFUPolicy(capacity int) EvictionPolicy {
+	if capacity < 1 {
+		capacity = 1
+	}
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	return &tinyLFUPolicy{
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * 4 / 5,
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		elements:     make(map[string]*list.Element),
+		sketch:       newCountMinSketch(capacity),
+	}
+}
+
+func (p *tinyLFUPolicy) OnAdd(key string) {
+	p.sketch.increment(key)
+	if _, found := p.elements[key]; found {
+		p.OnAccess(key)
+		return
+	}
+	p.elements[key] = p.window.PushFront(&tinyLFUEntry{key: key, region: regionWindow})
+	// Until the cache is full, keys leaving the window are admitted freely.
+	for p.window.Len() > p.windowCap {
+		p.move(p.window.Back(), regionProbation)
+	}
+}
+
+func (p *tinyLFUPolicy) OnAccess(key string) {
+	e, found := p.elements[key]
+	if !found {
+		return
+	}
+	p.sketch.increment(key)
+	switch e.Value.(*tinyLFUEntry).region {
+	case regionWindow:
+		p.window.MoveToFront(e)
+	case regionProbation:
+		p.move(e, regionProtected)
+		for p.protected.Len() > p.protectedCap {
+			p.move(p.protected.Back(), regionProbation)
+		}
+	case regionProtected:
+		p.protected.MoveToFront(e)
+	}
+}
+
+func (p *tinyLFUPolicy) OnRemove(key string) {
+	if e, found := p.elements[key]; found {
+		p.list(e.Value.(*tinyLFUEntry).region).Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim returns the window's oldest key or the main region's victim,
+// whichever has been used less often, and admits the window's key to the
+// main region if it wins. While the window is below its share, the main
+// region gives up its victim.
+func (p *tinyLFUPolicy) Victim() (string, bool) {
+	main := p.probation.Back()
+	if main == nil {
+		main = p.protected.Back()
+	}
+	candidate := p.window.Back()
+	if candidate == nil || (main != nil && p.window.Len() < p.windowCap) {
+		if main == nil {
+			return "", false
+		}
+		return main.Value.(*tinyLFUEntry).key, true
+	}
+	if main == nil {
+		return candidate.Value.(*tinyLFUEntry).key, true
+	}
+
+	candidateKey := candidate.Value.(*tinyLFUEntry).key
+	mainKey := main.Value.(*tinyLFUEntry).key
+	if p.sketch.estimate(candidateKey) > p.sketch.estimate(mainKey) {
+		p.move(candidate, regionProbation)
+		return mainKey, true
+	}
+	return candidateKey, true
+}
+
+// move moves e to the front of region.
+func (p *tinyLFUPolicy) move(e *list.Element, region int) {
+	entry := e.Value.(*tinyLFUEntry)
+	p.list(entry.region).Remove(e)
+	entry.region = region
+	p.elements[entry.key] = p.list(region).PushFront(entry)
+}
+
+func (p *tinyLFUPolicy) list(region int) *list.List {
+	switch region {
+	case regionWindow:
+		return p.window
+	case regionProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+// countMinSketch estimates how often keys were used recently with 4-bit
+// counters in four rows. Once it has counted ten times as many uses as it
+// has counters per row, all counters are halved, so that old popularity
+// fades.
+type countMinSketch struct {
+	seed      maphash.Seed
+	rows      [4][]uint8 // two 4-bit counters per byte
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity {
+		width *= 2
+	}
+	s := &countMinSketch{seed: maphash.MakeSeed(), mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width/2)
+	}
+	return s
+}
+
+// index returns the counter of a key hashed to h in row i, remixing h for
+// each row so that keys colliding in one row rarely collide in the others.
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	h += uint64(i) * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h & s.mask
+}
+
+func (s *countMinSketch) get(row int, n uint64) uint8 {
+	return s.rows[row][n/2] >> (4 * (n % 2)) & 0x0f
+}
+
+func (s *countMinSketch) increment(key string) {
+	h := maphash.String(s.seed, key)
+	for i := range s.rows {
+		n := s.index(h, i)
+		if s.get(i, n) < 15 {
+			s.rows[i][n/2] += 1 << (4 * (n % 2))
+		}
+	}
+	if s.additions++; s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	h := maphash.String(s.seed, key)
+	lowest := uint8(15)
+	for i := range s.rows {
+		if c := s.get(i, s.index(h, i)); c < lowest {
+			lowest = c
+		}
+	}
+	return lowest
+}
+
+// reset halves all counters.
+func (s *countMinSketch) reset() {
+	for _, row := range s.rows {
+		for j, b := range row {
+			row[j] = (b >> 1) & 0x77
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,89 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTinyLFUResistsScans(t *testing.T) {
+	c := New(Options{MaxEntries: 100, EvictionPolicy: NewTinyLFUPolicy(100)})
+	defer c.Stop()
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprint("hot", i)
+			if _, err := c.Get(key); err != nil {
+				c.Set(key, i)
+			}
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set(fmt.Sprint("scan", i), i)
+	}
+
+	if n := c.ItemCount(); n != 100 {
+		t.Fatalf("Expected 100 items, got %d", n)
+	}
+	var kept int
+	for i := 0; i < 50; i++ {
+		if _, err := c.Get(fmt.Sprint("hot", i)); err == nil {
+			kept++
+		}
+	}
+	if kept < 45 {
+		t.Fatalf("Expected at least 45 of 50 hot keys to survive the scan, got %d", kept)
+	}
+}
+
+func TestTinyLFUAdmitsNewPopularKeys(t *testing.T) {
+	// With room for 100 items, the window holds the one most recently
+	// added.
+	c := New(Options{MaxEntries: 100, EvictionPolicy: NewTinyLFUPolicy(100)})
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint("old", i), i)
+	}
+	c.Set("new", 0)
+	for i := 0; i < 5; i++ {
+		c.Get("new")
+	}
+
+	// Leaving the window, the popular new key is admitted in place of an
+	// old key that has been used only once, rather than being evicted.
+	c.Set("next", 0)
+	if _, err := c.Get("new"); err != nil {
+		t.Fatalf("Expected the popular new key to be admitted, got %v", err)
+	}
+	if n := c.ItemCount(); n != 100 {
+		t.Fatalf("Expected 100 items, got %d", n)
+	}
+	var evicted int
+	for i := 0; i < 100; i++ {
+		if _, err := c.Get(fmt.Sprint("old", i)); err != nil {
+			evicted++
+		}
+	}
+	if evicted != 2 {
+		t.Errorf("Expected 2 old keys to make room for new and next, got %d", evicted)
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(100)
+	for i := 0; i < 20; i++ {
+		s.increment("popular")
+	}
+	s.increment("rare")
+
+	if n := s.estimate("popular"); n != 15 {
+		t.Fatalf("Expected estimate of popular to saturate at 15, got %d", n)
+	}
+	if n := s.estimate("rare"); n < 1 || n > 2 {
+		t.Fatalf("Expected estimate of rare to be about 1, got %d", n)
+	}
+	s.reset()
+	if n := s.estimate("popular"); n != 7 {
+		t.Fatalf("Expected estimate of popular to be 7 after reset, got %d", n)
+	}
+}
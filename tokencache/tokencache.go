@@ -0,0 +1,340 @@
+// Package tokencache caches short-lived credentials and the documents used
+// to verify them, such as OAuth access tokens and JWKS key sets, in a
+// gocache.Cache. Values are refreshed in the background before they expire,
+// so callers rarely wait for a load, and a value whose refresh fails keeps
+// being served for a grace period instead of failing every request while
+// the issuer is down.
+//
+//	keys := tokencache.New(c, func(ctx context.Context, issuer string) (interface{}, time.Time, error) {
+//		set, maxAge, err := fetchJWKS(ctx, issuer)
+//		return set, time.Now().Add(maxAge), err
+//	}, tokencache.Options{MaxStale: time.Hour})
+//
+//	set, err := keys.Get(ctx, "https://issuer.example.com")
+package tokencache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gocache"
+)
+
+// Loader fetches the value for key, such as a token for an audience or the
+// key set of an issuer, and reports when it expires. A zero expiration
+// means Options.DefaultTTL from now.
+type Loader func(ctx context.Context, key string) (value interface{}, expires time.Time, err error)
+
+// Default option values.
+const (
+	DefaultRefreshBefore = time.Minute
+	DefaultRetryInterval = 10 * time.Second
+	DefaultTTL           = 5 * time.Minute
+)
+
+// ErrClosed is returned by Get after Close.
+var ErrClosed = errors.New("tokencache: closed")
+
+// Options configures a Refresher. Zero values select the defaults.
+type Options struct {
+	// RefreshBefore is how long before expiring a value is refreshed.
+	// Values that live less than twice as long are refreshed halfway
+	// through their life instead.
+	RefreshBefore time.Duration
+
+	// RetryInterval is the delay between attempts when a refresh fails.
+	RetryInterval time.Duration
+
+	// MaxStale is how long past its expiration a value is still served
+	// while refreshing it fails. If 0, values are not served stale.
+	MaxStale time.Duration
+
+	// DefaultTTL is the lifetime of values whose Loader returns no
+	// expiration, as is common for key sets.
+	DefaultTTL time.Duration
+
+	// KeyPrefix is prepended to keys to form cache keys. If empty,
+	// "token:" is used.
+	KeyPrefix string
+
+	// OnRefreshError, if set, is called when a background refresh fails,
+	// for logging.
+	OnRefreshError func(key string, err error)
+
+	// Clock provides the time that values expire and are refreshed by. It
+	// should be the clock of the cache. If nil, the system clock is used.
+	Clock gocache.Clock
+}
+
+// Refresher loads values with a Loader and keeps them fresh in a cache.
+type Refresher struct {
+	cache   *gocache.Cache
+	load    Loader
+	options Options
+
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	closed  bool
+}
+
+// entry tracks the refresh state of a key.
+type entry struct {
+	timer   *timer
+	used    bool          // read since the last refresh
+	loading chan struct{} // closed when a synchronous load ends
+	err     error         // result of that load
+}
+
+// timer runs a function once after a delay on Options.Clock.
+type timer struct {
+	ticker gocache.Ticker
+	stop   chan struct{}
+}
+
+// Stop keeps the function from running if it has not started yet.
+func (t *timer) Stop() {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+}
+
+// afterFunc is time.AfterFunc on Options.Clock.
+func (r *Refresher) afterFunc(d time.Duration, fn func()) *timer {
+	t := &timer{ticker: r.clock().NewTicker(d), stop: make(chan struct{})}
+	go func() {
+		defer t.ticker.Stop()
+		select {
+		case <-t.ticker.C():
+			fn()
+		case <-t.stop:
+		}
+	}()
+	return t
+}
+
+// clock returns Options.Clock, or the system clock.
+func (r *Refresher) clock() gocache.Clock {
+	if r.options.Clock != nil {
+		return r.options.Clock
+	}
+	return systemClock{}
+}
+
+// systemClock is the clock used when Options.Clock is nil.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) gocache.Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// New returns a refresher loading values with load into c. Background
+// refreshes stop when Close is called, or when c is closed.
+func New(c *gocache.Cache, load Loader, options Options) *Refresher {
+	if options.RefreshBefore <= 0 {
+		options.RefreshBefore = DefaultRefreshBefore
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = DefaultRetryInterval
+	}
+	if options.DefaultTTL <= 0 {
+		options.DefaultTTL = DefaultTTL
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = "token:"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Refresher{
+		cache:   c,
+		load:    load,
+		options: options,
+		ctx:     ctx,
+		cancel:  cancel,
+		entries: make(map[string]*entry),
+	}
+	c.OnShutdown(gocache.PhaseStopIntake, "tokencache", func(context.Context) error {
+		r.Close()
+		return nil
+	})
+	return r
+}
+
+// Get returns the value for key. A cached value is returned right away,
+// even if it is being refreshed or is stale after failed refreshes;
+// otherwise the value is loaded, with concurrent callers for the same key
+// waiting for a single load. Keys that are read keep being refreshed in
+// the background; keys that are not read between two refreshes are left
+// to expire.
+func (r *Refresher) Get(ctx context.Context, key string) (interface{}, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, ErrClosed
+		}
+		e := r.entries[key]
+		if e == nil {
+			e = &entry{}
+			r.entries[key] = e
+		}
+		e.used = true
+
+		if value, err := r.cache.Get(r.options.KeyPrefix + key); err == nil {
+			r.mu.Unlock()
+			return value, nil
+		}
+
+		if e.loading != nil {
+			loading := e.loading
+			r.mu.Unlock()
+			select {
+			case <-loading:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if e.err != nil {
+				return nil, e.err
+			}
+			// Read the loaded value from the cache.
+			continue
+		}
+
+		loading := make(chan struct{})
+		e.loading = loading
+		r.mu.Unlock()
+
+		value, expires, err := r.load(ctx, key)
+		if err == nil {
+			err = r.store(key, value, expires)
+		}
+
+		r.mu.Lock()
+		e.err = err
+		e.loading = nil
+		close(loading)
+		r.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+// Close stops all background refreshes. Later calls to Get return
+// ErrClosed.
+func (r *Refresher) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	r.cancel()
+	for _, e := range r.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+}
+
+// store caches a loaded value until it is too stale to serve and schedules
+// its refresh.
+func (r *Refresher) store(key string, value interface{}, expires time.Time) error {
+	now := r.clock().Now()
+	if expires.IsZero() {
+		expires = now.Add(r.options.DefaultTTL)
+	}
+	lifetime := expires.Sub(now)
+	if lifetime <= 0 {
+		return fmt.Errorf("tokencache: value loaded for %q has expired already", key)
+	}
+
+	if err := r.cache.SetWithExpiration(r.options.KeyPrefix+key, value, lifetime+r.options.MaxStale); err != nil {
+		return err
+	}
+
+	before := r.options.RefreshBefore
+	if lifetime < 2*before {
+		before = lifetime / 2
+	}
+	r.schedule(key, lifetime-before, expires.Add(r.options.MaxStale))
+	return nil
+}
+
+// schedule refreshes key after delay, giving up on failures at deadline,
+// when the cached value can no longer be served.
+func (r *Refresher) schedule(key string, delay time.Duration, deadline time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.entries[key]
+	if r.closed || e == nil {
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.timer = r.afterFunc(delay, func() { r.refresh(key, deadline) })
+}
+
+// refresh reloads key in the background if it was read since the last
+// refresh, retrying failures until deadline.
+func (r *Refresher) refresh(key string, deadline time.Time) {
+	r.mu.Lock()
+	e := r.entries[key]
+	if r.closed || e == nil {
+		r.mu.Unlock()
+		return
+	}
+	if !e.used {
+		// Drop the value too, so that a later Get loads a fresh one
+		// rather than finding a value nobody refreshes.
+		delete(r.entries, key)
+		r.cache.Delete(r.options.KeyPrefix + key)
+		r.mu.Unlock()
+		return
+	}
+	e.used = false
+	r.mu.Unlock()
+
+	value, expires, err := r.load(r.ctx, key)
+	if err == nil {
+		err = r.store(key, value, expires)
+	}
+	if err == nil {
+		return
+	}
+	if r.ctx.Err() != nil {
+		return
+	}
+	if r.options.OnRefreshError != nil {
+		r.options.OnRefreshError(key, err)
+	}
+
+	// Keep the entry alive for the retry, which serves the readers of the
+	// stale value.
+	r.mu.Lock()
+	e.used = true
+	r.mu.Unlock()
+	if delay := deadline.Sub(r.clock().Now()); delay > 0 {
+		r.schedule(key, min(r.options.RetryInterval, delay), deadline)
+	}
+}
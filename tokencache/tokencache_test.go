@@ -0,0 +1,159 @@
+package tokencache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocache"
+	"gocache/gocachetest"
+)
+
+// issuer is a Loader handing out numbered tokens that live for ttl on
+// clock, and failing while fail is set.
+type issuer struct {
+	clock *gocachetest.Clock
+	ttl   time.Duration
+	loads int64
+	fail  atomic.Bool
+}
+
+func (i *issuer) load(ctx context.Context, key string) (interface{}, time.Time, error) {
+	n := atomic.AddInt64(&i.loads, 1)
+	if i.fail.Load() {
+		return nil, time.Time{}, errors.New("issuer down")
+	}
+	return n, i.clock.Now().Add(i.ttl), nil
+}
+
+func newIssuer(ttl time.Duration) *issuer {
+	return &issuer{clock: gocachetest.NewClock(time.Unix(1700000000, 0)), ttl: ttl}
+}
+
+func newRefresher(t *testing.T, i *issuer, options Options) *Refresher {
+	t.Helper()
+	cache := gocache.New(gocache.Options{Clock: i.clock})
+	t.Cleanup(func() { cache.Close(context.Background()) })
+	options.Clock = i.clock
+	return New(cache, i.load, options)
+}
+
+// timerOf returns the refresh timer of key, or nil if key is not tracked.
+func timerOf(r *Refresher, key string) *timer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.entries[key]; e != nil {
+		return e.timer
+	}
+	return nil
+}
+
+// advance moves the clock of i forward by d and waits for the refresh of
+// key that comes due to schedule the next one, or to drop key.
+func advance(t *testing.T, r *Refresher, i *issuer, key string, d time.Duration) {
+	t.Helper()
+	old := timerOf(r, key)
+	i.clock.Advance(d)
+	for deadline := time.Now().Add(time.Second); timerOf(r, key) == old; {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the refresh")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefresherGet(t *testing.T) {
+	i := newIssuer(time.Hour)
+	r := newRefresher(t, i, Options{})
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := r.Get(context.Background(), "api"); err != nil || value != int64(1) {
+				t.Errorf("Expected token 1, got %v (%v)", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads := atomic.LoadInt64(&i.loads); loads != 1 {
+		t.Errorf("Expected concurrent Gets to share one load, got %d", loads)
+	}
+
+	r.Close()
+	if _, err := r.Get(context.Background(), "api"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestRefresherBackgroundRefresh(t *testing.T) {
+	i := newIssuer(200 * time.Millisecond)
+	r := newRefresher(t, i, Options{RefreshBefore: 100 * time.Millisecond})
+
+	r.Get(context.Background(), "api")
+	advance(t, r, i, "api", 100*time.Millisecond)
+	if value, _ := r.Get(context.Background(), "api"); value != int64(2) {
+		t.Errorf("Expected the token refreshed in the background, got %v", value)
+	}
+	if loads := atomic.LoadInt64(&i.loads); loads != 2 {
+		t.Errorf("Expected 2 loads, got %d", loads)
+	}
+
+	// The read above earns one more refresh, at 200ms; without further
+	// reads, the one after it drops the key instead of loading.
+	advance(t, r, i, "api", 100*time.Millisecond)
+	advance(t, r, i, "api", 100*time.Millisecond)
+	if loads := atomic.LoadInt64(&i.loads); loads != 3 {
+		t.Errorf("Expected an unread key not to be refreshed, got %d loads", loads)
+	}
+	if value, _ := r.Get(context.Background(), "api"); value != int64(4) {
+		t.Errorf("Expected a fresh load for a dropped key, got %v", value)
+	}
+}
+
+func TestRefresherServeStale(t *testing.T) {
+	i := newIssuer(100 * time.Millisecond)
+	var refreshErrors atomic.Int64
+	r := newRefresher(t, i, Options{
+		RefreshBefore:  50 * time.Millisecond,
+		RetryInterval:  20 * time.Millisecond,
+		MaxStale:       time.Second,
+		OnRefreshError: func(string, error) { refreshErrors.Add(1) },
+	})
+
+	r.Get(context.Background(), "api")
+	i.fail.Store(true)
+	advance(t, r, i, "api", 50*time.Millisecond)
+	advance(t, r, i, "api", 20*time.Millisecond)
+	advance(t, r, i, "api", 130*time.Millisecond)
+	if value, err := r.Get(context.Background(), "api"); err != nil || value != int64(1) {
+		t.Errorf("Expected the stale token while the issuer is down, got %v (%v)", value, err)
+	}
+	if refreshErrors.Load() != 3 {
+		t.Errorf("Expected failed refreshes to be retried and reported, got %d", refreshErrors.Load())
+	}
+
+	i.fail.Store(false)
+	advance(t, r, i, "api", 20*time.Millisecond)
+	if value, _ := r.Get(context.Background(), "api"); value == int64(1) {
+		t.Error("Expected a new token once the issuer is back")
+	}
+}
+
+func TestRefresherLoadError(t *testing.T) {
+	i := newIssuer(time.Hour)
+	i.fail.Store(true)
+	r := newRefresher(t, i, Options{})
+
+	if _, err := r.Get(context.Background(), "api"); err == nil {
+		t.Error("Expected the load error")
+	}
+	i.fail.Store(false)
+	if value, err := r.Get(context.Background(), "api"); err != nil || value != int64(2) {
+		t.Errorf("Expected a successful retry on the next Get, got %v (%v)", value, err)
+	}
+}
@@ -0,0 +1,234 @@
+package gocache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceOp is the kind of a recorded cache operation.
+type TraceOp byte
+
+// Operations recorded in a trace.
+const (
+	TraceGet    TraceOp = 1 // a lookup, hit or miss
+	TraceSet    TraceOp = 2 // a key stored
+	TraceDelete TraceOp = 3 // a key deleted
+)
+
+// String returns the name of the operation.
+func (op TraceOp) String() string {
+	switch op {
+	case TraceGet:
+		return "get"
+	case TraceSet:
+		return "set"
+	case TraceDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// TraceRecord is one operation of an access trace.
+type TraceRecord struct {
+	Time time.Time
+	Op   TraceOp
+	Key  string
+}
+
+// traceMagic and traceVersion start every trace file. Records follow, each
+// an op byte, the time in nanoseconds since the previous record as a
+// varint, and the key.
+var traceMagic = [4]byte{'G', 'O', 'C', 'T'}
+
+const traceVersion uint16 = 1
+
+// traceBuffer is the number of records a recorder holds before it drops
+// new ones.
+const traceBuffer = 1 << 16
+
+// TraceRecorder records the operations of a cache to a writer. See
+// Cache.Trace.
+type TraceRecorder struct {
+	wake    chan struct{} // has a value when there are records to write
+	done    chan struct{}
+	dropped uint64
+	err     error
+
+	mu      sync.Mutex
+	pending []TraceRecord
+	stopped bool
+}
+
+// Trace starts recording every Get, Set and Delete of the cache to w, with
+// the time of each, until Stop is called on the returned recorder. The
+// trace can be replayed against other configurations with
+// cmd/gocache-sim, or read with ReadTrace, to choose an eviction policy
+// and size from real traffic.
+//
+// Records are buffered and written by a background goroutine, so that
+// recording does not slow the cache down; the trace is complete once Stop
+// returns. If w cannot keep up, records are dropped and counted. Only one
+// trace is recorded at a time; starting a new one stops the previous one.
+func (c *Cache) Trace(w io.Writer) *TraceRecorder {
+	r := &TraceRecorder{wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go r.write(w)
+	if old := c.tracer.Swap(r); old != nil {
+		old.Stop()
+	}
+	return r
+}
+
+// trace records an operation on key if a trace is being recorded.
+func (c *Cache) trace(op TraceOp, key string) {
+	if r := c.tracer.Load(); r != nil {
+		r.record(TraceRecord{Time: c.clock.Now(), Op: op, Key: key})
+	}
+}
+
+func (r *TraceRecorder) record(rec TraceRecord) {
+	r.mu.Lock()
+	switch {
+	case r.stopped:
+	case len(r.pending) >= traceBuffer:
+		r.dropped++
+	default:
+		r.pending = append(r.pending, rec)
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write writes the pending records in batches until the recorder stops.
+func (r *TraceRecorder) write(w io.Writer) {
+	defer close(r.done)
+
+	bw := bufio.NewWriterSize(w, 64<<10)
+	var header [6]byte
+	copy(header[:4], traceMagic[:])
+	binary.BigEndian.PutUint16(header[4:], traceVersion)
+	bw.Write(header[:])
+
+	var (
+		last   int64
+		varint [binary.MaxVarintLen64]byte
+		batch  []TraceRecord
+	)
+	for range r.wake {
+		r.mu.Lock()
+		batch, r.pending = r.pending, batch[:0]
+		stopped := r.stopped
+		r.mu.Unlock()
+
+		for _, rec := range batch {
+			now := rec.Time.UnixNano()
+			bw.WriteByte(byte(rec.Op))
+			bw.Write(varint[:binary.PutVarint(varint[:], now-last)])
+			writeSnapshotString(bw, rec.Key)
+			last = now
+		}
+		if stopped {
+			break
+		}
+	}
+	r.err = bw.Flush()
+}
+
+// Stop stops recording, writes the records still buffered, and returns the
+// first error writing the trace. It is safe to call more than once.
+func (r *TraceRecorder) Stop() error {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	<-r.done
+	return r.err
+}
+
+// Dropped returns the number of records dropped because the writer could
+// not keep up.
+func (r *TraceRecorder) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// stopTrace stops the trace being recorded, if any.
+func (c *Cache) stopTrace() error {
+	if r := c.tracer.Swap(nil); r != nil {
+		return r.Stop()
+	}
+	return nil
+}
+
+// TraceReader reads a trace recorded with Cache.Trace.
+type TraceReader struct {
+	r    *bufio.Reader
+	last int64
+	err  error
+}
+
+// ReadTrace returns a reader of the trace in r. It returns ErrInvalidHeader
+// if r does not hold a trace.
+func ReadTrace(r io.Reader) (*TraceReader, error) {
+	br := bufio.NewReader(r)
+	var header [6]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != traceMagic {
+		return nil, ErrInvalidHeader
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version != traceVersion {
+		return nil, fmt.Errorf("%w: trace version %d (supported %d)", ErrUnsupportedVersion, version, traceVersion)
+	}
+	return &TraceReader{r: br}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the trace.
+func (t *TraceReader) Next() (TraceRecord, error) {
+	if t.err != nil {
+		return TraceRecord{}, t.err
+	}
+	rec, err := t.next()
+	if err != nil {
+		if errors.Is(err, io.EOF) && rec.Op != 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		t.err = err
+	}
+	return rec, err
+}
+
+func (t *TraceReader) next() (TraceRecord, error) {
+	op, err := t.r.ReadByte()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+	rec := TraceRecord{Op: TraceOp(op)}
+	if rec.Op < TraceGet || rec.Op > TraceDelete {
+		return rec, fmt.Errorf("%w: unknown trace operation %d", ErrCorruptSnapshot, op)
+	}
+	delta, err := binary.ReadVarint(t.r)
+	if err != nil {
+		return rec, err
+	}
+	if rec.Key, err = readSnapshotString(t.r); err != nil {
+		return rec, err
+	}
+	t.last += delta
+	rec.Time = time.Unix(0, t.last)
+	return rec, nil
+}
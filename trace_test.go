@@ -0,0 +1,119 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTraceRecordsAndReplays(t *testing.T) {
+	clock := newFakeClock()
+	c := New(Options{Clock: clock})
+	defer c.Stop()
+
+	var buf bytes.Buffer
+	rec := c.Trace(&buf)
+	start := clock.Now()
+	c.Set("a", 1)
+	clock.Advance(time.Second)
+	c.Get("a")
+	c.Get("missing")
+	clock.Advance(time.Millisecond)
+	c.Delete("a")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("after", 1) // not recorded
+
+	if rec.Dropped() != 0 {
+		t.Fatalf("Dropped = %d, want 0", rec.Dropped())
+	}
+
+	want := []TraceRecord{
+		{start, TraceSet, "a"},
+		{start.Add(time.Second), TraceGet, "a"},
+		{start.Add(time.Second), TraceGet, "missing"},
+		{start.Add(time.Second + time.Millisecond), TraceDelete, "a"},
+	}
+	tr, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range want {
+		got, err := tr.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !got.Time.Equal(w.Time) || got.Op != w.Op || got.Key != w.Key {
+			t.Fatalf("record %d = %v %v %q, want %v %v %q", i, got.Time, got.Op, got.Key, w.Time, w.Op, w.Key)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Next after the last record = %v, want io.EOF", err)
+	}
+}
+
+func TestTraceReplacesPrevious(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	var first, second bytes.Buffer
+	c.Trace(&first)
+	c.Set("a", 1)
+	rec := c.Trace(&second)
+	c.Set("b", 1)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		buf  *bytes.Buffer
+		want string
+	}{{&first, "a"}, {&second, "b"}} {
+		tr, err := ReadTrace(tc.buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tr.Next()
+		if err != nil || got.Key != tc.want {
+			t.Fatalf("first record = %q, %v; want %q", got.Key, err, tc.want)
+		}
+		if _, err := tr.Next(); err != io.EOF {
+			t.Fatalf("extra record, got %v", err)
+		}
+	}
+}
+
+func TestReadTraceRejectsOtherStreams(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	c.Set("a", 1)
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTrace(&buf); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("ReadTrace of a snapshot = %v, want ErrInvalidHeader", err)
+	}
+}
+
+func TestReadTraceTruncated(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+
+	var buf bytes.Buffer
+	rec := c.Trace(&buf)
+	c.Set("some-key", 1)
+	rec.Stop()
+
+	tr, err := ReadTrace(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Next of a truncated record = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,218 @@
+package gocache
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Txn is a transaction of Update. Its methods must only be called from the
+// function passed to Update.
+type Txn struct {
+	c      *Cache
+	reads  map[string]txnRead
+	writes map[string]txnWrite
+	order  []string // keys of writes, in the order first written
+}
+
+// txnRead is the state of a key when a transaction first read it.
+type txnRead struct {
+	value   interface{}
+	found   bool
+	version uint64
+}
+
+// txnWrite is a change of a key made by a transaction.
+type txnWrite struct {
+	value      interface{}
+	expiration int64 // Unix timestamp in nanoseconds, or 0
+	delete     bool
+}
+
+// Update runs fn in a transaction that can read and change several keys,
+// and applies its changes atomically: other goroutines see either none or
+// all of them, so related entries never become mutually inconsistent. If
+// fn returns an error, the changes are discarded and Update returns it.
+//
+// Transactions are optimistic. fn runs without the lock held, and the
+// changes are applied only if none of the keys fn read has changed since;
+// otherwise fn is run again in a new transaction, so it must not have side
+// effects other than through tx. Reads within a transaction are
+// repeatable and see its own changes.
+//
+// The changes are written through to Options.BackingStore after they are
+// applied; errors of the backing store are reported to
+// Options.OnBackingStoreError. Update returns ErrKeyLeased, and applies
+// nothing, if another caller holds a lease on a key it changes.
+func (c *Cache) Update(fn func(tx *Txn) error) error {
+	for {
+		tx := &Txn{c: c, reads: make(map[string]txnRead), writes: make(map[string]txnWrite)}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		committed, err := tx.commit()
+		if err != nil || committed {
+			return err
+		}
+	}
+}
+
+// Get returns the value of key as seen by the transaction.
+func (tx *Txn) Get(key string) (interface{}, error) {
+	if w, ok := tx.writes[key]; ok {
+		if w.delete {
+			return nil, ErrKeyNotFound
+		}
+		return tx.c.copyValue(w.value)
+	}
+
+	r, ok := tx.reads[key]
+	if !ok {
+		var err error
+		if r, err = tx.read(key); err != nil {
+			return nil, err
+		}
+		tx.reads[key] = r
+	}
+	if !r.found {
+		return nil, ErrKeyNotFound
+	}
+	return tx.c.copyValue(r.value)
+}
+
+// read reads key from the cache.
+func (tx *Txn) read(key string) (txnRead, error) {
+	c := tx.c
+	c.applyQueued(key)
+	c.mu.RLock()
+	item, found := c.items.get(key)
+	c.mu.RUnlock()
+	if !found || item.expiredAt(c.clock.Now().UnixNano()) {
+		return txnRead{}, nil
+	}
+	value, err := c.expand(item.Value)
+	if err != nil {
+		return txnRead{}, err
+	}
+	return txnRead{value: value, found: true, version: item.Version}, nil
+}
+
+// Set stores value under key with the default expiration when the
+// transaction commits.
+func (tx *Txn) Set(key string, value interface{}) error {
+	return tx.SetWithExpiration(key, value, tx.c.defaultExpiration())
+}
+
+// SetWithExpiration stores value under key for duration when the
+// transaction commits. If duration is 0, the item never expires.
+func (tx *Txn) SetWithExpiration(key string, value interface{}, duration time.Duration) error {
+	if value == nil && !tx.c.allowNil {
+		return ErrNilValue
+	}
+	if err := tx.c.validateKey(key); err != nil {
+		return err
+	}
+	var expiration int64
+	if duration > 0 {
+		expiration = tx.c.clock.Now().Add(duration).UnixNano()
+	}
+	tx.write(key, txnWrite{value: value, expiration: expiration})
+	return nil
+}
+
+// Delete removes key when the transaction commits.
+func (tx *Txn) Delete(key string) {
+	tx.write(key, txnWrite{delete: true})
+}
+
+func (tx *Txn) write(key string, w txnWrite) {
+	if _, ok := tx.writes[key]; !ok {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = w
+}
+
+// commit applies the changes of the transaction if the keys it read are
+// unchanged, and reports whether it did.
+func (tx *Txn) commit() (bool, error) {
+	c := tx.c
+	sets := make(map[string]*pendingSet)
+	for _, key := range tx.order {
+		if w := tx.writes[key]; !w.delete {
+			p, err := c.prepareSet(key, w.value, w.expiration, 0)
+			if err != nil {
+				return false, err
+			}
+			sets[key] = p
+		}
+	}
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	for key, r := range tx.reads {
+		if _, queued := c.async.lookup(key); queued {
+			// SetAsync changed the key after it was read.
+			c.unlock()
+			return false, nil
+		}
+		item, found := c.items.get(key)
+		found = found && !item.expiredAt(now.UnixNano())
+		if found != r.found || found && item.Version != r.version {
+			c.unlock()
+			return false, nil
+		}
+	}
+	for _, key := range tx.order {
+		if err := c.checkLease(key, 0, now.UnixNano()); err != nil {
+			c.unlock()
+			return false, err
+		}
+		if p := sets[key]; p != nil {
+			if err := c.checkCost(p); err != nil {
+				c.unlock()
+				return false, err
+			}
+		}
+	}
+
+	// Keep the keys stored so far from being evicted to make room for the
+	// next ones.
+	c.protected = make(map[string]struct{}, len(sets))
+	for _, key := range tx.order {
+		if p := sets[key]; p != nil {
+			c.storeLocked(p, now, math.MaxUint64)
+			c.protected[key] = struct{}{}
+			continue
+		}
+		c.async.supersede(key, math.MaxUint64)
+		if item, found := c.items.get(key); found {
+			c.remove(key)
+			c.emit(EventDeleted, key, item.Value)
+		}
+	}
+	c.protected = nil
+	warnings := c.checkSoftLimits()
+	c.unlock()
+
+	for _, key := range tx.order {
+		if p := sets[key]; p != nil {
+			c.finishSet(p, now)
+		} else {
+			c.trace(TraceDelete, key)
+		}
+	}
+	for _, w := range warnings {
+		c.onSoftLimit(w)
+	}
+	if c.backing != nil {
+		ctx := context.Background()
+		for _, key := range tx.order {
+			if w := tx.writes[key]; w.delete {
+				c.reportBacking(key, c.deleteThrough(ctx, key))
+			} else {
+				c.reportBacking(key, c.storeThrough(ctx, key, w.value, 0))
+			}
+		}
+	}
+	return true, nil
+}
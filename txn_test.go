@@ -0,0 +1,226 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateIsAtomic(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	c.Set("a", 100)
+	c.Set("b", 100)
+
+	transfer := func(from, to string) error {
+		return c.Update(func(tx *Txn) error {
+			x, err := tx.Get(from)
+			if err != nil {
+				return err
+			}
+			y, err := tx.Get(to)
+			if err != nil {
+				return err
+			}
+			if err := tx.Set(from, x.(int)-1); err != nil {
+				return err
+			}
+			return tx.Set(to, y.(int)+1)
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := transfer("a", "b"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Update(func(tx *Txn) error {
+					x, _ := tx.Get("a")
+					y, _ := tx.Get("b")
+					if x.(int)+y.(int) != 200 {
+						t.Errorf("Expected a+b to be 200, got a=%v b=%v", x, y)
+					}
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if a, _ := c.Get("a"); a != -700 {
+		t.Errorf("Expected a to be -700, got %v", a)
+	}
+	if b, _ := c.Get("b"); b != 900 {
+		t.Errorf("Expected b to be 900, got %v", b)
+	}
+}
+
+func TestUpdateDiscardsOnError(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	c.Set("a", 1)
+
+	errAbort := errors.New("abort")
+	err := c.Update(func(tx *Txn) error {
+		tx.Set("a", 2)
+		tx.Set("b", 2)
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Expected the error of fn, got %v", err)
+	}
+	if a, _ := c.Get("a"); a != 1 {
+		t.Errorf("Expected a to be 1, got %v", a)
+	}
+	if _, err := c.Get("b"); err != ErrKeyNotFound {
+		t.Errorf("Expected b not to be stored by a failed transaction, got %v", err)
+	}
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	c.Set("counter", 1)
+
+	runs := 0
+	err := c.Update(func(tx *Txn) error {
+		runs++
+		v, err := tx.Get("counter")
+		if err != nil {
+			return err
+		}
+		if runs == 1 {
+			c.Set("counter", 10) // a concurrent writer
+		}
+		return tx.Set("counter", v.(int)+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("Expected fn to run 2 times, got %d", runs)
+	}
+	if v, _ := c.Get("counter"); v != 11 {
+		t.Errorf("Expected counter to be 11, got %v", v)
+	}
+}
+
+func TestUpdateSeesOwnWrites(t *testing.T) {
+	c := New(Options{DefaultExpiration: time.Minute})
+	defer c.Stop()
+	c.Set("old", 1)
+
+	err := c.Update(func(tx *Txn) error {
+		tx.Set("new", 2)
+		tx.Delete("old")
+		if v, err := tx.Get("new"); v != 2 || err != nil {
+			t.Errorf("Expected 2 for a key set in the transaction, got %v, %v", v, err)
+		}
+		if _, err := tx.Get("old"); err != ErrKeyNotFound {
+			t.Errorf("Expected ErrKeyNotFound for a key deleted in the transaction, got %v", err)
+		}
+		if _, err := c.Get("new"); err != ErrKeyNotFound {
+			t.Errorf("Expected no change visible before the commit, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("old"); err != ErrKeyNotFound {
+		t.Errorf("Expected old to be deleted, got %v", err)
+	}
+	if v, exp, _ := c.GetWithExpiration("new"); v != 2 || exp.IsZero() {
+		t.Errorf("Expected new to be 2 with the default expiration, got %v expiring %v", v, exp)
+	}
+}
+
+func TestUpdateLeasedKey(t *testing.T) {
+	c := New(Options{})
+	defer c.Stop()
+	c.Lease("leased", time.Minute)
+
+	err := c.Update(func(tx *Txn) error {
+		tx.Set("free", 1)
+		tx.Set("leased", 1)
+		return nil
+	})
+	if err != ErrKeyLeased {
+		t.Fatalf("Expected ErrKeyLeased, got %v", err)
+	}
+	if _, err := c.Get("free"); err != ErrKeyNotFound {
+		t.Errorf("Expected no change applied by a failed transaction, got %v", err)
+	}
+}
+
+func TestUpdateIsAtomicReadOptimized(t *testing.T) {
+	c := New(Options{ReadOptimized: true})
+	defer c.Stop()
+	const keys = 100
+	for k := 0; k < keys; k++ {
+		c.Set(fmt.Sprint("key", k), 0)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 500; i++ {
+			c.Update(func(tx *Txn) error {
+				for k := 0; k < keys; k++ {
+					tx.Set(fmt.Sprint("key", k), i)
+				}
+				return nil
+			})
+		}
+	}()
+
+	// The transaction stores key0 first and the last key last, so reading
+	// them in that order must never see the last key behind.
+	last := fmt.Sprint("key", keys-1)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		first, _ := c.Get("key0")
+		latest, _ := c.Get(last)
+		if latest.(int) < first.(int) {
+			t.Fatalf("Expected %s to be at least %v, got %v", last, first, latest)
+		}
+	}
+}
+
+func TestUpdateDoesNotEvictItsOwnKeys(t *testing.T) {
+	c := New(Options{MaxEntries: 3})
+	defer c.Stop()
+	c.SetWithPriority("old1", 1, 0, 1)
+	c.SetWithPriority("old2", 2, 0, 1)
+
+	err := c.Update(func(tx *Txn) error {
+		tx.Set("a", 1)
+		return tx.Set("b", 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Expected %s stored by the transaction, got %v", key, err)
+		}
+	}
+	if n := c.ItemCount(); n != 3 {
+		t.Errorf("Expected 3 items, got %d", n)
+	}
+}
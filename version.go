@@ -0,0 +1,52 @@
+package gocache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Versions of the on-disk snapshot format. Every snapshot starts with a
+// header carrying one of these, so that nodes running different releases
+// can load each other's snapshots during a rolling upgrade.
+const (
+	// FormatVersion is the snapshot format written by this release.
+	// Version 2 adds optional encryption, version 3 entries whose expiration
+	// is relative to the time the snapshot was written.
+	FormatVersion uint16 = 3
+	// MinFormatVersion is the oldest snapshot format this release can read.
+	MinFormatVersion uint16 = 1
+)
+
+// headerMagic identifies gocache snapshot and replication streams.
+var headerMagic = [4]byte{'G', 'O', 'C', 'A'}
+
+// WriteHeader writes the magic bytes that start every gocache stream,
+// followed by the given version. Packages that define their own streams,
+// such as replication, use it with their own version numbers.
+func WriteHeader(w io.Writer, version uint16) error {
+	var buf [6]byte
+	copy(buf[:4], headerMagic[:])
+	binary.BigEndian.PutUint16(buf[4:], version)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// ReadHeader reads a header written by WriteHeader and returns its version.
+// It returns ErrInvalidHeader if the magic bytes do not match, and
+// ErrUnsupportedVersion if the version is outside [min, max].
+func ReadHeader(r io.Reader, min, max uint16) (uint16, error) {
+	var buf [6]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	if [4]byte(buf[:4]) != headerMagic {
+		return 0, ErrInvalidHeader
+	}
+
+	version := binary.BigEndian.Uint16(buf[4:])
+	if version < min || version > max {
+		return 0, fmt.Errorf("%w: %d (supported %d-%d)", ErrUnsupportedVersion, version, min, max)
+	}
+	return version, nil
+}
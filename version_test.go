@@ -0,0 +1,34 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, FormatVersion); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+
+	version, err := ReadHeader(&buf, MinFormatVersion, FormatVersion)
+	if err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	if version != FormatVersion {
+		t.Errorf("Expected version %d, got %d", FormatVersion, version)
+	}
+
+	buf.Reset()
+	WriteHeader(&buf, FormatVersion+1)
+	_, err = ReadHeader(&buf, MinFormatVersion, FormatVersion)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	_, err = ReadHeader(bytes.NewReader([]byte("NOPE\x00\x01")), MinFormatVersion, FormatVersion)
+	if err != ErrInvalidHeader {
+		t.Errorf("Expected ErrInvalidHeader, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package gocache
+
+import (
+	"context"
+	"time"
+)
+
+// GetVersioned is like Get but also returns the version of the item, which
+// increases with every change of the key. Pass it to SetIfVersion to
+// change the key only if nobody else has changed it since it was read.
+// The version of a value queued by SetAsync and not yet stored is 0.
+func (c *Cache) GetVersioned(key string) (interface{}, uint64, error) {
+	item, err := c.getItem(key)
+	return item.Value, item.Version, err
+}
+
+// GetVersionedWithExpiration is like GetVersioned but also returns the
+// expiration time of the item, or the zero time if it never expires.
+func (c *Cache) GetVersionedWithExpiration(key string) (interface{}, uint64, time.Time, error) {
+	item, err := c.getItem(key)
+	if err != nil || item.Expiration == 0 {
+		return item.Value, item.Version, time.Time{}, err
+	}
+	return item.Value, item.Version, time.Unix(0, item.Expiration), nil
+}
+
+// SetIfVersion stores value under key with the default expiration, like
+// Set, but only if the version of the item under key is still version, as
+// returned by GetVersioned. A version of 0 stores value only if the key is
+// missing or expired. It returns ErrVersionMismatch, and stores nothing,
+// if the key has changed, so that clients can implement optimistic
+// concurrency: read with GetVersioned, compute the new value, and retry
+// from the read on ErrVersionMismatch.
+func (c *Cache) SetIfVersion(key string, value interface{}, version uint64) error {
+	return c.SetIfVersionWithExpiration(key, value, c.defaultExpiration(), version)
+}
+
+// SetIfVersionWithExpiration is SetIfVersion storing value for duration,
+// or without expiration if duration is 0.
+func (c *Cache) SetIfVersionWithExpiration(key string, value interface{}, duration time.Duration, version uint64) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+	var expiration int64
+	if duration > 0 {
+		expiration = c.clock.Now().Add(duration).UnixNano()
+	}
+
+	stored, err := c.setIf(key, value, expiration, 0, 0, func(old Item, found bool) bool {
+		if !found {
+			return version == 0
+		}
+		return old.Version == version
+	})
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrVersionMismatch
+	}
+	return c.storeThrough(context.Background(), key, value, 0)
+}
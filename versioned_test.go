@@ -0,0 +1,82 @@
+package gocache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheSetIfVersion(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(Options{Clock: clock})
+	defer cache.Stop()
+
+	if err := cache.SetIfVersion("key", "first", 1); err != ErrVersionMismatch {
+		t.Errorf("Expected SetIfVersion of a missing key with a version to fail, got %v", err)
+	}
+	if err := cache.SetIfVersion("key", "first", 0); err != nil {
+		t.Fatalf("Expected SetIfVersion of a missing key with version 0 to succeed, got %v", err)
+	}
+	value, version, err := cache.GetVersioned("key")
+	if err != nil || value != "first" || version == 0 {
+		t.Fatalf("Expected first with a version, got %v, %d (%v)", value, version, err)
+	}
+
+	if err := cache.SetIfVersion("key", "second", version); err != nil {
+		t.Fatalf("Expected SetIfVersion with the current version to succeed, got %v", err)
+	}
+	if err := cache.SetIfVersion("key", "stale", version); err != ErrVersionMismatch {
+		t.Errorf("Expected SetIfVersion with a stale version to fail, got %v", err)
+	}
+	value, next, _ := cache.GetVersioned("key")
+	if value != "second" || next <= version {
+		t.Errorf("Expected second with a version above %d, got %v, %d", version, value, next)
+	}
+
+	if err := cache.SetIfVersionWithExpiration("key", "third", time.Second, next); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, expires, _ := cache.GetVersionedWithExpiration("key"); expires.IsZero() {
+		t.Error("Expected the item to expire")
+	}
+	clock.Advance(time.Minute)
+	if err := cache.SetIfVersion("key", "fresh", 0); err != nil {
+		t.Errorf("Expected SetIfVersion over an expired item with version 0 to succeed, got %v", err)
+	}
+}
+
+func TestCacheSetIfVersionConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+	cache.Set("counter", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				for {
+					value, version, err := cache.GetVersioned("counter")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					err = cache.SetIfVersion("counter", value.(int)+1, version)
+					if err == nil {
+						break
+					}
+					if err != ErrVersionMismatch {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("counter"); value != 800 {
+		t.Errorf("Expected 800 increments, got %v", value)
+	}
+}
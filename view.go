@@ -0,0 +1,105 @@
+package gocache
+
+import (
+	"sort"
+	"time"
+)
+
+// View is an immutable point-in-time view of the items of a cache,
+// returned by Snapshot. Changes made to the cache after the view was taken
+// are not seen through it, and items that expire later are still seen. A
+// View is safe for concurrent use and never blocks writers of the cache.
+type View struct {
+	c     *Cache
+	items map[string]Item // never modified
+	now   int64
+	n     int // unexpired items
+}
+
+// snapshotter is implemented by stores that can hand out an immutable copy
+// of their items without copying them.
+type snapshotter interface {
+	snapshot() map[string]Item
+}
+
+func (s *cowStore) snapshot() map[string]Item {
+	return *s.published.Load()
+}
+
+// Snapshot returns a point-in-time view of the unexpired items, for jobs
+// such as analytics that would otherwise hold the read lock during a long
+// Range. With Options.CopyOnWrite the view shares the published items map
+// and costs nothing; otherwise the items are copied under the read lock,
+// which is held only for the copy, and values are decompressed or cloned
+// lazily when read through the view.
+func (c *Cache) Snapshot() *View {
+	if s, ok := c.items.(snapshotter); ok {
+		v := &View{c: c, items: s.snapshot(), now: c.clock.Now().UnixNano()}
+		for _, item := range v.items {
+			if !item.expiredAt(v.now) {
+				v.n++
+			}
+		}
+		return v
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock.Now().UnixNano()
+	items := make(map[string]Item, c.items.len())
+	c.items.rangeItems(func(k string, v Item) bool {
+		if !v.expiredAt(now) {
+			items[k] = v
+		}
+		return true
+	})
+	return &View{c: c, items: items, now: now, n: len(items)}
+}
+
+// Time returns the time the view was taken.
+func (v *View) Time() time.Time {
+	return time.Unix(0, v.now)
+}
+
+// Len returns the number of items in the view.
+func (v *View) Len() int {
+	return v.n
+}
+
+// Get returns the value of key as of the time the view was taken, or
+// ErrKeyNotFound if it was missing or expired then.
+func (v *View) Get(key string) (interface{}, error) {
+	item, found := v.items[key]
+	if !found || item.expiredAt(v.now) {
+		return nil, ErrKeyNotFound
+	}
+	return v.c.expand(item.Value)
+}
+
+// Range calls fn for each entry of the view, in no particular order, until
+// fn returns false. No lock is held, so fn may take as long as it needs
+// and may modify the cache.
+func (v *View) Range(fn func(entry Entry) bool) {
+	for k, item := range v.items {
+		if item.expiredAt(v.now) {
+			continue
+		}
+		item.Value = v.c.valueOf(item.Value)
+		if !fn(Entry{Key: k, Item: item}) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys of the view, sorted.
+func (v *View) Keys() []string {
+	keys := make([]string, 0, v.n)
+	for k, item := range v.items {
+		if !item.expiredAt(v.now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshot(t *testing.T) {
+	for _, options := range []Options{{}, {CopyOnWrite: true}, {ReadOptimized: true}} {
+		clock := newFakeClock()
+		options.Clock = clock
+		cache := New(options)
+
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.SetWithExpiration("expired", 3, time.Second)
+		clock.Advance(time.Minute)
+
+		view := cache.Snapshot()
+		cache.Set("a", 10)
+		cache.Delete("b")
+		cache.Set("c", 3)
+
+		if n := view.Len(); n != 2 {
+			t.Errorf("Expected 2 items in the view, got %d", n)
+		}
+		if value, err := view.Get("a"); err != nil || value != 1 {
+			t.Errorf("Expected a=1 in the view, got %v (%v)", value, err)
+		}
+		if value, err := view.Get("b"); err != nil || value != 2 {
+			t.Errorf("Expected the deleted b in the view, got %v (%v)", value, err)
+		}
+		if _, err := view.Get("c"); err != ErrKeyNotFound {
+			t.Errorf("Expected c, added later, to be missing from the view, got %v", err)
+		}
+		if _, err := view.Get("expired"); err != ErrKeyNotFound {
+			t.Errorf("Expected an expired item to be missing from the view, got %v", err)
+		}
+		if keys := view.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+			t.Errorf("Expected keys [a b], got %v", keys)
+		}
+
+		// Range holds no lock, so fn may write to the cache.
+		seen := 0
+		view.Range(func(e Entry) bool {
+			seen++
+			cache.Set("copy:"+e.Key, e.Value)
+			return true
+		})
+		if seen != 2 {
+			t.Errorf("Expected Range to visit 2 entries, got %d", seen)
+		}
+		if value, _ := cache.Get("copy:a"); value != 1 {
+			t.Errorf("Expected copy:a=1, got %v", value)
+		}
+		if !view.Time().Equal(clock.Now()) {
+			t.Errorf("Expected the view time %v, got %v", clock.Now(), view.Time())
+		}
+		cache.Stop()
+	}
+}
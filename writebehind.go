@@ -0,0 +1,278 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default write-behind settings.
+const (
+	DefaultWriteBehindQueueSize     = 10000
+	DefaultWriteBehindBatchSize     = 100
+	DefaultWriteBehindFlushInterval = 100 * time.Millisecond
+	DefaultWriteBehindMaxRetries    = 5
+	DefaultWriteBehindRetryBackoff  = 100 * time.Millisecond
+)
+
+// WriteBehindOptions configures asynchronous writes to Options.BackingStore.
+// Zero values select the defaults.
+type WriteBehindOptions struct {
+	// Enabled makes the writes to the backing store asynchronous: Set and
+	// Delete return once the cache is updated, and a background goroutine
+	// writes the changes to the store in batches. A Get that falls
+	// through to the store sees the changes still queued, so a process
+	// always reads its own writes.
+	Enabled bool
+
+	// QueueSize bounds the number of keys with queued changes. Several
+	// changes of a key are coalesced into the latest. When the queue is
+	// full, writers of other keys block until there is room.
+	QueueSize int
+
+	// BatchSize is the largest number of changes written at once, and
+	// FlushInterval the longest a change waits for a batch to fill.
+	BatchSize     int
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times a failed batch is retried, waiting
+	// RetryBackoff before the first retry and twice as long before each
+	// next one. The changes of a batch that still fails are dropped and
+	// reported to Options.OnBackingStoreError.
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+func (o WriteBehindOptions) withDefaults() WriteBehindOptions {
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultWriteBehindQueueSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultWriteBehindBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = DefaultWriteBehindFlushInterval
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = DefaultWriteBehindMaxRetries
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = DefaultWriteBehindRetryBackoff
+	}
+	return o
+}
+
+// BackingWrite is a change queued for the backing store: Value stored
+// under Key, or Key deleted.
+type BackingWrite struct {
+	Key    string
+	Value  interface{}
+	Delete bool
+}
+
+// BatchBackingStore is a BackingStore that can write several changes at
+// once, such as in one database transaction. Write-behind uses WriteBatch
+// if the backing store implements it, and Store and Delete otherwise.
+type BatchBackingStore interface {
+	BackingStore
+	WriteBatch(ctx context.Context, writes []BackingWrite) error
+}
+
+// writeBehind queues changes for the backing store and writes them in the
+// background.
+type writeBehind struct {
+	store   BackingStore
+	options WriteBehindOptions
+	onError func(key string, err error)
+
+	mu       sync.Mutex
+	room     *sync.Cond // signaled when the queue shrinks or stops
+	pending  map[string]BackingWrite
+	order    []string                // keys of pending, oldest first
+	inflight map[string]BackingWrite // the batch being written
+	stopped  bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWriteBehind(store BackingStore, options WriteBehindOptions, onError func(string, error)) *writeBehind {
+	w := &writeBehind{
+		store:    store,
+		options:  options.withDefaults(),
+		onError:  onError,
+		pending:  make(map[string]BackingWrite),
+		inflight: make(map[string]BackingWrite),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.room = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// enqueue queues a change, blocking while the queue is full. It reports
+// false if write-behind has stopped, in which case the caller must write
+// the change itself.
+func (w *writeBehind) enqueue(change BackingWrite) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		if w.stopped {
+			return false
+		}
+		if _, queued := w.pending[change.Key]; queued {
+			w.pending[change.Key] = change
+			return true
+		}
+		if len(w.pending) < w.options.QueueSize {
+			break
+		}
+		w.room.Wait()
+	}
+	w.pending[change.Key] = change
+	w.order = append(w.order, change.Key)
+	if len(w.order) >= w.options.BatchSize {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// lookup returns the latest change of key that has not been written yet.
+func (w *writeBehind) lookup(key string) (BackingWrite, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if change, ok := w.pending[key]; ok {
+		return change, true
+	}
+	change, ok := w.inflight[key]
+	return change, ok
+}
+
+// run writes batches until stopped, then writes what is left.
+func (w *writeBehind) run() {
+	defer close(w.done)
+
+	timer := time.NewTimer(w.options.FlushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.wake:
+		case <-timer.C:
+		case <-w.stop:
+			for {
+				for w.flush() {
+				}
+				w.mu.Lock()
+				if len(w.order) == 0 {
+					w.stopped = true
+					w.room.Broadcast()
+					w.mu.Unlock()
+					return
+				}
+				w.mu.Unlock()
+			}
+		}
+		for w.flush() {
+			// Keep writing while full batches are waiting.
+			w.mu.Lock()
+			full := len(w.order) >= w.options.BatchSize
+			w.mu.Unlock()
+			if !full {
+				break
+			}
+		}
+		timer.Reset(w.options.FlushInterval)
+	}
+}
+
+// flush writes the oldest batch of changes, retrying with backoff, and
+// reports whether there was one.
+func (w *writeBehind) flush() bool {
+	w.mu.Lock()
+	n := min(len(w.order), w.options.BatchSize)
+	if n == 0 {
+		w.mu.Unlock()
+		return false
+	}
+	batch := make([]BackingWrite, n)
+	for i, key := range w.order[:n] {
+		batch[i] = w.pending[key]
+		w.inflight[key] = batch[i]
+		delete(w.pending, key)
+	}
+	w.order = w.order[n:]
+	w.room.Broadcast()
+	w.mu.Unlock()
+
+	backoff := w.options.RetryBackoff
+	batch, err := w.write(batch)
+	for retry := 0; err != nil && retry < w.options.MaxRetries; retry++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		batch, err = w.write(batch)
+	}
+	if err != nil && w.onError != nil {
+		for _, change := range batch {
+			w.onError(change.Key, err)
+		}
+	}
+
+	w.mu.Lock()
+	w.inflight = make(map[string]BackingWrite)
+	w.mu.Unlock()
+	return true
+}
+
+// write writes a batch to the store, and returns the changes that could
+// not be written.
+func (w *writeBehind) write(batch []BackingWrite) ([]BackingWrite, error) {
+	ctx := context.Background()
+	if bs, ok := w.store.(BatchBackingStore); ok {
+		if err := bs.WriteBatch(ctx, batch); err != nil {
+			return batch, fmt.Errorf("backing store: %w", err)
+		}
+		return nil, nil
+	}
+	for i, change := range batch {
+		var err error
+		if change.Delete {
+			err = w.store.Delete(ctx, change.Key)
+		} else {
+			err = w.store.Store(ctx, change.Key, change.Value)
+		}
+		if err != nil {
+			return batch[i:], fmt.Errorf("backing store: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+// drain writes all queued changes and stops write-behind, waiting until it
+// is done or ctx is.
+func (w *writeBehind) drain(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.stopped {
+		select {
+		case <-w.stop:
+		default:
+			close(w.stop)
+		}
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,160 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchStore is a BatchBackingStore recording its batches, whose writes
+// wait for release to be closed.
+type batchStore struct {
+	*memoryStore
+	release chan struct{}
+	fails   int // number of batches to fail
+
+	mu      sync.Mutex
+	batches [][]BackingWrite
+}
+
+func (b *batchStore) WriteBatch(ctx context.Context, writes []BackingWrite) error {
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fails > 0 {
+		b.fails--
+		return errors.New("deadlock detected")
+	}
+	b.batches = append(b.batches, append([]BackingWrite(nil), writes...))
+	for _, w := range writes {
+		if w.Delete {
+			b.memoryStore.Delete(ctx, w.Key)
+		} else {
+			b.memoryStore.Store(ctx, w.Key, w.Value)
+		}
+	}
+	return nil
+}
+
+func (b *batchStore) batchCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batches)
+}
+
+func TestWriteBehind(t *testing.T) {
+	store := &batchStore{memoryStore: newMemoryStore(), release: make(chan struct{})}
+	store.values["stale"] = "in the database"
+	cache := New(Options{
+		BackingStore: store,
+		WriteBehind:  WriteBehindOptions{Enabled: true, BatchSize: 10, FlushInterval: time.Millisecond},
+	})
+
+	// Writes return while the store is blocked, and coalesce per key.
+	for i := 0; i < 25; i++ {
+		cache.Set(fmt.Sprintf("k%d", i%20), i)
+	}
+	cache.Delete("stale")
+
+	// The process reads its own writes even if they left the cache, and a
+	// queued deletion hides the value still in the store.
+	cache.Flush()
+	if value, err := cache.Get("k3"); err != nil || value != 23 {
+		t.Errorf("Expected the queued value 23, got %v (%v)", value, err)
+	}
+	if _, err := cache.Get("stale"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected a queued deletion to hide the stored value, got %v", err)
+	}
+
+	close(store.release)
+	cache.Stop()
+	if n := store.batchCount(); n < 3 {
+		t.Errorf("Expected 21 changes in batches of 10, got %d batches", n)
+	}
+	for _, batch := range store.batches {
+		if len(batch) > 10 {
+			t.Errorf("Expected batches of at most 10, got %d", len(batch))
+		}
+	}
+	if len(store.values) != 20 || store.values["k3"] != 23 || store.values["stale"] != nil {
+		t.Errorf("Expected Stop to drain every change, got %v", store.values)
+	}
+
+	// After Stop, writes go to the store directly.
+	cache.Set("late", 1)
+	if store.get("late") != 1 {
+		t.Error("Expected a write after Stop to be stored synchronously")
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	store := &batchStore{memoryStore: newMemoryStore(), release: release, fails: 2}
+	var mu sync.Mutex
+	var reported []string
+	cache := New(Options{
+		BackingStore: store,
+		OnBackingStoreError: func(key string, err error) {
+			mu.Lock()
+			reported = append(reported, key)
+			mu.Unlock()
+		},
+		WriteBehind: WriteBehindOptions{Enabled: true, FlushInterval: time.Millisecond, MaxRetries: 2, RetryBackoff: time.Millisecond},
+	})
+
+	cache.Set("a", 1)
+	if err := cache.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if store.get("a") != 1 {
+		t.Error("Expected the write to succeed on the last retry")
+	}
+
+	// A batch failing more often than MaxRetries is reported.
+	store.fails = 3
+	cache = New(Options{
+		BackingStore: store,
+		OnBackingStoreError: func(key string, err error) {
+			mu.Lock()
+			reported = append(reported, key)
+			mu.Unlock()
+		},
+		WriteBehind: WriteBehindOptions{Enabled: true, FlushInterval: time.Millisecond, MaxRetries: 2, RetryBackoff: time.Millisecond},
+	})
+	cache.Set("b", 2)
+	cache.Stop()
+	if store.get("b") != nil || len(reported) != 1 || reported[0] != "b" {
+		t.Errorf("Expected b to be dropped and reported, got %v in the store, reported %v", store.get("b"), reported)
+	}
+}
+
+func TestWriteBehindQueueFull(t *testing.T) {
+	store := &batchStore{memoryStore: newMemoryStore(), release: make(chan struct{})}
+	cache := New(Options{
+		BackingStore: store,
+		WriteBehind:  WriteBehindOptions{Enabled: true, QueueSize: 2, BatchSize: 1, FlushInterval: time.Millisecond},
+	})
+	defer cache.Stop()
+
+	// One change is being written and two are queued, so the next blocks.
+	cache.Set("a", 1)
+	waitUntil(t, func() bool { _, inflight := cache.backing.behind.lookup("a"); return inflight })
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	done := make(chan struct{})
+	go func() {
+		cache.Set("d", 4)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected Set to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(store.release)
+	<-done
+}
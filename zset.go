@@ -0,0 +1,175 @@
+package gocache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+func init() {
+	// Let snapshots made with the default codec hold sorted sets.
+	gob.Register(SortedSet{})
+}
+
+// ScoredMember is a member of a sorted set and its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet is the value of a key holding a sorted set: its members
+// ordered by score, and members of equal score by name. Each member
+// appears once.
+type SortedSet []ScoredMember
+
+// less reports whether a sorts before b.
+func (a ScoredMember) less(b ScoredMember) bool {
+	return a.Score < b.Score || (a.Score == b.Score && a.Member < b.Member)
+}
+
+// search returns the index of the first member with a score of at least
+// score.
+func (s SortedSet) search(score float64) int {
+	return sort.Search(len(s), func(i int) bool { return s[i].Score >= score })
+}
+
+// ZAdd adds members to the sorted set stored under key, or changes their
+// score if they are in it already, and returns the number of members that
+// were not in it yet.
+//
+// Sorted sets are stored as SortedSet values, so Get returns a sorted set
+// as a SortedSet. A sorted set is created by the first ZAdd to a missing
+// or expired key, with the default expiration, and keeps its expiration
+// and priority when changed, like a list. Every change replaces the
+// stored slice with a new one, so sorted sets suit leaderboards and
+// time-windowed indexes of moderate size, such as events keyed by their
+// Unix time. Operations on a key holding another type of value return
+// ErrWrongType.
+func (c *Cache) ZAdd(key string, members ...ScoredMember) (int, error) {
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrInvalidScore
+		}
+	}
+
+	var added int
+	err := c.changeSortedSet(key, func(set SortedSet) SortedSet {
+		scores := make(map[string]float64, len(set)+len(members))
+		for _, m := range set {
+			scores[m.Member] = m.Score
+		}
+		for _, m := range members {
+			scores[m.Member] = m.Score
+		}
+		added = len(scores) - len(set)
+
+		next := make(SortedSet, 0, len(scores))
+		for member, score := range scores {
+			next = append(next, ScoredMember{Member: member, Score: score})
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].less(next[j]) })
+		return next
+	})
+	return added, err
+}
+
+// ZRangeByScore returns the members of the sorted set stored under key
+// with a score between min and max, both included, in order. Use
+// math.Inf for an open range. A missing sorted set is empty.
+func (c *Cache) ZRangeByScore(key string, min, max float64) ([]ScoredMember, error) {
+	set, err := c.getSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	i := set.search(min)
+	j := i
+	for j < len(set) && set[j].Score <= max {
+		j++
+	}
+	return append([]ScoredMember{}, set[i:j]...), nil
+}
+
+// ZRemRangeByScore removes the members of the sorted set stored under key
+// with a score between min and max, both included, and returns the number
+// removed. A sorted set emptied by ZRemRangeByScore stays in the cache
+// until it expires or is deleted.
+func (c *Cache) ZRemRangeByScore(key string, min, max float64) (int, error) {
+	var removed int
+	err := c.changeSortedSet(key, func(set SortedSet) SortedSet {
+		i := set.search(min)
+		j := i
+		for j < len(set) && set[j].Score <= max {
+			j++
+		}
+		if removed = j - i; removed == 0 {
+			return nil
+		}
+		next := make(SortedSet, 0, len(set)-removed)
+		next = append(next, set[:i]...)
+		return append(next, set[j:]...)
+	})
+	return removed, err
+}
+
+// ZScore returns the score of member in the sorted set stored under key,
+// or ErrKeyNotFound if the sorted set or the member is missing.
+func (c *Cache) ZScore(key, member string) (float64, error) {
+	set, err := c.getSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range set {
+		if m.Member == member {
+			return m.Score, nil
+		}
+	}
+	return 0, ErrKeyNotFound
+}
+
+// ZCard returns the number of members of the sorted set stored under key,
+// or 0 if it is missing.
+func (c *Cache) ZCard(key string) (int, error) {
+	set, err := c.getSortedSet(key)
+	return len(set), err
+}
+
+// getSortedSet returns the sorted set stored under key, or nil if it is
+// missing.
+func (c *Cache) getSortedSet(key string) (SortedSet, error) {
+	value, err := c.Get(key)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	set, ok := value.(SortedSet)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q holds %T, not a sorted set", ErrWrongType, key, value)
+	}
+	return set, nil
+}
+
+// changeSortedSet atomically replaces the sorted set stored under key, or
+// nil if it is missing, with the sorted set returned by fn, unless that is
+// nil.
+func (c *Cache) changeSortedSet(key string, fn func(set SortedSet) SortedSet) error {
+	if err := c.validateKey(key); err != nil {
+		return err
+	}
+
+	_, err := c.modify(key, func(value interface{}, found bool) (interface{}, bool, error) {
+		var set SortedSet
+		if found {
+			var ok bool
+			if set, ok = value.(SortedSet); !ok {
+				return nil, false, fmt.Errorf("%w: %q holds %T, not a sorted set", ErrWrongType, key, value)
+			}
+		}
+		next := fn(set)
+		return next, next != nil, nil
+	})
+	return err
+}
@@ -0,0 +1,99 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCacheSortedSet(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	n, err := cache.ZAdd("board",
+		ScoredMember{Member: "carol", Score: 30},
+		ScoredMember{Member: "alice", Score: 10},
+		ScoredMember{Member: "bob", Score: 20},
+		ScoredMember{Member: "dave", Score: 20},
+	)
+	if err != nil || n != 4 {
+		t.Errorf("Expected 4 members added, got %d (%v)", n, err)
+	}
+	if n, err := cache.ZAdd("board", ScoredMember{Member: "alice", Score: 25}); err != nil || n != 0 {
+		t.Errorf("Expected a changed score, got %d added (%v)", n, err)
+	}
+
+	got, err := cache.ZRangeByScore("board", 20, 25)
+	want := []ScoredMember{{"bob", 20}, {"dave", 20}, {"alice", 25}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v (%v)", want, got, err)
+	}
+	if all, _ := cache.ZRangeByScore("board", math.Inf(-1), math.Inf(1)); len(all) != 4 || all[3].Member != "carol" {
+		t.Errorf("Expected all 4 members ending with carol, got %v", all)
+	}
+	if none, _ := cache.ZRangeByScore("board", 100, 200); len(none) != 0 {
+		t.Errorf("Expected no members, got %v", none)
+	}
+	if score, err := cache.ZScore("board", "alice"); err != nil || score != 25 {
+		t.Errorf("Expected 25, got %v (%v)", score, err)
+	}
+	if _, err := cache.ZScore("board", "eve"); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	if n, err := cache.ZRemRangeByScore("board", math.Inf(-1), 20); err != nil || n != 2 {
+		t.Errorf("Expected 2 members removed, got %d (%v)", n, err)
+	}
+	if n, _ := cache.ZCard("board"); n != 2 {
+		t.Errorf("Expected 2 members left, got %d", n)
+	}
+
+	if _, err := cache.ZAdd("board", ScoredMember{Member: "x", Score: math.NaN()}); err != ErrInvalidScore {
+		t.Errorf("Expected ErrInvalidScore, got %v", err)
+	}
+	cache.Set("text", "x")
+	if _, err := cache.ZAdd("text", ScoredMember{Member: "a"}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cache.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(Options{})
+	defer restored.Stop()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := restored.ZCard("board"); err != nil || n != 2 {
+		t.Errorf("Expected the sorted set to survive a snapshot, got %d (%v)", n, err)
+	}
+}
+
+func TestCacheSortedSetConcurrent(t *testing.T) {
+	cache := New(Options{})
+	defer cache.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				cache.ZAdd("events", ScoredMember{Member: fmt.Sprint(i, "-", n), Score: float64(n)})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := cache.ZCard("events"); n != 400 {
+		t.Errorf("Expected 400 members, got %d", n)
+	}
+	if window, _ := cache.ZRangeByScore("events", 10, 19); len(window) != 80 {
+		t.Errorf("Expected 80 members in the window, got %d", len(window))
+	}
+}